@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 var (
@@ -18,6 +19,59 @@ var (
 // UploadPath Maybe override by ENV_VAR
 var UploadPath = "upload"
 
+// PDFFontPath TrueType font embedded into exported PDF files, override by ENV_VAR
+var PDFFontPath = ""
+
+// 大纲版本存储与保留策略，override by ENV_VAR
+var (
+	// VersionSnapshotInterval 每隔多少个差异版本保存一次完整快照
+	VersionSnapshotInterval = 20
+	// VersionKeepAllDays 最近多少天内的版本全部保留
+	VersionKeepAllDays = 7
+	// VersionKeepDailyDays 多少天内每天保留一个版本，更早的每周保留一个
+	VersionKeepDailyDays = 30
+	// VersionRetentionInterval 清理任务执行间隔（小时），0表示不清理
+	VersionRetentionInterval = 24
+)
+
+// SearchIndexInterval 全文搜索索引任务的执行间隔（秒），0表示不建立索引，override by ENV_VAR
+var SearchIndexInterval = 30
+
+// TokenHoldMinutes AI调用前预扣Token的有效期（分钟），过期后预扣不再占用余额，override by ENV_VAR
+var TokenHoldMinutes = 10
+
+// AI续写候选，override by ENV_VAR
+var (
+	// AIMaxCandidates 一次续写请求最多生成的候选数量
+	AIMaxCandidates = 5
+	// AICandidateExpireHours 候选的保留时间（小时），到期后不能再采纳，由清理任务删除（包括已采纳和未采纳的候选）
+	AICandidateExpireHours = 24
+	// AICandidateCleanupInterval 到期候选清理任务的执行间隔（分钟），0表示不清理
+	AICandidateCleanupInterval = 60
+)
+
+// 异步AI任务，override by ENV_VAR
+var (
+	// AIJobWorkers 执行异步AI任务的工作协程数，0表示不执行任务
+	AIJobWorkers = 2
+	// AIJobPollInterval 领取排队任务和更新执行中任务心跳的间隔（秒）
+	AIJobPollInterval = 2
+)
+
+// 长大纲续写的上下文管理，override by ENV_VAR
+var (
+	// AIContextRecentChars 续写时原样发送的大纲末尾字数，更早的部分以摘要代替
+	AIContextRecentChars = 3000
+	// OutlineSummaryInterval 大纲摘要刷新任务的执行间隔（秒），0表示不生成摘要
+	OutlineSummaryInterval = 60
+)
+
+// LLMDefaultProvider 系统设置llm_default_provider的初始值，设为fake时不配置API密钥也能离线使用AI功能，override by ENV_VAR
+var LLMDefaultProvider = "openai"
+
+// DraftIdleMinutes 大纲草稿超过多少分钟没有自动保存时提交为正式版本，0表示只在手动提交时保存，override by ENV_VAR
+var DraftIdleMinutes = 10
+
 func printHelp() {
 	fmt.Println("Gin Template " + Version + " - Your next project starts from here.")
 	fmt.Println("Copyright (C) 2023 JustSong. All rights reserved.")
@@ -26,6 +80,40 @@ func printHelp() {
 }
 
 func init() {
+	if os.Getenv("SESSION_SECRET") != "" {
+		SessionSecret = os.Getenv("SESSION_SECRET")
+	}
+	if os.Getenv("SQLITE_PATH") != "" {
+		SQLitePath = os.Getenv("SQLITE_PATH")
+	}
+	if os.Getenv("UPLOAD_PATH") != "" {
+		UploadPath = os.Getenv("UPLOAD_PATH")
+	}
+	if os.Getenv("PDF_FONT_PATH") != "" {
+		PDFFontPath = os.Getenv("PDF_FONT_PATH")
+	}
+	if os.Getenv("LLM_DEFAULT_PROVIDER") != "" {
+		LLMDefaultProvider = os.Getenv("LLM_DEFAULT_PROVIDER")
+	}
+	loadIntEnv("VERSION_SNAPSHOT_INTERVAL", &VersionSnapshotInterval)
+	loadIntEnv("VERSION_KEEP_ALL_DAYS", &VersionKeepAllDays)
+	loadIntEnv("VERSION_KEEP_DAILY_DAYS", &VersionKeepDailyDays)
+	loadIntEnv("VERSION_RETENTION_INTERVAL", &VersionRetentionInterval)
+	loadIntEnv("DRAFT_IDLE_MINUTES", &DraftIdleMinutes)
+	loadIntEnv("SEARCH_INDEX_INTERVAL", &SearchIndexInterval)
+	loadIntEnv("TOKEN_HOLD_MINUTES", &TokenHoldMinutes)
+	loadIntEnv("AI_MAX_CANDIDATES", &AIMaxCandidates)
+	loadIntEnv("AI_CANDIDATE_EXPIRE_HOURS", &AICandidateExpireHours)
+	loadIntEnv("AI_CANDIDATE_CLEANUP_INTERVAL", &AICandidateCleanupInterval)
+	loadIntEnv("AI_JOB_WORKERS", &AIJobWorkers)
+	loadIntEnv("AI_JOB_POLL_INTERVAL", &AIJobPollInterval)
+	loadIntEnv("AI_CONTEXT_RECENT_CHARS", &AIContextRecentChars)
+	loadIntEnv("OUTLINE_SUMMARY_INTERVAL", &OutlineSummaryInterval)
+}
+
+// ParseFlags 解析命令行参数，创建日志和上传目录，由main在启动时调用
+// 不在init中解析，导入该包的测试等程序不会因为自己的命令行参数而退出
+func ParseFlags() {
 	flag.Parse()
 
 	if *PrintVersion {
@@ -38,15 +126,6 @@ func init() {
 		os.Exit(0)
 	}
 
-	if os.Getenv("SESSION_SECRET") != "" {
-		SessionSecret = os.Getenv("SESSION_SECRET")
-	}
-	if os.Getenv("SQLITE_PATH") != "" {
-		SQLitePath = os.Getenv("SQLITE_PATH")
-	}
-	if os.Getenv("UPLOAD_PATH") != "" {
-		UploadPath = os.Getenv("UPLOAD_PATH")
-	}
 	if *LogDir != "" {
 		var err error
 		*LogDir, err = filepath.Abs(*LogDir)
@@ -64,3 +143,17 @@ func init() {
 		_ = os.Mkdir(UploadPath, 0777)
 	}
 }
+
+// loadIntEnv 读取非负整数环境变量，未设置或格式错误时保留默认值
+func loadIntEnv(name string, target *int) {
+	value := os.Getenv(name)
+	if value == "" {
+		return
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		log.Printf("invalid %s: %s, using default %d", name, value, *target)
+		return
+	}
+	*target = number
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"gin-template/define"
+	appservice "gin-template/service"
 	"gin-template/service/agent"
 	"gin-template/service/agent/service"
 	"github.com/cloudwego/eino/schema"
@@ -11,12 +12,14 @@ import (
 // AgentController 智能体控制器
 type AgentController struct {
 	agentService *service.MultiUserAgentService
+	bibleService *appservice.StoryBibleService
 }
 
 // NewAgentController 创建智能体控制器
-func NewAgentController() *AgentController {
+func NewAgentController(bibleService *appservice.StoryBibleService) *AgentController {
 	return &AgentController{
 		agentService: agent.GetGlobalAgentService(),
+		bibleService: bibleService,
 	}
 }
 
@@ -24,12 +27,15 @@ func NewAgentController() *AgentController {
 type ChatRequest struct {
 	SessionID string `json:"session_id" binding:"required"` // 会话ID
 	Message   string `json:"message" binding:"required"`    // 用户消息
+	ProjectId int64  `json:"project_id"`                    // 可选，带上该项目中与消息相关的故事设定
 }
 
 // ChatResponse 聊天响应
 type ChatResponse struct {
-	SessionID string `json:"session_id"` // 会话ID
-	Response  string `json:"response"`   // 智能体响应
+	SessionID     string `json:"session_id"`     // 会话ID
+	Response      string `json:"response"`       // 智能体响应
+	TokensCharged int64  `json:"tokens_charged"` // 按各模型的用量扣除的Token
+	TokenBalance  int64  `json:"token_balance"`  // 扣除后的Token余额
 }
 
 // Chat 处理聊天请求
@@ -53,19 +59,33 @@ func (c *AgentController) Chat(ctx *gin.Context) {
 	// 创建用户消息
 	message := schema.UserMessage(req.Message)
 
-	// 调用智能体服务生成响应
-	response, err := c.agentService.Generate(ctx, &define.GenerateRequest{
-		SessionID: req.SessionID,
-		Messages:  []*schema.Message{message},
-	})
+	// 指定项目时带上消息中提到的设定条目
+	var systemMessages []*schema.Message
+	if req.ProjectId > 0 {
+		if _, err := CheckProjectOwnership(ctx, req.ProjectId); err != nil {
+			return
+		}
+		if bible := c.bibleService.StoryBiblePrompt(req.ProjectId, req.Message); bible != "" {
+			systemMessages = append(systemMessages, schema.SystemMessage(bible))
+		}
+	}
+
+	// 调用智能体服务生成响应并扣费
+	response, err := c.agentService.GenerateWithBilling(ctx, ctx.GetInt64("id"), &define.GenerateRequest{
+		SessionID:      req.SessionID,
+		Messages:       []*schema.Message{message},
+		SystemMessages: systemMessages,
+	}, nil)
 	if err != nil {
 		ResponseErrorWithStatus(ctx, 500, "生成响应失败: "+err.Error())
 		return
 	}
 
 	ResponseOK(ctx, ChatResponse{
-		SessionID: response.SessionID,
-		Response:  response.Message.Content,
+		SessionID:     response.SessionID,
+		Response:      response.Response,
+		TokensCharged: response.TokensCharged,
+		TokenBalance:  response.TokenBalance,
 	})
 }
 
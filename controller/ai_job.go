@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/service"
+	"gin-template/service/agent"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AIJobController struct {
+	service *service.AIJobService
+}
+
+func NewAIJobController(jobSvc *service.AIJobService) *AIJobController {
+	return &AIJobController{
+		service: jobSvc,
+	}
+}
+
+// SubmitAIGenerateJob 提交异步AI续写任务，立即返回任务ID
+func (c *AIJobController) SubmitAIGenerateJob(ctx *gin.Context) {
+	projectId, project, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var aiReq define.AIGenerateRequest
+	if err := ctx.ShouldBindJSON(&aiReq); err != nil || (aiReq.NodeId == 0 && aiReq.Content == "") {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	job, err := c.service.SubmitOutlineGenerationJob(project.UserId, projectId, aiReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "任务已提交", job)
+}
+
+// SubmitAgentChatJob 提交异步智能体对话任务，立即返回任务ID
+func (c *AIJobController) SubmitAgentChatJob(ctx *gin.Context) {
+	var chatReq define.AgentChatJobRequest
+	if err := ctx.ShouldBindJSON(&chatReq); err != nil {
+		ResponseError(ctx, "无效的请求参数")
+		return
+	}
+	if agent.GetGlobalAgentService() == nil {
+		ResponseError(ctx, "智能体服务未启用")
+		return
+	}
+	if chatReq.ProjectId > 0 {
+		if _, err := CheckProjectOwnership(ctx, chatReq.ProjectId); err != nil {
+			return
+		}
+	}
+
+	userId := ctx.GetInt64("id")
+
+	job, err := c.service.SubmitAgentChatJob(userId, chatReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "任务已提交", job)
+}
+
+// GetAIJobs 分页获取当前用户的异步AI任务，可按状态筛选
+func (c *AIJobController) GetAIJobs(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	userId := ctx.GetInt64("id")
+
+	jobs, total, err := c.service.ListJobs(userId, ctx.Query("status"), (page-1)*limit, limit)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, define.BuildPageResponse(jobs, total, page, limit))
+}
+
+// GetAIJob 获取异步AI任务的状态和结果
+func (c *AIJobController) GetAIJob(ctx *gin.Context) {
+	userId := ctx.GetInt64("id")
+
+	job, err := c.service.GetJob(userId, ctx.Param("jobId"))
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, job)
+}
+
+// CancelAIJob 取消排队中或执行中的异步AI任务
+func (c *AIJobController) CancelAIJob(ctx *gin.Context) {
+	userId := ctx.GetInt64("id")
+
+	job, err := c.service.CancelJob(userId, ctx.Param("jobId"))
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	message := "任务已取消"
+	if job.Status != string(model.AIJobCancelled) {
+		message = "已请求取消，任务将在几秒内停止"
+	}
+	ResponseOKWithMessage(ctx, message, job)
+}
@@ -1,11 +1,13 @@
 package controller
 
 import (
+	"errors"
 	"gin-template/model"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // Response 统一响应结构
@@ -60,18 +62,51 @@ func ResponseErrorWithData(c *gin.Context, message string, data interface{}) {
 	})
 }
 
+// ResponseErrorWithStatusAndData 返回带状态码和数据的错误响应
+func ResponseErrorWithStatusAndData(c *gin.Context, status int, message string, data interface{}) {
+	c.JSON(status, Response{
+		Success: false,
+		Message: message,
+		Data:    data,
+	})
+}
+
 // ValidateProjectOwnership 验证项目所有权
-// 返回项目ID、项目信息和错误（如有）
+// 返回项目ID、项目信息和错误（如有），校验失败时已写入错误响应
 func ValidateProjectOwnership(c *gin.Context) (int64, *model.Project, error) {
 	// 解析项目ID
-	_, err := strconv.Atoi(c.Param("id"))
+	projectId, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		ResponseError(c, "无效的项目ID")
 		return 0, nil, err
 	}
 
+	project, err := CheckProjectOwnership(c, projectId)
+	if err != nil {
+		return 0, nil, err
+	}
+	return projectId, project, nil
+}
+
+// CheckProjectOwnership 验证当前用户是否拥有项目，用于项目ID不在路径中的接口，校验失败时已写入错误响应
+func CheckProjectOwnership(c *gin.Context, projectId int64) (*model.Project, error) {
 	// 获取当前用户ID
 	userId := c.GetInt64("id")
 
-	return userId, nil, nil
+	var project model.Project
+	if err := model.DB.Where("id = ?", projectId).First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ResponseError(c, "项目不存在")
+		} else {
+			ResponseError(c, "获取项目信息失败")
+		}
+		return nil, err
+	}
+
+	if project.UserId != userId {
+		ResponseError(c, "无权访问该项目")
+		return nil, errors.New("project ownership check failed")
+	}
+
+	return &project, nil
 }
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"gin-template/define"
+	"gin-template/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationController struct {
+	service *service.ModerationService
+}
+
+func NewModerationController(moderationSvc *service.ModerationService) *ModerationController {
+	return &ModerationController{
+		service: moderationSvc,
+	}
+}
+
+// GetModerationWords 分页获取敏感词，可按关键字筛选（管理员）
+func (c *ModerationController) GetModerationWords(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	words, total, err := c.service.ListWords(ctx.Query("keyword"), (page-1)*limit, limit)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, define.BuildPageResponse(words, total, page, limit))
+}
+
+// CreateModerationWord 添加敏感词（管理员）
+func (c *ModerationController) CreateModerationWord(ctx *gin.Context) {
+	var wordReq define.ModerationWordRequest
+	if err := ctx.ShouldBindJSON(&wordReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	word, err := c.service.CreateWord(wordReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "敏感词添加成功", word)
+}
+
+// ImportModerationWords 批量导入敏感词，已存在的词被跳过（管理员）
+func (c *ModerationController) ImportModerationWords(ctx *gin.Context) {
+	var importReq define.ModerationWordImportRequest
+	if err := ctx.ShouldBindJSON(&importReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.ImportWords(importReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "敏感词导入成功", result)
+}
+
+// UpdateModerationWord 修改敏感词（管理员）
+func (c *ModerationController) UpdateModerationWord(ctx *gin.Context) {
+	wordId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的敏感词ID")
+		return
+	}
+
+	var wordReq define.ModerationWordRequest
+	if err := ctx.ShouldBindJSON(&wordReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	word, err := c.service.UpdateWord(wordId, wordReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "敏感词修改成功", word)
+}
+
+// DeleteModerationWord 删除敏感词（管理员）
+func (c *ModerationController) DeleteModerationWord(ctx *gin.Context) {
+	wordId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的敏感词ID")
+		return
+	}
+
+	if err := c.service.DeleteWord(wordId); err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "敏感词删除成功", nil)
+}
+
+// GetModerationRecords 分页获取审核记录，可按状态筛选（管理员）
+func (c *ModerationController) GetModerationRecords(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	records, total, err := c.service.ListRecords(ctx.Query("status"), (page-1)*limit, limit)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, define.BuildPageResponse(records, total, page, limit))
+}
+
+// ReviewModerationRecord 复核待审核的记录（管理员）
+func (c *ModerationController) ReviewModerationRecord(ctx *gin.Context) {
+	recordId, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的记录ID")
+		return
+	}
+
+	var reviewReq define.ModerationReviewRequest
+	if err := ctx.ShouldBindJSON(&reviewReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	reviewerId := ctx.GetInt64("id")
+
+	record, err := c.service.ReviewRecord(reviewerId, recordId, reviewReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "复核完成", record)
+}
+
+// TestModeration 使用当前的审核规则检查一段文本，不产生审核记录（管理员）
+func (c *ModerationController) TestModeration(ctx *gin.Context) {
+	var testReq define.ModerationTestRequest
+	if err := ctx.ShouldBindJSON(&testReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.TestText(ctx.Request.Context(), testReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, result)
+}
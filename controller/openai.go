@@ -14,9 +14,12 @@ func AIPrompt(c *gin.Context) {
 		ResponseError(c, "请求格式不正确: " + err.Error())
 		return
 	}
+	// 记录审核时使用的调用者和来源
+	req.UserId = c.GetInt64("id")
+	req.Source = define.AISourcePrompt
 
-	// 调用服务层生成AI回复
-	result, err := service.GenerateAICompletion(req)
+	// 调用服务层生成AI回复并扣费
+	result, err := service.GenerateBilledAICompletion(c.Request.Context(), req)
 	if err != nil {
 		ResponseError(c, err.Error())
 		return
@@ -29,7 +32,7 @@ func AIPrompt(c *gin.Context) {
 // 获取可用的模型列表
 func GetAIModels(c *gin.Context) {
 	// 调用服务层获取模型列表
-	models, err := service.GetAvailableModels()
+	models, err := service.GetAvailableModels(c.Request.Context())
 	if err != nil {
 		ResponseError(c, err.Error())
 		return
@@ -37,4 +40,15 @@ func GetAIModels(c *gin.Context) {
 
 	// 返回成功结果
 	ResponseOK(c, models.Data)
+}
+
+// GetAIPricing 获取当前用户适用的AI计费规则和套餐折扣
+func GetAIPricing(c *gin.Context) {
+	pricing, err := service.GetPricingService().GetUserPricing(c.GetInt64("id"))
+	if err != nil {
+		ResponseError(c, err.Error())
+		return
+	}
+
+	ResponseOK(c, pricing)
 } 
\ No newline at end of file
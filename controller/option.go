@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"gin-template/common"
 	"gin-template/model"
+	"gin-template/service"
+	"gin-template/service/llm"
 	"net/http"
 	"strings"
 
@@ -14,7 +16,7 @@ func GetOptions(c *gin.Context) {
 	var options []*model.Option
 	common.OptionMapRWMutex.Lock()
 	for k, v := range common.OptionMap {
-		if strings.Contains(k, "Token") || strings.Contains(k, "Secret") {
+		if strings.Contains(k, "Token") || strings.Contains(k, "Secret") || strings.HasSuffix(k, "_api_key") {
 			continue
 		}
 		options = append(options, &model.Option{
@@ -66,6 +68,102 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case llm.OptionDefaultProvider:
+		if !llm.IsProvider(option.Value) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "不支持的模型服务商：" + option.Value,
+			})
+			return
+		}
+	case llm.OptionModelProviders:
+		if _, err := llm.ParseModelProviders(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case llm.OptionModelContextTokens:
+		if _, err := llm.ParseModelContextTokens(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case llm.OptionFallbackChain:
+		if _, err := llm.ParseFallbackChain(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case llm.OptionFakeError:
+		if err := llm.ParseFakeError(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case llm.OptionFakeScript:
+		if _, err := llm.ParseFakeScript(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case service.OptionModelPrices:
+		if _, err := service.ParseModelPrices(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case service.OptionPackageDiscounts:
+		if _, err := service.ParsePackageDiscounts(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case service.OptionCacheHitRate:
+		if _, err := service.ParseCacheHitRate(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case service.OptionAgentHold:
+		if _, err := service.ParseAgentHold(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case service.OptionModerationLLMStages:
+		if err := service.ParseModerationLLMStages(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case service.OptionModerationLLMAction:
+		if err := service.ParseModerationLLMAction(option.Value); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	}
 	err = model.UpdateOption(option.Key, option.Value)
 	if err != nil {
@@ -1,9 +1,11 @@
 package controller
 
 import (
+	"gin-template/common"
 	"gin-template/define"
 	"gin-template/service"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"strconv"
 )
 
@@ -48,13 +50,103 @@ func (c *OutlineController) SaveOutline(ctx *gin.Context) {
 		return
 	}
 
-	outline, err := c.service.SaveOutlineContent(projectId, outlineReq.Content) // 使用注入的服务实例
+	result, err := c.service.SaveOutlineContent(projectId, outlineReq.Content, outlineReq.BaseVersion, outlineReq.AutoMerge) // 使用注入的服务实例
 	if err != nil {
 		ResponseError(ctx, err.Error())
 		return
 	}
+	if result.Conflict {
+		ResponseErrorWithStatusAndData(ctx, http.StatusConflict, "大纲已被其他修改更新，请处理冲突后重新保存", result)
+		return
+	}
+	if result.Merged {
+		ResponseOKWithMessage(ctx, "已与其他修改自动合并并保存", result)
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "大纲保存成功", result)
+}
+
+// GetDraft 获取未提交的草稿（结构体方法）
+func (c *OutlineController) GetDraft(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	draft, err := c.service.GetDraft(projectId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, draft)
+}
+
+// AutosaveDraft 自动保存草稿，不创建版本（结构体方法）
+func (c *OutlineController) AutosaveDraft(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var draftReq define.DraftRequest
+	if err := ctx.ShouldBindJSON(&draftReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	draft, err := c.service.AutosaveDraft(projectId, draftReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, draft)
+}
+
+// CommitDraft 将草稿提交为正式版本（结构体方法）
+func (c *OutlineController) CommitDraft(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var commitReq define.CommitDraftRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&commitReq); err != nil {
+			ResponseError(ctx, "无效的参数")
+			return
+		}
+	}
+	autoMerge := commitReq.AutoMerge == nil || *commitReq.AutoMerge
+
+	result, err := c.service.CommitDraft(projectId, autoMerge)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+	if result.Conflict {
+		ResponseErrorWithStatusAndData(ctx, http.StatusConflict, "草稿与大纲的新修改冲突，请处理冲突后重新保存", result)
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "草稿已提交", result)
+}
+
+// DiscardDraft 丢弃草稿（结构体方法）
+func (c *OutlineController) DiscardDraft(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
 
-	ResponseOKWithMessage(ctx, "大纲保存成功", outline)
+	if err := c.service.DiscardDraft(projectId); err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "草稿已丢弃", nil)
 }
 
 // GetVersions 获取版本历史（结构体方法）
@@ -65,7 +157,8 @@ func (c *OutlineController) GetVersions(ctx *gin.Context) {
 	}
 
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
-	versions, err := c.service.GetVersionHistory(projectId, limit) // 使用注入的服务实例
+	branch := ctx.Query("branch")
+	versions, err := c.service.GetVersionHistory(projectId, branch, limit) // 使用注入的服务实例
 	if err != nil {
 		ResponseError(ctx, err.Error())
 		return
@@ -74,6 +167,303 @@ func (c *OutlineController) GetVersions(ctx *gin.Context) {
 	ResponseOK(ctx, versions)
 }
 
+// GetVersionDiff 对比两个版本的差异（结构体方法）
+func (c *OutlineController) GetVersionDiff(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var diffReq define.VersionDiffRequest
+	if err := ctx.ShouldBindQuery(&diffReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	diff, err := c.service.DiffVersions(projectId, diffReq.From, diffReq.To, diffReq.ChangesOnly)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, diff)
+}
+
+// RestoreVersion 恢复到指定历史版本（结构体方法）
+func (c *OutlineController) RestoreVersion(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var restoreReq define.RestoreVersionRequest
+	if err := ctx.ShouldBindJSON(&restoreReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.RestoreVersion(projectId, restoreReq.VersionNumber)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "版本恢复成功", result)
+}
+
+// PinVersion 固定或取消固定历史版本（结构体方法）
+func (c *OutlineController) PinVersion(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var pinReq define.PinVersionRequest
+	if err := ctx.ShouldBindJSON(&pinReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.PinVersion(projectId, pinReq.VersionNumber, pinReq.Pinned)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	message := "版本已取消固定"
+	if pinReq.Pinned {
+		message = "版本已固定"
+	}
+	ResponseOKWithMessage(ctx, message, result)
+}
+
+// GetBranches 获取分支列表（结构体方法）
+func (c *OutlineController) GetBranches(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	branches, err := c.service.ListBranches(projectId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, branches)
+}
+
+// CreateBranch 创建分支（结构体方法）
+func (c *OutlineController) CreateBranch(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var branchReq define.CreateBranchRequest
+	if err := ctx.ShouldBindJSON(&branchReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	branch, err := c.service.CreateBranch(projectId, branchReq.Name, branchReq.FromVersion)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "分支创建成功", branch)
+}
+
+// SwitchBranch 切换当前分支（结构体方法）
+func (c *OutlineController) SwitchBranch(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var switchReq define.SwitchBranchRequest
+	if err := ctx.ShouldBindJSON(&switchReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	outline, err := c.service.SwitchBranch(projectId, switchReq.Name)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "分支切换成功", outline)
+}
+
+// DeleteBranch 删除分支（结构体方法）
+func (c *OutlineController) DeleteBranch(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	name := ctx.Query("name")
+	if name == "" {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	if err := c.service.DeleteBranch(projectId, name); err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "分支删除成功", nil)
+}
+
+// MergeBranch 合并分支，存在冲突时返回冲突区域（结构体方法）
+func (c *OutlineController) MergeBranch(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var mergeReq define.MergeBranchRequest
+	if err := ctx.ShouldBindJSON(&mergeReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.MergeBranch(projectId, mergeReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	if len(result.Conflicts) > 0 {
+		ResponseErrorWithData(ctx, "合并存在冲突，请解决后重新提交", result)
+		return
+	}
+	if result.UpToDate {
+		ResponseOKWithMessage(ctx, "目标分支已包含源分支的全部修改", result)
+		return
+	}
+	ResponseOKWithMessage(ctx, "分支合并成功", result)
+}
+
+// GetOutlineNodes 获取大纲结构树（结构体方法）
+func (c *OutlineController) GetOutlineNodes(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	tree, err := c.service.GetOutlineTree(projectId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, tree)
+}
+
+// CreateOutlineNode 创建大纲节点（结构体方法）
+func (c *OutlineController) CreateOutlineNode(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var nodeReq define.CreateOutlineNodeRequest
+	if err := ctx.ShouldBindJSON(&nodeReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	tree, err := c.service.CreateOutlineNode(projectId, nodeReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "节点创建成功", tree)
+}
+
+// UpdateOutlineNode 修改大纲节点标题或正文（结构体方法）
+func (c *OutlineController) UpdateOutlineNode(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	nodeId, err := strconv.ParseInt(ctx.Param("nodeId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的节点ID")
+		return
+	}
+
+	var nodeReq define.UpdateOutlineNodeRequest
+	if err := ctx.ShouldBindJSON(&nodeReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	tree, err := c.service.UpdateOutlineNode(projectId, nodeId, nodeReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "节点修改成功", tree)
+}
+
+// DeleteOutlineNode 删除大纲节点及其子节点（结构体方法）
+func (c *OutlineController) DeleteOutlineNode(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	nodeId, err := strconv.ParseInt(ctx.Param("nodeId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的节点ID")
+		return
+	}
+
+	tree, err := c.service.DeleteOutlineNode(projectId, nodeId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "节点删除成功", tree)
+}
+
+// MoveOutlineNode 移动大纲节点或调整顺序（结构体方法）
+func (c *OutlineController) MoveOutlineNode(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	nodeId, err := strconv.ParseInt(ctx.Param("nodeId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的节点ID")
+		return
+	}
+
+	var moveReq define.MoveOutlineNodeRequest
+	if err := ctx.ShouldBindJSON(&moveReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	tree, err := c.service.MoveOutlineNode(projectId, nodeId, moveReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "节点移动成功", tree)
+}
+
 // AIGenerate AI续写（结构体方法）
 func (c *OutlineController) AIGenerate(ctx *gin.Context) {
 	projectId, project, err := ValidateProjectOwnership(ctx) // 假设验证函数返回用户ID
@@ -82,18 +472,98 @@ func (c *OutlineController) AIGenerate(ctx *gin.Context) {
 	}
 
 	var aiReq define.AIGenerateRequest
-	if err := ctx.ShouldBindJSON(&aiReq); err != nil {
+	if err := ctx.ShouldBindJSON(&aiReq); err != nil || (aiReq.NodeId == 0 && aiReq.Content == "") {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.GenerateOutlineWithAI(ctx.Request.Context(), project.UserId, projectId, aiReq) // 使用注入的服务实例
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, result)
+}
+
+// StreamAIGenerate AI续写（流式），通过SSE推送续写内容
+func (c *OutlineController) StreamAIGenerate(ctx *gin.Context) {
+	projectId, project, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var aiReq define.AIGenerateRequest
+	if err := ctx.ShouldBindJSON(&aiReq); err != nil || (aiReq.NodeId == 0 && aiReq.Content == "") {
 		ResponseError(ctx, "无效的参数")
 		return
 	}
 
-	result, err := c.service.GenerateOutlineWithAI( // 使用注入的服务实例
-		project.UserId,
-		projectId,
-		aiReq.Content,
-		aiReq.Style,
-		aiReq.WordLimit,
-	)
+	// 设置SSE响应头
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	streamId := common.GetUUID()
+	ctx.SSEvent("start", gin.H{"stream_id": streamId})
+	ctx.Writer.Flush()
+
+	// 客户端断开连接时请求的context被取消，续写随之停止
+	result, err := c.service.StreamOutlineWithAI(ctx.Request.Context(), streamId, project.UserId, projectId, aiReq,
+		func(delta string) {
+			ctx.SSEvent("delta", gin.H{"content": delta})
+			ctx.Writer.Flush()
+		})
+	if err != nil {
+		ctx.SSEvent("error", gin.H{"message": err.Error()})
+		ctx.Writer.Flush()
+		return
+	}
+
+	if result["cancelled"] == true {
+		ctx.SSEvent("cancelled", result)
+	} else {
+		ctx.SSEvent("done", result)
+	}
+	ctx.Writer.Flush()
+}
+
+// CancelAIGenerate 取消流式续写
+func (c *OutlineController) CancelAIGenerate(ctx *gin.Context) {
+	projectId, project, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var cancelReq define.AIStreamCancelRequest
+	if err := ctx.ShouldBindJSON(&cancelReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	if err := c.service.CancelAIStream(project.UserId, projectId, cancelReq.StreamId); err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "续写已取消", nil)
+}
+
+// GenerateAICandidates 为同一请求生成多个续写候选，候选采纳前不产生版本
+func (c *OutlineController) GenerateAICandidates(ctx *gin.Context) {
+	projectId, project, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var candidateReq define.AICandidateRequest
+	if err := ctx.ShouldBindJSON(&candidateReq); err != nil || (candidateReq.NodeId == 0 && candidateReq.Content == "") {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.GenerateAICandidates(ctx.Request.Context(), project.UserId, projectId, candidateReq)
 	if err != nil {
 		ResponseError(ctx, err.Error())
 		return
@@ -102,6 +572,178 @@ func (c *OutlineController) AIGenerate(ctx *gin.Context) {
 	ResponseOK(ctx, result)
 }
 
+// GetAICandidates 获取项目未到期的续写候选
+func (c *OutlineController) GetAICandidates(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	candidates, err := c.service.GetAICandidates(projectId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, candidates)
+}
+
+// AcceptAICandidate 采纳续写候选，保存为新的AI版本
+func (c *OutlineController) AcceptAICandidate(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	candidateId, err := strconv.ParseInt(ctx.Param("candidateId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的候选ID")
+		return
+	}
+
+	result, err := c.service.AcceptAICandidate(projectId, candidateId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+	if result.Outline.Conflict {
+		ResponseOKWithMessage(ctx, "候选内容与大纲的修改冲突，未能采纳", result)
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "候选已采纳", result)
+}
+
+// GetContinuityReport 获取版本已缓存的连贯性检查结果
+func (c *OutlineController) GetContinuityReport(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	versionNumber, err := strconv.Atoi(ctx.Query("version"))
+	if err != nil || versionNumber < 1 {
+		ResponseError(ctx, "无效的版本号")
+		return
+	}
+
+	report, err := c.service.GetContinuityReport(projectId, versionNumber)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+	if report == nil {
+		ResponseError(ctx, "该版本还没有检查结果")
+		return
+	}
+
+	ResponseOK(ctx, report)
+}
+
+// CheckContinuity 检查版本新增的内容与之前的内容是否矛盾
+func (c *OutlineController) CheckContinuity(ctx *gin.Context) {
+	projectId, project, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var checkReq define.ContinuityCheckRequest
+	if err := ctx.ShouldBindJSON(&checkReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	report, err := c.service.CheckContinuity(ctx.Request.Context(), project.UserId, projectId, checkReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, report)
+}
+
+// GetOutlineSummaries 获取大纲的滚动摘要
+func (c *OutlineController) GetOutlineSummaries(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	summaries, err := c.service.GetOutlineSummaries(projectId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, summaries)
+}
+
+// UpdateOutlineSummary 修改摘要，内容为空时恢复自动生成
+func (c *OutlineController) UpdateOutlineSummary(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	summaryId, err := strconv.ParseInt(ctx.Param("summaryId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的摘要ID")
+		return
+	}
+
+	var summaryReq define.UpdateOutlineSummaryRequest
+	if err := ctx.ShouldBindJSON(&summaryReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	summary, err := c.service.UpdateOutlineSummary(projectId, summaryId, summaryReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "摘要修改成功", summary)
+}
+
+// RefreshOutlineSummaries 立即刷新大纲的滚动摘要
+func (c *OutlineController) RefreshOutlineSummaries(ctx *gin.Context) {
+	projectId, project, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	summaries, err := c.service.RefreshOutlineSummaries(project.UserId, projectId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "摘要刷新成功", summaries)
+}
+
+// ExportOutline 导出大纲为文件（结构体方法）
+func (c *OutlineController) ExportOutline(ctx *gin.Context) {
+	_, project, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var exportReq define.ExportRequest
+	if err := ctx.ShouldBindJSON(&exportReq); err != nil || exportReq.VersionNumber < 0 {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.ExportOutlineToFile(project, exportReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "导出成功", result)
+}
+
 // ParseOutline 解析大纲文件（结构体方法）
 func (c *OutlineController) ParseOutline(ctx *gin.Context) {
 	// 验证项目所有权（保留必要验证）
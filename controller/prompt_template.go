@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"gin-template/define"
+	"gin-template/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromptTemplateController struct {
+	service *service.PromptTemplateService
+}
+
+func NewPromptTemplateController(promptSvc *service.PromptTemplateService) *PromptTemplateController {
+	return &PromptTemplateController{
+		service: promptSvc,
+	}
+}
+
+// GetPromptTemplates 获取所有提示词模板（管理员）
+func (c *PromptTemplateController) GetPromptTemplates(ctx *gin.Context) {
+	templates, err := c.service.ListTemplates()
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, templates)
+}
+
+// GetPromptTemplate 获取提示词模板详情及版本历史（管理员）
+func (c *PromptTemplateController) GetPromptTemplate(ctx *gin.Context) {
+	template, err := c.service.GetTemplate(ctx.Param("name"))
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, template)
+}
+
+// CreatePromptTemplateVersion 提交提示词模板的新版本，模板不存在时创建模板（管理员）
+func (c *PromptTemplateController) CreatePromptTemplateVersion(ctx *gin.Context) {
+	var versionReq define.PromptTemplateVersionRequest
+	if err := ctx.ShouldBindJSON(&versionReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	userId := ctx.GetInt64("id")
+
+	template, err := c.service.CreateVersion(userId, ctx.Param("name"), versionReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "模板版本创建成功", template)
+}
+
+// ActivatePromptTemplateVersion 切换提示词模板的生效版本（管理员）
+func (c *PromptTemplateController) ActivatePromptTemplateVersion(ctx *gin.Context) {
+	var activateReq define.PromptTemplateActivateRequest
+	if err := ctx.ShouldBindJSON(&activateReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	template, err := c.service.ActivateVersion(ctx.Param("name"), activateReq.Version)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "生效版本已切换", template)
+}
+
+// RenderPromptTemplate 预览提示词模板的渲染结果（管理员）
+func (c *PromptTemplateController) RenderPromptTemplate(ctx *gin.Context) {
+	var renderReq define.PromptTemplateRenderRequest
+	if err := ctx.ShouldBindJSON(&renderReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	result, err := c.service.Render(ctx.Param("name"), renderReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, result)
+}
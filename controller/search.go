@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"gin-template/define"
+	"gin-template/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SearchController struct {
+	service *service.SearchService
+}
+
+func NewSearchController(searchSvc *service.SearchService) *SearchController {
+	return &SearchController{
+		service: searchSvc,
+	}
+}
+
+// Search 在当前用户的项目、大纲和历史版本中全文搜索
+func (c *SearchController) Search(ctx *gin.Context) {
+	var req define.SearchRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	userId := ctx.GetInt64("id")
+
+	response, err := c.service.Search(userId, req)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, response)
+}
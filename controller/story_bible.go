@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"gin-template/define"
+	"gin-template/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StoryBibleController 故事设定集控制器
+type StoryBibleController struct {
+	service *service.StoryBibleService
+}
+
+// NewStoryBibleController 创建故事设定集控制器实例
+func NewStoryBibleController(bibleSvc *service.StoryBibleService) *StoryBibleController {
+	return &StoryBibleController{
+		service: bibleSvc,
+	}
+}
+
+// GetStoryEntities 获取项目的设定条目，可按类型筛选
+func (c *StoryBibleController) GetStoryEntities(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	entities, err := c.service.ListEntities(projectId, ctx.Query("type"))
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, entities)
+}
+
+// CreateStoryEntity 创建设定条目
+func (c *StoryBibleController) CreateStoryEntity(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var entityReq define.StoryEntityRequest
+	if err := ctx.ShouldBindJSON(&entityReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	entity, err := c.service.CreateEntity(projectId, entityReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "设定条目创建成功", entity)
+}
+
+// GetStoryEntity 获取设定条目及其关系
+func (c *StoryBibleController) GetStoryEntity(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	entityId, err := strconv.ParseInt(ctx.Param("entityId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的条目ID")
+		return
+	}
+
+	entity, err := c.service.GetEntity(projectId, entityId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, entity)
+}
+
+// UpdateStoryEntity 修改设定条目
+func (c *StoryBibleController) UpdateStoryEntity(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	entityId, err := strconv.ParseInt(ctx.Param("entityId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的条目ID")
+		return
+	}
+
+	var entityReq define.StoryEntityRequest
+	if err := ctx.ShouldBindJSON(&entityReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	entity, err := c.service.UpdateEntity(projectId, entityId, entityReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "设定条目修改成功", entity)
+}
+
+// DeleteStoryEntity 删除设定条目及其关系
+func (c *StoryBibleController) DeleteStoryEntity(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	entityId, err := strconv.ParseInt(ctx.Param("entityId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的条目ID")
+		return
+	}
+
+	if err := c.service.DeleteEntity(projectId, entityId); err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "设定条目删除成功", nil)
+}
+
+// GetStoryRelations 获取项目的所有关系
+func (c *StoryBibleController) GetStoryRelations(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	relations, err := c.service.ListRelations(projectId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, relations)
+}
+
+// CreateStoryRelation 创建设定条目之间的关系
+func (c *StoryBibleController) CreateStoryRelation(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	var relationReq define.StoryRelationRequest
+	if err := ctx.ShouldBindJSON(&relationReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	relation, err := c.service.CreateRelation(projectId, relationReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "关系创建成功", relation)
+}
+
+// DeleteStoryRelation 删除关系
+func (c *StoryBibleController) DeleteStoryRelation(ctx *gin.Context) {
+	projectId, _, err := ValidateProjectOwnership(ctx)
+	if err != nil {
+		return
+	}
+
+	relationId, err := strconv.ParseInt(ctx.Param("relationId"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的关系ID")
+		return
+	}
+
+	if err := c.service.DeleteRelation(projectId, relationId); err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "关系删除成功", nil)
+}
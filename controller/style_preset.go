@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"gin-template/define"
+	"gin-template/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StylePresetController struct {
+	service *service.StylePresetService
+}
+
+func NewStylePresetController(presetSvc *service.StylePresetService) *StylePresetController {
+	return &StylePresetController{
+		service: presetSvc,
+	}
+}
+
+// GetStylePresets 获取可用的风格预设：公共预设和当前用户的私有预设
+func (c *StylePresetController) GetStylePresets(ctx *gin.Context) {
+	userId := ctx.GetInt64("id")
+
+	presets, err := c.service.ListPresets(userId)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, presets)
+}
+
+// CreateStylePreset 创建当前用户的私有风格预设
+func (c *StylePresetController) CreateStylePreset(ctx *gin.Context) {
+	var presetReq define.StylePresetRequest
+	if err := ctx.ShouldBindJSON(&presetReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	userId := ctx.GetInt64("id")
+
+	preset, err := c.service.CreateUserPreset(userId, presetReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "风格预设创建成功", preset)
+}
+
+// GetPublicStylePresets 获取所有公共风格预设，包括已停用的（管理员）
+func (c *StylePresetController) GetPublicStylePresets(ctx *gin.Context) {
+	presets, err := c.service.ListPublicPresets()
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOK(ctx, presets)
+}
+
+// CreatePublicStylePreset 创建公共风格预设（管理员）
+func (c *StylePresetController) CreatePublicStylePreset(ctx *gin.Context) {
+	var presetReq define.StylePresetRequest
+	if err := ctx.ShouldBindJSON(&presetReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	preset, err := c.service.CreatePublicPreset(presetReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "风格预设创建成功", preset)
+}
+
+// UpdatePublicStylePreset 修改公共风格预设（管理员）
+func (c *StylePresetController) UpdatePublicStylePreset(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	var presetReq define.StylePresetRequest
+	if err := ctx.ShouldBindJSON(&presetReq); err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	preset, err := c.service.UpdatePublicPreset(id, presetReq)
+	if err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "风格预设修改成功", preset)
+}
+
+// DeletePublicStylePreset 删除公共风格预设（管理员）
+func (c *StylePresetController) DeletePublicStylePreset(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ResponseError(ctx, "无效的参数")
+		return
+	}
+
+	if err := c.service.DeletePublicPreset(id); err != nil {
+		ResponseError(ctx, err.Error())
+		return
+	}
+
+	ResponseOKWithMessage(ctx, "风格预设删除成功", nil)
+}
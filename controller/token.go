@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"strconv"
+
+	"gin-template/common"
+	"gin-template/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenController struct {
+	tokenService *service.TokenService
+}
+
+func NewTokenController(tokenService *service.TokenService) *TokenController {
+	return &TokenController{
+		tokenService: tokenService,
+	}
+}
+
+// GetUserTokens 获取当前用户的Token余额、可用余额和交易记录
+func (c *TokenController) GetUserTokens(ctx *gin.Context) {
+	userId := ctx.GetInt64("id")
+
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	result, err := c.tokenService.GetUserTokenSummary(userId, page, limit)
+	if err != nil {
+		common.SysError("[token]获取Token余额失败")
+		ResponseError(ctx, "获取Token余额失败")
+		return
+	}
+	ResponseOK(ctx, result)
+}
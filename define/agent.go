@@ -18,9 +18,21 @@ type GenerateRequest struct {
 	SessionID string            `json:"session_id"` // 为空则创建新会话
 	Messages  []*schema.Message `json:"messages"`
 	UserInfo  map[string]string `json:"user_info,omitempty"`
+	// 只用于本次调用的系统消息，如项目的故事设定，不保存到会话历史
+	SystemMessages []*schema.Message `json:"-"`
 }
 
 type GenerateResponseForAgent struct {
 	SessionID string          `json:"session_id"`
 	Message   *schema.Message `json:"message"`
+	// 本次调用中各模型的用量，用于计费
+	Usage []AgentModelUsage `json:"usage"`
+}
+
+// AgentModelUsage 智能体一次调用中某个模型的用量，多次调用同一模型时累加
+type AgentModelUsage struct {
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
 }
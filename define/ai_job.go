@@ -0,0 +1,50 @@
+package define
+
+import "encoding/json"
+
+// AgentChatJobRequest 提交智能体对话任务的请求参数
+type AgentChatJobRequest struct {
+	SessionID string `json:"session_id" binding:"required"` // 会话ID
+	Message   string `json:"message" binding:"required"`    // 用户消息
+	ProjectId int64  `json:"project_id"`                    // 可选，带上该项目中与消息相关的故事设定
+}
+
+// AIJobProgress 异步任务的生成、扣费和保存进度，记录在任务上，进程中断后重新执行的任务据此跳过已完成的步骤；同步调用时为nil
+type AIJobProgress interface {
+	// TransactionUUID 任务扣费使用的交易UUID，同一任务的每次执行都相同，重复扣费时因交易UUID重复而失败
+	TransactionUUID() string
+	// Generated 将之前的执行记录的生成结果解析到output，尚未记录时返回false
+	Generated(output interface{}) bool
+	// MarkGenerated 在扣费前记录生成结果，之后重新执行时直接使用该结果，不再调用AI
+	MarkGenerated(output interface{})
+	// Settled 之前的执行已扣除的Token，尚未扣费时返回false
+	Settled() (int64, bool)
+	// MarkSettled 记录扣费已完成
+	MarkSettled(amount int64)
+	// MarkSaved 记录结果已保存，之后重新执行时直接以该结果结束
+	MarkSaved(result interface{})
+}
+
+// AgentChatJobResult 智能体对话任务的结果
+type AgentChatJobResult struct {
+	SessionID     string `json:"session_id"`
+	Response      string `json:"response"`
+	TokensCharged int64  `json:"tokens_charged"`
+	TokenBalance  int64  `json:"token_balance"`
+}
+
+// AIJobResponse 异步AI任务的状态
+type AIJobResponse struct {
+	JobId     string `json:"job_id"`
+	Type      string `json:"type"` // outline_generation或agent_chat
+	ProjectId int64  `json:"project_id"`
+	Status    string `json:"status"` // queued、running、succeeded、failed或cancelled
+	// 成功时的结果，与同步接口的返回相同，只在获取单个任务时返回
+	Result          json.RawMessage `json:"result,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	Attempts        int             `json:"attempts"`
+	CancelRequested bool            `json:"cancel_requested"`
+	CreatedAt       int64           `json:"created_at"`
+	StartedAt       int64           `json:"started_at"`
+	FinishedAt      int64           `json:"finished_at"`
+}
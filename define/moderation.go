@@ -0,0 +1,87 @@
+package define
+
+// AI调用的来源，记录在内容审核记录中
+const (
+	AISourcePrompt     = "ai_prompt"             // POST /api/ai/prompt
+	AISourceOutline    = "outline_generation"    // 续写和多候选续写
+	AISourceContinuity = "continuity_check"      // 连贯性检查
+	AISourceSummary    = "outline_summary"       // 滚动摘要
+	AISourceModeration = "moderation_classifier" // 内容审核的模型分类
+)
+
+// 审核记录的状态
+const (
+	ModerationStatusPending  = "pending"  // 待复核
+	ModerationStatusApproved = "approved" // 复核通过，没有违规
+	ModerationStatusRejected = "rejected" // 确认违规
+	ModerationStatusBlocked  = "blocked"  // 已拦截，不需要复核
+)
+
+// ModerationWordRequest 创建或修改敏感词的请求参数
+type ModerationWordRequest struct {
+	Word     string `json:"word" binding:"required,max=255"`
+	IsRegex  bool   `json:"is_regex"`
+	Category string `json:"category" binding:"max=50"`
+	Action   string `json:"action" binding:"required"` // block拦截，mask打码，flag放行并记录待复核
+}
+
+// ModerationWordImportRequest 批量导入敏感词的请求参数，已存在的词被跳过
+type ModerationWordImportRequest struct {
+	Words    []string `json:"words" binding:"required"`
+	Category string   `json:"category" binding:"max=50"`
+	Action   string   `json:"action" binding:"required"`
+}
+
+// ModerationWordImportResponse 批量导入敏感词的结果
+type ModerationWordImportResponse struct {
+	Created int `json:"created"`
+	Skipped int `json:"skipped"` // 已存在或为空的词
+}
+
+// ModerationMatch 一条审核命中
+type ModerationMatch struct {
+	Engine   string `json:"engine"` // keyword敏感词，llm模型分类
+	Rule     string `json:"rule"`   // 命中的敏感词，模型分类时为模型名
+	Category string `json:"category,omitempty"`
+	Action   string `json:"action"`
+	Text     string `json:"text,omitempty"`   // 命中的文字
+	Reason   string `json:"reason,omitempty"` // 模型给出的理由
+	Start    int    `json:"start"`            // 命中文字的字符位置，模型分类时为-1
+	End      int    `json:"end"`
+}
+
+// ModerationRecordResponse 审核记录
+type ModerationRecordResponse struct {
+	Id         int64             `json:"id"`
+	UserId     int64             `json:"user_id"`
+	ProjectId  int64             `json:"project_id"`
+	Stage      string            `json:"stage"`
+	Source     string            `json:"source"`
+	Action     string            `json:"action"`
+	Content    string            `json:"content"`
+	Matches    []ModerationMatch `json:"matches"`
+	Status     string            `json:"status"`
+	ReviewerId int64             `json:"reviewer_id"`
+	ReviewNote string            `json:"review_note"`
+	ReviewedAt int64             `json:"reviewed_at"`
+	CreatedAt  int64             `json:"created_at"`
+}
+
+// ModerationReviewRequest 复核审核记录的请求参数
+type ModerationReviewRequest struct {
+	Status string `json:"status" binding:"required"` // approved没有违规，rejected确认违规
+	Note   string `json:"note" binding:"max=255"`
+}
+
+// ModerationTestRequest 试运行审核的请求参数
+type ModerationTestRequest struct {
+	Text  string `json:"text" binding:"required"`
+	Stage string `json:"stage"` // prompt或generation，默认为generation
+}
+
+// ModerationTestResponse 试运行审核的结果，不会记录到审核队列
+type ModerationTestResponse struct {
+	Action  string            `json:"action"` // pass、flag、mask或block
+	Text    string            `json:"text"`   // 打码后的文本
+	Matches []ModerationMatch `json:"matches"`
+}
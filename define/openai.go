@@ -1,12 +1,5 @@
 package define
 
-// OpenAI API相关配置常量
-const (
-	// OpenAI API端点
-	OpenAIAPIURL    = "http://1.12.219.175:3001/v1/chat/completions"
-	OpenAIModelsURL = "http://1.12.219.175:3001/v1/models"
-)
-
 // GenerateAIPromptRequest 表示生成AI提示的请求
 type GenerateAIPromptRequest struct {
 	// 系统提示，定义AI的行为或背景
@@ -19,25 +12,48 @@ type GenerateAIPromptRequest struct {
 	MaxTokens   int     `json:"max_tokens" binding:"omitempty"`
 	// 额外的上下文信息（可选）
 	ContextData []string `json:"context_data" binding:"omitempty"`
+	// 不使用缓存的结果，重新调用模型，新的结果仍会写入缓存
+	NoCache bool `json:"no_cache"`
+	// 调用方信息，由服务端填写，用于内容审核记录
+	UserId    int64  `json:"-"`
+	ProjectId int64  `json:"-"`
+	Source    string `json:"-"`
 }
 
 // GenerateResponse 表示AI生成的响应
 type GenerateResponse struct {
 	Content    string `json:"content"`
 	TokensUsed int    `json:"tokens_used"`
-	Model      string `json:"model"`
-	RequestID  string `json:"request_id"`
-	Error      string `json:"error,omitempty"`
-	StatusCode int    `json:"-"`
+	// 提示词和生成内容的用量，用于按模型计费
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	Model            string `json:"model"`
+	RequestID        string `json:"request_id"`
+	Provider         string `json:"provider"` // 实际调用的模型服务商
+	Attempts         int    `json:"attempts"` // 调用次数，包括重试和切换到备用服务商
+	Cached           bool   `json:"cached"`   // 是否为缓存的结果，此时没有调用模型
+	Error            string `json:"error,omitempty"`
+	StatusCode       int    `json:"-"`
+}
+
+// AIPromptResponse /ai/prompt的结果，附带本次扣除的Token和扣除后的余额
+type AIPromptResponse struct {
+	GenerateResponse
+	TokensCharged int64 `json:"tokens_charged"`
+	TokenBalance  int64 `json:"token_balance"`
+}
+
+// ModelInfo 可用模型
+type ModelInfo struct {
+	ID       string `json:"id"`
+	Object   string `json:"object"`
+	OwnedBy  string `json:"owned_by"`
+	Provider string `json:"provider"` // 提供该模型的服务商
 }
 
 // ModelsResponse 表示模型列表响应
 type ModelsResponse struct {
-	Data []struct {
-		ID      string `json:"id"`
-		Object  string `json:"object"`
-		OwnedBy string `json:"owned_by"`
-	} `json:"data"`
-	Error      string `json:"error,omitempty"`
-	StatusCode int    `json:"-"`
+	Data       []ModelInfo `json:"data"`
+	Error      string      `json:"error,omitempty"`
+	StatusCode int         `json:"-"`
 }
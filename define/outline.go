@@ -4,19 +4,336 @@ import "mime/multipart"
 
 // OutlineRequest 大纲请求结构
 type OutlineRequest struct {
-	Content string `json:"content" binding:"required"`
+	Content     string `json:"content" binding:"required"`
+	BaseVersion *int   `json:"base_version"` // 本次编辑所基于的版本号，不传时不检查冲突直接保存
+	AutoMerge   bool   `json:"auto_merge"`   // 基础版本已过期时与当前内容做三方合并，没有冲突则直接保存合并结果
+}
+
+// SaveOutlineResponse 保存大纲的结果
+// 发生冲突时不会保存，返回服务器上的当前内容及冲突区域（target为服务器当前内容，source为本次提交的内容）
+type SaveOutlineResponse struct {
+	Id             int64           `json:"id"`
+	ProjectId      int64           `json:"project_id"`
+	Content        string          `json:"content"`
+	CurrentVersion int             `json:"current_version"`
+	CurrentBranch  string          `json:"current_branch"`
+	CreatedAt      int64           `json:"created_at"`
+	UpdatedAt      int64           `json:"updated_at"`
+	BaseVersion    int             `json:"base_version"`
+	Merged         bool            `json:"merged"` // 是否与基础版本之后的其他修改自动合并
+	Conflict       bool            `json:"conflict"`
+	Conflicts      []MergeConflict `json:"conflicts,omitempty"`
+}
+
+// OutlineDetail 大纲内容，Draft为未提交的自动保存草稿，没有草稿时为null
+type OutlineDetail struct {
+	Id             int64       `json:"id"`
+	ProjectId      int64       `json:"project_id"`
+	Content        string      `json:"content"`
+	CurrentVersion int         `json:"current_version"`
+	CurrentBranch  string      `json:"current_branch"`
+	CreatedAt      int64       `json:"created_at"`
+	UpdatedAt      int64       `json:"updated_at"`
+	Draft          *DraftBrief `json:"draft"`
+}
+
+// DraftRequest 自动保存草稿请求结构
+type DraftRequest struct {
+	Content     string `json:"content"`
+	BaseVersion *int   `json:"base_version"` // 草稿所基于的版本号，不传时为大纲当前版本
+}
+
+// CommitDraftRequest 提交草稿请求结构
+type CommitDraftRequest struct {
+	AutoMerge *bool `json:"auto_merge"` // 草稿的基础版本已过期时是否自动合并，默认为true
+}
+
+// DraftBrief 草稿摘要信息
+type DraftBrief struct {
+	ProjectId   int64  `json:"project_id"`
+	BaseVersion int    `json:"base_version"`
+	Branch      string `json:"branch"`
+	Revision    int64  `json:"revision"`
+	Outdated    bool   `json:"outdated"` // 大纲在草稿的基础版本之后已有新版本，提交时需要合并
+	Conflict    bool   `json:"conflict"` // 空闲自动提交时与新版本冲突，需要手动处理
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+// DraftResponse 草稿内容
+type DraftResponse struct {
+	DraftBrief
+	Content string `json:"content"`
 }
 
 // AIGenerateRequest AI续写请求结构
 type AIGenerateRequest struct {
-	Content   string `json:"content" binding:"required"`
+	Content string `json:"content"` // 未指定节点时必填
+	StyleId int64  `json:"styleId"` // 风格预设ID
+	// 兼容旧请求的风格标识，如scifi、xianxia，只在未指定styleId时按公共预设的code查找
 	Style     string `json:"style"`
-	WordLimit int    `json:"wordLimit"`
+	WordLimit int    `json:"wordLimit"` // 为0时使用风格预设的默认字数
+	NodeId    int64  `json:"nodeId"`    // 只续写指定的大纲节点，续写内容插入到该节点末尾
+	NoCache   bool   `json:"noCache"`   // 不使用缓存的续写结果，重新调用模型
+}
+
+// AICandidateRequest 生成多个续写候选的请求参数
+type AICandidateRequest struct {
+	AIGenerateRequest
+	Count int `json:"count" binding:"min=0"` // 候选数量，为0时生成2个
+}
+
+// AI续写候选状态
+const (
+	AICandidateStatusPending  = "pending"
+	AICandidateStatusAccepted = "accepted"
+	AICandidateStatusRejected = "rejected" // 同一批次的其他候选已被采纳
+)
+
+// AICandidate AI续写候选
+type AICandidate struct {
+	Id              int64  `json:"id"`
+	BatchId         string `json:"batch_id"`
+	Seq             int    `json:"seq"`
+	Content         string `json:"content"`
+	BaseVersion     int    `json:"base_version"`
+	NodeId          int64  `json:"node_id"`
+	StylePresetId   int64  `json:"style_preset_id"`
+	TokensUsed      int    `json:"tokens_used"`
+	Model           string `json:"model"`
+	Status          string `json:"status"`
+	AcceptedVersion int    `json:"accepted_version,omitempty"`
+	ExpiresAt       int64  `json:"expires_at"`
+	CreatedAt       int64  `json:"created_at"`
+}
+
+// AICandidatesResponse 一次请求生成的续写候选
+type AICandidatesResponse struct {
+	BatchId       string        `json:"batch_id"`
+	Candidates    []AICandidate `json:"candidates"`
+	Failed        int           `json:"failed"`         // 生成失败的候选数量，失败的候选不计费
+	TokensUsed    int           `json:"tokens_used"`    // 所有候选的用量之和
+	TokensCharged int64         `json:"tokens_charged"` // 按模型计费规则和套餐折扣实际扣除的Token
+	TokenBalance  int64         `json:"token_balance"`
+}
+
+// AICandidateAcceptResponse 采纳候选的结果，Outline.Conflict为true时候选未被采纳，仍可重试
+type AICandidateAcceptResponse struct {
+	Candidate AICandidate          `json:"candidate"`
+	Outline   *SaveOutlineResponse `json:"outline"`
+}
+
+// OutlineSummary 大纲摘要，续写长大纲时代替较早的内容
+type OutlineSummary struct {
+	Id            int64  `json:"id"`
+	Path          string `json:"path"`  // 从顶层到该节点的标题路径，概要为空
+	Level         int    `json:"level"` // 0为整份大纲的概要
+	Title         string `json:"title"`
+	Summary       string `json:"summary"`
+	SourceVersion int    `json:"source_version,omitempty"`
+	Edited        bool   `json:"edited"` // 作者修改过，不会被自动覆盖
+	Stale         bool   `json:"stale"`  // 作者修改过的摘要对应的原文已经变化
+	UpdatedAt     int64  `json:"updated_at"`
+}
+
+// UpdateOutlineSummaryRequest 修改大纲摘要的请求参数
+type UpdateOutlineSummaryRequest struct {
+	Summary string `json:"summary" binding:"max=5000"` // 为空时恢复为自动生成
+}
+
+// AIStreamCancelRequest 取消流式续写请求参数
+type AIStreamCancelRequest struct {
+	StreamId string `json:"stream_id" binding:"required"` // 开始续写时start事件返回的ID
+}
+
+// VersionDiffRequest 版本对比请求参数
+type VersionDiffRequest struct {
+	From        int  `form:"from" binding:"required,min=1"`
+	To          int  `form:"to" binding:"required,min=1"`
+	ChangesOnly bool `form:"changes_only"` // 为true时不返回未变化的段落
+}
+
+// VersionBrief 版本摘要信息
+type VersionBrief struct {
+	VersionNumber int    `json:"version_number"`
+	IsAiGenerated bool   `json:"is_ai_generated"`
+	AiStyle       string `json:"ai_style"`
+	StylePresetId int64  `json:"style_preset_id"`
+	TokensUsed    int    `json:"tokens_used"`
+	BranchName    string `json:"branch_name"`
+	Pinned        bool   `json:"pinned"`
+	// 从哪个版本恢复而来，0表示不是恢复产生的版本
+	RestoredFromVersion int   `json:"restored_from_version"`
+	CreatedAt           int64 `json:"created_at"`
+}
+
+// RestoreVersionRequest 恢复版本请求结构
+type RestoreVersionRequest struct {
+	VersionNumber int `json:"version_number" binding:"required,min=1"`
+}
+
+// PinVersionRequest 固定版本请求结构
+type PinVersionRequest struct {
+	VersionNumber int  `json:"version_number" binding:"required,min=1"`
+	Pinned        bool `json:"pinned"`
+}
+
+// RestoreVersionResponse 恢复版本响应结构
+type RestoreVersionResponse struct {
+	OutlineId      int64        `json:"outline_id"`
+	ProjectId      int64        `json:"project_id"`
+	Content        string       `json:"content"`
+	CurrentVersion int          `json:"current_version"`
+	Version        VersionBrief `json:"version"`
+}
+
+// BranchInfo 分支信息
+type BranchInfo struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	HeadVersion int    `json:"head_version"` // 分支头版本号，0表示分支上还没有版本
+	BaseVersion int    `json:"base_version"` // 创建分支时所基于的版本号
+	IsCurrent   bool   `json:"is_current"`
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+// BranchListResponse 分支列表响应结构
+type BranchListResponse struct {
+	CurrentBranch string       `json:"current_branch"`
+	Branches      []BranchInfo `json:"branches"`
+}
+
+// CreateBranchRequest 创建分支请求结构
+type CreateBranchRequest struct {
+	Name        string `json:"name" binding:"required"`
+	FromVersion int    `json:"from_version"` // 基于哪个版本创建，为0时基于当前分支的最新版本
+}
+
+// SwitchBranchRequest 切换分支请求结构
+type SwitchBranchRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeBranchRequest 合并分支请求结构
+type MergeBranchRequest struct {
+	Source string `json:"source" binding:"required"`
+	Target string `json:"target"` // 为空时合并到当前分支
+	// 手动解决冲突后的完整内容，传入时直接作为合并结果
+	ResolvedContent *string `json:"resolved_content"`
+}
+
+// MergeConflict 合并冲突区域，行号从0开始
+type MergeConflict struct {
+	BaseStart   int    `json:"base_start"`
+	TargetStart int    `json:"target_start"`
+	SourceStart int    `json:"source_start"`
+	Base        string `json:"base"`
+	Target      string `json:"target"`
+	Source      string `json:"source"`
+}
+
+// MergeBranchResponse 合并分支响应结构
+type MergeBranchResponse struct {
+	Source    string          `json:"source"`
+	Target    string          `json:"target"`
+	Merged    bool            `json:"merged"`
+	UpToDate  bool            `json:"up_to_date"` // 源分支的修改已全部包含在目标分支中
+	Conflicts []MergeConflict `json:"conflicts,omitempty"`
+	Version   *VersionBrief   `json:"version,omitempty"`
+}
+
+// OutlineNodeInfo 大纲结构节点
+type OutlineNodeInfo struct {
+	Id       int64             `json:"id"`
+	ParentId int64             `json:"parent_id"`
+	Kind     string            `json:"kind"` // preamble, volume, chapter, section, heading, beat
+	Level    int               `json:"level"`
+	Heading  string            `json:"heading"` // 标题行原文
+	Title    string            `json:"title"`
+	Body     string            `json:"body"`
+	Children []OutlineNodeInfo `json:"children"`
+}
+
+// OutlineTreeResponse 大纲结构响应
+type OutlineTreeResponse struct {
+	OutlineId      int64             `json:"outline_id"`
+	ProjectId      int64             `json:"project_id"`
+	CurrentVersion int               `json:"current_version"`
+	CurrentBranch  string            `json:"current_branch"`
+	NodeId         int64             `json:"node_id,omitempty"` // 本次创建或修改的节点ID
+	Nodes          []OutlineNodeInfo `json:"nodes"`
+}
+
+// CreateOutlineNodeRequest 创建大纲节点请求结构
+type CreateOutlineNodeRequest struct {
+	ParentId int64  `json:"parent_id"` // 为0时创建顶层节点
+	Position *int   `json:"position"`  // 在同级节点中的位置，为空时追加到末尾
+	Kind     string `json:"kind" binding:"required,oneof=volume chapter section heading beat"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+// UpdateOutlineNodeRequest 修改大纲节点请求结构，为空的字段不修改
+type UpdateOutlineNodeRequest struct {
+	Title *string `json:"title"`
+	Body  *string `json:"body"`
+}
+
+// MoveOutlineNodeRequest 移动大纲节点请求结构
+type MoveOutlineNodeRequest struct {
+	ParentId int64 `json:"parent_id"` // 为0时移动到顶层
+	Position *int  `json:"position"`  // 在新的同级节点中的位置，为空时追加到末尾
+}
+
+// DiffSegment 段落内的字符级差异片段
+type DiffSegment struct {
+	Type string `json:"type"` // equal, insert, delete
+	Text string `json:"text"`
+}
+
+// ParagraphDiff 段落级差异
+type ParagraphDiff struct {
+	Type     string        `json:"type"`      // equal, insert, delete, modify
+	OldIndex int           `json:"old_index"` // 在旧版本中的段落序号，不存在时为-1
+	NewIndex int           `json:"new_index"` // 在新版本中的段落序号，不存在时为-1
+	OldText  string        `json:"old_text,omitempty"`
+	NewText  string        `json:"new_text,omitempty"`
+	Segments []DiffSegment `json:"segments,omitempty"` // 仅modify类型返回字符级差异
+}
+
+// DiffStats 差异统计，字数按字符（rune）计算
+type DiffStats struct {
+	ParagraphsAdded    int `json:"paragraphs_added"`
+	ParagraphsRemoved  int `json:"paragraphs_removed"`
+	ParagraphsModified int `json:"paragraphs_modified"`
+	CharsAdded         int `json:"chars_added"`
+	CharsRemoved       int `json:"chars_removed"`
+}
+
+// VersionDiffResponse 版本对比结果
+type VersionDiffResponse struct {
+	From       VersionBrief    `json:"from"`
+	To         VersionBrief    `json:"to"`
+	Paragraphs []ParagraphDiff `json:"paragraphs"`
+	Stats      DiffStats       `json:"stats"`
 }
 
 // ExportRequest 导出请求结构
 type ExportRequest struct {
-	Format string `json:"format" binding:"required"`
+	Format        string `json:"format" binding:"required,oneof=txt md docx pdf epub"`
+	VersionNumber int    `json:"version_number"` // 导出指定版本，为0时导出当前内容
+	SplitChapters bool   `json:"split_chapters"` // 按章节拆分：docx/pdf每章另起一页，epub每章单独成篇，txt/md打包为每章一个文件的zip
+	TitlePage     bool   `json:"title_page"`     // 是否生成标题页（项目标题和简介）
+}
+
+// ExportResponse 导出结果
+type ExportResponse struct {
+	FileUrl       string `json:"file_url"`
+	FileName      string `json:"file_name"`
+	FileSize      int64  `json:"file_size"`
+	Format        string `json:"format"`
+	VersionNumber int    `json:"version_number"` // 导出内容对应的版本号，0表示大纲还没有保存过版本
 }
 
 // OutlineFileInfo 大纲文件信息结构
@@ -31,4 +348,50 @@ type OutlineFileInfo struct {
 type FileHeader struct {
 	*multipart.FileHeader
 	SaveFile func(string) error
-} 
\ No newline at end of file
+}
+
+// 连贯性问题的类型
+const (
+	ContinuityTimeline  = "timeline"  // 时间线矛盾
+	ContinuityCharacter = "character" // 人物的年龄、外貌、性格、能力等前后不一致
+	ContinuityDeath     = "death"     // 已死亡或离开的人物无解释地再次出现
+	ContinuityPlace     = "place"     // 地名改变或地点设定不一致
+	ContinuityOther     = "other"
+)
+
+// ContinuityCheckRequest 连贯性检查请求参数
+type ContinuityCheckRequest struct {
+	VersionNumber int  `json:"version_number" binding:"required,min=1"`
+	Force         bool `json:"force"` // 为true时忽略已缓存的结果重新检查，重新计费
+}
+
+// ContinuityEvidence 问题的引文依据
+type ContinuityEvidence struct {
+	Source string `json:"source"` // new：本版本新增的内容，previous：之前的内容
+	Quote  string `json:"quote"`
+	Found  bool   `json:"found"` // 引文能否在对应版本的内容中找到
+	Start  int    `json:"start"` // 引文在对应版本内容中的起止位置（字符），未找到时为-1
+	End    int    `json:"end"`
+	Line   int    `json:"line"` // 引文所在行，从1开始，未找到时为0
+}
+
+// ContinuityIssue 疑似的连贯性问题
+type ContinuityIssue struct {
+	Type        string               `json:"type"`
+	Description string               `json:"description"`
+	Evidence    []ContinuityEvidence `json:"evidence"`
+}
+
+// ContinuityReport 版本的连贯性检查结果
+type ContinuityReport struct {
+	VersionNumber int               `json:"version_number"`
+	BaseVersion   int               `json:"base_version"` // 对比的父版本号
+	IsAiGenerated bool              `json:"is_ai_generated"`
+	Issues        []ContinuityIssue `json:"issues"`
+	TokensUsed    int               `json:"tokens_used"`
+	Model         string            `json:"model"`
+	Cached        bool              `json:"cached"`                   // 是否为已缓存的结果，缓存的结果不计费
+	TokensCharged int64             `json:"tokens_charged,omitempty"` // 本次检查实际扣除的Token，缓存的结果不返回
+	TokenBalance  int64             `json:"token_balance,omitempty"`
+	CreatedAt     int64             `json:"created_at"`
+}
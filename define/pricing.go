@@ -0,0 +1,36 @@
+package define
+
+// ModelPrice 模型的计费规则，倍率为每个模型token折合的平台Token数
+type ModelPrice struct {
+	PromptRate     float64 `json:"prompt"`               // 提示词倍率
+	CompletionRate float64 `json:"completion"`           // 生成内容倍率
+	MinCharge      int64   `json:"min_charge,omitempty"` // 每次调用的最低扣费
+}
+
+// ModelPriceInfo 计费规则列表中的一项，Model以*结尾时按前缀匹配
+type ModelPriceInfo struct {
+	Model string `json:"model"`
+	ModelPrice
+}
+
+// AIPricingResponse 当前用户适用的计费规则
+type AIPricingResponse struct {
+	Prices       []ModelPriceInfo `json:"prices"`
+	DefaultPrice ModelPrice       `json:"default_price"` // 没有匹配规则的模型的计费规则
+	PackageId    int64            `json:"package_id"`
+	Discount     float64          `json:"discount"` // 当前套餐的折扣，1表示不打折
+}
+
+// AICharge 一次AI调用的扣费明细
+type AICharge struct {
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	PromptRate       float64 `json:"prompt_rate"`
+	CompletionRate   float64 `json:"completion_rate"`
+	Discount         float64 `json:"discount"`
+	MinCharge        int64   `json:"min_charge"`
+	Cached           bool    `json:"cached"`     // 是否为缓存的结果
+	CacheRate        float64 `json:"cache_rate"` // 缓存的结果按正常扣费的多少倍扣费
+	Amount           int64   `json:"amount"`     // 实际扣除的平台Token数
+}
@@ -0,0 +1,65 @@
+package define
+
+// PromptVariable 提示词模板变量
+type PromptVariable struct {
+	Name        string `json:"name" binding:"required,max=50"`
+	Description string `json:"description"`
+	Default     string `json:"default"`  // 渲染时未传入该变量时使用
+	Required    bool   `json:"required"` // 必填变量未传入时渲染失败
+}
+
+// PromptTemplateVersionRequest 提交提示词模板新版本的请求参数
+type PromptTemplateVersionRequest struct {
+	Description string           `json:"description" binding:"max=255"` // 为空时保留原描述
+	Content     string           `json:"content" binding:"required"`
+	Variables   []PromptVariable `json:"variables" binding:"dive"`
+	Comment     string           `json:"comment" binding:"max=255"`
+	Activate    bool             `json:"activate"` // 是否立即生效
+}
+
+// PromptTemplateActivateRequest 切换提示词模板生效版本的请求参数
+type PromptTemplateActivateRequest struct {
+	Version int `json:"version" binding:"required,min=1"`
+}
+
+// PromptTemplateRenderRequest 预览提示词模板的请求参数
+type PromptTemplateRenderRequest struct {
+	Version   int               `json:"version"` // 为0时使用生效版本
+	Content   string            `json:"content"` // 不为空时预览尚未保存的内容，此时使用Definitions作为变量定义
+	Variables map[string]string `json:"variables"`
+	// 预览未保存的内容时的变量定义
+	Definitions []PromptVariable `json:"definitions" binding:"dive"`
+}
+
+// PromptTemplateRenderResponse 提示词模板预览结果
+type PromptTemplateRenderResponse struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"` // 预览未保存的内容时为0
+	Content string `json:"content"`
+}
+
+// PromptTemplateBrief 提示词模板列表项
+type PromptTemplateBrief struct {
+	Id            int64  `json:"id"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	ActiveVersion int    `json:"active_version"`
+	UpdatedAt     int64  `json:"updated_at"`
+}
+
+// PromptTemplateVersionResponse 提示词模板版本
+type PromptTemplateVersionResponse struct {
+	Version   int              `json:"version"`
+	Content   string           `json:"content"`
+	Variables []PromptVariable `json:"variables"`
+	Comment   string           `json:"comment"`
+	CreatedBy int64            `json:"created_by"`
+	CreatedAt int64            `json:"created_at"`
+	Active    bool             `json:"active"`
+}
+
+// PromptTemplateResponse 提示词模板详情及版本历史
+type PromptTemplateResponse struct {
+	PromptTemplateBrief
+	Versions []PromptTemplateVersionResponse `json:"versions"`
+}
@@ -0,0 +1,31 @@
+package define
+
+// SearchRequest 全文搜索请求参数
+type SearchRequest struct {
+	Query     string `form:"q" binding:"required"` // 多个关键词用空格分隔，需全部命中
+	ProjectId int64  `form:"project_id"`           // 只搜索指定项目
+	AiOnly    bool   `form:"ai_only"`              // 只搜索AI生成的历史版本
+	Page      int    `form:"page"`
+	Limit     int    `form:"limit"`
+}
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	DocType       string `json:"doc_type"` // project：项目标题和简介，outline：大纲当前内容，version：历史版本中新增的内容
+	ProjectId     int64  `json:"project_id"`
+	ProjectTitle  string `json:"project_title"`
+	VersionNumber int    `json:"version_number,omitempty"`
+	IsAiGenerated bool   `json:"is_ai_generated"`
+	BranchName    string `json:"branch_name,omitempty"`
+	// 命中位置附近的片段，已做HTML转义，命中的关键词用<mark>标记
+	Snippets   []string `json:"snippets"`
+	MatchCount int      `json:"match_count"`
+	Score      int      `json:"score"` // 排序得分：关键词出现次数加上文档类型的权重，结果按得分从高到低排列
+	UpdatedAt  int64    `json:"updated_at"`
+}
+
+// SearchResponse 搜索结果分页响应
+type SearchResponse struct {
+	Data       []SearchResult `json:"data"`
+	Pagination Pagination     `json:"pagination"`
+}
@@ -0,0 +1,58 @@
+package define
+
+// 设定条目的类型
+const (
+	StoryEntityCharacter = "character" // 人物
+	StoryEntityLocation  = "location"  // 地点
+	StoryEntityFaction   = "faction"   // 势力
+	StoryEntityItem      = "item"      // 物品
+	StoryEntityRule      = "rule"      // 世界规则
+)
+
+// StoryAttribute 设定条目的属性，如年龄、能力
+type StoryAttribute struct {
+	Key   string `json:"key" binding:"required,max=50"`
+	Value string `json:"value" binding:"max=500"`
+}
+
+// StoryEntityRequest 创建或修改设定条目的请求参数
+type StoryEntityRequest struct {
+	Type        string           `json:"type" binding:"required,oneof=character location faction item rule"`
+	Name        string           `json:"name" binding:"required,max=100"`
+	Aliases     []string         `json:"aliases"`
+	Description string           `json:"description" binding:"max=5000"`
+	Attributes  []StoryAttribute `json:"attributes" binding:"dive"`
+	Pinned      bool             `json:"pinned"` // 每次续写都带上该条目
+}
+
+// StoryEntity 设定条目
+type StoryEntity struct {
+	Id          int64            `json:"id"`
+	Type        string           `json:"type"`
+	Name        string           `json:"name"`
+	Aliases     []string         `json:"aliases"`
+	Description string           `json:"description"`
+	Attributes  []StoryAttribute `json:"attributes"`
+	Pinned      bool             `json:"pinned"`
+	Relations   []StoryRelation  `json:"relations,omitempty"` // 只在获取单个条目时返回
+	UpdatedAt   int64            `json:"updated_at"`
+}
+
+// StoryRelationRequest 创建关系的请求参数，表示source是target的type，如“张三是李四的师父”
+type StoryRelationRequest struct {
+	SourceId    int64  `json:"source_id" binding:"required"`
+	TargetId    int64  `json:"target_id" binding:"required"`
+	Type        string `json:"type" binding:"required,max=50"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// StoryRelation 设定条目之间的关系
+type StoryRelation struct {
+	Id          int64  `json:"id"`
+	SourceId    int64  `json:"source_id"`
+	SourceName  string `json:"source_name"`
+	TargetId    int64  `json:"target_id"`
+	TargetName  string `json:"target_name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
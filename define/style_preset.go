@@ -0,0 +1,34 @@
+package define
+
+// StylePresetRequest 创建或修改风格预设的请求参数
+type StylePresetRequest struct {
+	Code             string   `json:"code"` // 只对公共预设有效
+	Name             string   `json:"name" binding:"required,max=50"`
+	Description      string   `json:"description" binding:"max=255"`
+	SystemPrompt     string   `json:"system_prompt" binding:"required"`
+	Examples         []string `json:"examples"`
+	Temperature      float64  `json:"temperature" binding:"min=0,max=2"` // 为0时使用模型服务的默认值
+	DefaultWordLimit int      `json:"default_word_limit" binding:"min=0"`
+	MinWordLimit     int      `json:"min_word_limit" binding:"min=0"`
+	MaxWordLimit     int      `json:"max_word_limit" binding:"min=0"`
+	SortOrder        int      `json:"sort_order"`
+	Enabled          *bool    `json:"enabled"` // 只对公共预设有效，不传时为启用
+}
+
+// StylePresetResponse 风格预设
+type StylePresetResponse struct {
+	Id               int64    `json:"id"`
+	Code             string   `json:"code,omitempty"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	SystemPrompt     string   `json:"system_prompt"`
+	Examples         []string `json:"examples"`
+	Temperature      float64  `json:"temperature"`
+	DefaultWordLimit int      `json:"default_word_limit"`
+	MinWordLimit     int      `json:"min_word_limit"`
+	MaxWordLimit     int      `json:"max_word_limit"`
+	SortOrder        int      `json:"sort_order"`
+	Enabled          bool     `json:"enabled"`
+	IsPrivate        bool     `json:"is_private"` // 是否为当前用户的私有预设
+	UpdatedAt        int64    `json:"updated_at"`
+}
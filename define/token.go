@@ -15,6 +15,14 @@ const (
 	TransactionStatusCompleted = "completed"
 )
 
+// Token预扣状态
+const (
+	TokenHoldStatusActive   = "active"
+	TokenHoldStatusSettled  = "settled"
+	TokenHoldStatusReleased = "released"
+	TokenHoldStatusExpired  = "expired"
+)
+
 // TokenBalance 用户Token余额信息
 type TokenBalance struct {
 	UserID    uint      `json:"user_id"`
@@ -58,6 +66,24 @@ type TokenTransactionList struct {
 	Pages        int                `json:"pages"`
 }
 
+// UserTokenResponse 用户Token余额和交易记录
+type UserTokenResponse struct {
+	Balance          int64         `json:"balance"`
+	AvailableBalance int64         `json:"available_balance"` // 余额减去进行中的AI调用预扣
+	Records          []TokenRecord `json:"records"`
+	Pagination       Pagination    `json:"pagination"`
+}
+
+// TokenRecord 一条Token交易记录
+type TokenRecord struct {
+	Id          int64  `json:"id"`
+	Amount      int64  `json:"amount"`  // 正数为增加，负数为减少
+	Balance     int64  `json:"balance"` // 交易后的余额
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
 // TokenInitRequest 初始化用户Token账户的请求
 type TokenInitRequest struct {
 	UserID         uint  `json:"user_id" binding:"required"`
@@ -26,6 +26,98 @@ CREATE TABLE `files`  (
                           INDEX `idx_files_uploader`(`uploader` ASC) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for outline_branches
+-- ----------------------------
+DROP TABLE IF EXISTS `outline_branches`;
+CREATE TABLE `outline_branches`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `outline_id` bigint NULL DEFAULT NULL,
+                             `name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `head_version_id` bigint NULL DEFAULT 0,
+                             `base_version_id` bigint NULL DEFAULT 0,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             INDEX `idx_outline_branches_outline_id`(`outline_id` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for outline_drafts
+-- ----------------------------
+DROP TABLE IF EXISTS `outline_drafts`;
+CREATE TABLE `outline_drafts`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `base_version` bigint NULL DEFAULT 0,
+                             `branch` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `revision` bigint NULL DEFAULT 0,
+                             `conflict` tinyint(1) NULL DEFAULT 0,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_outline_drafts_project_id`(`project_id` ASC) USING BTREE,
+                             INDEX `idx_outline_drafts_updated_at`(`updated_at` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for search_documents
+-- ----------------------------
+DROP TABLE IF EXISTS `search_documents`;
+CREATE TABLE `search_documents`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `user_id` bigint NULL DEFAULT NULL,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `doc_type` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `source_id` bigint NULL DEFAULT NULL,
+                             `version_number` bigint NULL DEFAULT 0,
+                             `is_ai_generated` tinyint(1) NULL DEFAULT 0,
+                             `branch_name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `title` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `source_updated_at` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_search_source`(`doc_type` ASC, `source_id` ASC) USING BTREE,
+                             INDEX `idx_search_documents_user_id`(`user_id` ASC) USING BTREE,
+                             INDEX `idx_search_documents_project_id`(`project_id` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for search_postings
+-- ----------------------------
+DROP TABLE IF EXISTS `search_postings`;
+CREATE TABLE `search_postings`  (
+                             `user_id` bigint NOT NULL,
+                             `gram` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+                             `document_id` bigint NOT NULL,
+                             PRIMARY KEY (`user_id`, `gram`, `document_id`) USING BTREE,
+                             INDEX `idx_search_postings_document_id`(`document_id` ASC) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for outline_nodes
+-- ----------------------------
+DROP TABLE IF EXISTS `outline_nodes`;
+CREATE TABLE `outline_nodes`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `outline_id` bigint NULL DEFAULT NULL,
+                             `parent_id` bigint NULL DEFAULT 0,
+                             `kind` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `level` bigint NULL DEFAULT NULL,
+                             `marker` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `title` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `body` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `sort_order` bigint NULL DEFAULT 0,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             INDEX `idx_outline_nodes_outline_id`(`outline_id` ASC) USING BTREE,
+                             INDEX `idx_outline_nodes_parent_id`(`parent_id` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for outlines
 -- ----------------------------
@@ -35,6 +127,7 @@ CREATE TABLE `outlines`  (
                              `project_id` bigint NULL DEFAULT NULL,
                              `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
                              `current_version` bigint NULL DEFAULT NULL,
+                             `current_branch` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT 'main',
                              `created_at` bigint NULL DEFAULT NULL, -- 改为bigint
                              `updated_at` bigint NULL DEFAULT NULL, -- 改为bigint
                              PRIMARY KEY (`id`) USING BTREE,
@@ -108,6 +201,264 @@ CREATE TABLE `referrals`  (
                               INDEX `idx_referrals_code`(`code` ASC) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 2 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for style_presets
+-- ----------------------------
+DROP TABLE IF EXISTS `style_presets`;
+CREATE TABLE `style_presets`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `user_id` bigint NULL DEFAULT 0,
+                             `code` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `description` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `system_prompt` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `examples` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `temperature` double NULL DEFAULT NULL,
+                             `default_word_limit` bigint NULL DEFAULT NULL,
+                             `min_word_limit` bigint NULL DEFAULT NULL,
+                             `max_word_limit` bigint NULL DEFAULT NULL,
+                             `sort_order` bigint NULL DEFAULT NULL,
+                             `enabled` tinyint(1) NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             INDEX `idx_style_presets_user_id`(`user_id` ASC) USING BTREE,
+                             INDEX `idx_style_presets_code`(`code` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for ai_candidates
+-- ----------------------------
+DROP TABLE IF EXISTS `ai_candidates`;
+CREATE TABLE `ai_candidates`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `batch_id` varchar(36) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `user_id` bigint NULL DEFAULT NULL,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `seq` bigint NULL DEFAULT NULL,
+                             `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `outline_content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `base_version` bigint NULL DEFAULT NULL,
+                             `node_id` bigint NULL DEFAULT NULL,
+                             `style_preset_id` bigint NULL DEFAULT NULL,
+                             `word_limit` bigint NULL DEFAULT NULL,
+                             `tokens_used` bigint NULL DEFAULT NULL,
+                             `model` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT 'pending',
+                             `accepted_version` bigint NULL DEFAULT NULL,
+                             `expires_at` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             INDEX `idx_ai_candidates_batch_id`(`batch_id` ASC) USING BTREE,
+                             INDEX `idx_ai_candidates_project_id`(`project_id` ASC) USING BTREE,
+                             INDEX `idx_ai_candidates_expires_at`(`expires_at` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for outline_summaries
+-- ----------------------------
+DROP TABLE IF EXISTS `outline_summaries`;
+CREATE TABLE `outline_summaries`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `path` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `level` bigint NULL DEFAULT NULL,
+                             `title` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `summary` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `source_hash` varchar(40) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `source_version` bigint NULL DEFAULT NULL,
+                             `edited` tinyint(1) NULL DEFAULT NULL,
+                             `stale` tinyint(1) NULL DEFAULT NULL,
+                             `sort_order` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_outline_summary_path`(`project_id` ASC, `path` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for story_entities
+-- ----------------------------
+DROP TABLE IF EXISTS `story_entities`;
+CREATE TABLE `story_entities`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `type` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `name` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `aliases` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `description` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `attributes` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `pinned` tinyint(1) NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             INDEX `idx_story_entities_project_id`(`project_id` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for story_relations
+-- ----------------------------
+DROP TABLE IF EXISTS `story_relations`;
+CREATE TABLE `story_relations`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `source_id` bigint NULL DEFAULT NULL,
+                             `target_id` bigint NULL DEFAULT NULL,
+                             `type` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `description` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             INDEX `idx_story_relations_project_id`(`project_id` ASC) USING BTREE,
+                             INDEX `idx_story_relations_source_id`(`source_id` ASC) USING BTREE,
+                             INDEX `idx_story_relations_target_id`(`target_id` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for continuity_reports
+-- ----------------------------
+DROP TABLE IF EXISTS `continuity_reports`;
+CREATE TABLE `continuity_reports`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `version_number` bigint NULL DEFAULT NULL,
+                             `base_version` bigint NULL DEFAULT NULL,
+                             `issues` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `tokens_used` bigint NULL DEFAULT NULL,
+                             `model` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_continuity_report_version`(`project_id` ASC, `version_number` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for moderation_words
+-- ----------------------------
+DROP TABLE IF EXISTS `moderation_words`;
+CREATE TABLE `moderation_words`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `word` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `is_regex` tinyint(1) NULL DEFAULT 0,
+                             `category` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `action` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_moderation_words_word`(`word` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for moderation_records
+-- ----------------------------
+DROP TABLE IF EXISTS `moderation_records`;
+CREATE TABLE `moderation_records`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `user_id` bigint NULL DEFAULT NULL,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `stage` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `source` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `action` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `matches` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `reviewer_id` bigint NULL DEFAULT NULL,
+                             `review_note` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `reviewed_at` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             INDEX `idx_moderation_records_user_id`(`user_id` ASC) USING BTREE,
+                             INDEX `idx_moderation_records_status`(`status` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for ai_jobs
+-- ----------------------------
+DROP TABLE IF EXISTS `ai_jobs`;
+CREATE TABLE `ai_jobs`  (
+                             `job_id` varchar(36) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+                             `user_id` bigint NULL DEFAULT NULL,
+                             `project_id` bigint NULL DEFAULT NULL,
+                             `job_type` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+                             `payload` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+                             `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `result` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `last_error` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `attempts` bigint NULL DEFAULT 0,
+                             `max_attempts` bigint NULL DEFAULT 3,
+                             `cancel_requested` tinyint(1) NULL DEFAULT 0,
+                             `heartbeat_at` bigint NULL DEFAULT NULL,
+                             `settled_at` bigint NULL DEFAULT NULL,
+                             `settled_amount` bigint NULL DEFAULT NULL,
+                             `saved_at` bigint NULL DEFAULT NULL,
+                             `started_at` bigint NULL DEFAULT NULL,
+                             `finished_at` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`job_id`) USING BTREE,
+                             INDEX `idx_ai_jobs_user_id`(`user_id` ASC) USING BTREE,
+                             INDEX `idx_ai_jobs_status`(`status` ASC) USING BTREE,
+                             INDEX `idx_ai_jobs_created_at`(`created_at` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for prompt_templates
+-- ----------------------------
+DROP TABLE IF EXISTS `prompt_templates`;
+CREATE TABLE `prompt_templates`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `name` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `description` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `active_version` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_prompt_templates_name`(`name` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for prompt_template_versions
+-- ----------------------------
+DROP TABLE IF EXISTS `prompt_template_versions`;
+CREATE TABLE `prompt_template_versions`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `template_id` bigint NULL DEFAULT NULL,
+                             `version` bigint NULL DEFAULT NULL,
+                             `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `variables` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `comment` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `created_by` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_prompt_template_version`(`template_id` ASC, `version` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for token_holds
+-- ----------------------------
+DROP TABLE IF EXISTS `token_holds`;
+CREATE TABLE `token_holds`  (
+                             `id` bigint NOT NULL AUTO_INCREMENT,
+                             `hold_uuid` varchar(36) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL,
+                             `user_id` bigint NOT NULL,
+                             `amount` bigint NULL DEFAULT NULL,
+                             `settled_amount` bigint NULL DEFAULT NULL,
+                             `transaction_uuid` varchar(36) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `status` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT 'active',
+                             `related_entity_type` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `related_entity_id` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL,
+                             `description` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `expires_at` bigint NULL DEFAULT NULL,
+                             `created_at` bigint NULL DEFAULT NULL,
+                             `updated_at` bigint NULL DEFAULT NULL,
+                             PRIMARY KEY (`id`) USING BTREE,
+                             UNIQUE INDEX `idx_token_holds_hold_uuid`(`hold_uuid` ASC) USING BTREE,
+                             INDEX `idx_token_holds_user_status`(`user_id` ASC, `status` ASC) USING BTREE,
+                             INDEX `idx_token_holds_expires_at`(`expires_at` ASC) USING BTREE
+) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for versions
 -- ----------------------------
@@ -119,13 +470,24 @@ CREATE TABLE `versions`  (
                              `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
                              `is_ai_generated` tinyint(1) NULL DEFAULT NULL,
                              `ai_style` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL,
+                             `style_preset_id` bigint NULL DEFAULT 0,
                              `word_limit` bigint NULL DEFAULT NULL,
                              `tokens_used` bigint NULL DEFAULT NULL,
+                             `restored_from_version` bigint NULL DEFAULT 0,
+                             `parent_id` bigint NULL DEFAULT 0,
+                             `merge_parent_id` bigint NULL DEFAULT 0,
+                             `branch_name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT 'main',
+                             `pinned` tinyint(1) NULL DEFAULT 0,
+                             `storage_type` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT 'full',
+                             `delta_base_id` bigint NULL DEFAULT 0,
+                             `delta` longblob NULL,
+                             `delta_depth` bigint NULL DEFAULT 0,
                              `created_at` bigint NULL DEFAULT NULL, -- 改为bigint
                              PRIMARY KEY (`id`) USING BTREE,
                              UNIQUE INDEX `unique_outline_version`(`outline_id` ASC, `version_number` ASC) USING BTREE,
                              INDEX `idx_outline_id`(`outline_id` ASC) USING BTREE,
-                             INDEX `idx_versions_outline_id`(`outline_id` ASC) USING BTREE
+                             INDEX `idx_versions_outline_id`(`outline_id` ASC) USING BTREE,
+                             INDEX `idx_versions_parent_id`(`parent_id` ASC) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
 -- 其他表（options/packages/subscriptions等）的时间字段已默认是bigint类型，无需修改
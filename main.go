@@ -52,6 +52,7 @@ var buildFS embed.FS
 var indexPage []byte
 
 func main() {
+	common.ParseFlags()
 	common.SetupGinLog()
 	common.SysLog("Gin Template " + common.Version + " started")
 	if os.Getenv("GIN_MODE") != "debug" {
@@ -77,12 +78,21 @@ func main() {
 		common.FatalLog(err1)
 	}
 	InitTokenService()
+	service.InitVersionRetentionService(repository.NewOutlineRepository(model.DB))
+	service.InitSearchIndexService(repository.NewSearchRepository(model.DB), repository.NewOutlineRepository(model.DB))
+	service.InitAICandidateCleanupService(repository.NewAICandidateRepository(model.DB))
+	service.InitPromptTemplates(repository.NewPromptTemplateRepository(model.DB))
+	service.SetModerationService(service.NewModerationService(repository.NewModerationRepository(model.DB), repository.NewPromptTemplateRepository(model.DB)))
 
 	// Initialize Redis
 	err = common.InitRedisClient()
 	if err != nil {
 		common.FatalLog(err)
 	}
+	// 草稿按是否启用Redis选择存储位置，需在Redis初始化之后启动
+	outlineService := newBackgroundOutlineService()
+	service.InitDraftPromotionService(outlineService)
+	service.InitOutlineSummaryService(outlineService)
 
 	// Initialize options
 	model.InitOptionMap()
@@ -115,7 +125,20 @@ func main() {
 	InitTask(scheduler)
 	//scheduler.Start()
 
-	//agent.InitAgent()
+	// 异步AI任务调度器，启动时继续执行进程退出前中断的任务
+	jobQueue, err3 := task.NewDBJobQueue(model.DB)
+	if err3 != nil {
+		common.FatalLog(err3)
+	}
+	jobScheduler := task.NewDBJobScheduler(jobQueue, common.AIJobWorkers, time.Duration(common.AIJobPollInterval)*time.Second)
+	bibleService, err4 := InitializeStoryBibleService(model.DB)
+	if err4 != nil {
+		common.FatalLog(err4)
+	}
+	InitAIJobs(jobScheduler, outlineService, bibleService)
+	jobScheduler.Start()
+
+	//agent.InitAgent(repository.NewPromptTemplateRepository(model.DB))
 
 	router.SetRouter(server, buildFS, indexPage, controllers)
 	var port = os.Getenv("PORT")
@@ -135,9 +158,32 @@ func InitTask(scheduler *task.DBScheduler) {
 	scheduler.RegisterHandler(task.TokenDebitCompensationTask, task2.CompensationTokenDebit)
 }
 
+// InitAIJobs 注册异步AI任务的处理器
+func InitAIJobs(scheduler *task.DBJobScheduler, outlineService *service.OutlineService, bibleService *service.StoryBibleService) {
+	scheduler.RegisterHandler(task.OutlineGenerationJob, task2.OutlineGenerationJob(outlineService))
+	scheduler.RegisterHandler(task.AgentChatJob, task2.AgentChatJob(bibleService))
+}
+
 func InitTokenService() {
 	tokenRepository := repository.NewTokenRepository(model.DB)
 	tokenService := service.NewTokenService(tokenRepository)
 	service.SetTokenService(tokenService)
+	service.SetPricingService(service.NewPricingService(repository.NewPackageRepository(model.DB)))
 	service.InitReconciliationService(tokenRepository, repository.NewTokenReconciliationRepository(model.DB))
 }
+
+// newBackgroundOutlineService 创建后台任务使用的大纲服务
+func newBackgroundOutlineService() *service.OutlineService {
+	return service.NewOutlineService(
+		repository.NewTokenRepository(model.DB),
+		repository.NewTokenReconciliationRepository(model.DB),
+		repository.NewOutlineRepository(model.DB),
+		repository.NewOutlineDraftRepository(model.DB),
+		repository.NewStylePresetRepository(model.DB),
+		repository.NewPromptTemplateRepository(model.DB),
+		repository.NewAICandidateRepository(model.DB),
+		repository.NewOutlineSummaryRepository(model.DB),
+		repository.NewStoryBibleRepository(model.DB),
+		repository.NewContinuityReportRepository(model.DB),
+	)
+}
@@ -0,0 +1,40 @@
+package model
+
+// AIJobStatus 异步AI任务的状态
+type AIJobStatus string
+
+const (
+	AIJobQueued    AIJobStatus = "queued"
+	AIJobRunning   AIJobStatus = "running"
+	AIJobSucceeded AIJobStatus = "succeeded"
+	AIJobFailed    AIJobStatus = "failed"
+	AIJobCancelled AIJobStatus = "cancelled"
+)
+
+// AIJob 异步AI任务，提交后由任务调度器的工作协程执行，进程重启后中断的任务重新排队
+type AIJob struct {
+	JobID     string `json:"job_id" gorm:"primaryKey;size:36"`
+	UserId    int64  `json:"user_id" gorm:"index"`
+	ProjectId int64  `json:"project_id"` // 不属于项目的任务为0
+	JobType   string `json:"job_type" gorm:"size:50;not null"`
+	// 任务参数，存储为JSON
+	Payload string      `json:"payload" gorm:"type:text;not null"`
+	Status  AIJobStatus `json:"status" gorm:"size:20;index"`
+	// 成功时的结果，存储为JSON
+	Result    string `json:"result" gorm:"type:text"`
+	LastError string `json:"last_error" gorm:"type:text"`
+	// 执行次数，进程重启中断的任务重新执行时加1，达到MaxAttempts后不再重试
+	Attempts        int   `json:"attempts" gorm:"default:0"`
+	MaxAttempts     int   `json:"max_attempts" gorm:"default:3"`
+	CancelRequested bool  `json:"cancel_requested"` // 执行中的任务被取消，由执行任务的工作协程中断
+	HeartbeatAt     int64 `json:"heartbeat_at"`     // 执行中的任务最近一次心跳时间，长时间没有心跳时视为中断
+	// 生成、扣费和保存结果的进度，进程中断后重新执行时不再重复调用AI、扣费和保存
+	Generated     string `json:"generated" gorm:"type:text"` // 扣费前记录的生成结果，JSON，为空时尚未生成
+	SettledAt     int64  `json:"settled_at"`                 // 扣费完成的时间，为0时尚未扣费
+	SettledAmount int64  `json:"settled_amount"`             // 已扣除的Token
+	SavedAt       int64  `json:"saved_at"`                   // 结果保存完成的时间，此时Result为保存后的结果
+	StartedAt     int64  `json:"started_at"`
+	FinishedAt    int64  `json:"finished_at"`
+	CreatedAt     int64  `json:"created_at" gorm:"index"`
+	UpdatedAt     int64  `json:"updated_at"`
+}
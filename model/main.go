@@ -82,6 +82,62 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&OutlineBranch{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&OutlineNode{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&OutlineDraft{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&AICandidate{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&OutlineSummary{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ContinuityReport{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&StoryEntity{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&StoryRelation{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&SearchDocument{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&SearchPosting{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&TokenHold{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&StylePreset{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&PromptTemplate{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&PromptTemplateVersion{})
+		if err != nil {
+			return err
+		}
 		err = db.AutoMigrate(&Referral{})
 		if err != nil {
 			return err
@@ -90,7 +146,23 @@ func InitDB() (err error) {
 		if err != nil {
 			return err
 		}
+		err = db.AutoMigrate(&ModerationWord{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&ModerationRecord{})
+		if err != nil {
+			return err
+		}
+		err = db.AutoMigrate(&AIJob{})
+		if err != nil {
+			return err
+		}
 		err = createRootAccountIfNeed()
+		if err != nil {
+			return err
+		}
+		err = createStylePresetsIfNeed()
 		return err
 	} else {
 		common.FatalLog(err)
@@ -0,0 +1,34 @@
+package model
+
+// ModerationWord 管理员维护的敏感词，IsRegex为true时Word为正则表达式
+type ModerationWord struct {
+	Id       int64  `json:"id"`
+	Word     string `json:"word" gorm:"type:varchar(255);uniqueIndex"`
+	IsRegex  bool   `json:"is_regex"`
+	Category string `json:"category" gorm:"type:varchar(50)"` // 分类，如政治、色情、广告，仅用于展示和统计
+	// 处理方式：block拦截，mask打码，flag放行并记录待复核
+	Action    string `json:"action" gorm:"type:varchar(20)"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// ModerationRecord 内容审核记录，标记待复核的内容进入审核队列，被拦截的内容也会记录以便追溯
+type ModerationRecord struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id" gorm:"index"`
+	ProjectId int64  `json:"project_id"`
+	Stage     string `json:"stage" gorm:"type:varchar(20)"`  // prompt提示词，generation生成内容
+	Source    string `json:"source" gorm:"type:varchar(50)"` // 调用来源，如ai_prompt、outline_generation
+	Action    string `json:"action" gorm:"type:varchar(20)"` // 最终的处理方式
+	// 被审核的内容，超长时截断
+	Content string `json:"content" gorm:"type:text"`
+	// 命中列表，存储为JSON数组
+	Matches string `json:"matches" gorm:"type:text"`
+	// 状态：pending待复核，approved复核通过，rejected确认违规，blocked已拦截（不需要复核）
+	Status     string `json:"status" gorm:"type:varchar(20);index"`
+	ReviewerId int64  `json:"reviewer_id"`
+	ReviewNote string `json:"review_note" gorm:"type:varchar(255)"`
+	ReviewedAt int64  `json:"reviewed_at"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
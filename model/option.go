@@ -55,6 +55,44 @@ func InitOptionMap() {
 	common.OptionMap["openai_api_key"] = ""
 	common.OptionMap["openai_default_model"] = "gpt-3.5-turbo"
 	common.OptionMap["openai_api_base"] = "https://api.openai.com"
+	common.OptionMap["deepseek_api_key"] = ""
+	common.OptionMap["deepseek_default_model"] = "deepseek-chat"
+	common.OptionMap["deepseek_api_base"] = "https://api.deepseek.com/"
+	common.OptionMap["ark_api_key"] = ""
+	common.OptionMap["ark_default_model"] = ""
+	common.OptionMap["ark_api_base"] = "https://ark.cn-beijing.volces.com/api/v3"
+	// 模拟服务商，不访问网络，用于本地开发和测试
+	common.OptionMap["fake_default_model"] = "fake-model"
+	common.OptionMap["fake_latency_ms"] = "300"
+	common.OptionMap["fake_stream_interval_ms"] = "30"
+	common.OptionMap["fake_error"] = ""
+	common.OptionMap["fake_error_count"] = "0"
+	common.OptionMap["fake_script"] = `[{"source":"continuity_check","reply":"{\"issues\":[]}"},{"source":"moderation_classifier","reply":"{\"flagged\":false}"}]`
+	// 模型服务商选择，模型名按llm_model_providers映射到服务商，未匹配时使用llm_default_provider
+	common.OptionMap["llm_default_provider"] = common.LLMDefaultProvider
+	common.OptionMap["llm_model_providers"] = `{"deepseek-*":"deepseek","doubao-*":"ark","ep-*":"ark"}`
+	// 模型的上下文长度，续写时按此裁剪发送的大纲内容，未配置的模型按8192处理
+	common.OptionMap["llm_model_context_tokens"] = `{"gpt-3.5-turbo*":16385,"gpt-4o*":128000,"deepseek-*":65536}`
+	// 调用服务商的超时、重试和熔断，主服务商失败后按llm_fallback_chain依次尝试备用服务商
+	common.OptionMap["llm_request_timeout_seconds"] = "60"
+	common.OptionMap["llm_stream_idle_timeout_seconds"] = "30"
+	common.OptionMap["llm_max_retries"] = "2"
+	common.OptionMap["llm_breaker_failures"] = "5"
+	common.OptionMap["llm_breaker_cooldown_seconds"] = "30"
+	common.OptionMap["llm_fallback_chain"] = "[]"
+	// 相同请求的响应缓存，启用Redis时缓存在Redis中，否则缓存在内存中
+	common.OptionMap["llm_cache_ttl_seconds"] = "600"
+	common.OptionMap["llm_cache_size"] = "1000"
+	// AI调用的计费规则和套餐折扣，没有匹配规则的模型按用量一比一扣费
+	common.OptionMap["ai_model_prices"] = "{}"
+	common.OptionMap["ai_package_discounts"] = "{}"
+	common.OptionMap["ai_cache_hit_rate"] = "0"
+	common.OptionMap["ai_agent_hold"] = "20000"
+	// 内容审核，敏感词在管理后台维护，模型分类默认关闭
+	common.OptionMap["moderation_enabled"] = "true"
+	common.OptionMap["moderation_llm_stages"] = ""
+	common.OptionMap["moderation_llm_model"] = ""
+	common.OptionMap["moderation_llm_action"] = "flag"
 	common.OptionMapRWMutex.Unlock()
 	options, _ := AllOption()
 	for _, option := range options {
@@ -1,11 +1,15 @@
 package model
 
+// DefaultBranchName 默认分支名
+const DefaultBranchName = "main"
+
 // Outline 大纲模型
 type Outline struct {
 	Id             int64  `json:"id"`
 	ProjectId      int64  `json:"project_id" gorm:"index"`
 	Content        string `json:"content" gorm:"type:text"`
 	CurrentVersion int    `json:"current_version"`
+	CurrentBranch  string `json:"current_branch" gorm:"type:varchar(50);default:'main'"`
 	CreatedAt      int64  `json:"created_at"`
 	UpdatedAt      int64  `json:"updated_at"`
 }
@@ -13,12 +17,144 @@ type Outline struct {
 // Version 版本历史模型
 type Version struct {
 	Id            int64  `json:"id"`
-	OutlineId     int64  `json:"outline_id" gorm:"index"`
-	VersionNumber int    `json:"version_number"`
+	OutlineId     int64  `json:"outline_id" gorm:"index;uniqueIndex:unique_outline_version"`
+	VersionNumber int    `json:"version_number" gorm:"uniqueIndex:unique_outline_version"`
 	Content       string `json:"content" gorm:"type:text"`
 	IsAiGenerated bool   `json:"is_ai_generated"`
-	AiStyle       string `json:"ai_style"`
-	WordLimit     int    `json:"word_limit"`
-	TokensUsed    int    `json:"tokens_used"`
+	// 旧版本保存的风格文本，新的AI版本只记录StylePresetId
+	AiStyle string `json:"ai_style"`
+	// 续写使用的风格预设ID，0表示未使用预设
+	StylePresetId int64 `json:"style_preset_id"`
+	WordLimit     int   `json:"word_limit"`
+	TokensUsed    int   `json:"tokens_used"`
+	// 从哪个版本恢复而来，0表示不是恢复产生的版本
+	RestoredFromVersion int `json:"restored_from_version"`
+	// 父版本ID，0表示分支上的第一个版本
+	ParentId int64 `json:"parent_id" gorm:"index"`
+	// 合并产生的版本记录被合并分支的头版本ID
+	MergeParentId int64  `json:"merge_parent_id"`
+	BranchName    string `json:"branch_name" gorm:"type:varchar(50);default:'main'"`
+	// 手动固定的版本，不会被保留策略清理
+	Pinned bool `json:"pinned"`
+	// 内容存储方式，差异存储时Content为空，内容由DeltaBaseId版本的内容加上Delta还原
+	StorageType string `json:"-" gorm:"type:varchar(10);default:'full'"`
+	DeltaBaseId int64  `json:"-"`
+	Delta       []byte `json:"-"`
+	// 距最近一个完整快照的差异层数
+	DeltaDepth int   `json:"-"`
+	CreatedAt  int64 `json:"created_at"`
+}
+
+// 版本内容存储方式
+const (
+	VersionStorageFull  = "full"
+	VersionStorageDelta = "delta"
+)
+
+// OutlineBranch 大纲分支模型，每个分支指向自己的头版本
+type OutlineBranch struct {
+	Id            int64  `json:"id"`
+	OutlineId     int64  `json:"outline_id" gorm:"index"`
+	Name          string `json:"name" gorm:"type:varchar(50)"`
+	HeadVersionId int64  `json:"head_version_id"`
+	BaseVersionId int64  `json:"base_version_id"` // 创建分支时所基于的版本ID
 	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
+}
+
+// OutlineNode 大纲结构节点（卷、章、情节点等），由大纲内容解析而来
+// 标题行原文为 Marker + Title，节点按 SortOrder 在同级节点中排序
+type OutlineNode struct {
+	Id        int64  `json:"id"`
+	OutlineId int64  `json:"outline_id" gorm:"index"`
+	ParentId  int64  `json:"parent_id" gorm:"index"`
+	Kind      string `json:"kind" gorm:"type:varchar(20)"`
+	Level     int    `json:"level"`
+	Marker    string `json:"marker"`
+	Title     string `json:"title"`
+	Body      string `json:"body" gorm:"type:text"`
+	SortOrder int    `json:"sort_order"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// OutlineDraft 大纲自动保存的草稿，每个大纲最多一份，提交后才成为正式版本
+// 启用Redis时草稿保存在Redis中，不使用该表
+type OutlineDraft struct {
+	Id        int64  `json:"id"`
+	ProjectId int64  `json:"project_id" gorm:"uniqueIndex"`
+	Content   string `json:"content" gorm:"type:text"`
+	// 草稿所基于的大纲版本号和分支
+	BaseVersion int    `json:"base_version"`
+	Branch      string `json:"branch" gorm:"type:varchar(50)"`
+	// 每次自动保存递增，用于判断草稿在读取后是否被更新
+	Revision int64 `json:"revision"`
+	// 空闲提交时与大纲的新修改冲突，需要用户手动处理
+	Conflict  bool  `json:"conflict"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at" gorm:"index"`
+}
+
+// AICandidate 一次续写请求生成的候选结果，采纳前不产生版本
+// 同一次请求的候选共用BatchId，采纳其中一个后其余候选标记为未采纳，所有候选到期后删除
+type AICandidate struct {
+	Id        int64  `json:"id"`
+	BatchId   string `json:"batch_id" gorm:"type:varchar(36);index"`
+	UserId    int64  `json:"user_id"`
+	ProjectId int64  `json:"project_id" gorm:"index"`
+	// 候选在本次请求中的序号，从1开始
+	Seq int `json:"seq"`
+	// 续写生成的内容
+	Content string `json:"content" gorm:"type:text"`
+	// 续写内容合入后的完整大纲，采纳时基于BaseVersion保存，与之后的修改自动合并
+	OutlineContent string `json:"-" gorm:"type:text"`
+	BaseVersion    int    `json:"base_version"`
+	NodeId         int64  `json:"node_id"`
+	StylePresetId  int64  `json:"style_preset_id"`
+	WordLimit      int    `json:"word_limit"`
+	TokensUsed     int    `json:"tokens_used"`
+	Model          string `json:"model" gorm:"type:varchar(100)"`
+	Status         string `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	// 采纳后生成的版本号
+	AcceptedVersion int   `json:"accepted_version"`
+	ExpiresAt       int64 `json:"expires_at" gorm:"index"`
+	CreatedAt       int64 `json:"created_at"`
+	UpdatedAt       int64 `json:"updated_at"`
+}
+
+// OutlineSummary 大纲的滚动摘要，续写长大纲时代替较早的内容发送给模型
+// Level为0的是整份大纲的概要，其余按大纲结构逐层对应一个节点，Path为从顶层到该节点的标题路径
+type OutlineSummary struct {
+	Id        int64  `json:"id"`
+	ProjectId int64  `json:"project_id" gorm:"uniqueIndex:idx_outline_summary_path"`
+	Path      string `json:"path" gorm:"type:varchar(500);uniqueIndex:idx_outline_summary_path"`
+	Level     int    `json:"level"`
+	Title     string `json:"title"`
+	Summary   string `json:"summary" gorm:"type:text"`
+	// 生成摘要时原文的哈希，原文变化后重新生成
+	SourceHash string `json:"-" gorm:"type:varchar(40)"`
+	// 最近一次刷新时的大纲版本号，只对概要有效
+	SourceVersion int `json:"source_version"`
+	// 作者修改过的摘要不会被自动覆盖，原文变化后标记为过时
+	Edited    bool  `json:"edited"`
+	Stale     bool  `json:"stale"`
+	SortOrder int   `json:"sort_order"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// ContinuityReport 版本的连贯性检查结果，对比该版本新增的内容与父版本的内容
+// 版本内容不会改变，检查结果按版本缓存，再次查看时不重复计费
+type ContinuityReport struct {
+	Id            int64 `json:"id"`
+	ProjectId     int64 `json:"project_id" gorm:"uniqueIndex:idx_continuity_report_version"`
+	VersionNumber int   `json:"version_number" gorm:"uniqueIndex:idx_continuity_report_version"`
+	// 对比的父版本号
+	BaseVersion int `json:"base_version"`
+	// 疑似矛盾列表，存储为JSON数组
+	Issues     string `json:"issues" gorm:"type:text"`
+	TokensUsed int    `json:"tokens_used"`
+	Model      string `json:"model" gorm:"type:varchar(100)"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
 }
@@ -0,0 +1,53 @@
+package model
+
+import "encoding/json"
+
+// PromptTemplate 提示词模板，按名称引用，ActiveVersion指向当前生效的版本
+type PromptTemplate struct {
+	Id            int64  `json:"id"`
+	Name          string `json:"name" gorm:"type:varchar(100);uniqueIndex"`
+	Description   string `json:"description" gorm:"type:varchar(255)"`
+	ActiveVersion int    `json:"active_version"`
+	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
+}
+
+// PromptTemplateVersion 提示词模板的一个版本，创建后不再修改
+type PromptTemplateVersion struct {
+	Id         int64  `json:"id"`
+	TemplateId int64  `json:"template_id" gorm:"uniqueIndex:idx_prompt_template_version"`
+	Version    int    `json:"version" gorm:"uniqueIndex:idx_prompt_template_version"`
+	Content    string `json:"content" gorm:"type:text"`
+	// 模板变量，存储为JSON数组
+	Variables string `json:"variables" gorm:"type:text"`
+	Comment   string `json:"comment" gorm:"type:varchar(255)"`
+	CreatedBy int64  `json:"created_by"` // 0表示系统内置的默认版本
+	CreatedAt int64  `json:"created_at"`
+}
+
+// PromptVariable 模板变量，渲染时未传入的变量使用默认值
+type PromptVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+	Required    bool   `json:"required"`
+}
+
+// VariableList 解析模板变量
+func (v *PromptTemplateVersion) VariableList() []PromptVariable {
+	var variables []PromptVariable
+	if v.Variables != "" {
+		_ = json.Unmarshal([]byte(v.Variables), &variables)
+	}
+	return variables
+}
+
+// SetVariables 保存模板变量
+func (v *PromptTemplateVersion) SetVariables(variables []PromptVariable) {
+	if len(variables) == 0 {
+		v.Variables = ""
+		return
+	}
+	data, _ := json.Marshal(variables)
+	v.Variables = string(data)
+}
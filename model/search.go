@@ -0,0 +1,34 @@
+package model
+
+// 搜索文档类型
+const (
+	SearchDocProject = "project" // 项目标题和简介
+	SearchDocOutline = "outline" // 大纲当前内容
+	SearchDocVersion = "version" // 历史版本相对父版本新增的内容
+)
+
+// SearchDocument 全文搜索的索引文档，由后台任务根据项目、大纲和历史版本生成
+type SearchDocument struct {
+	Id        int64  `json:"id"`
+	UserId    int64  `json:"user_id" gorm:"index"`
+	ProjectId int64  `json:"project_id" gorm:"index"`
+	DocType   string `json:"doc_type" gorm:"type:varchar(20);uniqueIndex:idx_search_source"`
+	// 来源记录ID：项目ID、大纲ID或版本ID
+	SourceId      int64  `json:"source_id" gorm:"uniqueIndex:idx_search_source"`
+	VersionNumber int    `json:"version_number"`
+	IsAiGenerated bool   `json:"is_ai_generated"`
+	BranchName    string `json:"branch_name" gorm:"type:varchar(50)"`
+	Title         string `json:"title"`
+	Content       string `json:"content" gorm:"type:text"`
+	// 来源记录被索引时的更新时间，来源更新后重新索引
+	SourceUpdatedAt int64 `json:"source_updated_at"`
+	CreatedAt       int64 `json:"created_at"`
+	UpdatedAt       int64 `json:"updated_at"`
+}
+
+// SearchPosting n-gram倒排索引，按用户划分，查询只扫描当前用户的索引
+type SearchPosting struct {
+	UserId     int64  `gorm:"primaryKey;autoIncrement:false"`
+	Gram       string `gorm:"type:varchar(32);primaryKey"`
+	DocumentId int64  `gorm:"primaryKey;autoIncrement:false;index"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import "encoding/json"
+
+// StoryEntity 故事设定集中的条目：人物、地点、势力、物品或世界规则
+type StoryEntity struct {
+	Id        int64  `json:"id"`
+	ProjectId int64  `json:"project_id" gorm:"index"`
+	Type      string `json:"type" gorm:"type:varchar(20)"`
+	Name      string `json:"name" gorm:"type:varchar(100)"`
+	// 别名，存储为JSON字符串数组，正文中出现别名时同样视为提到该条目
+	Aliases     string `json:"aliases" gorm:"type:text"`
+	Description string `json:"description" gorm:"type:text"`
+	// 属性，如年龄、能力，存储为JSON数组以保持顺序
+	Attributes string `json:"attributes" gorm:"type:text"`
+	// 常驻的条目每次续写都会带上，适合没有固定名称的世界规则
+	Pinned    bool  `json:"pinned"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// StoryAttribute 设定条目的属性
+type StoryAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// AliasList 解析别名
+func (e *StoryEntity) AliasList() []string {
+	var aliases []string
+	if e.Aliases != "" {
+		_ = json.Unmarshal([]byte(e.Aliases), &aliases)
+	}
+	return aliases
+}
+
+// SetAliases 保存别名
+func (e *StoryEntity) SetAliases(aliases []string) {
+	if len(aliases) == 0 {
+		e.Aliases = ""
+		return
+	}
+	data, _ := json.Marshal(aliases)
+	e.Aliases = string(data)
+}
+
+// AttributeList 解析属性
+func (e *StoryEntity) AttributeList() []StoryAttribute {
+	var attributes []StoryAttribute
+	if e.Attributes != "" {
+		_ = json.Unmarshal([]byte(e.Attributes), &attributes)
+	}
+	return attributes
+}
+
+// SetAttributes 保存属性
+func (e *StoryEntity) SetAttributes(attributes []StoryAttribute) {
+	if len(attributes) == 0 {
+		e.Attributes = ""
+		return
+	}
+	data, _ := json.Marshal(attributes)
+	e.Attributes = string(data)
+}
+
+// StoryRelation 设定条目之间的关系，SourceId是TargetId的Type，如“张三是李四的师父”
+type StoryRelation struct {
+	Id          int64  `json:"id"`
+	ProjectId   int64  `json:"project_id" gorm:"index"`
+	SourceId    int64  `json:"source_id" gorm:"index"`
+	TargetId    int64  `json:"target_id" gorm:"index"`
+	Type        string `json:"type" gorm:"type:varchar(50)"`
+	Description string `json:"description" gorm:"type:varchar(500)"`
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
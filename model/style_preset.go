@@ -0,0 +1,134 @@
+package model
+
+import (
+	"encoding/json"
+	"gin-template/common"
+)
+
+// StylePreset 写作风格预设，UserId为0的是管理员维护的公共预设，否则是用户的私有预设
+type StylePreset struct {
+	Id     int64 `json:"id"`
+	UserId int64 `json:"user_id" gorm:"index"`
+	// 公共预设的标识，兼容按风格名称续写的旧请求，如scifi、xianxia
+	Code         string `json:"code" gorm:"type:varchar(50);index"`
+	Name         string `json:"name" gorm:"type:varchar(50)"`
+	Description  string `json:"description" gorm:"type:varchar(255)"`
+	SystemPrompt string `json:"system_prompt" gorm:"type:text"`
+	// 示例片段，存储为JSON字符串数组
+	Examples    string  `json:"examples" gorm:"type:text"`
+	Temperature float64 `json:"temperature"`
+	// 续写字数提示，请求未指定字数时使用默认值，超出范围时截断到范围内，0表示不限制
+	DefaultWordLimit int `json:"default_word_limit"`
+	MinWordLimit     int `json:"min_word_limit"`
+	MaxWordLimit     int `json:"max_word_limit"`
+	SortOrder        int `json:"sort_order"`
+	// 停用的预设不再出现在列表中，也不能用于续写，已使用它的历史版本不受影响
+	Enabled   bool  `json:"enabled"`
+	CreatedAt int64 `json:"created_at"`
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// ExampleList 解析示例片段
+func (p *StylePreset) ExampleList() []string {
+	var examples []string
+	if p.Examples != "" {
+		_ = json.Unmarshal([]byte(p.Examples), &examples)
+	}
+	return examples
+}
+
+// SetExamples 保存示例片段
+func (p *StylePreset) SetExamples(examples []string) {
+	if len(examples) == 0 {
+		p.Examples = ""
+		return
+	}
+	data, _ := json.Marshal(examples)
+	p.Examples = string(data)
+}
+
+// defaultStylePresets 首次启动时创建的公共风格预设
+var defaultStylePresets = []StylePreset{
+	{
+		Code:         "scifi",
+		Name:         "硬核科幻",
+		Description:  "注重科学设定的严谨性和推演，技术细节可信",
+		SystemPrompt: "请以硬核科幻的风格续写。设定要符合已知的物理规律或给出自洽的推演，科技、社会和人物的变化要有因果链条；情节推进依靠设定本身带来的冲突，避免用未解释的超能力解决问题。",
+		Examples: `["飞船在拉格朗日点悬停了第三十七天，反应堆的中子通量仍在缓慢爬升。林舟知道，如果三天内找不到冷却剂泄漏的位置，他们就只能选择弃船。",` +
+			`"第二章 相对论的代价：返航的舰队发现地球已过去两百年，舰长必须决定是接受新秩序，还是用舰上唯一的反物质储备换取谈判筹码。"]`,
+		Temperature:      0.6,
+		DefaultWordLimit: 800,
+		MinWordLimit:     300,
+		MaxWordLimit:     3000,
+		SortOrder:        1,
+	},
+	{
+		Code:         "xianxia",
+		Name:         "古典仙侠",
+		Description:  "古风叙事，讲究意境与修行体系",
+		SystemPrompt: "请以古典仙侠的风格续写。语言典雅，多用古风词汇和意象；修行境界、宗门势力和法宝功法的设定要前后一致；情节兼顾问道修心与恩怨情仇。",
+		Examples: `["青云山下，少年背着半截断剑拾级而上。山门前的老道看了他一眼，只说了一句：剑断了，心可曾断？",` +
+			`"第三卷 问心：主角筑基失败，道心蒙尘，下山入世历练，于红尘中勘破执念，重修无情道。"]`,
+		Temperature:      0.8,
+		DefaultWordLimit: 1000,
+		MinWordLimit:     300,
+		MaxWordLimit:     3000,
+		SortOrder:        2,
+	},
+	{
+		Code:         "urban",
+		Name:         "都市言情",
+		Description:  "现代都市背景，侧重人物情感与关系发展",
+		SystemPrompt: "请以都市言情的风格续写。背景贴近现代都市生活，人物对话自然生动；感情线循序渐进，误会与和解要有合理动机；适当穿插职场、家庭等现实元素。",
+		Examples: `["加班到凌晨的写字楼只剩她这一盏灯。电梯门打开时，她没想到会再遇见那个三年前不告而别的人。",` +
+			`"第五章 合约到期：女主决定不再续约，男主第一次放下骄傲，在发布会后台拦住了她。"]`,
+		Temperature:      0.8,
+		DefaultWordLimit: 800,
+		MinWordLimit:     200,
+		MaxWordLimit:     2500,
+		SortOrder:        3,
+	},
+	{
+		Code:             "fantasy",
+		Name:             "东方玄幻",
+		Description:      "宏大的世界观与升级体系，节奏明快",
+		SystemPrompt:     "请以东方玄幻的风格续写。世界观宏大，力量体系层次分明；主角成长线清晰，每个阶段都有明确的目标和对手；节奏明快，爽点与伏笔交替出现。",
+		Examples:         `["第一卷 觉醒：边陲小镇的少年在血脉觉醒仪式上被判定为废脉，却在当夜听到了祖传玉佩中的声音。"]`,
+		Temperature:      0.9,
+		DefaultWordLimit: 1000,
+		MinWordLimit:     300,
+		MaxWordLimit:     3000,
+		SortOrder:        4,
+	},
+	{
+		Code:             "history",
+		Name:             "历史架空",
+		Description:      "以历史为底色，考究的制度与人物群像",
+		SystemPrompt:     "请以历史架空的风格续写。官制、军制、经济等设定参考真实历史并保持考究；人物行为符合时代背景，避免现代观念的生硬代入；重视群像刻画和权谋博弈。",
+		Examples:         `["第二章 户部亏空：新任侍郎查账时发现三年的漕粮对不上数，而账册上的每一个签押都指向当朝首辅的门生。"]`,
+		Temperature:      0.7,
+		DefaultWordLimit: 1000,
+		MinWordLimit:     300,
+		MaxWordLimit:     3000,
+		SortOrder:        5,
+	},
+}
+
+// createStylePresetsIfNeed 没有公共风格预设时创建默认预设
+func createStylePresetsIfNeed() error {
+	var count int64
+	if err := DB.Model(&StylePreset{}).Where("user_id = ?", 0).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	common.SysLog("no style preset exists, create default style presets")
+	for _, preset := range defaultStylePresets {
+		preset.Enabled = true
+		if err := DB.Create(&preset).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -32,3 +32,24 @@ type TokenTransaction struct {
 func (TokenTransaction) TableName() string {
 	return "token_transactions"
 }
+
+// TokenHold 代表AI调用前的Token预扣记录，预扣中的金额不计入可用余额
+type TokenHold struct {
+	ID                int64  `gorm:"primaryKey;autoIncrement"`
+	HoldUUID          string `gorm:"type:varchar(36);uniqueIndex;not null"` // 用于幂等性检查
+	UserID            int64  `gorm:"index:idx_token_holds_user_status;not null"`
+	Amount            int64  // 预扣金额
+	SettledAmount     int64  // 结算时实际扣减的金额，按实际用量扣减，可能超过预扣金额
+	TransactionUUID   string `gorm:"type:varchar(36)"`                                                    // 结算产生的交易流水
+	Status            string `gorm:"type:varchar(20);index:idx_token_holds_user_status;default:'active'"` // "active", "settled", "released", "expired"
+	RelatedEntityType string `gorm:"type:varchar(50)"`
+	RelatedEntityID   string `gorm:"type:varchar(100)"`
+	Description       string `gorm:"type:text"`
+	ExpiresAt         int64  `gorm:"index"` // 过期后不再占用余额
+	CreatedAt         int64
+	UpdatedAt         int64
+}
+
+func (TokenHold) TableName() string {
+	return "token_holds"
+}
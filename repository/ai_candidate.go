@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"gin-template/define"
+	"gin-template/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// AICandidateRepository AI续写候选仓库
+type AICandidateRepository struct {
+	db *gorm.DB
+}
+
+// NewAICandidateRepository 创建AI续写候选仓库实例
+func NewAICandidateRepository(db *gorm.DB) *AICandidateRepository {
+	return &AICandidateRepository{db: db}
+}
+
+// CreateCandidates 保存一批候选
+func (r *AICandidateRepository) CreateCandidates(candidates []*model.AICandidate) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return r.db.Create(&candidates).Error
+}
+
+// GetCandidateById 获取候选
+func (r *AICandidateRepository) GetCandidateById(id int64) (*model.AICandidate, error) {
+	var candidate model.AICandidate
+	err := r.db.Where("id = ?", id).First(&candidate).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &candidate, err
+}
+
+// GetProjectCandidates 获取项目未到期的候选，新批次在前
+func (r *AICandidateRepository) GetProjectCandidates(projectId int64) ([]*model.AICandidate, error) {
+	var candidates []*model.AICandidate
+	err := r.db.Where("project_id = ? AND expires_at > ?", projectId, time.Now().Unix()).
+		Order("id desc").Find(&candidates).Error
+	return candidates, err
+}
+
+// ClaimCandidate 将待采纳且未到期的候选标记为已采纳，返回是否标记成功，用于防止同一候选被重复采纳
+func (r *AICandidateRepository) ClaimCandidate(id int64) (bool, error) {
+	result := r.db.Model(&model.AICandidate{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, define.AICandidateStatusPending, time.Now().Unix()).
+		Update("status", define.AICandidateStatusAccepted)
+	return result.RowsAffected == 1, result.Error
+}
+
+// UnclaimCandidate 采纳失败时恢复为待采纳
+func (r *AICandidateRepository) UnclaimCandidate(id int64) error {
+	return r.db.Model(&model.AICandidate{}).
+		Where("id = ? AND status = ?", id, define.AICandidateStatusAccepted).
+		Update("status", define.AICandidateStatusPending).Error
+}
+
+// FinishAccept 记录采纳生成的版本号，并将同一批次的其他待采纳候选标记为未采纳
+func (r *AICandidateRepository) FinishAccept(candidate *model.AICandidate, versionNumber int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(candidate).Update("accepted_version", versionNumber).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.AICandidate{}).
+			Where("batch_id = ? AND id <> ? AND status = ?", candidate.BatchId, candidate.Id, define.AICandidateStatusPending).
+			Update("status", define.AICandidateStatusRejected).Error
+	})
+}
+
+// DeleteExpiredCandidates 删除到期的候选，返回删除的数量；采纳的候选内容已保存为版本，未采纳的候选不再使用，都一并删除
+// 到期前刚被领取、还没有记录版本号的候选可能仍在采纳中，到期超过一小时后才删除
+func (r *AICandidateRepository) DeleteExpiredCandidates() (int64, error) {
+	now := time.Now().Unix()
+	result := r.db.Where("expires_at <= ? AND (status <> ? OR accepted_version > 0 OR expires_at <= ?)",
+		now, define.AICandidateStatusAccepted, now-3600).Delete(&model.AICandidate{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"gin-template/model"
+	"gorm.io/gorm"
+)
+
+// ContinuityReportRepository 连贯性检查结果仓库
+type ContinuityReportRepository struct {
+	db *gorm.DB
+}
+
+// NewContinuityReportRepository 创建连贯性检查结果仓库实例
+func NewContinuityReportRepository(db *gorm.DB) *ContinuityReportRepository {
+	return &ContinuityReportRepository{db: db}
+}
+
+// GetReport 获取版本的检查结果
+func (r *ContinuityReportRepository) GetReport(projectId int64, versionNumber int) (*model.ContinuityReport, error) {
+	var report model.ContinuityReport
+	err := r.db.Where("project_id = ? AND version_number = ?", projectId, versionNumber).First(&report).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &report, err
+}
+
+// SaveReport 保存检查结果，重新检查时覆盖同一版本的旧结果
+func (r *ContinuityReportRepository) SaveReport(report *model.ContinuityReport) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ? AND version_number = ?", report.ProjectId, report.VersionNumber).
+			Delete(&model.ContinuityReport{}).Error; err != nil {
+			return err
+		}
+		report.Id = 0
+		return tx.Create(report).Error
+	})
+}
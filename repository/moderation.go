@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"gin-template/model"
+	"gorm.io/gorm"
+)
+
+// ModerationRepository 内容审核仓库，包括敏感词和审核记录
+type ModerationRepository struct {
+	db *gorm.DB
+}
+
+// NewModerationRepository 创建内容审核仓库实例
+func NewModerationRepository(db *gorm.DB) *ModerationRepository {
+	return &ModerationRepository{db: db}
+}
+
+// GetAllWords 获取所有敏感词
+func (r *ModerationRepository) GetAllWords() ([]*model.ModerationWord, error) {
+	var words []*model.ModerationWord
+	err := r.db.Order("id asc").Find(&words).Error
+	return words, err
+}
+
+// GetWords 分页获取敏感词，keyword不为空时按包含的文字筛选
+func (r *ModerationRepository) GetWords(keyword string, offset, limit int) ([]*model.ModerationWord, int64, error) {
+	var words []*model.ModerationWord
+	var total int64
+	filter := func(db *gorm.DB) *gorm.DB {
+		if keyword != "" {
+			return db.Where("word LIKE ?", "%"+keyword+"%")
+		}
+		return db
+	}
+	if err := r.db.Model(&model.ModerationWord{}).Scopes(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := r.db.Scopes(filter).Order("id desc").Limit(limit).Offset(offset).Find(&words).Error; err != nil {
+		return nil, 0, err
+	}
+	return words, total, nil
+}
+
+// GetWordById 获取敏感词
+func (r *ModerationRepository) GetWordById(id int64) (*model.ModerationWord, error) {
+	var word model.ModerationWord
+	err := r.db.Where("id = ?", id).First(&word).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &word, err
+}
+
+// GetWordByText 按文字获取敏感词
+func (r *ModerationRepository) GetWordByText(text string) (*model.ModerationWord, error) {
+	var word model.ModerationWord
+	err := r.db.Where("word = ?", text).First(&word).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &word, err
+}
+
+// CreateWord 创建敏感词
+func (r *ModerationRepository) CreateWord(word *model.ModerationWord) error {
+	return r.db.Create(word).Error
+}
+
+// UpdateWord 更新敏感词
+func (r *ModerationRepository) UpdateWord(word *model.ModerationWord) error {
+	return r.db.Save(word).Error
+}
+
+// DeleteWord 删除敏感词
+func (r *ModerationRepository) DeleteWord(word *model.ModerationWord) error {
+	return r.db.Delete(word).Error
+}
+
+// CreateRecord 创建审核记录
+func (r *ModerationRepository) CreateRecord(record *model.ModerationRecord) error {
+	return r.db.Create(record).Error
+}
+
+// GetRecords 分页获取审核记录，新记录在前，status为空时返回所有状态
+func (r *ModerationRepository) GetRecords(status string, offset, limit int) ([]*model.ModerationRecord, int64, error) {
+	var records []*model.ModerationRecord
+	var total int64
+	filter := func(db *gorm.DB) *gorm.DB {
+		if status != "" {
+			return db.Where("status = ?", status)
+		}
+		return db
+	}
+	if err := r.db.Model(&model.ModerationRecord{}).Scopes(filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := r.db.Scopes(filter).Order("id desc").Limit(limit).Offset(offset).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+// GetRecordById 获取审核记录
+func (r *ModerationRepository) GetRecordById(id int64) (*model.ModerationRecord, error) {
+	var record model.ModerationRecord
+	err := r.db.Where("id = ?", id).First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &record, err
+}
+
+// UpdateRecord 更新审核记录
+func (r *ModerationRepository) UpdateRecord(record *model.ModerationRecord) error {
+	return r.db.Save(record).Error
+}
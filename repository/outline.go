@@ -1,8 +1,12 @@
 package repository
 
 import (
+	"errors"
 	"gin-template/model"
+	"gin-template/util"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 // OutlineRepository 提供大纲相关的数据库操作
@@ -30,58 +34,78 @@ func (r *OutlineRepository) GetOutlineByProjectId(projectId int64) (*model.Outli
 	return &outline, nil
 }
 
-// SaveOutline 保存大纲内容并创建新版本
-func (r *OutlineRepository) SaveOutline(projectId int64, content string, isAiGenerated bool, aiStyle string, wordLimit int, tokensUsed int) (*model.Outline, error) {
+// ErrOutlineVersionConflict 保存时大纲已不是请求所基于的版本
+var ErrOutlineVersionConflict = errors.New("大纲已被其他修改更新，请刷新后重试")
 
-	// 查找是否存在大纲
-	var outline model.Outline
-	result := r.DB.Where("project_id = ?", projectId).First(&outline)
-
-	if result.Error != nil {
-		if result.Error != gorm.ErrRecordNotFound {
-			return nil, result.Error
-		}
-
-		// 如果不存在，创建新大纲
-		outline = model.Outline{
-			ProjectId:      projectId,
-			Content:        content,
-			CurrentVersion: 1,
-		}
-
-		if err := r.DB.Create(&outline).Error; err != nil {
-			return nil, err
-		}
-	} else {
-		// 如果存在，更新大纲内容和版本
-		outline.Content = content
-		outline.CurrentVersion += 1
-
-		if err := r.DB.Save(&outline).Error; err != nil {
-			return nil, err
-		}
+// SaveOutline 保存大纲内容，并在当前分支上创建新版本
+func (r *OutlineRepository) SaveOutline(projectId int64, content string, isAiGenerated bool, stylePresetId int64, wordLimit int, tokensUsed int) (*model.Outline, error) {
+	version := model.Version{
+		Content:       content,
+		IsAiGenerated: isAiGenerated,
+		StylePresetId: stylePresetId,
+		WordLimit:     wordLimit,
+		TokensUsed:    tokensUsed,
 	}
+	return r.saveOutline(projectId, &version, -1)
+}
 
-	// 创建新版本记录
+// SaveOutlineFromBase 与SaveOutline相同，但只有大纲当前版本号仍为baseVersion时才保存，否则返回ErrOutlineVersionConflict
+// baseVersion为0表示请求基于一个还没有任何版本的大纲
+func (r *OutlineRepository) SaveOutlineFromBase(projectId int64, baseVersion int, content string, isAiGenerated bool, stylePresetId int64, wordLimit int, tokensUsed int) (*model.Outline, error) {
 	version := model.Version{
-		OutlineId:     outline.Id,
-		VersionNumber: outline.CurrentVersion,
 		Content:       content,
 		IsAiGenerated: isAiGenerated,
-		AiStyle:       aiStyle,
+		StylePresetId: stylePresetId,
 		WordLimit:     wordLimit,
 		TokensUsed:    tokensUsed,
 	}
+	return r.saveOutline(projectId, &version, baseVersion)
+}
+
+// saveOutline 在当前分支上追加版本，baseVersion小于0时不检查当前版本
+func (r *OutlineRepository) saveOutline(projectId int64, version *model.Version, baseVersion int) (*model.Outline, error) {
+	var outline model.Outline
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// 查找是否存在大纲
+		result := tx.Where("project_id = ?", projectId).First(&outline)
+		if result.Error != nil {
+			if result.Error != gorm.ErrRecordNotFound {
+				return result.Error
+			}
+
+			// 如果不存在，创建新大纲，版本在下面统一创建
+			outline = model.Outline{
+				ProjectId:     projectId,
+				Content:       version.Content,
+				CurrentBranch: model.DefaultBranchName,
+			}
+			if err := tx.Create(&outline).Error; err != nil {
+				return err
+			}
+		}
+		if baseVersion >= 0 && outline.CurrentVersion != baseVersion {
+			return ErrOutlineVersionConflict
+		}
+
+		if err := ensureBranches(tx, &outline); err != nil {
+			return err
+		}
+		branch, err := findBranch(tx, outline.Id, outline.CurrentBranch)
+		if err != nil {
+			return err
+		}
+		return appendVersion(tx, &outline, branch, version)
+	})
 
-	if err := r.DB.Create(&version).Error; err != nil {
+	if err != nil {
 		return nil, err
 	}
-
 	return &outline, nil
 }
 
-// GetVersionHistory 获取版本历史
-func (r *OutlineRepository) GetVersionHistory(projectId int64, limit int) ([]*model.Version, error) {
+// GetVersionHistory 获取版本历史，branch不为空时只返回该分支上创建的版本
+func (r *OutlineRepository) GetVersionHistory(projectId int64, branch string, limit int) ([]*model.Version, error) {
 	var outline model.Outline
 	var versions []*model.Version
 
@@ -95,10 +119,503 @@ func (r *OutlineRepository) GetVersionHistory(projectId int64, limit int) ([]*mo
 	}
 
 	// 获取版本历史，按版本号降序排列
-	err = r.DB.Where("outline_id = ?", outline.Id).
-		Order("version_number desc").
+	query := r.DB.Where("outline_id = ?", outline.Id)
+	if branch != "" {
+		query = query.Where("branch_name = ?", branch)
+	}
+	err = query.Order("version_number desc").
 		Limit(limit).
 		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 差异存储的版本还原为完整内容
+	err = loadVersionContents(r.DB, versions...)
+	return versions, err
+}
+
+// GetVersionByNumber 根据项目ID和版本号获取指定版本
+func (r *OutlineRepository) GetVersionByNumber(projectId int64, versionNumber int) (*model.Version, error) {
+	var outline model.Outline
+	err := r.DB.Where("project_id = ?", projectId).First(&outline).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var version model.Version
+	err = r.DB.Where("outline_id = ? AND version_number = ?", outline.Id, versionNumber).First(&version).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // 返回nil表示未找到记录
+		}
+		return nil, err
+	}
+	if err := loadVersionContents(r.DB, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// RestoreVersion 将指定版本的内容复制回大纲，并作为当前分支上的新版本保存
+// 新版本沿用原版本的AI元数据，并记录恢复来源
+func (r *OutlineRepository) RestoreVersion(projectId int64, versionNumber int) (*model.Outline, *model.Version, error) {
+	var outline model.Outline
+	var restored model.Version
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", projectId).First(&outline).Error; err != nil {
+			return err
+		}
+
+		var source model.Version
+		if err := tx.Where("outline_id = ? AND version_number = ?", outline.Id, versionNumber).First(&source).Error; err != nil {
+			return err
+		}
+		if err := loadVersionContents(tx, &source); err != nil {
+			return err
+		}
+
+		if err := ensureBranches(tx, &outline); err != nil {
+			return err
+		}
+		branch, err := findBranch(tx, outline.Id, outline.CurrentBranch)
+		if err != nil {
+			return err
+		}
+
+		restored = model.Version{
+			Content:             source.Content,
+			IsAiGenerated:       source.IsAiGenerated,
+			AiStyle:             source.AiStyle,
+			StylePresetId:       source.StylePresetId,
+			WordLimit:           source.WordLimit,
+			TokensUsed:          source.TokensUsed,
+			RestoredFromVersion: source.VersionNumber,
+		}
+		return appendVersion(tx, &outline, branch, &restored)
+	})
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, nil // 返回nil表示大纲或版本不存在
+		}
+		return nil, nil, err
+	}
+	return &outline, &restored, nil
+}
 
+// GetVersionById 根据版本ID获取版本
+func (r *OutlineRepository) GetVersionById(versionId int64) (*model.Version, error) {
+	var version model.Version
+	err := r.DB.Where("id = ?", versionId).First(&version).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // 返回nil表示未找到记录
+		}
+		return nil, err
+	}
+	if err := loadVersionContents(r.DB, &version); err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// GetVersionGraph 获取大纲所有版本的版本号、父子关系和元数据（不含内容），用于查找共同祖先和清理历史版本
+func (r *OutlineRepository) GetVersionGraph(outlineId int64) ([]*model.Version, error) {
+	var versions []*model.Version
+	err := r.DB.Select("id", "version_number", "parent_id", "merge_parent_id", "branch_name", "is_ai_generated", "pinned", "created_at").
+		Where("outline_id = ?", outlineId).
+		Find(&versions).Error
 	return versions, err
 }
+
+// GetBranches 获取项目大纲及其全部分支
+func (r *OutlineRepository) GetBranches(projectId int64) (*model.Outline, []*model.OutlineBranch, error) {
+	var outline model.Outline
+	var branches []*model.OutlineBranch
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", projectId).First(&outline).Error; err != nil {
+			return err
+		}
+		if err := ensureBranches(tx, &outline); err != nil {
+			return err
+		}
+		return tx.Where("outline_id = ?", outline.Id).Order("id asc").Find(&branches).Error
+	})
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, nil // 返回nil表示大纲不存在
+		}
+		return nil, nil, err
+	}
+	return &outline, branches, nil
+}
+
+// CreateBranch 基于指定版本创建新分支，versionNumber为0时基于当前分支的头版本
+func (r *OutlineRepository) CreateBranch(projectId int64, name string, versionNumber int) (*model.OutlineBranch, error) {
+	var branch model.OutlineBranch
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var outline model.Outline
+		if err := tx.Where("project_id = ?", projectId).First(&outline).Error; err != nil {
+			return err
+		}
+		if err := ensureBranches(tx, &outline); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.OutlineBranch{}).Where("outline_id = ? AND name = ?", outline.Id, name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("分支名已存在")
+		}
+
+		var baseVersionId int64
+		if versionNumber > 0 {
+			var source model.Version
+			if err := tx.Where("outline_id = ? AND version_number = ?", outline.Id, versionNumber).First(&source).Error; err != nil {
+				return err
+			}
+			baseVersionId = source.Id
+		} else {
+			current, err := findBranch(tx, outline.Id, outline.CurrentBranch)
+			if err != nil {
+				return err
+			}
+			baseVersionId = current.HeadVersionId
+		}
+
+		branch = model.OutlineBranch{
+			OutlineId:     outline.Id,
+			Name:          name,
+			HeadVersionId: baseVersionId,
+			BaseVersionId: baseVersionId,
+		}
+		return tx.Create(&branch).Error
+	})
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // 返回nil表示大纲或版本不存在
+		}
+		return nil, err
+	}
+	return &branch, nil
+}
+
+// SwitchBranch 切换当前分支，大纲内容替换为该分支头版本的内容
+func (r *OutlineRepository) SwitchBranch(projectId int64, name string) (*model.Outline, error) {
+	var outline model.Outline
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", projectId).First(&outline).Error; err != nil {
+			return err
+		}
+		if err := ensureBranches(tx, &outline); err != nil {
+			return err
+		}
+		branch, err := findBranch(tx, outline.Id, name)
+		if err != nil {
+			return err
+		}
+
+		outline.CurrentBranch = branch.Name
+		outline.Content = ""
+		outline.CurrentVersion = 0
+		if branch.HeadVersionId != 0 {
+			var head model.Version
+			if err := tx.Where("id = ?", branch.HeadVersionId).First(&head).Error; err != nil {
+				return err
+			}
+			if err := loadVersionContents(tx, &head); err != nil {
+				return err
+			}
+			outline.Content = head.Content
+			outline.CurrentVersion = head.VersionNumber
+		}
+		return tx.Save(&outline).Error
+	})
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // 返回nil表示大纲不存在
+		}
+		return nil, err
+	}
+	return &outline, nil
+}
+
+// DeleteBranch 删除分支，分支上的历史版本保留
+func (r *OutlineRepository) DeleteBranch(projectId int64, name string) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var outline model.Outline
+		if err := tx.Where("project_id = ?", projectId).First(&outline).Error; err != nil {
+			return err
+		}
+		if err := ensureBranches(tx, &outline); err != nil {
+			return err
+		}
+		if name == model.DefaultBranchName {
+			return errors.New("不能删除默认分支")
+		}
+		if name == outline.CurrentBranch {
+			return errors.New("不能删除当前所在的分支，请先切换到其他分支")
+		}
+		branch, err := findBranch(tx, outline.Id, name)
+		if err != nil {
+			return err
+		}
+		return tx.Delete(branch).Error
+	})
+
+	if err == gorm.ErrRecordNotFound {
+		return errors.New("大纲不存在")
+	}
+	return err
+}
+
+// MergeIntoBranch 在目标分支上创建合并版本
+// expectedHeadId 为计算合并结果时目标分支的头版本，若期间目标分支有新的保存则拒绝写入
+func (r *OutlineRepository) MergeIntoBranch(outlineId int64, target string, expectedHeadId int64, mergeParentId int64, content string) (*model.Outline, *model.Version, error) {
+	var outline model.Outline
+	var merged model.Version
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", outlineId).First(&outline).Error; err != nil {
+			return err
+		}
+		branch, err := findBranch(tx, outline.Id, target)
+		if err != nil {
+			return err
+		}
+		if branch.HeadVersionId != expectedHeadId {
+			return errors.New("目标分支已有新的修改，请重新合并")
+		}
+
+		merged = model.Version{
+			Content:       content,
+			MergeParentId: mergeParentId,
+		}
+		return appendVersion(tx, &outline, branch, &merged)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return &outline, &merged, nil
+}
+
+// ensureBranches 确保大纲至少有默认分支
+// 引入分支之前的旧数据没有分支记录，此时按版本号顺序把已有版本串联为默认分支
+func ensureBranches(tx *gorm.DB, outline *model.Outline) error {
+	var count int64
+	if err := tx.Model(&model.OutlineBranch{}).Where("outline_id = ?", outline.Id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var versions []*model.Version
+	err := tx.Select("id", "parent_id", "branch_name").
+		Where("outline_id = ?", outline.Id).
+		Order("version_number asc").
+		Find(&versions).Error
+	if err != nil {
+		return err
+	}
+
+	var parentId int64
+	for _, version := range versions {
+		if version.ParentId != parentId || version.BranchName != model.DefaultBranchName {
+			err := tx.Model(&model.Version{}).Where("id = ?", version.Id).Updates(map[string]interface{}{
+				"parent_id":   parentId,
+				"branch_name": model.DefaultBranchName,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		parentId = version.Id
+	}
+
+	branch := model.OutlineBranch{
+		OutlineId:     outline.Id,
+		Name:          model.DefaultBranchName,
+		HeadVersionId: parentId,
+	}
+	if err := tx.Create(&branch).Error; err != nil {
+		return err
+	}
+
+	if outline.CurrentBranch != model.DefaultBranchName {
+		outline.CurrentBranch = model.DefaultBranchName
+		return tx.Model(outline).Update("current_branch", model.DefaultBranchName).Error
+	}
+	return nil
+}
+
+// findBranch 按名称查找分支
+func findBranch(tx *gorm.DB, outlineId int64, name string) (*model.OutlineBranch, error) {
+	var branch model.OutlineBranch
+	err := tx.Where("outline_id = ? AND name = ?", outlineId, name).First(&branch).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("分支不存在")
+		}
+		return nil, err
+	}
+	return &branch, nil
+}
+
+// appendVersion 在分支头部追加新版本并移动分支头
+// 版本号在整个大纲内递增；若分支为当前分支，同步更新大纲内容和当前版本号
+// 先锁定大纲行，使同一大纲的并发保存串行分配版本号，(outline_id, version_number)唯一索引兜底
+func appendVersion(tx *gorm.DB, outline *model.Outline, branch *model.OutlineBranch, version *model.Version) error {
+	var locked model.Outline
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&locked, outline.Id).Error
+	if err != nil {
+		return err
+	}
+
+	var maxNumber int
+	err = tx.Model(&model.Version{}).
+		Where("outline_id = ?", outline.Id).
+		Select("COALESCE(MAX(version_number), 0)").
+		Scan(&maxNumber).Error
+	if err != nil {
+		return err
+	}
+
+	version.OutlineId = outline.Id
+	version.VersionNumber = maxNumber + 1
+	version.BranchName = branch.Name
+	version.ParentId = branch.HeadVersionId
+	if err := encodeVersionContent(tx, version); err != nil {
+		return err
+	}
+	// 差异存储时不写入完整内容，写入后恢复，调用方仍拿到完整内容
+	content := version.Content
+	if version.StorageType == model.VersionStorageDelta {
+		version.Content = ""
+	}
+	err = tx.Create(version).Error
+	version.Content = content
+	if err != nil {
+		return err
+	}
+
+	branch.HeadVersionId = version.Id
+	if err := tx.Save(branch).Error; err != nil {
+		return err
+	}
+
+	if branch.Name == outline.CurrentBranch {
+		// 以读取时的版本号为条件更新，并发事务已先行写入时放弃本次保存，避免互相覆盖
+		now := time.Now().Unix()
+		result := tx.Model(outline).
+			Where("current_version = ?", outline.CurrentVersion).
+			Updates(map[string]interface{}{
+				"content":         version.Content,
+				"current_version": version.VersionNumber,
+				"updated_at":      now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOutlineVersionConflict
+		}
+		outline.Content = version.Content
+		outline.CurrentVersion = version.VersionNumber
+		outline.UpdatedAt = now
+	}
+	return nil
+}
+
+// GetOutlineNodes 获取大纲的全部结构节点，按父节点和同级顺序排列
+func (r *OutlineRepository) GetOutlineNodes(outlineId int64) ([]*model.OutlineNode, error) {
+	var nodes []*model.OutlineNode
+	err := r.DB.Where("outline_id = ?", outlineId).
+		Order("parent_id asc, sort_order asc").
+		Find(&nodes).Error
+	return nodes, err
+}
+
+// SaveOutlineNodes 用节点树替换大纲的结构节点
+// 节点ID属于该大纲时原地更新，否则新建并回写ID；不再出现在树中的节点被删除
+func (r *OutlineRepository) SaveOutlineNodes(outlineId int64, roots []*util.OutlineSection) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var existingIds []int64
+		if err := tx.Model(&model.OutlineNode{}).Where("outline_id = ?", outlineId).Pluck("id", &existingIds).Error; err != nil {
+			return err
+		}
+		existing := make(map[int64]bool, len(existingIds))
+		for _, id := range existingIds {
+			existing[id] = true
+		}
+
+		kept := make(map[int64]bool)
+		var save func(sections []*util.OutlineSection, parentId int64) error
+		save = func(sections []*util.OutlineSection, parentId int64) error {
+			for i, section := range sections {
+				if section.Id != 0 && existing[section.Id] && !kept[section.Id] {
+					err := tx.Model(&model.OutlineNode{}).Where("id = ?", section.Id).Updates(map[string]interface{}{
+						"parent_id":  parentId,
+						"kind":       section.Kind,
+						"level":      section.Level,
+						"marker":     section.Marker,
+						"title":      section.Title,
+						"body":       section.Body,
+						"sort_order": i,
+					}).Error
+					if err != nil {
+						return err
+					}
+				} else {
+					node := model.OutlineNode{
+						OutlineId: outlineId,
+						ParentId:  parentId,
+						Kind:      section.Kind,
+						Level:     section.Level,
+						Marker:    section.Marker,
+						Title:     section.Title,
+						Body:      section.Body,
+						SortOrder: i,
+					}
+					if err := tx.Create(&node).Error; err != nil {
+						return err
+					}
+					section.Id = node.Id
+				}
+				kept[section.Id] = true
+				if err := save(section.Children, section.Id); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := save(roots, 0); err != nil {
+			return err
+		}
+
+		var staleIds []int64
+		for _, id := range existingIds {
+			if !kept[id] {
+				staleIds = append(staleIds, id)
+			}
+		}
+		if len(staleIds) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", staleIds).Delete(&model.OutlineNode{}).Error
+	})
+}
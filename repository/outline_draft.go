@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gin-template/common"
+	"gin-template/model"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+const (
+	// draftIdleKey 按最后自动保存时间排序的草稿集合，用于查找空闲的草稿
+	draftIdleKey = "outlineDraft:idle"
+	// draftTTL Redis中草稿的过期时间
+	draftTTL = 30 * 24 * time.Hour
+)
+
+// takeDraftScript 草稿修订号未变化时删除草稿
+var takeDraftScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'revision') ~= ARGV[1] then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+redis.call('ZREM', KEYS[2], ARGV[2])
+return 1
+`)
+
+// restoreDraftScript 没有新草稿时放回草稿，冲突的草稿不再参与空闲提交
+var restoreDraftScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'project_id', ARGV[1], 'content', ARGV[2], 'base_version', ARGV[3], 'branch', ARGV[4],
+	'revision', ARGV[5], 'conflict', ARGV[6], 'created_at', ARGV[7], 'updated_at', ARGV[8])
+redis.call('EXPIRE', KEYS[1], ARGV[9])
+if ARGV[6] == '0' then
+	redis.call('ZADD', KEYS[2], ARGV[8], ARGV[1])
+end
+return 1
+`)
+
+// OutlineDraftRepository 大纲草稿存储，启用Redis时保存在Redis中，否则保存在outline_drafts表
+type OutlineDraftRepository struct {
+	DB *gorm.DB
+}
+
+// NewOutlineDraftRepository 创建一个新的OutlineDraftRepository实例
+func NewOutlineDraftRepository(db *gorm.DB) *OutlineDraftRepository {
+	return &OutlineDraftRepository{
+		DB: db,
+	}
+}
+
+func draftKey(projectId int64) string {
+	return fmt.Sprintf("outlineDraft:%d", projectId)
+}
+
+// GetDraft 获取项目大纲的草稿，没有草稿时返回nil
+func (r *OutlineDraftRepository) GetDraft(projectId int64) (*model.OutlineDraft, error) {
+	if common.RedisEnabled {
+		values, err := common.RDB.HGetAll(context.Background(), draftKey(projectId)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return parseDraftHash(projectId, values), nil
+	}
+
+	var draft model.OutlineDraft
+	err := r.DB.Where("project_id = ?", projectId).First(&draft).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil // 返回nil表示没有草稿
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// SaveDraft 保存草稿内容，修订号加一并清除冲突标记
+func (r *OutlineDraftRepository) SaveDraft(projectId int64, content string, baseVersion int, branch string) (*model.OutlineDraft, error) {
+	now := time.Now().Unix()
+	draft := model.OutlineDraft{
+		ProjectId:   projectId,
+		Content:     content,
+		BaseVersion: baseVersion,
+		Branch:      branch,
+		UpdatedAt:   now,
+	}
+
+	if common.RedisEnabled {
+		ctx := context.Background()
+		key := draftKey(projectId)
+		var revision *redis.IntCmd
+		var createdAt *redis.StringCmd
+		_, err := common.RDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSetNX(ctx, key, "created_at", now)
+			pipe.HSet(ctx, key, map[string]interface{}{
+				"project_id":   projectId,
+				"content":      content,
+				"base_version": baseVersion,
+				"branch":       branch,
+				"conflict":     0,
+				"updated_at":   now,
+			})
+			revision = pipe.HIncrBy(ctx, key, "revision", 1)
+			createdAt = pipe.HGet(ctx, key, "created_at")
+			pipe.Expire(ctx, key, draftTTL)
+			pipe.ZAdd(ctx, draftIdleKey, &redis.Z{Score: float64(now), Member: projectId})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		draft.Revision = revision.Val()
+		draft.CreatedAt, _ = strconv.ParseInt(createdAt.Val(), 10, 64)
+		return &draft, nil
+	}
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var existing model.OutlineDraft
+		err := tx.Where("project_id = ?", projectId).First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			draft.Revision = 1
+			return tx.Create(&draft).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		draft.Id = existing.Id
+		draft.Revision = existing.Revision + 1
+		draft.CreatedAt = existing.CreatedAt
+		return tx.Model(&existing).Updates(map[string]interface{}{
+			"content":      content,
+			"base_version": baseVersion,
+			"branch":       branch,
+			"revision":     draft.Revision,
+			"conflict":     false,
+			"updated_at":   now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// TakeDraft 草稿仍为指定修订号时删除草稿并返回true，用于提交草稿前占有草稿，避免覆盖提交期间新的自动保存
+func (r *OutlineDraftRepository) TakeDraft(projectId int64, revision int64) (bool, error) {
+	if common.RedisEnabled {
+		taken, err := takeDraftScript.Run(context.Background(), common.RDB,
+			[]string{draftKey(projectId), draftIdleKey}, revision, projectId).Int()
+		return taken == 1, err
+	}
+
+	result := r.DB.Where("project_id = ? AND revision = ?", projectId, revision).Delete(&model.OutlineDraft{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RestoreDraft 提交失败时放回草稿，期间已有新的自动保存时保留新草稿
+func (r *OutlineDraftRepository) RestoreDraft(draft *model.OutlineDraft) error {
+	if common.RedisEnabled {
+		conflict := 0
+		if draft.Conflict {
+			conflict = 1
+		}
+		return restoreDraftScript.Run(context.Background(), common.RDB,
+			[]string{draftKey(draft.ProjectId), draftIdleKey},
+			draft.ProjectId, draft.Content, draft.BaseVersion, draft.Branch,
+			draft.Revision, conflict, draft.CreatedAt, draft.UpdatedAt, int64(draftTTL.Seconds())).Err()
+	}
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.OutlineDraft{}).Where("project_id = ?", draft.ProjectId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		restored := *draft
+		restored.Id = 0
+		return tx.Create(&restored).Error
+	})
+}
+
+// DeleteDraft 删除项目大纲的草稿
+func (r *OutlineDraftRepository) DeleteDraft(projectId int64) error {
+	if common.RedisEnabled {
+		ctx := context.Background()
+		_, err := common.RDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, draftKey(projectId))
+			pipe.ZRem(ctx, draftIdleKey, projectId)
+			return nil
+		})
+		return err
+	}
+
+	return r.DB.Where("project_id = ?", projectId).Delete(&model.OutlineDraft{}).Error
+}
+
+// GetIdleDrafts 获取在before之前最后一次自动保存、且没有冲突的草稿
+func (r *OutlineDraftRepository) GetIdleDrafts(before int64, limit int) ([]*model.OutlineDraft, error) {
+	var drafts []*model.OutlineDraft
+	if common.RedisEnabled {
+		ctx := context.Background()
+		members, err := common.RDB.ZRangeByScore(ctx, draftIdleKey, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(before, 10),
+			Count: int64(limit),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			projectId, _ := strconv.ParseInt(member, 10, 64)
+			draft, err := r.GetDraft(projectId)
+			if err != nil {
+				return nil, err
+			}
+			if draft == nil {
+				// 草稿已过期
+				common.RDB.ZRem(ctx, draftIdleKey, member)
+				continue
+			}
+			drafts = append(drafts, draft)
+		}
+		return drafts, nil
+	}
+
+	err := r.DB.Where("updated_at <= ? AND conflict = ?", before, false).
+		Order("updated_at asc").
+		Limit(limit).
+		Find(&drafts).Error
+	return drafts, err
+}
+
+func parseDraftHash(projectId int64, values map[string]string) *model.OutlineDraft {
+	draft := &model.OutlineDraft{
+		ProjectId: projectId,
+		Content:   values["content"],
+		Branch:    values["branch"],
+		Conflict:  values["conflict"] == "1",
+	}
+	draft.BaseVersion, _ = strconv.Atoi(values["base_version"])
+	draft.Revision, _ = strconv.ParseInt(values["revision"], 10, 64)
+	draft.CreatedAt, _ = strconv.ParseInt(values["created_at"], 10, 64)
+	draft.UpdatedAt, _ = strconv.ParseInt(values["updated_at"], 10, 64)
+	return draft
+}
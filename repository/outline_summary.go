@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"gin-template/model"
+	"gorm.io/gorm"
+)
+
+// OutlineSummaryRepository 大纲摘要仓库
+type OutlineSummaryRepository struct {
+	db *gorm.DB
+}
+
+// NewOutlineSummaryRepository 创建大纲摘要仓库实例
+func NewOutlineSummaryRepository(db *gorm.DB) *OutlineSummaryRepository {
+	return &OutlineSummaryRepository{db: db}
+}
+
+// OutlineSummaryTarget 需要刷新摘要的大纲
+type OutlineSummaryTarget struct {
+	ProjectId      int64
+	UserId         int64
+	CurrentVersion int
+}
+
+// GetOutlinesToSummarize 获取内容长度超过minLength且有新版本提交的大纲，没有概要的大纲也包括在内
+func (r *OutlineSummaryRepository) GetOutlinesToSummarize(minLength int, limit int) ([]*OutlineSummaryTarget, error) {
+	var targets []*OutlineSummaryTarget
+	err := r.db.Table("outlines").
+		Select("outlines.project_id, projects.user_id, outlines.current_version").
+		Joins("JOIN projects ON projects.id = outlines.project_id").
+		Joins("LEFT JOIN outline_summaries ON outline_summaries.project_id = outlines.project_id AND outline_summaries.level = 0").
+		Where("LENGTH(outlines.content) > ?", minLength).
+		Where("outline_summaries.id IS NULL OR outline_summaries.source_version <> outlines.current_version").
+		Order("outlines.updated_at asc").
+		Limit(limit).
+		Scan(&targets).Error
+	return targets, err
+}
+
+// GetSummaries 获取项目的所有摘要，按大纲顺序排列，概要在前
+func (r *OutlineSummaryRepository) GetSummaries(projectId int64) ([]*model.OutlineSummary, error) {
+	var summaries []*model.OutlineSummary
+	err := r.db.Where("project_id = ?", projectId).Order("sort_order asc, id asc").Find(&summaries).Error
+	return summaries, err
+}
+
+// GetSummaryById 获取摘要
+func (r *OutlineSummaryRepository) GetSummaryById(id int64) (*model.OutlineSummary, error) {
+	var summary model.OutlineSummary
+	err := r.db.Where("id = ?", id).First(&summary).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &summary, err
+}
+
+// ReplaceSummaries 保存一次刷新的结果：新增或更新summaries，删除不再对应大纲节点的旧摘要
+func (r *OutlineSummaryRepository) ReplaceSummaries(projectId int64, summaries []*model.OutlineSummary) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		keepIds := make([]int64, 0, len(summaries))
+		for _, summary := range summaries {
+			if summary.Id == 0 {
+				if err := tx.Create(summary).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Save(summary).Error; err != nil {
+				return err
+			}
+			keepIds = append(keepIds, summary.Id)
+		}
+
+		query := tx.Where("project_id = ?", projectId)
+		if len(keepIds) > 0 {
+			query = query.Where("id NOT IN ?", keepIds)
+		}
+		return query.Delete(&model.OutlineSummary{}).Error
+	})
+}
+
+// UpdateSummary 更新摘要
+func (r *OutlineSummaryRepository) UpdateSummary(summary *model.OutlineSummary) error {
+	return r.db.Save(summary).Error
+}
+
+// InvalidateSummaries 清除概要记录的大纲版本，使摘要在下次定时刷新时重新生成
+func (r *OutlineSummaryRepository) InvalidateSummaries(projectId int64) error {
+	return r.db.Model(&model.OutlineSummary{}).
+		Where("project_id = ? AND level = 0", projectId).
+		Update("source_version", 0).Error
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"errors"
+	"gin-template/common"
+	"gin-template/model"
+	"gin-template/util"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// errPinnedDuringThinning 清理期间版本被固定，放弃本次对该大纲的清理
+var errPinnedDuringThinning = errors.New("version pinned during thinning")
+
+// SetVersionPinned 固定或取消固定指定版本，版本不存在时返回nil
+func (r *OutlineRepository) SetVersionPinned(projectId int64, versionNumber int, pinned bool) (*model.Version, error) {
+	version, err := r.GetVersionByNumber(projectId, versionNumber)
+	if err != nil || version == nil {
+		return nil, err
+	}
+	if err := r.DB.Model(&model.Version{}).Where("id = ?", version.Id).Update("pinned", pinned).Error; err != nil {
+		return nil, err
+	}
+	version.Pinned = pinned
+	return version, nil
+}
+
+// GetOutlineIdsAfter 按ID顺序分批获取大纲ID
+func (r *OutlineRepository) GetOutlineIdsAfter(afterId int64, limit int) ([]int64, error) {
+	var ids []int64
+	err := r.DB.Model(&model.Outline{}).
+		Where("id > ?", afterId).
+		Order("id asc").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ThinVersions 删除保留策略选出的历史版本，返回实际删除的数量
+// 分支头版本、分支基础版本、合并来源版本、当前版本以及AI生成和固定的版本始终保留；
+// 以被删除版本为父版本或差异基础的版本会改挂到被删除版本的父版本和差异基础上
+func (r *OutlineRepository) ThinVersions(outlineId int64, versionIds []int64) (int, error) {
+	deleted := 0
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var outline model.Outline
+		if err := tx.Where("id = ?", outlineId).First(&outline).Error; err != nil {
+			return err
+		}
+		if err := ensureBranches(tx, &outline); err != nil {
+			return err
+		}
+
+		var versions []*model.Version
+		if err := tx.Where("outline_id = ?", outlineId).Order("id asc").Find(&versions).Error; err != nil {
+			return err
+		}
+		var branches []*model.OutlineBranch
+		if err := tx.Where("outline_id = ?", outlineId).Find(&branches).Error; err != nil {
+			return err
+		}
+
+		protected := make(map[int64]bool)
+		for _, branch := range branches {
+			protected[branch.HeadVersionId] = true
+			protected[branch.BaseVersionId] = true
+		}
+		byId := make(map[int64]*model.Version, len(versions))
+		for _, version := range versions {
+			byId[version.Id] = version
+			if version.Pinned || version.IsAiGenerated || version.VersionNumber == outline.CurrentVersion {
+				protected[version.Id] = true
+			}
+			if version.MergeParentId != 0 {
+				protected[version.MergeParentId] = true
+			}
+		}
+		remove := make(map[int64]bool, len(versionIds))
+		for _, id := range versionIds {
+			if byId[id] != nil && !protected[id] {
+				remove[id] = true
+			}
+		}
+
+		cache := make(map[int64]string)
+		for _, version := range versions {
+			if !remove[version.Id] {
+				continue
+			}
+			for _, child := range versions {
+				if child.StorageType == model.VersionStorageDelta && child.DeltaBaseId == version.Id {
+					if err := rebaseVersion(tx, child, version, byId, cache); err != nil {
+						return err
+					}
+				}
+				if child.ParentId == version.Id {
+					child.ParentId = version.ParentId
+					if err := tx.Model(&model.Version{}).Where("id = ?", child.Id).Update("parent_id", child.ParentId).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			// 只删除仍未固定的版本，避免与固定操作并发时误删
+			result := tx.Where("id = ? AND pinned = ?", version.Id, false).Delete(&model.Version{})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errPinnedDuringThinning
+			}
+			deleted++
+		}
+		return nil
+	})
+
+	if err == errPinnedDuringThinning {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// rebaseVersion 把以removed为差异基础的child改为以removed的差异基础为基础；removed为完整快照时child改存完整内容
+func rebaseVersion(tx *gorm.DB, child *model.Version, removed *model.Version, byId map[int64]*model.Version, cache map[int64]string) error {
+	content, err := resolveVersionContent(tx, child, cache)
+	if err != nil {
+		return err
+	}
+
+	child.StorageType = model.VersionStorageFull
+	child.DeltaBaseId = 0
+	child.Delta = nil
+	child.DeltaDepth = 0
+	child.Content = content
+	if removed.StorageType == model.VersionStorageDelta && byId[removed.DeltaBaseId] != nil {
+		baseContent, err := resolveVersionContent(tx, byId[removed.DeltaBaseId], cache)
+		if err != nil {
+			return err
+		}
+		delta, err := util.EncodeDelta(baseContent, content)
+		if err != nil {
+			return err
+		}
+		if len(delta) < len(content) {
+			child.StorageType = model.VersionStorageDelta
+			child.DeltaBaseId = removed.DeltaBaseId
+			child.Delta = delta
+			child.DeltaDepth = removed.DeltaDepth
+			child.Content = ""
+		}
+	}
+
+	return tx.Model(&model.Version{}).Where("id = ?", child.Id).Updates(map[string]interface{}{
+		"content":       child.Content,
+		"storage_type":  child.StorageType,
+		"delta_base_id": child.DeltaBaseId,
+		"delta":         child.Delta,
+		"delta_depth":   child.DeltaDepth,
+	}).Error
+}
+
+// encodeVersionContent 确定新版本的存储方式
+// 父版本距最近的完整快照不足VersionSnapshotInterval层时存储相对父版本的压缩差异，
+// 否则或差异不比原文小时存储完整内容；Content保持为完整内容，由调用方决定写入的内容
+func encodeVersionContent(tx *gorm.DB, version *model.Version) error {
+	version.StorageType = model.VersionStorageFull
+	version.DeltaBaseId = 0
+	version.Delta = nil
+	version.DeltaDepth = 0
+	if version.ParentId == 0 || version.Content == "" {
+		return nil
+	}
+
+	var parent model.Version
+	if err := tx.Where("id = ?", version.ParentId).First(&parent).Error; err != nil {
+		return err
+	}
+	if parent.DeltaDepth+1 >= common.VersionSnapshotInterval {
+		return nil
+	}
+	baseContent, err := resolveVersionContent(tx, &parent, make(map[int64]string))
+	if err != nil {
+		return err
+	}
+	delta, err := util.EncodeDelta(baseContent, version.Content)
+	if err != nil {
+		return err
+	}
+	if len(delta) >= len(version.Content) {
+		return nil
+	}
+
+	version.StorageType = model.VersionStorageDelta
+	version.DeltaBaseId = parent.Id
+	version.Delta = delta
+	version.DeltaDepth = parent.DeltaDepth + 1
+	return nil
+}
+
+// loadVersionContents 还原差异存储的版本内容，按版本ID从小到大处理以复用已还原的差异基础
+func loadVersionContents(tx *gorm.DB, versions ...*model.Version) error {
+	sorted := make([]*model.Version, len(versions))
+	copy(sorted, versions)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Id < sorted[j].Id
+	})
+
+	cache := make(map[int64]string)
+	for _, version := range sorted {
+		content, err := resolveVersionContent(tx, version, cache)
+		if err != nil {
+			return err
+		}
+		version.Content = content
+	}
+	return nil
+}
+
+// resolveVersionContent 沿差异链向上找到完整快照或已还原的版本，再依次应用差异得到版本内容
+func resolveVersionContent(tx *gorm.DB, version *model.Version, cache map[int64]string) (string, error) {
+	var chain []*model.Version
+	var content string
+	current := version
+	for {
+		if cached, ok := cache[current.Id]; ok {
+			content = cached
+			break
+		}
+		if current.StorageType != model.VersionStorageDelta {
+			content = current.Content
+			cache[current.Id] = content
+			break
+		}
+		chain = append(chain, current)
+
+		var base model.Version
+		if err := tx.Where("id = ?", current.DeltaBaseId).First(&base).Error; err != nil {
+			return "", err
+		}
+		current = &base
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		var err error
+		content, err = util.ApplyDelta(content, chain[i].Delta)
+		if err != nil {
+			return "", err
+		}
+		cache[chain[i].Id] = content
+	}
+	return content, nil
+}
@@ -41,7 +41,7 @@ func (r *PackageRepository) GetAllPackages() ([]model.Package, error) {
 // The service layer will handle the logic of returning a free package if no active subscription.
 func (r *PackageRepository) GetUserCurrentSubscription(userID int64) (*model.Subscription, error) {
 	var subscription model.Subscription
-	err := r.db.Where("user_id = ? AND status = ? AND expiry_date > ?", userID, "active", time.Now()).
+	err := r.db.Where("user_id = ? AND status = ? AND expiry_date > ?", userID, "active", time.Now().Unix()).
 		Order("expiry_date DESC").
 		First(&subscription).Error
 	if err != nil {
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"gin-template/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PromptTemplateRepository 提示词模板仓库
+type PromptTemplateRepository struct {
+	db *gorm.DB
+}
+
+// NewPromptTemplateRepository 创建提示词模板仓库实例
+func NewPromptTemplateRepository(db *gorm.DB) *PromptTemplateRepository {
+	return &PromptTemplateRepository{db: db}
+}
+
+// GetTemplates 获取所有提示词模板
+func (r *PromptTemplateRepository) GetTemplates() ([]*model.PromptTemplate, error) {
+	var templates []*model.PromptTemplate
+	err := r.db.Order("name asc").Find(&templates).Error
+	return templates, err
+}
+
+// GetTemplateByName 按名称获取提示词模板
+func (r *PromptTemplateRepository) GetTemplateByName(name string) (*model.PromptTemplate, error) {
+	var template model.PromptTemplate
+	err := r.db.Where("name = ?", name).First(&template).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &template, err
+}
+
+// GetVersions 获取模板的所有版本，新版本在前
+func (r *PromptTemplateRepository) GetVersions(templateId int64) ([]*model.PromptTemplateVersion, error) {
+	var versions []*model.PromptTemplateVersion
+	err := r.db.Where("template_id = ?", templateId).Order("version desc").Find(&versions).Error
+	return versions, err
+}
+
+// GetVersion 获取模板的指定版本
+func (r *PromptTemplateRepository) GetVersion(templateId int64, version int) (*model.PromptTemplateVersion, error) {
+	var templateVersion model.PromptTemplateVersion
+	err := r.db.Where("template_id = ? AND version = ?", templateId, version).First(&templateVersion).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &templateVersion, err
+}
+
+// GetActiveVersion 按模板名称获取当前生效的版本，模板不存在时返回 nil
+func (r *PromptTemplateRepository) GetActiveVersion(name string) (*model.PromptTemplateVersion, error) {
+	var templateVersion model.PromptTemplateVersion
+	err := r.db.Table("prompt_template_versions").
+		Select("prompt_template_versions.*").
+		Joins("JOIN prompt_templates ON prompt_templates.id = prompt_template_versions.template_id AND prompt_templates.active_version = prompt_template_versions.version").
+		Where("prompt_templates.name = ?", name).
+		First(&templateVersion).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &templateVersion, err
+}
+
+// CreateVersion 为模板追加一个新版本，模板不存在时一并创建，activate为true时同时设为生效版本
+// 版本号在事务内按模板加锁后分配，并发提交不会得到相同的版本号
+func (r *PromptTemplateRepository) CreateVersion(template *model.PromptTemplate, templateVersion *model.PromptTemplateVersion, activate bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing model.PromptTemplate
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", template.Name).First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			if err := tx.Create(template).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else {
+			*template = existing
+		}
+
+		var latest int
+		if err := tx.Model(&model.PromptTemplateVersion{}).Where("template_id = ?", template.Id).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		templateVersion.TemplateId = template.Id
+		templateVersion.Version = latest + 1
+		if err := tx.Create(templateVersion).Error; err != nil {
+			return err
+		}
+
+		if !activate {
+			return nil
+		}
+		template.ActiveVersion = templateVersion.Version
+		return tx.Model(template).Update("active_version", template.ActiveVersion).Error
+	})
+}
+
+// SetActiveVersion 切换模板的生效版本
+func (r *PromptTemplateRepository) SetActiveVersion(template *model.PromptTemplate, version int) error {
+	template.ActiveVersion = version
+	return r.db.Model(template).Update("active_version", version).Error
+}
+
+// UpdateDescription 修改模板描述
+func (r *PromptTemplateRepository) UpdateDescription(template *model.PromptTemplate) error {
+	return r.db.Model(template).Update("description", template.Description).Error
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"gin-template/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// postingBatchSize 批量写入倒排索引的条数
+const postingBatchSize = 500
+
+// SearchRepository 全文搜索索引的数据库操作
+type SearchRepository struct {
+	DB *gorm.DB
+}
+
+// NewSearchRepository 创建一个新的SearchRepository实例
+func NewSearchRepository(db *gorm.DB) *SearchRepository {
+	return &SearchRepository{
+		DB: db,
+	}
+}
+
+// SearchFilter 搜索条件
+type SearchFilter struct {
+	UserId    int64
+	ProjectId int64 // 不为0时只搜索该项目
+	AiOnly    bool  // 只搜索AI生成的历史版本
+}
+
+// OutlineToIndex 需要重新索引的大纲及其所属用户
+type OutlineToIndex struct {
+	model.Outline
+	UserId int64
+}
+
+// VersionToIndex 需要索引的历史版本及其所属项目和用户
+type VersionToIndex struct {
+	model.Version
+	ProjectId int64
+	UserId    int64
+}
+
+// GetProjectsToIndex 获取还没有索引或索引后又更新过的项目
+func (r *SearchRepository) GetProjectsToIndex(limit int) ([]*model.Project, error) {
+	var projects []*model.Project
+	err := r.DB.Table("projects AS p").
+		Select("p.*").
+		Joins("LEFT JOIN search_documents AS d ON d.doc_type = ? AND d.source_id = p.id", model.SearchDocProject).
+		Where("d.id IS NULL OR d.source_updated_at < p.updated_at").
+		Order("p.id asc").
+		Limit(limit).
+		Find(&projects).Error
+	return projects, err
+}
+
+// GetOutlinesToIndex 获取还没有索引或索引后又更新过的大纲
+func (r *SearchRepository) GetOutlinesToIndex(limit int) ([]*OutlineToIndex, error) {
+	var outlines []*OutlineToIndex
+	err := r.DB.Table("outlines AS o").
+		Select("o.*, p.user_id").
+		Joins("JOIN projects AS p ON p.id = o.project_id").
+		Joins("LEFT JOIN search_documents AS d ON d.doc_type = ? AND d.source_id = o.id", model.SearchDocOutline).
+		Where("d.id IS NULL OR d.source_updated_at < o.updated_at").
+		Order("o.id asc").
+		Limit(limit).
+		Find(&outlines).Error
+	return outlines, err
+}
+
+// GetVersionsToIndex 获取ID大于已索引的最大版本ID、且在createdBefore之前创建的历史版本，内容已还原为完整内容
+// 版本创建后不再修改，按ID递增顺序索引即可；只取创建了一段时间的版本，避免还未提交的事务中ID更小的版本被跳过
+func (r *SearchRepository) GetVersionsToIndex(createdBefore int64, limit int) ([]*VersionToIndex, error) {
+	var lastId int64
+	err := r.DB.Model(&model.SearchDocument{}).
+		Where("doc_type = ?", model.SearchDocVersion).
+		Select("COALESCE(MAX(source_id), 0)").
+		Scan(&lastId).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*VersionToIndex
+	err = r.DB.Table("versions AS v").
+		Select("v.*, o.project_id, p.user_id").
+		Joins("JOIN outlines AS o ON o.id = v.outline_id").
+		Joins("JOIN projects AS p ON p.id = o.project_id").
+		Where("v.id > ? AND v.created_at < ?", lastId, createdBefore).
+		Order("v.id asc").
+		Limit(limit).
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	plain := make([]*model.Version, len(versions))
+	for i, version := range versions {
+		plain[i] = &version.Version
+	}
+	if err := loadVersionContents(r.DB, plain...); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// SaveDocument 保存索引文档并更新倒排索引，只写入新增和删除变化的索引词
+func (r *SearchRepository) SaveDocument(document *model.SearchDocument, grams []string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var existing model.SearchDocument
+		err := tx.Where("doc_type = ? AND source_id = ?", document.DocType, document.SourceId).First(&existing).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		var oldGrams []string
+		if err == nil {
+			document.Id = existing.Id
+			document.CreatedAt = existing.CreatedAt
+			if err := tx.Save(document).Error; err != nil {
+				return err
+			}
+			if existing.UserId == document.UserId {
+				if err := tx.Model(&model.SearchPosting{}).Where("user_id = ? AND document_id = ?", existing.UserId, existing.Id).Pluck("gram", &oldGrams).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Where("document_id = ?", existing.Id).Delete(&model.SearchPosting{}).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Create(document).Error; err != nil {
+			return err
+		}
+
+		keep := make(map[string]bool, len(grams))
+		for _, gram := range grams {
+			keep[gram] = true
+		}
+		old := make(map[string]bool, len(oldGrams))
+		var removed []string
+		for _, gram := range oldGrams {
+			old[gram] = true
+			if !keep[gram] {
+				removed = append(removed, gram)
+			}
+		}
+		var added []model.SearchPosting
+		for _, gram := range grams {
+			if !old[gram] {
+				added = append(added, model.SearchPosting{UserId: document.UserId, Gram: gram, DocumentId: document.Id})
+			}
+		}
+
+		for start := 0; start < len(removed); start += postingBatchSize {
+			end := start + postingBatchSize
+			if end > len(removed) {
+				end = len(removed)
+			}
+			err := tx.Where("user_id = ? AND document_id = ? AND gram IN ?", document.UserId, document.Id, removed[start:end]).
+				Delete(&model.SearchPosting{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		// 大小写不敏感的排序规则下不同的词可能被视为重复，忽略重复即可
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(added, postingBatchSize).Error
+	})
+}
+
+// Search 查找当前用户下包含全部索引词的文档，按来源更新时间倒序最多返回limit条候选
+func (r *SearchRepository) Search(filter SearchFilter, grams []string, limit int) ([]*model.SearchDocument, error) {
+	matched := r.DB.Model(&model.SearchPosting{}).
+		Select("document_id").
+		Where("user_id = ? AND gram IN ?", filter.UserId, grams).
+		Group("document_id").
+		Having("COUNT(*) = ?", len(grams))
+
+	query := r.DB.Where("user_id = ? AND id IN (?)", filter.UserId, matched)
+	if filter.ProjectId != 0 {
+		query = query.Where("project_id = ?", filter.ProjectId)
+	}
+	if filter.AiOnly {
+		query = query.Where("doc_type = ? AND is_ai_generated = ?", model.SearchDocVersion, true)
+	}
+
+	var documents []*model.SearchDocument
+	err := query.Order("source_updated_at desc").Limit(limit).Find(&documents).Error
+	return documents, err
+}
+
+// GetProjectTitles 获取项目的当前标题
+func (r *SearchRepository) GetProjectTitles(projectIds []int64) (map[int64]string, error) {
+	var projects []*model.Project
+	if err := r.DB.Select("id", "title").Where("id IN ?", projectIds).Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	titles := make(map[int64]string, len(projects))
+	for _, project := range projects {
+		titles[project.Id] = project.Title
+	}
+	return titles, nil
+}
+
+// RemoveStaleDocuments 删除来源已被删除的索引文档（项目被删除、版本被保留策略清理），返回仍然有效的文档
+func (r *SearchRepository) RemoveStaleDocuments(documents []*model.SearchDocument) ([]*model.SearchDocument, error) {
+	var projectIds, versionIds []int64
+	for _, document := range documents {
+		projectIds = append(projectIds, document.ProjectId)
+		if document.DocType == model.SearchDocVersion {
+			versionIds = append(versionIds, document.SourceId)
+		}
+	}
+	if len(documents) == 0 {
+		return documents, nil
+	}
+
+	var existingProjects, existingVersions []int64
+	if err := r.DB.Model(&model.Project{}).Where("id IN ?", projectIds).Pluck("id", &existingProjects).Error; err != nil {
+		return nil, err
+	}
+	if len(versionIds) > 0 {
+		if err := r.DB.Model(&model.Version{}).Where("id IN ?", versionIds).Pluck("id", &existingVersions).Error; err != nil {
+			return nil, err
+		}
+	}
+	projects := make(map[int64]bool, len(existingProjects))
+	for _, id := range existingProjects {
+		projects[id] = true
+	}
+	versions := make(map[int64]bool, len(existingVersions))
+	for _, id := range existingVersions {
+		versions[id] = true
+	}
+
+	var valid []*model.SearchDocument
+	var staleIds []int64
+	for _, document := range documents {
+		if !projects[document.ProjectId] || (document.DocType == model.SearchDocVersion && !versions[document.SourceId]) {
+			staleIds = append(staleIds, document.Id)
+			continue
+		}
+		valid = append(valid, document)
+	}
+	if len(staleIds) == 0 {
+		return valid, nil
+	}
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id IN ?", staleIds).Delete(&model.SearchPosting{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", staleIds).Delete(&model.SearchDocument{}).Error
+	})
+	return valid, err
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"gin-template/model"
+	"gorm.io/gorm"
+)
+
+// StoryBibleRepository 故事设定集仓库
+type StoryBibleRepository struct {
+	db *gorm.DB
+}
+
+// NewStoryBibleRepository 创建故事设定集仓库实例
+func NewStoryBibleRepository(db *gorm.DB) *StoryBibleRepository {
+	return &StoryBibleRepository{db: db}
+}
+
+// GetEntities 获取项目的设定条目，entityType为空时返回所有类型
+func (r *StoryBibleRepository) GetEntities(projectId int64, entityType string) ([]*model.StoryEntity, error) {
+	var entities []*model.StoryEntity
+	query := r.db.Where("project_id = ?", projectId)
+	if entityType != "" {
+		query = query.Where("type = ?", entityType)
+	}
+	err := query.Order("type asc, id asc").Find(&entities).Error
+	return entities, err
+}
+
+// CountEntities 统计项目的设定条目数量
+func (r *StoryBibleRepository) CountEntities(projectId int64) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.StoryEntity{}).Where("project_id = ?", projectId).Count(&count).Error
+	return count, err
+}
+
+// GetEntityById 获取设定条目
+func (r *StoryBibleRepository) GetEntityById(id int64) (*model.StoryEntity, error) {
+	var entity model.StoryEntity
+	err := r.db.Where("id = ?", id).First(&entity).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &entity, err
+}
+
+// CreateEntity 创建设定条目
+func (r *StoryBibleRepository) CreateEntity(entity *model.StoryEntity) error {
+	return r.db.Create(entity).Error
+}
+
+// UpdateEntity 更新设定条目
+func (r *StoryBibleRepository) UpdateEntity(entity *model.StoryEntity) error {
+	return r.db.Save(entity).Error
+}
+
+// DeleteEntity 删除设定条目及其所有关系
+func (r *StoryBibleRepository) DeleteEntity(entity *model.StoryEntity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("source_id = ? OR target_id = ?", entity.Id, entity.Id).Delete(&model.StoryRelation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(entity).Error
+	})
+}
+
+// GetRelations 获取项目的所有关系
+func (r *StoryBibleRepository) GetRelations(projectId int64) ([]*model.StoryRelation, error) {
+	var relations []*model.StoryRelation
+	err := r.db.Where("project_id = ?", projectId).Order("id asc").Find(&relations).Error
+	return relations, err
+}
+
+// GetRelationById 获取关系
+func (r *StoryBibleRepository) GetRelationById(id int64) (*model.StoryRelation, error) {
+	var relation model.StoryRelation
+	err := r.db.Where("id = ?", id).First(&relation).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &relation, err
+}
+
+// CreateRelation 创建关系
+func (r *StoryBibleRepository) CreateRelation(relation *model.StoryRelation) error {
+	return r.db.Create(relation).Error
+}
+
+// DeleteRelation 删除关系
+func (r *StoryBibleRepository) DeleteRelation(relation *model.StoryRelation) error {
+	return r.db.Delete(relation).Error
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"gin-template/model"
+	"gorm.io/gorm"
+)
+
+// StylePresetRepository 风格预设仓库
+type StylePresetRepository struct {
+	db *gorm.DB
+}
+
+// NewStylePresetRepository 创建风格预设仓库实例
+func NewStylePresetRepository(db *gorm.DB) *StylePresetRepository {
+	return &StylePresetRepository{db: db}
+}
+
+// GetAvailablePresets 获取用户可用的风格预设：启用的公共预设和用户自己的私有预设，公共预设在前
+func (r *StylePresetRepository) GetAvailablePresets(userId int64) ([]*model.StylePreset, error) {
+	var presets []*model.StylePreset
+	err := r.db.Where("(user_id = 0 OR user_id = ?) AND enabled = ?", userId, true).
+		Order("user_id asc, sort_order asc, id asc").Find(&presets).Error
+	return presets, err
+}
+
+// GetPublicPresets 获取所有公共预设，包括已停用的
+func (r *StylePresetRepository) GetPublicPresets() ([]*model.StylePreset, error) {
+	var presets []*model.StylePreset
+	err := r.db.Where("user_id = 0").Order("sort_order asc, id asc").Find(&presets).Error
+	return presets, err
+}
+
+// GetPresetById 获取风格预设
+func (r *StylePresetRepository) GetPresetById(id int64) (*model.StylePreset, error) {
+	var preset model.StylePreset
+	err := r.db.Where("id = ?", id).First(&preset).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &preset, err
+}
+
+// GetPublicPresetByCode 按标识获取启用的公共预设
+func (r *StylePresetRepository) GetPublicPresetByCode(code string) (*model.StylePreset, error) {
+	var preset model.StylePreset
+	err := r.db.Where("user_id = 0 AND code = ? AND enabled = ?", code, true).First(&preset).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil // 未找到时返回 nil
+	}
+	return &preset, err
+}
+
+// CountUserPresets 统计用户的私有预设数量
+func (r *StylePresetRepository) CountUserPresets(userId int64) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.StylePreset{}).Where("user_id = ?", userId).Count(&count).Error
+	return count, err
+}
+
+// CreatePreset 创建风格预设
+func (r *StylePresetRepository) CreatePreset(preset *model.StylePreset) error {
+	return r.db.Create(preset).Error
+}
+
+// UpdatePreset 更新风格预设
+func (r *StylePresetRepository) UpdatePreset(preset *model.StylePreset) error {
+	return r.db.Save(preset).Error
+}
+
+// DeletePreset 删除风格预设
+func (r *StylePresetRepository) DeletePreset(preset *model.StylePreset) error {
+	return r.db.Delete(preset).Error
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TokenRepository struct {
@@ -106,6 +107,12 @@ func (r *TokenRepository) GetTransactionByUUID(transactionUUID string) (*model.T
 // amount 为正表示增加，为负表示减少
 func (r *TokenRepository) ModifyTokenBalanceWithTransaction(tx *gorm.DB, userID int64, amount int64, transactionUUID string,
 	transactionType string, description string, relatedEntityType string, relatedEntityID string) (*model.UserToken, error) {
+	return r.modifyTokenBalance(tx, userID, amount, false, transactionUUID, transactionType, description, relatedEntityType, relatedEntityID)
+}
+
+// modifyTokenBalance 在事务中修改用户Token余额，allowDeficit为true时允许扣减后余额为负
+func (r *TokenRepository) modifyTokenBalance(tx *gorm.DB, userID int64, amount int64, allowDeficit bool, transactionUUID string,
+	transactionType string, description string, relatedEntityType string, relatedEntityID string) (*model.UserToken, error) {
 
 	// 1. 使用悲观锁获取用户Token信息
 	var userToken model.UserToken
@@ -117,7 +124,7 @@ func (r *TokenRepository) ModifyTokenBalanceWithTransaction(tx *gorm.DB, userID
 	}
 
 	// 2. 对于扣减操作，检查余额是否充足
-	if amount < 0 && userToken.Balance < -amount {
+	if amount < 0 && !allowDeficit && userToken.Balance < -amount {
 		return nil, fmt.Errorf("用户 %d 余额不足: 当前 %d, 尝试扣减 %d", userID, userToken.Balance, -amount)
 	}
 
@@ -254,7 +261,12 @@ func (r *TokenRepository) DebitUserToken(userID int64, amount int64, transaction
 			return nil // 事务成功，不重复处理
 		}
 
-		// 2. 扣减Token余额（注意这里传入负的金额值）
+		// 2. 锁定账户并检查可用余额，未过期的预扣已被AI调用占用，不能再被直接扣减
+		if err := r.checkAvailableBalance(tx, userID, amount); err != nil {
+			return err
+		}
+
+		// 3. 扣减Token余额（注意这里传入负的金额值）
 		updatedToken, err := r.ModifyTokenBalanceWithTransaction(
 			tx, userID, -amount, transactionUUID, transactionType, description, relatedEntityType, relatedEntityID)
 		if err != nil {
@@ -286,6 +298,254 @@ func (r *TokenRepository) GetTokenBalance(userID int64) (int64, error) {
 	return userToken.Balance, nil
 }
 
+// GetAvailableBalance 获取用户可用Token余额，即余额减去未过期的预扣
+func (r *TokenRepository) GetAvailableBalance(userID int64) (int64, error) {
+	userToken, err := r.GetUserToken(userID)
+	if err != nil {
+		return 0, err
+	}
+	held, err := r.activeHoldAmount(r.DB, userID, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	if userToken.Balance < held {
+		return 0, nil
+	}
+	return userToken.Balance - held, nil
+}
+
+// checkAvailableBalance 锁定用户账户，可用余额（余额减去未过期的预扣）不足amount时返回错误
+func (r *TokenRepository) checkAvailableBalance(tx *gorm.DB, userID int64, amount int64) error {
+	var userToken model.UserToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&userToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("用户 %d 的Token账户不存在", userID)
+		}
+		return err
+	}
+	held, err := r.activeHoldAmount(tx, userID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if userToken.Balance-held < amount {
+		return fmt.Errorf("用户 %d 可用余额不足: 当前 %d, 预扣中 %d, 尝试扣减 %d", userID, userToken.Balance, held, amount)
+	}
+	return nil
+}
+
+// activeHoldAmount 统计用户未过期的预扣金额
+func (r *TokenRepository) activeHoldAmount(tx *gorm.DB, userID int64, now int64) (int64, error) {
+	var held int64
+	err := tx.Model(&model.TokenHold{}).
+		Where("user_id = ? AND status = ? AND expires_at > ?", userID, define.TokenHoldStatusActive, now).
+		Select("COALESCE(SUM(amount), 0)").Scan(&held).Error
+	return held, err
+}
+
+// ReserveUserToken 预扣用户Token，可用余额不足时失败
+// 预扣不修改余额，只在expiresAt之前从可用余额中扣除
+func (r *TokenRepository) ReserveUserToken(userID int64, amount int64, holdUUID string, description string,
+	relatedEntityType string, relatedEntityID string, expiresAt int64) (*model.TokenHold, error) {
+
+	if amount <= 0 {
+		return nil, errors.New("预扣的金额必须为正数")
+	}
+
+	// 如果没有提供预扣UUID，则生成一个
+	if holdUUID == "" {
+		holdUUID = uuid.New().String()
+	}
+
+	var hold *model.TokenHold
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// 1. 幂等性检查
+		var existingHold model.TokenHold
+		err := tx.Where("hold_uuid = ?", holdUUID).First(&existingHold).Error
+		if err == nil {
+			hold = &existingHold
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// 2. 获取用户Token信息，顺便将该用户已过期的预扣标记为过期
+		var userToken model.UserToken
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("user_id = ?", userID).First(&userToken).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("用户 %d 的Token账户不存在", userID)
+			}
+			return err
+		}
+		now := time.Now().Unix()
+		if err := r.expireUserHolds(tx, userID, now); err != nil {
+			return err
+		}
+
+		// 3. 检查可用余额是否充足
+		held, err := r.activeHoldAmount(tx, userID, now)
+		if err != nil {
+			return err
+		}
+		if userToken.Balance-held < amount {
+			return fmt.Errorf("用户 %d 可用余额不足: 当前 %d, 预扣中 %d, 尝试预扣 %d", userID, userToken.Balance, held, amount)
+		}
+
+		// 4. 使用乐观锁更新账户版本号，使同一用户的并发预扣依次进行
+		result := tx.Model(&model.UserToken{}).
+			Where("user_id = ? AND version = ?", userID, userToken.Version).
+			Updates(map[string]interface{}{
+				"version":    userToken.Version + 1,
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("预扣Token失败，可能发生并发冲突")
+		}
+
+		// 5. 创建预扣记录
+		hold = &model.TokenHold{
+			HoldUUID:          holdUUID,
+			UserID:            userID,
+			Amount:            amount,
+			Status:            define.TokenHoldStatusActive,
+			RelatedEntityType: relatedEntityType,
+			RelatedEntityID:   relatedEntityID,
+			Description:       description,
+			ExpiresAt:         expiresAt,
+		}
+		return tx.Create(hold).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// SettleTokenHold 按实际用量结算预扣：扣减余额、记录交易流水并结束预扣，返回扣减后的账户和结算后的预扣记录
+// 按实际用量全额扣减，超出预扣金额的部分允许余额为负，预扣金额和实际扣减的金额都记录在预扣记录中
+// 已结算的预扣直接返回当前账户，已过期或已释放的预扣不能结算
+func (r *TokenRepository) SettleTokenHold(holdUUID string, actualAmount int64, transactionUUID string,
+	transactionType string, description string) (*model.UserToken, *model.TokenHold, error) {
+
+	if actualAmount < 0 {
+		return nil, nil, errors.New("结算的金额不能为负数")
+	}
+
+	// 如果没有提供交易UUID，则生成一个
+	if transactionUUID == "" {
+		transactionUUID = uuid.New().String()
+	}
+
+	var finalUserToken *model.UserToken
+	var hold model.TokenHold
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// 1. 获取预扣记录
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hold_uuid = ?", holdUUID).First(&hold).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("预扣记录 %s 不存在", holdUUID)
+			}
+			return err
+		}
+
+		var userToken model.UserToken
+		if err := tx.Where("user_id = ?", hold.UserID).First(&userToken).Error; err != nil {
+			return err
+		}
+
+		switch hold.Status {
+		case define.TokenHoldStatusSettled:
+			// 已结算，不重复扣减
+			finalUserToken = &userToken
+			return nil
+		case define.TokenHoldStatusReleased:
+			return fmt.Errorf("预扣记录 %s 已释放", holdUUID)
+		case define.TokenHoldStatusExpired:
+			return fmt.Errorf("预扣记录 %s 已过期", holdUUID)
+		}
+		if hold.ExpiresAt <= time.Now().Unix() {
+			return fmt.Errorf("预扣记录 %s 已过期", holdUUID)
+		}
+
+		// 2. 扣减实际用量；预扣金额以内的部分已在预扣时占用可用余额，超出预扣的部分允许余额为负
+		amount := actualAmount
+		finalUserToken = &userToken
+		settledTransactionUUID := ""
+		if amount > 0 {
+			updatedToken, err := r.modifyTokenBalance(
+				tx, hold.UserID, -amount, true, transactionUUID, transactionType, description, hold.RelatedEntityType, hold.RelatedEntityID)
+			if err != nil {
+				return err
+			}
+			finalUserToken = updatedToken
+			settledTransactionUUID = transactionUUID
+		}
+
+		// 3. 结束预扣
+		result := tx.Model(&model.TokenHold{}).
+			Where("id = ? AND status = ?", hold.ID, hold.Status).
+			Updates(map[string]interface{}{
+				"status":           define.TokenHoldStatusSettled,
+				"settled_amount":   amount,
+				"transaction_uuid": settledTransactionUUID,
+				"updated_at":       time.Now().Unix(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("结算预扣失败，可能发生并发冲突")
+		}
+		hold.Status = define.TokenHoldStatusSettled
+		hold.SettledAmount = amount
+		hold.TransactionUUID = settledTransactionUUID
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return finalUserToken, &hold, nil
+}
+
+// ExtendTokenHold 将未过期预扣的有效期延长到expiresAt，已结算、已释放或已过期的预扣不受影响
+func (r *TokenRepository) ExtendTokenHold(holdUUID string, expiresAt int64) error {
+	now := time.Now().Unix()
+	return r.DB.Model(&model.TokenHold{}).
+		Where("hold_uuid = ? AND status = ? AND expires_at > ? AND expires_at < ?", holdUUID, define.TokenHoldStatusActive, now, expiresAt).
+		Updates(map[string]interface{}{
+			"expires_at": expiresAt,
+			"updated_at": now,
+		}).Error
+}
+
+// ReleaseTokenHold 释放预扣，不扣减余额；已结算或已释放的预扣不受影响
+func (r *TokenRepository) ReleaseTokenHold(holdUUID string) error {
+	return r.DB.Model(&model.TokenHold{}).
+		Where("hold_uuid = ? AND status IN ?", holdUUID, []string{define.TokenHoldStatusActive, define.TokenHoldStatusExpired}).
+		Updates(map[string]interface{}{
+			"status":     define.TokenHoldStatusReleased,
+			"updated_at": time.Now().Unix(),
+		}).Error
+}
+
+// expireUserHolds 将用户已过期的预扣标记为过期
+func (r *TokenRepository) expireUserHolds(tx *gorm.DB, userID int64, now int64) error {
+	return tx.Model(&model.TokenHold{}).
+		Where("user_id = ? AND status = ? AND expires_at <= ?", userID, define.TokenHoldStatusActive, now).
+		Updates(map[string]interface{}{
+			"status":     define.TokenHoldStatusExpired,
+			"updated_at": now,
+		}).Error
+}
+
 // GetUserTokenTransactions 获取用户Token交易记录
 func (r *TokenRepository) GetUserTokenTransactions(userID int64, page, limit int) ([]model.TokenTransaction, int64, error) {
 	var transactions []model.TokenTransaction
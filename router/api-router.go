@@ -12,11 +12,28 @@ type APIControllers struct {
 	// 基础控制器
 
 	ReferralController *controller.ReferralController
+	TokenController    *controller.TokenController
 
 	// 项目与大纲控制器
 	ProjectController *controller.ProjectController
 	OutlineController *controller.OutlineController
 
+	// 故事设定集控制器
+	StoryBibleController *controller.StoryBibleController
+
+	// 全文搜索控制器
+	SearchController *controller.SearchController
+
+	// 风格预设控制器
+	StylePresetController    *controller.StylePresetController
+	PromptTemplateController *controller.PromptTemplateController
+
+	// 异步AI任务控制器
+	AIJobController *controller.AIJobController
+
+	// 内容审核控制器
+	ModerationController *controller.ModerationController
+
 	// 套餐控制器
 	PackageController *controller.PackageController
 
@@ -52,15 +69,68 @@ func SetApiRouter(router *gin.Engine, controllers *APIControllers) {
 		aiRoute := apiRouter.Group("/ai")
 		aiRoute.Use(middleware.UserAuth()) // 需要登录才能使用
 		{
-			aiRoute.POST("/prompt", controller.AIPrompt)   // 提交提示并获取响应
-			aiRoute.GET("/models", controller.GetAIModels) // 获取可用模型列表
-			//aiRoute.POST("/generate/:id", controller.AIGenerate) // AI续写
+			aiRoute.POST("/prompt", controller.AIPrompt)                                                                  // 提交提示并获取响应
+			aiRoute.GET("/models", controller.GetAIModels)                                                                // 获取可用模型列表
+			aiRoute.GET("/pricing", controller.GetAIPricing)                                                              // 获取当前用户适用的计费规则
+			aiRoute.POST("/generate/:id", controllers.OutlineController.AIGenerate)                                       // AI续写
+			aiRoute.POST("/generate/:id/stream", controllers.OutlineController.StreamAIGenerate)                          // AI续写（流式）
+			aiRoute.POST("/generate/:id/cancel", controllers.OutlineController.CancelAIGenerate)                          // 取消流式续写
+			aiRoute.POST("/generate/:id/jobs", controllers.AIJobController.SubmitAIGenerateJob)                           // 提交异步续写任务
+			aiRoute.POST("/generate/:id/candidates", controllers.OutlineController.GenerateAICandidates)                  // 生成多个续写候选
+			aiRoute.GET("/generate/:id/candidates", controllers.OutlineController.GetAICandidates)                        // 获取未到期的续写候选
+			aiRoute.POST("/generate/:id/candidates/:candidateId/accept", controllers.OutlineController.AcceptAICandidate) // 采纳候选，生成新版本
+			aiRoute.GET("/jobs", controllers.AIJobController.GetAIJobs)                                                   // 获取异步任务列表
+			aiRoute.GET("/jobs/:jobId", controllers.AIJobController.GetAIJob)                                             // 获取异步任务的状态和结果
+			aiRoute.POST("/jobs/:jobId/cancel", controllers.AIJobController.CancelAIJob)                                  // 取消异步任务
+		}
+
+		// 风格预设API路由
+		styleRoute := apiRouter.Group("/styles")
+		styleRoute.Use(middleware.UserAuth()) // 需要登录才能使用
+		{
+			styleRoute.GET("", controllers.StylePresetController.GetStylePresets)    // 获取可用的风格预设
+			styleRoute.POST("", controllers.StylePresetController.CreateStylePreset) // 创建私有风格预设
+		}
+		styleAdminRoute := apiRouter.Group("/styles/admin")
+		styleAdminRoute.Use(middleware.AdminAuth(), middleware.NoTokenAuth())
+		{
+			styleAdminRoute.GET("", controllers.StylePresetController.GetPublicStylePresets)          // 获取所有公共风格预设
+			styleAdminRoute.POST("", controllers.StylePresetController.CreatePublicStylePreset)       // 创建公共风格预设
+			styleAdminRoute.PUT("/:id", controllers.StylePresetController.UpdatePublicStylePreset)    // 修改公共风格预设
+			styleAdminRoute.DELETE("/:id", controllers.StylePresetController.DeletePublicStylePreset) // 删除公共风格预设
+		}
+
+		// 提示词模板API路由（管理员）
+		promptAdminRoute := apiRouter.Group("/prompts/admin")
+		promptAdminRoute.Use(middleware.AdminAuth(), middleware.NoTokenAuth())
+		{
+			promptAdminRoute.GET("", controllers.PromptTemplateController.GetPromptTemplates)                          // 获取所有提示词模板
+			promptAdminRoute.GET("/:name", controllers.PromptTemplateController.GetPromptTemplate)                     // 获取模板详情及版本历史
+			promptAdminRoute.POST("/:name/versions", controllers.PromptTemplateController.CreatePromptTemplateVersion) // 提交新版本
+			promptAdminRoute.PUT("/:name/active", controllers.PromptTemplateController.ActivatePromptTemplateVersion)  // 切换生效版本
+			promptAdminRoute.POST("/:name/render", controllers.PromptTemplateController.RenderPromptTemplate)          // 预览渲染结果
+		}
+
+		// 内容审核API路由（管理员）
+		moderationAdminRoute := apiRouter.Group("/moderation/admin")
+		moderationAdminRoute.Use(middleware.AdminAuth(), middleware.NoTokenAuth())
+		{
+			moderationAdminRoute.GET("/words", controllers.ModerationController.GetModerationWords)                   // 分页获取敏感词
+			moderationAdminRoute.POST("/words", controllers.ModerationController.CreateModerationWord)                // 添加敏感词
+			moderationAdminRoute.POST("/words/import", controllers.ModerationController.ImportModerationWords)        // 批量导入敏感词
+			moderationAdminRoute.PUT("/words/:id", controllers.ModerationController.UpdateModerationWord)             // 修改敏感词
+			moderationAdminRoute.DELETE("/words/:id", controllers.ModerationController.DeleteModerationWord)          // 删除敏感词
+			moderationAdminRoute.GET("/records", controllers.ModerationController.GetModerationRecords)               // 分页获取审核记录
+			moderationAdminRoute.POST("/records/:id/review", controllers.ModerationController.ReviewModerationRecord) // 复核审核记录
+			moderationAdminRoute.POST("/test", controllers.ModerationController.TestModeration)                       // 测试审核规则
 		}
 
 		// 智能体相关路由
 		agentGroup := apiRouter.Group("/v1/agent")
+		agentGroup.Use(middleware.UserAuth()) // 需要登录才能使用，对话可以带上项目的故事设定
 		{
 			agentGroup.POST("/chat", controllers.AgentController.Chat)
+			agentGroup.POST("/chat/jobs", controllers.AIJobController.SubmitAgentChatJob) // 提交异步对话任务
 			//agentGroup.POST("/chat/stream", agentController.StreamChat)
 		}
 
@@ -75,16 +145,55 @@ func SetApiRouter(router *gin.Engine, controllers *APIControllers) {
 			projectRoute.DELETE("/:id", controllers.ProjectController.DeleteProject) // 删除项目
 		}
 
+		// 故事设定集API路由
+		bibleRoute := apiRouter.Group("/bible")
+		bibleRoute.Use(middleware.UserAuth()) // 需要登录才能使用
+		{
+			bibleRoute.GET("/entities/:id", controllers.StoryBibleController.GetStoryEntities)                    // 获取设定条目列表
+			bibleRoute.POST("/entities/:id", controllers.StoryBibleController.CreateStoryEntity)                  // 创建设定条目
+			bibleRoute.GET("/entities/:id/:entityId", controllers.StoryBibleController.GetStoryEntity)            // 获取设定条目及其关系
+			bibleRoute.PUT("/entities/:id/:entityId", controllers.StoryBibleController.UpdateStoryEntity)         // 修改设定条目
+			bibleRoute.DELETE("/entities/:id/:entityId", controllers.StoryBibleController.DeleteStoryEntity)      // 删除设定条目及其关系
+			bibleRoute.GET("/relations/:id", controllers.StoryBibleController.GetStoryRelations)                  // 获取关系列表
+			bibleRoute.POST("/relations/:id", controllers.StoryBibleController.CreateStoryRelation)               // 创建关系
+			bibleRoute.DELETE("/relations/:id/:relationId", controllers.StoryBibleController.DeleteStoryRelation) // 删除关系
+		}
+
+		// 全文搜索API路由
+		apiRouter.GET("/search", middleware.UserAuth(), controllers.SearchController.Search) // 搜索项目、大纲和历史版本
+
 		// 大纲管理API路由
 		outlineRoute := apiRouter.Group("/outlines")
 		outlineRoute.Use(middleware.UserAuth()) // 需要登录才能使用
 		{
-			outlineRoute.GET("/:id", controllers.OutlineController.GetOutline)           // 获取大纲内容
-			outlineRoute.POST("/:id", controllers.OutlineController.SaveOutline)         // 保存大纲内容
-			outlineRoute.GET("/versions/:id", controllers.OutlineController.GetVersions) // 获取版本历史
-			outlineRoute.POST("/parse/:id", controllers.OutlineController.ParseOutline)  // 解析大纲文件
-			outlineRoute.POST("/upload/:id", controllers.OutlineController.ParseOutline) // 上传大纲文件（兼容旧接口）
-			//outlineRoute.POST("/export/:id", controller.ExportOutline)  // 导出大纲
+			outlineRoute.GET("/:id", controllers.OutlineController.GetOutline)                                 // 获取大纲内容
+			outlineRoute.POST("/:id", controllers.OutlineController.SaveOutline)                               // 保存大纲内容
+			outlineRoute.GET("/draft/:id", controllers.OutlineController.GetDraft)                             // 获取未提交的草稿
+			outlineRoute.POST("/draft/:id", controllers.OutlineController.AutosaveDraft)                       // 自动保存草稿
+			outlineRoute.POST("/draft/:id/commit", controllers.OutlineController.CommitDraft)                  // 提交草稿为正式版本
+			outlineRoute.DELETE("/draft/:id", controllers.OutlineController.DiscardDraft)                      // 丢弃草稿
+			outlineRoute.GET("/versions/:id", controllers.OutlineController.GetVersions)                       // 获取版本历史
+			outlineRoute.GET("/versions/:id/diff", controllers.OutlineController.GetVersionDiff)               // 对比两个版本
+			outlineRoute.POST("/versions/:id/restore", controllers.OutlineController.RestoreVersion)           // 恢复历史版本
+			outlineRoute.POST("/versions/:id/pin", controllers.OutlineController.PinVersion)                   // 固定或取消固定历史版本
+			outlineRoute.GET("/branches/:id", controllers.OutlineController.GetBranches)                       // 获取分支列表
+			outlineRoute.POST("/branches/:id", controllers.OutlineController.CreateBranch)                     // 创建分支
+			outlineRoute.DELETE("/branches/:id", controllers.OutlineController.DeleteBranch)                   // 删除分支
+			outlineRoute.POST("/branches/:id/switch", controllers.OutlineController.SwitchBranch)              // 切换分支
+			outlineRoute.POST("/branches/:id/merge", controllers.OutlineController.MergeBranch)                // 合并分支
+			outlineRoute.GET("/nodes/:id", controllers.OutlineController.GetOutlineNodes)                      // 获取大纲结构
+			outlineRoute.POST("/nodes/:id", controllers.OutlineController.CreateOutlineNode)                   // 创建大纲节点
+			outlineRoute.PUT("/nodes/:id/:nodeId", controllers.OutlineController.UpdateOutlineNode)            // 修改大纲节点
+			outlineRoute.DELETE("/nodes/:id/:nodeId", controllers.OutlineController.DeleteOutlineNode)         // 删除大纲节点
+			outlineRoute.POST("/nodes/:id/:nodeId/move", controllers.OutlineController.MoveOutlineNode)        // 移动大纲节点
+			outlineRoute.GET("/continuity/:id", controllers.OutlineController.GetContinuityReport)             // 获取已缓存的连贯性检查结果
+			outlineRoute.POST("/continuity/:id", controllers.OutlineController.CheckContinuity)                // 检查版本的连贯性
+			outlineRoute.GET("/summaries/:id", controllers.OutlineController.GetOutlineSummaries)              // 获取滚动摘要
+			outlineRoute.PUT("/summaries/:id/:summaryId", controllers.OutlineController.UpdateOutlineSummary)  // 修改摘要
+			outlineRoute.POST("/summaries/:id/refresh", controllers.OutlineController.RefreshOutlineSummaries) // 立即刷新摘要
+			outlineRoute.POST("/parse/:id", controllers.OutlineController.ParseOutline)                        // 解析大纲文件
+			outlineRoute.POST("/upload/:id", controllers.OutlineController.ParseOutline)                       // 上传大纲文件（兼容旧接口）
+			outlineRoute.POST("/export/:id", controllers.OutlineController.ExportOutline)                      // 导出大纲
 		}
 
 		// 套餐管理API路由
@@ -112,6 +221,7 @@ func SetApiRouter(router *gin.Engine, controllers *APIControllers) {
 				selfRoute.PUT("/self", controller.UpdateSelf)
 				selfRoute.DELETE("/self", controller.DeleteSelf)
 				selfRoute.GET("/token", controller.GenerateToken)
+				selfRoute.GET("/tokens", controllers.TokenController.GetUserTokens)             // 获取Token余额、可用余额和交易记录
 				selfRoute.GET("/package", controllers.PackageController.GetUserPackage)         // 获取当前用户的套餐信息
 				selfRoute.GET("/referral-code", controllers.ReferralController.GetReferralCode) // 获取个人推荐码
 				selfRoute.GET("/referrals", controllers.ReferralController.GetReferrals)        // 获取推荐记录
@@ -15,12 +15,12 @@ type Config struct {
 	ReviserModel model.ChatModel
 	// 工具配置
 	ToolsConfig compose.ToolsNodeConfig
-	// Planner 智能体的 system prompt
-	PlannerSystemPrompt string
-	// Executor 智能体的 system prompt
-	ExecutorSystemPrompt string
-	// Reviser 智能体的 system prompt
-	ReviserSystemPrompt string
+	// Planner 智能体的 system prompt，每次调用模型前获取，提示词模板切换生效版本后立即生效
+	PlannerSystemPrompt func() string
+	// Executor 智能体的 system prompt，获取方式同上
+	ExecutorSystemPrompt func() string
+	// Reviser 智能体的 system prompt，获取方式同上
+	ReviserSystemPrompt func() string
 	// 最大执行步骤数量
 	MaxStep int
 }
@@ -1,5 +1,6 @@
 package config
 
+// 各智能体的默认 system prompt，首次启动时作为提示词模板 agent_planner、agent_executor、agent_reviser 的第一个版本写入
 const (
 	DefaultPlannerPrompt = `你会收到用户关于乐园 A 的游园规划的问题。你的工作是仔细倾听用户的**所有需求**，思考如何在满足所有需求的情况下规划行程，需要获取和分析哪些信息，形成一个分步骤的严谨的解决思路，把这个思路输出出去，交给后面实际的问题解决者去解决。注意，你不是要给出实际的规划，而是要给出这个问题的解决思路和解决计划。
 
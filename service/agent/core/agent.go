@@ -70,13 +70,15 @@ func NewMultiAgent(ctx context.Context, config *config.Config) (*PlanExecuteMult
 		return &state{}
 	}))
 
-	// 在大模型执行之前，向全局状态中保存上下文，并组装本次的上下文
-	modelPreHandle := func(systemPrompt string, isDeepSeek bool) compose.StatePreHandler[[]*schema.Message, *state] {
+	// 在大模型执行之前，向全局状态中保存上下文，并组装本次的上下文，system prompt 在每次调用时获取
+	modelPreHandle := func(prompt func() string, isDeepSeek bool) compose.StatePreHandler[[]*schema.Message, *state] {
 		return func(ctx context.Context, input []*schema.Message, state *state) ([]*schema.Message, error) {
 			for _, msg := range input {
 				state.messages = append(state.messages, msg)
 			}
 
+			systemPrompt := prompt()
+
 			if isDeepSeek {
 				return append([]*schema.Message{schema.SystemMessage(systemPrompt)}, convertMessagesForDeepSeek(state.messages)...), nil
 			}
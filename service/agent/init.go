@@ -14,6 +14,8 @@ import (
 	"github.com/cloudwego/eino/flow/agent"
 	"github.com/cloudwego/eino/schema"
 
+	"gin-template/repository"
+	appservice "gin-template/service"
 	"gin-template/service/agent/config"
 	"gin-template/service/agent/core"
 	"gin-template/service/agent/service"
@@ -26,7 +28,8 @@ var (
 	globalAgentService *service.MultiUserAgentService
 )
 
-func InitAgent() {
+// InitAgent 初始化多智能体，各智能体的 system prompt 在每次调用模型前从提示词模板的生效版本中加载
+func InitAgent(promptRepo *repository.PromptTemplateRepository) {
 	ctx := context.Background()
 
 	//初始化model
@@ -52,7 +55,7 @@ func InitAgent() {
 		log.Fatalf("get tools config failed: %v", err)
 	}
 
-	// 创建多智能体的配置，system prompt 都用默认值
+	// 创建多智能体的配置，system prompt 使用提示词模板的生效版本，模板不可用时退回内置的默认值
 	agentConfig := &config.Config{
 		// planner 在调试时大部分场景不需要真的去生成，可以用 mock 输出替代
 		PlannerModel: &debug.ChatModelDebugDecorator{
@@ -63,9 +66,9 @@ func InitAgent() {
 		ReviserModel: &debug.ChatModelDebugDecorator{
 			Model: deepSeekModel,
 		},
-		ReviserSystemPrompt:  config.DefaultReviserPrompt,
-		ExecutorSystemPrompt: config.DefaultExecutorPrompt,
-		PlannerSystemPrompt:  config.DefaultPlannerPrompt,
+		ReviserSystemPrompt:  agentPrompt(promptRepo, appservice.PromptAgentReviser),
+		ExecutorSystemPrompt: agentPrompt(promptRepo, appservice.PromptAgentExecutor),
+		PlannerSystemPrompt:  agentPrompt(promptRepo, appservice.PromptAgentPlanner),
 	}
 
 	// 创建智能体池配置
@@ -109,6 +112,13 @@ func InitAgent() {
 	printer.Wait() // 等待所有输出都处理完再结束
 }
 
+// agentPrompt 返回按名称渲染提示词模板生效版本的函数
+func agentPrompt(promptRepo *repository.PromptTemplateRepository, name string) func() string {
+	return func() string {
+		return appservice.RenderPrompt(promptRepo, name, nil)
+	}
+}
+
 // GetGlobalAgentService 获取全局智能体服务实例
 func GetGlobalAgentService() *service.MultiUserAgentService {
 	return globalAgentService
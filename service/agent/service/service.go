@@ -3,6 +3,11 @@ package service
 import (
 	"context"
 	"gin-template/define"
+	appservice "gin-template/service"
+
+	"github.com/cloudwego/eino/compose"
+	flowagent "github.com/cloudwego/eino/flow/agent"
+	"github.com/cloudwego/eino/schema"
 
 	"gin-template/service/agent/session"
 )
@@ -21,37 +26,110 @@ func NewMultiUserAgentService(sessionManager *session.SessionManager) *MultiUser
 
 // Generate 生成回复
 func (s *MultiUserAgentService) Generate(ctx context.Context, req *define.GenerateRequest) (*define.GenerateResponseForAgent, error) {
+	session, history, response, err := s.generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 更新会话状态
+	session.Messages = history
+	return response, nil
+}
+
+// GenerateWithBilling 生成回复并向用户扣费：调用前按ai_agent_hold预扣，成功后按各模型的用量结算，结算成功后才更新会话
+// 调用或结算失败时释放预扣并返回错误，回复既不返回也不保存到会话；异步任务通过progress记录生成的回复、扣费和结果，同步调用时为nil
+func (s *MultiUserAgentService) GenerateWithBilling(ctx context.Context, userId int64, req *define.GenerateRequest, progress define.AIJobProgress) (*define.AgentChatJobResult, error) {
+	// 进程中断前已生成回复的任务直接使用记录的回复，只重新扣费和保存
+	var recorded define.GenerateResponseForAgent
+	generated, err := appservice.ResumeGenerated(progress, &recorded)
+	if err != nil {
+		return nil, err
+	}
+	hold, err := appservice.ReserveAgentTokens(userId, req.SessionID, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	var session *define.SessionState
+	var history []*schema.Message
+	var response *define.GenerateResponseForAgent
+	if generated {
+		session, history, response, err = s.resume(req, &recorded)
+	} else {
+		session, history, response, err = s.generate(ctx, req)
+		if err == nil && progress != nil {
+			progress.MarkGenerated(response)
+		}
+	}
+	if err != nil {
+		appservice.ReleaseAgentTokens(hold)
+		return nil, err
+	}
+	charged, userToken, err := appservice.SettleAgentTokens(userId, req.SessionID, hold, response.Usage, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	// 更新会话状态
+	session.Messages = history
+	result := &define.AgentChatJobResult{
+		SessionID:     response.SessionID,
+		Response:      response.Message.Content,
+		TokensCharged: charged,
+		TokenBalance:  userToken.Balance,
+	}
+	if progress != nil {
+		progress.MarkSaved(result)
+	}
+	return result, nil
+}
+
+// generate 调用智能体生成回复，返回会话和加上本次消息与回复后的会话历史，由调用方决定是否保存
+func (s *MultiUserAgentService) generate(ctx context.Context, req *define.GenerateRequest) (*define.SessionState, []*schema.Message, *define.GenerateResponseForAgent, error) {
 	// 获取会话
 	session, err := s.sessionManager.GetOrCreateSession(req.SessionID)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	// 更新会话消息
-	allMessages := append(session.Messages, req.Messages...)
+	allMessages := append(append([]*schema.Message{}, session.Messages...), req.Messages...)
 
 	// 借用智能体实例
 	agent, err := s.sessionManager.GetAgentPool().BorrowAgent(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	defer s.sessionManager.GetAgentPool().ReturnAgent(agent)
 
 	//Todo 组装所有的messages
 
-	// 调用智能体生成回复
-	result, err := agent.Generate(ctx, allMessages)
+	// 本次调用的系统消息放在最前面，不保存到会话
+	callMessages := append(append([]*schema.Message{}, req.SystemMessages...), allMessages...)
+
+	// 调用智能体生成回复，同时统计各模型的用量
+	collector := newUsageCollector()
+	result, err := agent.Generate(ctx, callMessages, flowagent.WithComposeOptions(compose.WithCallbacks(collector.handler())))
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	// 更新会话状态
-	session.Messages = append(allMessages, result)
-
-	return &define.GenerateResponseForAgent{
+	return session, append(allMessages, result), &define.GenerateResponseForAgent{
 		SessionID: session.ID,
 		Message:   result,
+		Usage:     collector.result(callMessages, result),
 	}, nil
 }
 
+// resume 使用异步任务之前的执行记录的回复，返回会话和加上本次消息与回复后的会话历史，不调用智能体
+func (s *MultiUserAgentService) resume(req *define.GenerateRequest, response *define.GenerateResponseForAgent) (*define.SessionState, []*schema.Message, *define.GenerateResponseForAgent, error) {
+	session, err := s.sessionManager.GetOrCreateSession(req.SessionID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	history := append(append(append([]*schema.Message{}, session.Messages...), req.Messages...), response.Message)
+	response.SessionID = session.ID
+	return session, history, response, nil
+}
+
 //todo  添加stream流式调用
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"gin-template/define"
+	"gin-template/service/llm"
+	"sync"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// usageCollector 通过回调累计智能体一次调用中各模型的用量
+type usageCollector struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	models []string
+	usage  map[string]*define.AgentModelUsage
+}
+
+func newUsageCollector() *usageCollector {
+	return &usageCollector{usage: make(map[string]*define.AgentModelUsage)}
+}
+
+// handler 返回统计ChatModel用量的回调，流式输出在后台读取回调的副本
+func (c *usageCollector) handler() callbacks.Handler {
+	return callbacks.NewHandlerBuilder().
+		OnEndFn(func(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+			if isChatModel(info) {
+				c.add(model.ConvCallbackOutput(output))
+			}
+			return ctx
+		}).
+		OnEndWithStreamOutputFn(func(ctx context.Context, info *callbacks.RunInfo, output *schema.StreamReader[callbacks.CallbackOutput]) context.Context {
+			if !isChatModel(info) {
+				output.Close()
+				return ctx
+			}
+			c.wg.Add(1)
+			go func() {
+				defer c.wg.Done()
+				defer output.Close()
+				// 服务商在最后一段返回整次调用的用量
+				var last *model.CallbackOutput
+				for {
+					chunk, err := output.Recv()
+					if err != nil {
+						break
+					}
+					if converted := model.ConvCallbackOutput(chunk); converted != nil && outputUsage(converted) != nil {
+						last = converted
+					}
+				}
+				c.add(last)
+			}()
+			return ctx
+		}).
+		Build()
+}
+
+func isChatModel(info *callbacks.RunInfo) bool {
+	return info != nil && info.Component == components.ComponentOfChatModel
+}
+
+// outputUsage 取回调输出中的用量，组件自身上报时在TokenUsage中，由图注入回调时在消息的ResponseMeta中
+func outputUsage(output *model.CallbackOutput) *llm.Usage {
+	if output.TokenUsage != nil {
+		return &llm.Usage{
+			PromptTokens:     output.TokenUsage.PromptTokens,
+			CompletionTokens: output.TokenUsage.CompletionTokens,
+			TotalTokens:      output.TokenUsage.TotalTokens,
+		}
+	}
+	if output.Message != nil && output.Message.ResponseMeta != nil && output.Message.ResponseMeta.Usage != nil {
+		usage := output.Message.ResponseMeta.Usage
+		return &llm.Usage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+	}
+	return nil
+}
+
+func (c *usageCollector) add(output *model.CallbackOutput) {
+	if output == nil {
+		return
+	}
+	usage := outputUsage(output)
+	if usage == nil {
+		return
+	}
+	modelName := ""
+	if output.Config != nil {
+		modelName = output.Config.Model
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.usage[modelName]
+	if !ok {
+		item = &define.AgentModelUsage{Model: modelName}
+		c.usage[modelName] = item
+		c.models = append(c.models, modelName)
+	}
+	item.PromptTokens += usage.PromptTokens
+	item.CompletionTokens += usage.CompletionTokens
+	item.TotalTokens += usage.TotalTokens
+}
+
+// result 等待流式输出读取完毕后返回各模型的用量
+// 没有模型上报用量时按输入消息和回复估算，记在空模型名下
+func (c *usageCollector) result(input []*schema.Message, output *schema.Message) []define.AgentModelUsage {
+	c.wg.Wait()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.models) == 0 {
+		messages := make([]llm.Message, 0, len(input))
+		for _, message := range input {
+			messages = append(messages, llm.Message{Role: string(message.Role), Content: message.Content})
+		}
+		promptTokens := llm.EstimatePromptTokens(messages)
+		completionTokens := llm.EstimateTokens(output.Content)
+		return []define.AgentModelUsage{{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		}}
+	}
+	result := make([]define.AgentModelUsage, 0, len(c.models))
+	for _, name := range c.models {
+		result = append(result, *c.usage[name])
+	}
+	return result
+}
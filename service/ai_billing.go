@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/service/llm"
+	"strconv"
+	"strings"
+)
+
+// OptionAgentHold 智能体对话预扣的平台Token数，智能体会多次调用模型，无法预先估算用量，结算时按实际用量扣除，可能超过该数额
+const OptionAgentHold = "ai_agent_hold"
+
+// defaultAgentHold 没有配置或配置有误时智能体对话的预扣数额
+const defaultAgentHold = 20000
+
+// ParseAgentHold 解析并校验智能体对话的预扣数额
+func ParseAgentHold(value string) (int64, error) {
+	hold, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || hold <= 0 {
+		return 0, fmt.Errorf("智能体对话的预扣数额应为正整数")
+	}
+	return hold, nil
+}
+
+// GenerateBilledAICompletion 调用模型生成补全并向调用者扣费，用于/ai/prompt
+// 调用前按最多可能消耗的token预扣，成功后按实际用量结算，结算失败时不返回生成的内容，调用失败时释放预扣
+func GenerateBilledAICompletion(ctx context.Context, req define.GenerateAIPromptRequest) (*define.AIPromptResponse, error) {
+	description := "AI prompt call"
+	hold, err := holdAITokens(req.UserId, GetPricingService().Estimate(req.UserId, req), description, "ai_prompt", "")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := GenerateAICompletion(ctx, req)
+	if err != nil {
+		_ = GetTokenService().ReleaseToken(hold.HoldUUID)
+		return nil, err
+	}
+
+	charge := GetPricingService().ChargeResponse(req.UserId, response)
+	userToken, err := settleAITokens(hold.HoldUUID, charge.Amount, "ai_prompt_debit", chargeDescription(description, charge))
+	if err != nil {
+		return nil, err
+	}
+	return &define.AIPromptResponse{
+		GenerateResponse: response,
+		TokensCharged:    charge.Amount,
+		TokenBalance:     userToken.Balance,
+	}, nil
+}
+
+// ResumeGenerated 将异步任务之前的执行在扣费前记录的生成结果解析到output，有记录时返回true，调用方直接使用该结果，不再调用AI
+// 已扣费却没有记录生成结果时返回错误：重新调用AI生成的内容不会再扣费，任务应失败
+func ResumeGenerated(progress define.AIJobProgress, output interface{}) (bool, error) {
+	if progress == nil {
+		return false, nil
+	}
+	if progress.Generated(output) {
+		return true, nil
+	}
+	if amount, settled := progress.Settled(); settled {
+		common.SysError(fmt.Sprintf("[AIBilling] Job %s was charged %d tokens but its generated output was not recorded", progress.TransactionUUID(), amount))
+		return false, fmt.Errorf("任务已扣费但没有记录生成的内容，无法重新执行")
+	}
+	return false, nil
+}
+
+// ReserveAgentTokens 智能体对话前按ai_agent_hold预扣；异步任务之前的执行已扣费时不再预扣，返回nil
+func ReserveAgentTokens(userId int64, sessionId string, progress define.AIJobProgress) (*model.TokenHold, error) {
+	if progress != nil {
+		if _, settled := progress.Settled(); settled {
+			return nil, nil
+		}
+	}
+	hold, err := ParseAgentHold(model.GetSetting(OptionAgentHold))
+	if err != nil {
+		hold = defaultAgentHold
+	}
+	return holdAITokens(userId, hold, agentChargeDescription(sessionId), "agent_session", sessionId)
+}
+
+// ReleaseAgentTokens 智能体对话失败时释放预扣
+func ReleaseAgentTokens(hold *model.TokenHold) {
+	if hold != nil {
+		_ = GetTokenService().ReleaseToken(hold.HoldUUID)
+	}
+}
+
+// SettleAgentTokens 按智能体各次模型调用的用量结算预扣，返回扣除的Token和扣除后的账户
+// 扣费超出预扣时仍按实际用量扣除；结算失败时释放预扣并返回错误，此时不应返回智能体的回复
+// 异步任务之前的执行已扣费时（hold为nil）不再扣费，返回之前扣除的Token
+func SettleAgentTokens(userId int64, sessionId string, hold *model.TokenHold, usage []define.AgentModelUsage, progress define.AIJobProgress) (int64, *model.UserToken, error) {
+	if hold == nil && progress != nil {
+		amount, _ := progress.Settled()
+		userToken, err := GetTokenService().GetUserToken(userId)
+		return amount, userToken, err
+	}
+	charges := make([]define.AICharge, 0, len(usage))
+	for _, item := range usage {
+		charges = append(charges, GetPricingService().Charge(userId, item.Model, llm.Usage{
+			PromptTokens:     item.PromptTokens,
+			CompletionTokens: item.CompletionTokens,
+			TotalTokens:      item.TotalTokens,
+		}))
+	}
+	description := agentChargeDescription(sessionId)
+	if len(charges) > 0 {
+		description = chargeDescription(description, charges...)
+	}
+	amount := totalCharge(charges)
+	userToken, err := settleAITokensAs(aiTransactionUUID(progress), hold.HoldUUID, amount, "ai_agent_debit", description)
+	if err != nil {
+		return 0, nil, err
+	}
+	if progress != nil {
+		progress.MarkSettled(amount)
+	}
+	return amount, userToken, nil
+}
+
+func agentChargeDescription(sessionId string) string {
+	return fmt.Sprintf("AI agent chat for session [%s]", sessionId)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/task"
+
+	"github.com/google/uuid"
+)
+
+// maxActiveAIJobs 每个用户最多同时排队或执行的异步AI任务数
+const maxActiveAIJobs = 5
+
+// AIJobService 异步AI任务，提交后立即返回任务ID，由任务调度器在后台执行，客户端轮询状态和结果
+type AIJobService struct {
+	jobQueue *task.DBJobQueue
+}
+
+func NewAIJobService(jobQueue *task.DBJobQueue) *AIJobService {
+	return &AIJobService{jobQueue: jobQueue}
+}
+
+// SubmitOutlineGenerationJob queues an AI continuation of the project's outline, tokens are reserved when the job starts
+func (s *AIJobService) SubmitOutlineGenerationJob(userId int64, projectId int64, req define.AIGenerateRequest) (*define.AIJobResponse, error) {
+	return s.submit(userId, projectId, task.OutlineGenerationJob, req)
+}
+
+// SubmitAgentChatJob queues a message to the agent
+func (s *AIJobService) SubmitAgentChatJob(userId int64, req define.AgentChatJobRequest) (*define.AIJobResponse, error) {
+	return s.submit(userId, req.ProjectId, task.AgentChatJob, req)
+}
+
+func (s *AIJobService) submit(userId int64, projectId int64, jobType string, payload interface{}) (*define.AIJobResponse, error) {
+	active, err := s.jobQueue.CountActiveJobs(userId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[AIJobService] Failed to count active jobs of user %d: %v", userId, err))
+		return nil, err
+	}
+	if active >= maxActiveAIJobs {
+		return nil, fmt.Errorf("最多同时有%d个排队或执行中的任务，请等待完成后再提交", maxActiveAIJobs)
+	}
+
+	data, _ := json.Marshal(payload)
+	job := &model.AIJob{
+		JobID:     uuid.New().String(),
+		UserId:    userId,
+		ProjectId: projectId,
+		JobType:   jobType,
+		Payload:   string(data),
+	}
+	if err := s.jobQueue.AddJob(job); err != nil {
+		common.SysError(fmt.Sprintf("[AIJobService] Failed to queue %s job for user %d: %v", jobType, userId, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[AIJobService] Queued %s job %s for user %d", jobType, job.JobID, userId))
+	response := toAIJobResponse(job, false)
+	return &response, nil
+}
+
+// ListJobs 分页获取用户的任务，status为空时返回所有状态
+func (s *AIJobService) ListJobs(userId int64, status string, offset, limit int) ([]define.AIJobResponse, int64, error) {
+	jobs, total, err := s.jobQueue.GetUserJobs(userId, status, offset, limit)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[AIJobService] Failed to list jobs of user %d: %v", userId, err))
+		return nil, 0, err
+	}
+	responses := make([]define.AIJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		responses = append(responses, toAIJobResponse(job, false))
+	}
+	return responses, total, nil
+}
+
+// GetJob 获取任务的状态和结果
+func (s *AIJobService) GetJob(userId int64, jobId string) (*define.AIJobResponse, error) {
+	job, err := s.getUserJob(userId, jobId)
+	if err != nil {
+		return nil, err
+	}
+	response := toAIJobResponse(job, true)
+	return &response, nil
+}
+
+// CancelJob cancels a queued job at once, a running job is stopped by its worker within a few seconds.
+// A job that has already started saving its result still succeeds.
+func (s *AIJobService) CancelJob(userId int64, jobId string) (*define.AIJobResponse, error) {
+	job, err := s.getUserJob(userId, jobId)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != model.AIJobQueued && job.Status != model.AIJobRunning {
+		return nil, fmt.Errorf("任务已结束，无法取消")
+	}
+	if err := s.jobQueue.CancelJob(jobId); err != nil {
+		common.SysError(fmt.Sprintf("[AIJobService] Failed to cancel job %s: %v", jobId, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[AIJobService] User %d cancelled job %s", userId, jobId))
+	return s.GetJob(userId, jobId)
+}
+
+func (s *AIJobService) getUserJob(userId int64, jobId string) (*model.AIJob, error) {
+	job, err := s.jobQueue.GetJob(jobId)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.UserId != userId {
+		return nil, fmt.Errorf("任务不存在")
+	}
+	return job, nil
+}
+
+func toAIJobResponse(job *model.AIJob, withResult bool) define.AIJobResponse {
+	response := define.AIJobResponse{
+		JobId:           job.JobID,
+		Type:            job.JobType,
+		ProjectId:       job.ProjectId,
+		Status:          string(job.Status),
+		Error:           job.LastError,
+		Attempts:        job.Attempts,
+		CancelRequested: job.CancelRequested,
+		CreatedAt:       job.CreatedAt,
+		StartedAt:       job.StartedAt,
+		FinishedAt:      job.FinishedAt,
+	}
+	if withResult && job.Result != "" {
+		response.Result = json.RawMessage(job.Result)
+	}
+	return response
+}
@@ -0,0 +1,173 @@
+package document
+
+import (
+	"bytes"
+	"encoding/xml"
+	"gin-template/util"
+	"regexp"
+	"strings"
+)
+
+// 导出内容块类型
+const (
+	BlockHeading   = "heading"   // 标题，Level为1-6
+	BlockParagraph = "paragraph" // 正文段落
+	BlockItem      = "item"      // 列表项，Level为缩进层级（从1开始）
+)
+
+// maxHeadingLevel 导出格式支持的最大标题层级
+const maxHeadingLevel = 6
+
+// indentedItemPattern 正文中带缩进的列表行（未缩进的列表行已被解析为情节点）
+var indentedItemPattern = regexp.MustCompile(`^([ \t　]*)[-*+•][ \t]+`)
+
+// Block 导出文档中的一个内容块
+type Block struct {
+	Type  string
+	Level int
+	Text  string
+}
+
+// Chapter 导出文档中的一章，不分章导出时整个大纲为一章
+type Chapter struct {
+	Title  string // 章节标题，用于目录和EPUB导航，没有标题时为空
+	Blocks []Block
+}
+
+// Book 待导出的文档
+type Book struct {
+	Title         string
+	Description   string
+	TitlePage     bool // 是否生成标题页（书名和简介）
+	SplitChapters bool // 每章是否另起一页（EPUB为单独的文件，txt/md为压缩包中单独的文件）
+	Chapters      []*Chapter
+	Source        string // 大纲原文，txt格式不分章时直接输出原文
+}
+
+// NewBook 将大纲文本转换为待导出的文档
+// 分章时以第一个“第X章”节点所在的层级为章的层级，没有章节标记时以顶层节点分章
+func NewBook(title string, description string, content string, titlePage bool, splitChapters bool) *Book {
+	book := &Book{
+		Title:         strings.TrimSpace(title),
+		Description:   strings.TrimSpace(description),
+		TitlePage:     titlePage,
+		SplitChapters: splitChapters,
+		Source:        content,
+	}
+
+	roots := util.ParseOutline(content)
+	chapterDepth := 1
+	if splitChapters {
+		chapterDepth = findChapterDepth(roots, 1)
+		if chapterDepth == 0 {
+			chapterDepth = 1
+		}
+	}
+
+	current := &Chapter{}
+	var walk func(sections []*util.OutlineSection, depth int)
+	walk = func(sections []*util.OutlineSection, depth int) {
+		for _, section := range sections {
+			heading := displayHeading(section)
+			if splitChapters && depth <= chapterDepth && section.Kind != util.OutlineKindPreamble {
+				if len(current.Blocks) > 0 {
+					book.Chapters = append(book.Chapters, current)
+				}
+				current = &Chapter{Title: heading}
+			}
+
+			switch {
+			case section.Kind == util.OutlineKindPreamble:
+			case section.Kind == util.OutlineKindBeat:
+				current.Blocks = append(current.Blocks, Block{Type: BlockItem, Level: 1, Text: heading})
+			default:
+				level := depth
+				if level > maxHeadingLevel {
+					level = maxHeadingLevel
+				}
+				current.Blocks = append(current.Blocks, Block{Type: BlockHeading, Level: level, Text: heading})
+			}
+			current.Blocks = append(current.Blocks, bodyBlocks(section)...)
+			walk(section.Children, depth+1)
+		}
+	}
+	walk(roots, 1)
+	if len(current.Blocks) > 0 || len(book.Chapters) == 0 {
+		book.Chapters = append(book.Chapters, current)
+	}
+
+	// 不分章时用第一个标题作为整体标题，便于生成目录
+	if !splitChapters {
+		for _, block := range current.Blocks {
+			if block.Type == BlockHeading {
+				current.Title = block.Text
+				break
+			}
+		}
+	}
+	return book
+}
+
+// findChapterDepth 返回第一个章节点所在的深度，找不到时返回0
+func findChapterDepth(sections []*util.OutlineSection, depth int) int {
+	for _, section := range sections {
+		if section.Kind == util.OutlineKindChapter {
+			return depth
+		}
+		if found := findChapterDepth(section.Children, depth+1); found > 0 {
+			return found
+		}
+	}
+	return 0
+}
+
+// displayHeading 返回去掉Markdown标记后的标题文字，保留“第X章”等编号
+func displayHeading(section *util.OutlineSection) string {
+	marker := strings.TrimLeft(section.Marker, "#")
+	if section.Kind == util.OutlineKindBeat {
+		// 情节点的列表符号由导出格式自己呈现，数字编号保留
+		marker = strings.TrimLeft(marker, "-*+• \t")
+	}
+	return strings.TrimSpace(strings.TrimSpace(marker) + " " + strings.TrimSpace(section.Title))
+}
+
+// bodyBlocks 将节点正文按行转换为段落和列表项，空行被忽略
+func bodyBlocks(section *util.OutlineSection) []Block {
+	var blocks []Block
+	for _, line := range util.SplitLines(section.Body) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := indentedItemPattern.FindStringSubmatch(line); m != nil {
+			indent := 0
+			for _, r := range m[1] {
+				if r == '\t' || r == '　' {
+					indent += 2
+				} else {
+					indent++
+				}
+			}
+			// 情节点正文中的列表是情节点的下级
+			level := indent / 2
+			if section.Kind == util.OutlineKindBeat {
+				level++
+			}
+			if level < 1 {
+				level = 1
+			}
+			blocks = append(blocks, Block{Type: BlockItem, Level: level, Text: strings.TrimSpace(line[len(m[0]):])})
+			continue
+		}
+		blocks = append(blocks, Block{Type: BlockParagraph, Text: strings.TrimSpace(line)})
+	}
+	return blocks
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// xmlEscape 转义XML特殊字符，非法的控制字符被替换为U+FFFD
+func xmlEscape(s string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(s))
+	return buffer.String()
+}
@@ -0,0 +1,394 @@
+package document
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxDocxPartSize 单个XML部件解压后的最大字节数，防止压缩炸弹
+const maxDocxPartSize = 64 << 20
+
+// WordprocessingML 主命名空间（过渡格式和严格格式）
+const (
+	wordNamespace       = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	wordStrictNamespace = "http://purl.oclc.org/ooxml/wordprocessingml/main"
+)
+
+// ParseDocx 读取 .docx 文件并转换为大纲文本
+// 段落按行输出；标题段落输出为 Markdown 标题（# 的个数为标题级别）；
+// 列表段落按层级缩进，项目符号输出为“- ”，编号列表保留 Word 中显示的编号（如“1.”、“第一章”）；
+// 表格每行输出为“| 单元格 | 单元格 |”
+func ParseDocx(path string) (string, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("无法打开docx文件: %v", err)
+	}
+	defer reader.Close()
+	return parseDocxArchive(&reader.Reader)
+}
+
+// ParseDocxReader 从内存或其他来源读取 .docx 内容并转换为大纲文本
+func ParseDocxReader(r io.ReaderAt, size int64) (string, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("无法打开docx文件: %v", err)
+	}
+	return parseDocxArchive(reader)
+}
+
+func parseDocxArchive(archive *zip.Reader) (string, error) {
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	documentFile, ok := files["word/document.xml"]
+	if !ok {
+		return "", errors.New("docx文件缺少word/document.xml，可能不是有效的Word文档")
+	}
+
+	styles := make(map[string]*docxStyle)
+	if file, ok := files["word/styles.xml"]; ok {
+		if err := decodeDocxPart(file, func(decoder *xml.Decoder) error {
+			return parseDocxStyles(decoder, styles)
+		}); err != nil {
+			return "", fmt.Errorf("解析styles.xml失败: %v", err)
+		}
+	}
+
+	numbering := newDocxNumbering()
+	if file, ok := files["word/numbering.xml"]; ok {
+		if err := decodeDocxPart(file, numbering.parse); err != nil {
+			return "", fmt.Errorf("解析numbering.xml失败: %v", err)
+		}
+	}
+
+	converter := &docxConverter{styles: styles, numbering: numbering}
+	if err := decodeDocxPart(documentFile, converter.parse); err != nil {
+		return "", fmt.Errorf("解析document.xml失败: %v", err)
+	}
+	return converter.text(), nil
+}
+
+func decodeDocxPart(file *zip.File, parse func(decoder *xml.Decoder) error) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	decoder := xml.NewDecoder(io.LimitReader(rc, maxDocxPartSize))
+	// 部分文档声明了非UTF-8编码，内容实际仍按UTF-8处理
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return parse(decoder)
+}
+
+// wordAttr 读取 w: 命名空间下的属性值
+func wordAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name && (isWordNamespace(attr.Name.Space) || attr.Name.Space == "") {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// isWord 判断元素是否为 w: 命名空间下的指定元素
+func isWord(name xml.Name, local string) bool {
+	return name.Local == local && isWordNamespace(name.Space)
+}
+
+func isWordNamespace(space string) bool {
+	return space == wordNamespace || space == wordStrictNamespace || space == "w"
+}
+
+// docxStyle 段落样式中与结构相关的属性
+type docxStyle struct {
+	name       string
+	basedOn    string
+	outlineLvl int // -1表示未设置，0-8对应标题1-9，9表示正文
+	numId      string
+	ilvl       int
+}
+
+func parseDocxStyles(decoder *xml.Decoder, styles map[string]*docxStyle) error {
+	var current *docxStyle
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch {
+			case isWord(element.Name, "style"):
+				current = nil
+				if wordAttr(element, "type") == "paragraph" {
+					current = &docxStyle{outlineLvl: -1}
+					styles[wordAttr(element, "styleId")] = current
+				}
+			case current == nil:
+			case isWord(element.Name, "name"):
+				current.name = strings.ToLower(wordAttr(element, "val"))
+			case isWord(element.Name, "basedOn"):
+				current.basedOn = wordAttr(element, "val")
+			case isWord(element.Name, "outlineLvl"):
+				if level, err := strconv.Atoi(wordAttr(element, "val")); err == nil {
+					current.outlineLvl = level
+				}
+			case isWord(element.Name, "numId"):
+				current.numId = wordAttr(element, "val")
+			case isWord(element.Name, "ilvl"):
+				current.ilvl, _ = strconv.Atoi(wordAttr(element, "val"))
+			}
+		case xml.EndElement:
+			if isWord(element.Name, "style") {
+				current = nil
+			}
+		}
+	}
+}
+
+// headingLevel 根据样式（含继承的样式）计算标题级别，0表示不是标题
+func headingLevel(styles map[string]*docxStyle, styleId string) int {
+	for depth := 0; styleId != "" && depth < 10; depth++ {
+		style, ok := styles[styleId]
+		if !ok {
+			break
+		}
+		if style.outlineLvl >= 0 {
+			return outlineLevelToHeading(style.outlineLvl)
+		}
+		if style.name == "title" {
+			return 1
+		}
+		if strings.HasPrefix(style.name, "heading ") {
+			if level, err := strconv.Atoi(strings.TrimPrefix(style.name, "heading ")); err == nil {
+				return clampHeading(level)
+			}
+		}
+		styleId = style.basedOn
+	}
+	// 没有样式定义时，按内置样式ID识别
+	if strings.HasPrefix(strings.ToLower(styleId), "heading") {
+		if level, err := strconv.Atoi(styleId[len("heading"):]); err == nil {
+			return clampHeading(level)
+		}
+	}
+	return 0
+}
+
+// styleNumbering 获取样式（含继承的样式）上定义的编号
+func styleNumbering(styles map[string]*docxStyle, styleId string) (string, int) {
+	for depth := 0; styleId != "" && depth < 10; depth++ {
+		style, ok := styles[styleId]
+		if !ok {
+			break
+		}
+		if style.numId != "" {
+			return style.numId, style.ilvl
+		}
+		styleId = style.basedOn
+	}
+	return "", 0
+}
+
+func outlineLevelToHeading(outlineLvl int) int {
+	if outlineLvl < 0 || outlineLvl >= 9 {
+		return 0
+	}
+	return clampHeading(outlineLvl + 1)
+}
+
+// clampHeading Markdown 最多支持6级标题
+func clampHeading(level int) int {
+	if level > 6 {
+		return 6
+	}
+	if level < 0 {
+		return 0
+	}
+	return level
+}
+
+// docxParagraph 解析过程中的段落状态
+type docxParagraph struct {
+	styleId    string
+	outlineLvl int
+	numId      string
+	ilvl       int
+	hasNumPr   bool
+	text       strings.Builder
+}
+
+// docxConverter 将 document.xml 转换为文本
+type docxConverter struct {
+	styles    map[string]*docxStyle
+	numbering *docxNumbering
+	lines     []string
+
+	paragraph *docxParagraph
+	inTable   bool
+	row       []string
+	cell      []string
+}
+
+func (c *docxConverter) parse(decoder *xml.Decoder) error {
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			if err := c.start(decoder, element); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			c.end(element)
+		case xml.CharData:
+			// 只有 w:t 中的文字需要输出，在 start 中读取
+		}
+	}
+}
+
+func (c *docxConverter) start(decoder *xml.Decoder, element xml.StartElement) error {
+	name := element.Name
+	switch {
+	// 文本框、替代内容的后备版本、已删除的修订、域代码和制表位定义不输出
+	case name.Local == "Fallback", isWord(name, "txbxContent"), isWord(name, "del"),
+		isWord(name, "instrText"), isWord(name, "delText"), isWord(name, "sectPr"), isWord(name, "tabs"):
+		return decoder.Skip()
+	case isWord(name, "tbl"):
+		c.inTable = true
+	case isWord(name, "tr"):
+		c.row = nil
+	case isWord(name, "tc"):
+		c.cell = nil
+	case isWord(name, "p"):
+		c.paragraph = &docxParagraph{outlineLvl: -1}
+	case c.paragraph == nil:
+	case isWord(name, "pStyle"):
+		c.paragraph.styleId = wordAttr(element, "val")
+	case isWord(name, "outlineLvl"):
+		if level, err := strconv.Atoi(wordAttr(element, "val")); err == nil {
+			c.paragraph.outlineLvl = level
+		}
+	case isWord(name, "numPr"):
+		c.paragraph.hasNumPr = true
+	case isWord(name, "numId"):
+		c.paragraph.numId = wordAttr(element, "val")
+	case isWord(name, "ilvl"):
+		c.paragraph.ilvl, _ = strconv.Atoi(wordAttr(element, "val"))
+	case isWord(name, "rPr"):
+		// 隐藏文字等字符属性与结构无关
+		return decoder.Skip()
+	case isWord(name, "t"):
+		var text string
+		if err := decoder.DecodeElement(&text, &element); err != nil {
+			return err
+		}
+		c.paragraph.text.WriteString(text)
+	case isWord(name, "tab"), isWord(name, "ptab"):
+		c.paragraph.text.WriteString("\t")
+	case isWord(name, "br"), isWord(name, "cr"):
+		if wordAttr(element, "type") != "page" && wordAttr(element, "type") != "column" {
+			c.paragraph.text.WriteString("\n")
+		}
+	case isWord(name, "noBreakHyphen"):
+		c.paragraph.text.WriteString("-")
+	}
+	return nil
+}
+
+func (c *docxConverter) end(element xml.EndElement) {
+	name := element.Name
+	switch {
+	case isWord(name, "p"):
+		if c.paragraph == nil {
+			return
+		}
+		line := c.renderParagraph(c.paragraph)
+		c.paragraph = nil
+		if c.inTable {
+			if line != "" {
+				c.cell = append(c.cell, line)
+			}
+			return
+		}
+		c.lines = append(c.lines, line)
+	case isWord(name, "tc"):
+		c.row = append(c.row, strings.Join(c.cell, " "))
+		c.cell = nil
+	case isWord(name, "tr"):
+		cells := make([]string, len(c.row))
+		for i, cell := range c.row {
+			cells[i] = strings.ReplaceAll(cell, "|", "\\|")
+		}
+		c.lines = append(c.lines, "| "+strings.Join(cells, " | ")+" |")
+		c.row = nil
+	case isWord(name, "tbl"):
+		c.inTable = false
+	}
+}
+
+// renderParagraph 将段落转换为一行文本，标题加上 # 前缀，列表加上缩进和编号
+func (c *docxConverter) renderParagraph(paragraph *docxParagraph) string {
+	text := paragraph.text.String()
+
+	level := outlineLevelToHeading(paragraph.outlineLvl)
+	if paragraph.outlineLvl < 0 {
+		level = headingLevel(c.styles, paragraph.styleId)
+	}
+
+	numId, ilvl := paragraph.numId, paragraph.ilvl
+	if !paragraph.hasNumPr {
+		numId, ilvl = styleNumbering(c.styles, paragraph.styleId)
+	}
+	label, bullet := "", false
+	if numId != "" && numId != "0" {
+		label, bullet = c.numbering.next(numId, ilvl)
+	}
+
+	if level > 0 {
+		// 标题中不能换行
+		text = strings.TrimSpace(strings.ReplaceAll(text, "\n", " "))
+		if label != "" && !bullet {
+			text = label + " " + text
+		}
+		return strings.Repeat("#", level) + " " + strings.TrimSpace(text)
+	}
+
+	if numId == "" || numId == "0" {
+		return text
+	}
+	indent := strings.Repeat("  ", ilvl)
+	if bullet || label == "" {
+		return indent + "- " + text
+	}
+	return indent + label + " " + text
+}
+
+// text 拼接所有行并去掉首尾空行
+func (c *docxConverter) text() string {
+	start, end := 0, len(c.lines)
+	for start < end && strings.TrimSpace(c.lines[start]) == "" {
+		start++
+	}
+	for end > start && strings.TrimSpace(c.lines[end-1]) == "" {
+		end--
+	}
+	return strings.Join(c.lines[start:end], "\n")
+}
@@ -0,0 +1,154 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildDocx 将testdata/docx下的目录打包为docx文件，目录中的文件按相对路径放入压缩包
+func buildDocx(t *testing.T, name string) []byte {
+	t.Helper()
+	root := filepath.Join("testdata", "docx", name)
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		writer, err := archive.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("build %s: %v", name, err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("build %s: %v", name, err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseDocx(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		{
+			// 标题级别来自样式名、继承的样式、内置样式ID和段落的大纲级别；段落、空段落和换行都保留
+			fixture: "headings",
+			want: []string{
+				"# 长夜将尽",
+				"# 第一部",
+				"# 第一章 归乡",
+				"主角在雨夜回到了小镇。",
+				"",
+				"第一行\n第二行",
+				"## 旧友 重逢",
+				"## 渡口",
+				"### 内置样式",
+				"#### 大纲级别",
+				"人物\t年龄",
+				"保留插入的修订",
+				"| 姓名 | 身份 |",
+				"| 林远 | 镖师\\|护卫 |",
+			},
+		},
+		{
+			// 多级编号、项目符号、样式上的编号、起始值覆盖和未定义的编号
+			fixture: "numbering",
+			want: []string{
+				"# 第一章 出发",
+				"1. 离开小镇",
+				"  1.1. 收拾行装",
+				"    (a) 带上玉佩",
+				"    (b) 带上地图",
+				"  1.2. 告别旧友",
+				"2. 渡河",
+				"  2.1. 遇到船夫",
+				"- 伏笔：玉佩",
+				"  - 来历不明",
+				"- 伏笔：地图",
+				"# 第二章 渡口",
+				"# 尾声",
+				"I. 第一幕",
+				"II. 第二幕",
+				"I. 重新编号",
+				"- 未定义的编号",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data := buildDocx(t, tt.fixture)
+			got, err := ParseDocxReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("ParseDocxReader() error = %v", err)
+			}
+			if want := strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("ParseDocxReader() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseDocxInvalid(t *testing.T) {
+	var empty bytes.Buffer
+	if err := zip.NewWriter(&empty).Close(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"不是压缩包", []byte("plain text")},
+		{"缺少document.xml", empty.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDocxReader(bytes.NewReader(tt.data), int64(len(tt.data))); err == nil {
+				t.Errorf("ParseDocxReader() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestFormatDocxNumber(t *testing.T) {
+	tests := []struct {
+		value  int
+		format string
+		want   string
+	}{
+		{3, "decimal", "3"},
+		{3, "lowerLetter", "c"},
+		{28, "upperLetter", "BB"},
+		{4, "lowerRoman", "iv"},
+		{1994, "upperRoman", "MCMXCIV"},
+		{12, "chineseCounting", "十二"},
+		{3, "ideographTraditional", "丙"},
+		{7, "decimalZero", "07"},
+		{2, "decimalEnclosedCircle", "②"},
+		{12, "decimalFullWidth", "１２"},
+		{5, "unknownFormat", "5"},
+	}
+	for _, tt := range tests {
+		if got := formatDocxNumber(tt.value, tt.format); got != tt.want {
+			t.Errorf("formatDocxNumber(%d, %q) = %q, want %q", tt.value, tt.format, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package document
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// docxHeadingSizes 各级标题字号（半磅）
+var docxHeadingSizes = [maxHeadingLevel]int{32, 30, 28, 26, 24, 22}
+
+// WriteDocx 以Word文档格式导出，分章时每章另起一页
+func WriteDocx(w io.Writer, book *Book) error {
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxPackageRels},
+		{"docProps/core.xml", docxCoreProperties(book)},
+		{"word/_rels/document.xml.rels", docxDocumentRels},
+		{"word/styles.xml", docxStyles()},
+		{"word/numbering.xml", docxNumberingPart()},
+		{"word/document.xml", docxDocument(book)},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func docxDocument(book *Book) string {
+	var body strings.Builder
+	pageBreak := false
+	if book.TitlePage {
+		body.WriteString(writeDocxParagraph("Title", "", book.Title, false))
+		for _, line := range strings.Split(book.Description, "\n") {
+			if strings.TrimSpace(line) != "" {
+				body.WriteString(writeDocxParagraph("Subtitle", "", strings.TrimSpace(line), false))
+			}
+		}
+		pageBreak = true
+	}
+
+	for i, chapter := range book.Chapters {
+		if book.SplitChapters && i > 0 {
+			pageBreak = true
+		}
+		for _, block := range chapter.Blocks {
+			switch block.Type {
+			case BlockHeading:
+				body.WriteString(writeDocxParagraph(fmt.Sprintf("Heading%d", block.Level), "", block.Text, pageBreak))
+			case BlockItem:
+				numPr := fmt.Sprintf(`<w:numPr><w:ilvl w:val="%d"/><w:numId w:val="1"/></w:numPr>`, clampListLevel(block.Level)-1)
+				body.WriteString(writeDocxParagraph("ListParagraph", numPr, block.Text, pageBreak))
+			default:
+				body.WriteString(writeDocxParagraph("", "", block.Text, pageBreak))
+			}
+			pageBreak = false
+		}
+	}
+
+	return xmlHeader + `<w:document xmlns:w="` + wordNamespace + `" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` +
+		body.String() +
+		`<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1800" w:bottom="1440" w:left="1800" w:header="851" w:footer="992" w:gutter="0"/></w:sectPr>` +
+		`</w:body></w:document>`
+}
+
+// writeDocxParagraph 生成一个段落，pageBreak为true时段落另起一页
+func writeDocxParagraph(style string, numPr string, text string, pageBreak bool) string {
+	var builder strings.Builder
+	builder.WriteString("<w:p>")
+	if style != "" || numPr != "" || pageBreak {
+		builder.WriteString("<w:pPr>")
+		if style != "" {
+			builder.WriteString(`<w:pStyle w:val="` + style + `"/>`)
+		}
+		if pageBreak {
+			builder.WriteString("<w:pageBreakBefore/>")
+		}
+		builder.WriteString(numPr)
+		builder.WriteString("</w:pPr>")
+	}
+	if text != "" {
+		builder.WriteString(`<w:r><w:t xml:space="preserve">` + xmlEscape(text) + `</w:t></w:r>`)
+	}
+	builder.WriteString("</w:p>")
+	return builder.String()
+}
+
+func docxStyles() string {
+	var builder strings.Builder
+	builder.WriteString(xmlHeader + `<w:styles xmlns:w="` + wordNamespace + `">`)
+	builder.WriteString(`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Times New Roman" w:hAnsi="Times New Roman" w:eastAsia="宋体" w:cs="Times New Roman"/><w:sz w:val="21"/><w:szCs w:val="21"/><w:lang w:val="en-US" w:eastAsia="zh-CN"/></w:rPr></w:rPrDefault>` +
+		`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="360" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>`)
+	builder.WriteString(`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>`)
+	builder.WriteString(`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+		`<w:pPr><w:spacing w:before="2400" w:after="600"/><w:jc w:val="center"/></w:pPr><w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="44"/><w:szCs w:val="44"/></w:rPr></w:style>`)
+	builder.WriteString(`<w:style w:type="paragraph" w:styleId="Subtitle"><w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+		`<w:pPr><w:jc w:val="center"/></w:pPr><w:rPr><w:color w:val="595959"/><w:sz w:val="24"/><w:szCs w:val="24"/></w:rPr></w:style>`)
+	for i, size := range docxHeadingSizes {
+		builder.WriteString(fmt.Sprintf(`<w:style w:type="paragraph" w:styleId="Heading%d"><w:name w:val="heading %d"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>`+
+			`<w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:outlineLvl w:val="%d"/></w:pPr>`+
+			`<w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="%d"/><w:szCs w:val="%d"/></w:rPr></w:style>`, i+1, i+1, i, size, size))
+	}
+	builder.WriteString(`<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/><w:basedOn w:val="Normal"/><w:qFormat/><w:pPr><w:spacing w:after="60"/></w:pPr></w:style>`)
+	builder.WriteString(`</w:styles>`)
+	return builder.String()
+}
+
+// docxNumberingPart 定义项目符号列表，各级依次缩进
+func docxNumberingPart() string {
+	bullets := []string{"•", "◦", "▪"}
+	var builder strings.Builder
+	builder.WriteString(xmlHeader + `<w:numbering xmlns:w="` + wordNamespace + `"><w:abstractNum w:abstractNumId="0"><w:multiLevelType w:val="hybridMultilevel"/>`)
+	for level := 0; level < docxMaxLevels; level++ {
+		builder.WriteString(fmt.Sprintf(`<w:lvl w:ilvl="%d"><w:start w:val="1"/><w:numFmt w:val="bullet"/><w:lvlText w:val="%s"/><w:lvlJc w:val="left"/>`+
+			`<w:pPr><w:ind w:left="%d" w:hanging="420"/></w:pPr></w:lvl>`, level, bullets[level%len(bullets)], 420*(level+1)))
+	}
+	builder.WriteString(`</w:abstractNum><w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num></w:numbering>`)
+	return builder.String()
+}
+
+func docxCoreProperties(book *Book) string {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return xmlHeader + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<dc:title>` + xmlEscape(book.Title) + `</dc:title>` +
+		`<dc:description>` + xmlEscape(book.Description) + `</dc:description>` +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + now + `</dcterms:created>` +
+		`<dcterms:modified xsi:type="dcterms:W3CDTF">` + now + `</dcterms:modified>` +
+		`</cp:coreProperties>`
+}
+
+// clampListLevel 将列表层级限制在Word支持的1-9级
+func clampListLevel(level int) int {
+	if level < 1 {
+		return 1
+	}
+	if level > docxMaxLevels {
+		return docxMaxLevels
+	}
+	return level
+}
+
+const docxContentTypes = xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`</Types>`
+
+const docxPackageRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+const docxDocumentRels = xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>` +
+	`</Relationships>`
@@ -0,0 +1,206 @@
+package document
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const epubStyle = `body { font-family: serif; line-height: 1.6; margin: 0 5%; }
+h1, h2, h3, h4, h5, h6 { font-family: sans-serif; line-height: 1.3; }
+.title-page { text-align: center; margin-top: 30%; }
+.title-page h1 { font-size: 2em; }
+.description { color: #555; }
+ul { padding-left: 1.5em; }
+`
+
+// epubFile EPUB中的一个内容文件
+type epubFile struct {
+	id    string
+	name  string
+	title string
+	body  string
+}
+
+// WriteEpub 以EPUB 3格式导出（同时包含EPUB 2的toc.ncx以兼容旧阅读器），分章时每章为单独的内容文件
+func WriteEpub(w io.Writer, book *Book) error {
+	title := book.Title
+	if title == "" {
+		title = "大纲"
+	}
+
+	var files []epubFile
+	if book.TitlePage {
+		var body strings.Builder
+		body.WriteString(`<section class="title-page"><h1>` + xmlEscape(title) + `</h1>`)
+		for _, line := range strings.Split(book.Description, "\n") {
+			if strings.TrimSpace(line) != "" {
+				body.WriteString(`<p class="description">` + xmlEscape(strings.TrimSpace(line)) + `</p>`)
+			}
+		}
+		body.WriteString(`</section>`)
+		files = append(files, epubFile{id: "title", name: "title.xhtml", title: title, body: body.String()})
+	}
+	for i, chapter := range book.Chapters {
+		chapterTitle := chapter.Title
+		if chapterTitle == "" {
+			chapterTitle = title
+		}
+		files = append(files, epubFile{
+			id:    fmt.Sprintf("chapter%d", i+1),
+			name:  fmt.Sprintf("chapter%d.xhtml", i+1),
+			title: chapterTitle,
+			body:  epubChapterBody(chapter),
+		})
+	}
+
+	identifier := "urn:uuid:" + uuid.New().String()
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"META-INF/container.xml", epubContainer},
+		{"OEBPS/content.opf", epubPackage(book, title, identifier, files)},
+		{"OEBPS/nav.xhtml", epubNav(title, files)},
+		{"OEBPS/toc.ncx", epubNcx(title, identifier, files)},
+		{"OEBPS/style.css", epubStyle},
+	}
+	for _, file := range files {
+		parts = append(parts, struct {
+			name    string
+			content string
+		}{"OEBPS/" + file.name, epubXhtml(file.title, file.body)})
+	}
+
+	archive := zip.NewWriter(w)
+	// mimetype必须是第一个文件且不压缩
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func epubChapterBody(chapter *Chapter) string {
+	var builder strings.Builder
+	listDepth := 0
+	for _, block := range chapter.Blocks {
+		target := 0
+		if block.Type == BlockItem {
+			target = block.Level
+		}
+		// 按列表层级打开或关闭嵌套的ul，嵌套列表放在上一个li之内
+		for listDepth > target {
+			builder.WriteString("</li></ul>")
+			listDepth--
+		}
+		if listDepth > 0 && listDepth == target {
+			builder.WriteString("</li>")
+		}
+		for listDepth < target {
+			builder.WriteString("<ul>")
+			listDepth++
+			if listDepth < target {
+				builder.WriteString("<li>")
+			}
+		}
+
+		switch block.Type {
+		case BlockHeading:
+			builder.WriteString(fmt.Sprintf("<h%d>%s</h%d>\n", block.Level, xmlEscape(block.Text), block.Level))
+		case BlockItem:
+			builder.WriteString("<li>" + xmlEscape(block.Text))
+		default:
+			builder.WriteString("<p>" + xmlEscape(block.Text) + "</p>\n")
+		}
+	}
+	for listDepth > 0 {
+		builder.WriteString("</li></ul>")
+		listDepth--
+	}
+	return builder.String()
+}
+
+func epubXhtml(title string, body string) string {
+	return xmlHeader + `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="zh-CN" lang="zh-CN">
+<head><meta charset="UTF-8"/><title>` + xmlEscape(title) + `</title><link rel="stylesheet" type="text/css" href="style.css"/></head>
+<body>
+` + body + `
+</body>
+</html>
+`
+}
+
+func epubPackage(book *Book, title string, identifier string, files []epubFile) string {
+	var manifest, spine strings.Builder
+	for _, file := range files {
+		manifest.WriteString(fmt.Sprintf(`<item id="%s" href="%s" media-type="application/xhtml+xml"/>`+"\n", file.id, file.name))
+		spine.WriteString(fmt.Sprintf(`<itemref idref="%s"/>`+"\n", file.id))
+	}
+	description := ""
+	if book.Description != "" {
+		description = "<dc:description>" + xmlEscape(book.Description) + "</dc:description>\n"
+	}
+	return xmlHeader + `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="zh-CN">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="book-id">` + identifier + `</dc:identifier>
+<dc:title>` + xmlEscape(title) + `</dc:title>
+<dc:language>zh-CN</dc:language>
+` + description + `<meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + `</meta>
+</metadata>
+<manifest>
+<item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+<item id="style" href="style.css" media-type="text/css"/>
+` + manifest.String() + `</manifest>
+<spine toc="ncx">
+` + spine.String() + `</spine>
+</package>
+`
+}
+
+func epubNav(title string, files []epubFile) string {
+	var items strings.Builder
+	for _, file := range files {
+		items.WriteString(`<li><a href="` + file.name + `">` + xmlEscape(file.title) + "</a></li>\n")
+	}
+	return epubXhtml(title, `<nav epub:type="toc" id="toc"><h1>目录</h1><ol>
+`+items.String()+`</ol></nav>`)
+}
+
+func epubNcx(title string, identifier string, files []epubFile) string {
+	var points strings.Builder
+	for i, file := range files {
+		points.WriteString(fmt.Sprintf(`<navPoint id="nav%d" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/></navPoint>`+"\n",
+			i+1, i+1, xmlEscape(file.title), file.name))
+	}
+	return xmlHeader + `<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="` + identifier + `"/><meta name="dtb:depth" content="1"/></head>
+<docTitle><text>` + xmlEscape(title) + `</text></docTitle>
+<navMap>
+` + points.String() + `</navMap>
+</ncx>
+`
+}
+
+const epubContainer = xmlHeader + `<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>
+`
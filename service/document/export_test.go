@@ -0,0 +1,256 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// exportOutline 导出测试使用的大纲，包含两章、情节点和正文
+const exportOutline = "# 第一章 归乡\n主角在雨夜回到了小镇。\n- 遇到旧友\n# 第二章 渡口\n主角来到渡口，等待天亮。\n"
+
+// readZip 读取压缩包，返回按顺序排列的文件名和文件内容
+func readZip(t *testing.T, data []byte) ([]string, map[string]string) {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	names := make([]string, 0, len(archive.File))
+	contents := make(map[string]string, len(archive.File))
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		names = append(names, file.Name)
+		contents[file.Name] = string(content)
+	}
+	return names, contents
+}
+
+func TestNewBookChapters(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		splitChapters bool
+		want          []string // 各章标题
+	}{
+		{name: "不分章", content: exportOutline, want: []string{"第一章 归乡"}},
+		{name: "按章节标记分章", content: exportOutline, splitChapters: true, want: []string{"第一章 归乡", "第二章 渡口"}},
+		{
+			name:          "章节在第二层",
+			content:       "# 第一部\n## 第一章 归乡\n主角回到小镇。\n## 第二章 渡口\n主角来到渡口。\n# 第二部\n## 第三章 远行\n主角离开小镇。\n",
+			splitChapters: true,
+			want:          []string{"第一部", "第一章 归乡", "第二章 渡口", "第二部", "第三章 远行"},
+		},
+		{name: "没有章节标记时以顶层节点分章", content: "# 起\n开端。\n# 承\n发展。\n", splitChapters: true, want: []string{"起", "承"}},
+		{name: "空大纲", content: "", splitChapters: true, want: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := NewBook("长夜将尽", "", tt.content, false, tt.splitChapters)
+			var got []string
+			for _, chapter := range book.Chapters {
+				got = append(got, chapter.Title)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("NewBook() chapters = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteDocx(t *testing.T) {
+	for _, split := range []bool{false, true} {
+		t.Run(fmt.Sprintf("split=%v", split), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteDocx(&buf, NewBook("长夜将尽", "一个关于归乡的故事", exportOutline, true, split)); err != nil {
+				t.Fatalf("WriteDocx() error = %v", err)
+			}
+			names, contents := readZip(t, buf.Bytes())
+			for _, part := range []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml", "word/styles.xml", "word/numbering.xml"} {
+				if _, ok := contents[part]; !ok {
+					t.Errorf("WriteDocx() parts = %v, missing %s", names, part)
+				}
+			}
+			pageBreaks := strings.Count(contents["word/document.xml"], "<w:pageBreakBefore/>")
+			// 标题页之后分页，分章时第二章前也分页
+			wantBreaks := 1
+			if split {
+				wantBreaks = 2
+			}
+			if pageBreaks != wantBreaks {
+				t.Errorf("page breaks = %d, want %d", pageBreaks, wantBreaks)
+			}
+
+			// 导出的文件可以重新导入，标题和正文保持不变
+			text, err := ParseDocxReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("ParseDocxReader() error = %v", err)
+			}
+			for _, want := range []string{"# 第一章 归乡", "主角在雨夜回到了小镇。", "遇到旧友", "# 第二章 渡口", "主角来到渡口，等待天亮。"} {
+				if !strings.Contains(text, want) {
+					t.Errorf("ParseDocxReader() = %q, missing %q", text, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteEpub(t *testing.T) {
+	tests := []struct {
+		name      string
+		split     bool
+		titlePage bool
+		want      []string // 期望的章节文件
+	}{
+		{name: "不分章", want: []string{"OEBPS/chapter1.xhtml"}},
+		{name: "分章", split: true, want: []string{"OEBPS/chapter1.xhtml", "OEBPS/chapter2.xhtml"}},
+		{name: "分章带标题页", split: true, titlePage: true, want: []string{"OEBPS/title.xhtml", "OEBPS/chapter1.xhtml", "OEBPS/chapter2.xhtml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteEpub(&buf, NewBook("长夜将尽", "一个关于归乡的故事", exportOutline, tt.titlePage, tt.split)); err != nil {
+				t.Fatalf("WriteEpub() error = %v", err)
+			}
+			archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("zip.NewReader() error = %v", err)
+			}
+			// mimetype必须是第一个文件且不压缩
+			if first := archive.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+				t.Errorf("first entry = %s (method %d), want stored mimetype", first.Name, first.Method)
+			}
+
+			names, contents := readZip(t, buf.Bytes())
+			if contents["mimetype"] != "application/epub+zip" {
+				t.Errorf("mimetype = %q", contents["mimetype"])
+			}
+			for _, part := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/toc.ncx"} {
+				if _, ok := contents[part]; !ok {
+					t.Errorf("WriteEpub() parts = %v, missing %s", names, part)
+				}
+			}
+			var chapters []string
+			for _, name := range names {
+				if strings.HasPrefix(name, "OEBPS/") && strings.HasSuffix(name, ".xhtml") && name != "OEBPS/nav.xhtml" {
+					chapters = append(chapters, name)
+					// 内容页在content.opf的清单中并出现在目录中
+					file := strings.TrimPrefix(name, "OEBPS/")
+					if !strings.Contains(contents["OEBPS/content.opf"], `href="`+file+`"`) || !strings.Contains(contents["OEBPS/nav.xhtml"], `href="`+file+`"`) {
+						t.Errorf("%s not listed in content.opf or nav.xhtml", name)
+					}
+				}
+			}
+			if strings.Join(chapters, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("content files = %v, want %v", chapters, tt.want)
+			}
+		})
+	}
+}
+
+// pdfObjectPattern 交叉引用表中的对象条目
+var pdfObjectPattern = regexp.MustCompile(`(?m)^(\d{10}) 00000 n $`)
+
+// checkPDF 检查PDF的文件头、文件尾和交叉引用表，返回页数
+func checkPDF(t *testing.T, data []byte) int {
+	t.Helper()
+	text := string(data)
+	if !strings.HasPrefix(text, "%PDF-") || !strings.HasSuffix(text, "%%EOF\n") {
+		t.Fatalf("PDF header or trailer missing")
+	}
+	start := strings.LastIndex(text, "startxref\n")
+	if start < 0 {
+		t.Fatalf("startxref missing")
+	}
+	xref, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(text[start+len("startxref\n"):], "%%EOF\n")))
+	if err != nil || xref <= 0 || xref >= len(text) || !strings.HasPrefix(text[xref:], "xref\n") {
+		t.Fatalf("startxref does not point to the xref table")
+	}
+	// 交叉引用表中的每个偏移都指向对应编号的对象
+	trailer := strings.Index(text[xref:], "trailer")
+	entries := pdfObjectPattern.FindAllStringSubmatch(text[xref:xref+trailer], -1)
+	if len(entries) == 0 {
+		t.Fatalf("xref table is empty")
+	}
+	for i, match := range entries {
+		offset, _ := strconv.Atoi(match[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(text[offset:], want) {
+			t.Errorf("xref entry %d points to %q, want %q", i+1, text[offset:offset+len(want)], want)
+		}
+	}
+	count := regexp.MustCompile(`/Type /Pages /Kids \[[^\]]*\] /Count (\d+)`).FindStringSubmatch(text)
+	if count == nil {
+		t.Fatalf("page tree missing")
+	}
+	pages, _ := strconv.Atoi(count[1])
+	return pages
+}
+
+func TestWritePDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		split     bool
+		titlePage bool
+		pages     int
+	}{
+		{name: "不分章", content: exportOutline, pages: 1},
+		{name: "分章时每章另起一页", content: exportOutline, split: true, pages: 2},
+		{name: "标题页单独一页", content: exportOutline, split: true, titlePage: true, pages: 3},
+		{name: "空大纲也输出一页", content: "", pages: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WritePDF(&buf, NewBook("长夜将尽", "一个关于归乡的故事", tt.content, tt.titlePage, tt.split), nil); err != nil {
+				t.Fatalf("WritePDF() error = %v", err)
+			}
+			if pages := checkPDF(t, buf.Bytes()); pages != tt.pages {
+				t.Errorf("WritePDF() pages = %d, want %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestWriteTextSplitChapters(t *testing.T) {
+	tests := []struct {
+		name   string
+		writer func(w io.Writer, book *Book) error
+		want   []string
+	}{
+		{name: "纯文本", writer: WriteText, want: []string{"00_长夜将尽.txt", "01_第一章 归乡.txt", "02_第二章 渡口.txt"}},
+		{name: "Markdown", writer: WriteMarkdown, want: []string{"00_长夜将尽.md", "01_第一章 归乡.md", "02_第二章 渡口.md"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.writer(&buf, NewBook("长夜将尽", "一个关于归乡的故事", exportOutline, true, true)); err != nil {
+				t.Fatalf("write error = %v", err)
+			}
+			names, contents := readZip(t, buf.Bytes())
+			if strings.Join(names, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("files = %q, want %q", names, tt.want)
+			}
+			// 每个文件只包含本章的内容
+			if first := contents[tt.want[1]]; !strings.Contains(first, "主角在雨夜回到了小镇。") || strings.Contains(first, "渡口") {
+				t.Errorf("%s = %q", tt.want[1], first)
+			}
+			if second := contents[tt.want[2]]; !strings.Contains(second, "主角来到渡口，等待天亮。") || strings.Contains(second, "小镇") {
+				t.Errorf("%s = %q", tt.want[2], second)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package document
+
+import (
+	"encoding/xml"
+	"fmt"
+	"gin-template/util"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// docxMaxLevels Word 列表最多9级
+const docxMaxLevels = 9
+
+// docxLevel 列表某一级的编号格式
+type docxLevel struct {
+	start   int
+	numFmt  string
+	lvlText string
+	restart int // lvlRestart，-1表示默认（上一级出现时重新编号），0表示从不重新编号
+}
+
+// docxAbstractNum 抽象编号定义
+type docxAbstractNum struct {
+	levels [docxMaxLevels]*docxLevel
+}
+
+// docxNum 编号实例，引用抽象编号并可覆盖起始值
+type docxNum struct {
+	abstractId     string
+	startOverrides map[int]int
+	started        bool
+}
+
+// docxNumbering numbering.xml 的解析结果及各列表的当前计数
+type docxNumbering struct {
+	abstracts map[string]*docxAbstractNum
+	nums      map[string]*docxNum
+	// 编号计数按抽象编号保存，引用同一抽象编号的列表连续编号
+	counters map[string]*docxCounter
+}
+
+// docxCounter 各级列表的当前编号，set为false表示该级尚未开始编号
+type docxCounter struct {
+	values [docxMaxLevels]int
+	set    [docxMaxLevels]bool
+}
+
+func newDocxNumbering() *docxNumbering {
+	return &docxNumbering{
+		abstracts: make(map[string]*docxAbstractNum),
+		nums:      make(map[string]*docxNum),
+		counters:  make(map[string]*docxCounter),
+	}
+}
+
+func (n *docxNumbering) parse(decoder *xml.Decoder) error {
+	var abstract *docxAbstractNum
+	var level *docxLevel
+	var num *docxNum
+	overrideLevel := -1
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := element.Name
+			switch {
+			case isWord(name, "abstractNum"):
+				abstract = &docxAbstractNum{}
+				n.abstracts[wordAttr(element, "abstractNumId")] = abstract
+			case isWord(name, "num"):
+				num = &docxNum{startOverrides: make(map[int]int)}
+				n.nums[wordAttr(element, "numId")] = num
+			case isWord(name, "abstractNumId") && num != nil:
+				num.abstractId = wordAttr(element, "val")
+			case isWord(name, "lvlOverride") && num != nil:
+				overrideLevel, _ = strconv.Atoi(wordAttr(element, "ilvl"))
+			case isWord(name, "startOverride") && num != nil && overrideLevel >= 0:
+				if start, err := strconv.Atoi(wordAttr(element, "val")); err == nil {
+					num.startOverrides[overrideLevel] = start
+				}
+			case isWord(name, "lvl") && abstract != nil:
+				ilvl, err := strconv.Atoi(wordAttr(element, "ilvl"))
+				if err != nil || ilvl < 0 || ilvl >= docxMaxLevels {
+					level = nil
+					continue
+				}
+				level = &docxLevel{start: 1, numFmt: "decimal", restart: -1}
+				abstract.levels[ilvl] = level
+			case level == nil:
+			case isWord(name, "start"):
+				if start, err := strconv.Atoi(wordAttr(element, "val")); err == nil {
+					level.start = start
+				}
+			case isWord(name, "numFmt"):
+				level.numFmt = wordAttr(element, "val")
+			case isWord(name, "lvlText"):
+				level.lvlText = wordAttr(element, "val")
+			case isWord(name, "lvlRestart"):
+				if restart, err := strconv.Atoi(wordAttr(element, "val")); err == nil {
+					level.restart = restart
+				}
+			}
+		case xml.EndElement:
+			switch {
+			case isWord(element.Name, "lvl"):
+				level = nil
+			case isWord(element.Name, "abstractNum"):
+				abstract = nil
+			case isWord(element.Name, "lvlOverride"):
+				overrideLevel = -1
+			case isWord(element.Name, "num"):
+				num = nil
+			}
+		}
+	}
+}
+
+// next 推进列表计数并返回该段落显示的编号，第二个返回值表示是否为项目符号
+func (n *docxNumbering) next(numId string, ilvl int) (string, bool) {
+	num, ok := n.nums[numId]
+	if !ok {
+		return "", true
+	}
+	abstract, ok := n.abstracts[num.abstractId]
+	if !ok {
+		return "", true
+	}
+	if ilvl < 0 || ilvl >= docxMaxLevels {
+		ilvl = 0
+	}
+	level := abstract.levels[ilvl]
+	if level == nil {
+		return "", true
+	}
+
+	counter, ok := n.counters[num.abstractId]
+	if !ok {
+		counter = &docxCounter{}
+		n.counters[num.abstractId] = counter
+	}
+	// 带起始值覆盖的编号实例第一次出现时重新开始编号
+	if !num.started {
+		num.started = true
+		for overridden, start := range num.startOverrides {
+			if overridden >= 0 && overridden < docxMaxLevels {
+				counter.values[overridden] = start - 1
+				counter.set[overridden] = true
+			}
+		}
+	}
+
+	if !counter.set[ilvl] {
+		counter.values[ilvl] = level.start - 1
+		counter.set[ilvl] = true
+	}
+	counter.values[ilvl]++
+	// 下级列表在上级出现后重新编号
+	for deeper := ilvl + 1; deeper < docxMaxLevels; deeper++ {
+		if deeperLevel := abstract.levels[deeper]; deeperLevel == nil || deeperLevel.restart != 0 {
+			counter.set[deeper] = false
+		}
+	}
+
+	if level.numFmt == "bullet" || level.numFmt == "none" {
+		return "", level.numFmt == "bullet"
+	}
+
+	label := level.lvlText
+	for i := docxMaxLevels - 1; i >= 0; i-- {
+		placeholder := "%" + strconv.Itoa(i+1)
+		if !strings.Contains(label, placeholder) {
+			continue
+		}
+		value := counter.values[i]
+		format := "decimal"
+		if lvl := abstract.levels[i]; lvl != nil {
+			format = lvl.numFmt
+			if !counter.set[i] {
+				value = lvl.start
+			}
+		}
+		label = strings.ReplaceAll(label, placeholder, formatDocxNumber(value, format))
+	}
+	return strings.TrimSpace(label), false
+}
+
+// formatDocxNumber 按 Word 的编号格式格式化数字，不支持的格式按阿拉伯数字输出
+func formatDocxNumber(value int, format string) string {
+	switch format {
+	case "lowerLetter":
+		return docxLetters(value, 'a')
+	case "upperLetter":
+		return docxLetters(value, 'A')
+	case "lowerRoman":
+		return strings.ToLower(docxRoman(value))
+	case "upperRoman":
+		return docxRoman(value)
+	case "chineseCounting", "chineseCountingThousand", "ideographTraditional", "ideographLegalTraditional",
+		"japaneseCounting", "taiwaneseCounting", "taiwaneseCountingThousand":
+		if format == "ideographTraditional" && value >= 1 && value <= 10 {
+			return string([]rune("甲乙丙丁戊己庚辛壬癸")[value-1])
+		}
+		return util.FormatChineseNumber(value)
+	case "decimalZero":
+		return fmt.Sprintf("%02d", value)
+	case "decimalEnclosedCircle", "decimalEnclosedCircleChinese":
+		if value >= 1 && value <= 20 {
+			return string(rune('①' + value - 1))
+		}
+	case "decimalFullWidth", "decimalFullWidth2":
+		return strings.Map(func(r rune) rune { return r - '0' + '０' }, strconv.Itoa(value))
+	}
+	return strconv.Itoa(value)
+}
+
+// docxLetters 1->a, 26->z, 27->aa（Word 的字母编号按重复字母递增）
+func docxLetters(value int, base rune) string {
+	if value <= 0 {
+		return strconv.Itoa(value)
+	}
+	letter := string(base + rune((value-1)%26))
+	return strings.Repeat(letter, (value-1)/26+1)
+}
+
+func docxRoman(value int) string {
+	if value <= 0 || value >= 4000 {
+		return strconv.Itoa(value)
+	}
+	numerals := []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+	var builder strings.Builder
+	for _, numeral := range numerals {
+		for value >= numeral.value {
+			builder.WriteString(numeral.symbol)
+			value -= numeral.value
+		}
+	}
+	return builder.String()
+}
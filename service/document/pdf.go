@@ -0,0 +1,532 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"gin-template/util"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// PDF页面尺寸（A4，单位为磅）及版式
+const (
+	pdfPageWidth    = 595.28
+	pdfPageHeight   = 841.89
+	pdfMarginX      = 72.0
+	pdfMarginTop    = 72.0
+	pdfMarginBottom = 72.0
+	pdfBodySize     = 11.0
+	pdfTitleSize    = 26.0
+	pdfLineSpacing  = 1.6
+	pdfListIndent   = 18.0
+)
+
+// pdfHeadingSizes 各级标题字号
+var pdfHeadingSizes = [maxHeadingLevel]float64{20, 17, 15, 13, 12, 11.5}
+
+// 不能出现在行首的标点（避头）和不能出现在行尾的标点（避尾）
+const (
+	pdfNoLineStart = "，。、；：？！）》」』】〉〕”’…—·,.;:?!)]}%"
+	pdfNoLineEnd   = "（《「『【〈〔“‘([{"
+)
+
+// WritePDF 以PDF格式导出，分章时每章另起一页
+// font为nil时使用PDF阅读器内置的中文字体STSong-Light（不嵌入字体，显示效果取决于阅读器）
+func WritePDF(w io.Writer, book *Book, font *TrueTypeFont) error {
+	var pf pdfFont
+	if font != nil {
+		pf = &pdfEmbeddedFont{ttf: font, used: make(map[uint16]rune)}
+	} else {
+		pf = pdfBuiltinFont{}
+	}
+	layout := &pdfLayout{font: pf}
+
+	if book.TitlePage {
+		layout.newPage(false)
+		layout.y = pdfPageHeight * 0.7
+		title := book.Title
+		if title == "" {
+			title = "大纲"
+		}
+		layout.paragraph(title, pdfTitleSize, 0, 0, pdfTitleSize, true)
+		for _, line := range strings.Split(book.Description, "\n") {
+			if strings.TrimSpace(line) != "" {
+				layout.paragraph(strings.TrimSpace(line), 12, 0, 0, 4, true)
+			}
+		}
+	}
+
+	for i, chapter := range book.Chapters {
+		if layout.page == nil || !layout.page.numbered || (book.SplitChapters && i > 0) {
+			layout.newPage(true)
+		}
+		for _, block := range chapter.Blocks {
+			switch block.Type {
+			case BlockHeading:
+				size := pdfHeadingSizes[block.Level-1]
+				// 标题与下一行正文放在同一页
+				layout.ensureSpace(size*pdfLineSpacing + size*0.6 + pdfBodySize*pdfLineSpacing)
+				page, y := layout.paragraph(block.Text, size, 0, size*0.6, size*0.3, false)
+				if block.Level <= 2 {
+					layout.bookmarks = append(layout.bookmarks, &pdfBookmark{title: block.Text, level: block.Level, page: page, y: y})
+				}
+			case BlockItem:
+				indent := pdfListIndent * float64(block.Level)
+				page, y := layout.paragraph(block.Text, pdfBodySize, indent, 0, 2, false)
+				layout.pages[page].bullet(pdfMarginX+indent-pdfListIndent/2, y-pdfBodySize*0.35, block.Level)
+			default:
+				layout.paragraph(block.Text, pdfBodySize, 0, 0, 4, false)
+			}
+		}
+	}
+	if len(layout.pages) == 0 {
+		layout.newPage(true)
+	}
+
+	data, err := layout.render(book.Title)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// pdfFont PDF中使用的字体，宽度单位为1/1000 em
+type pdfFont interface {
+	width(r rune) float64
+	// encode 将文字编码为十六进制字符串，用于Tj操作符
+	encode(text string) string
+	// write 写入字体相关对象，返回Type0字体字典的对象编号
+	write(w *pdfWriter) int
+}
+
+// pdfBuiltinFont 阅读器内置的中文字体（Adobe-GB1字符集），不嵌入字体程序
+type pdfBuiltinFont struct{}
+
+func (pdfBuiltinFont) width(r rune) float64 {
+	if r < 0x80 {
+		return 500
+	}
+	return 1000
+}
+
+func (pdfBuiltinFont) encode(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		builder.WriteString(fmt.Sprintf("%04X", r))
+	}
+	return builder.String()
+}
+
+func (pdfBuiltinFont) write(w *pdfWriter) int {
+	descriptor := w.reserve()
+	w.object(descriptor, "<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>")
+	cidFont := w.reserve()
+	w.object(cidFont, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor %d 0 R /DW 1000 /W [1 95 500] >>", descriptor))
+	font := w.reserve()
+	w.object(font, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [%d 0 R] >>", cidFont))
+	return font
+}
+
+// pdfEmbeddedFont 嵌入PDF的TrueType字体子集，字形编号直接作为CID（Identity-H编码）
+type pdfEmbeddedFont struct {
+	ttf  *TrueTypeFont
+	used map[uint16]rune // 已使用的字形及其对应的字符，用于生成子集和ToUnicode
+}
+
+func (f *pdfEmbeddedFont) width(r rune) float64 {
+	return f.ttf.advance(f.ttf.Glyph(r))
+}
+
+func (f *pdfEmbeddedFont) encode(text string) string {
+	var builder strings.Builder
+	for _, r := range text {
+		gid := f.ttf.Glyph(r)
+		if gid != 0 {
+			if _, ok := f.used[gid]; !ok {
+				f.used[gid] = r
+			}
+		}
+		builder.WriteString(fmt.Sprintf("%04X", gid))
+	}
+	return builder.String()
+}
+
+func (f *pdfEmbeddedFont) write(w *pdfWriter) int {
+	glyphs := make([]int, 0, len(f.used))
+	usedSet := make(map[uint16]bool, len(f.used))
+	for gid := range f.used {
+		glyphs = append(glyphs, int(gid))
+		usedSet[gid] = true
+	}
+	sort.Ints(glyphs)
+
+	// 子集字体名前缀由6个大写字母组成，按所用字形生成
+	hash := md5.New()
+	for _, gid := range glyphs {
+		fmt.Fprintf(hash, "%d,", gid)
+	}
+	sum := hash.Sum(nil)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	baseFont := string(tag) + "+" + f.ttf.Name
+
+	program := f.ttf.Subset(usedSet)
+	fontFile := w.reserve()
+	w.stream(fontFile, fmt.Sprintf("/Length1 %d", len(program)), program)
+
+	descriptor := w.reserve()
+	ttf := f.ttf
+	w.object(descriptor, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		baseFont, ttf.scale(ttf.bbox[0]), ttf.scale(ttf.bbox[1]), ttf.scale(ttf.bbox[2]), ttf.scale(ttf.bbox[3]),
+		ttf.scale(ttf.ascent), ttf.scale(ttf.descent), ttf.scale(ttf.capHeight), fontFile))
+
+	var widths strings.Builder
+	for _, gid := range glyphs {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, int(ttf.advance(uint16(gid))+0.5))
+	}
+	cidFont := w.reserve()
+	w.object(cidFont, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s] /CIDToGIDMap /Identity >>",
+		baseFont, descriptor, strings.TrimSpace(widths.String())))
+
+	toUnicode := w.reserve()
+	w.stream(toUnicode, "", []byte(f.toUnicode(glyphs)))
+
+	font := w.reserve()
+	w.object(font, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>", baseFont, cidFont, toUnicode))
+	return font
+}
+
+// toUnicode 生成字形到Unicode的映射，使导出的PDF可以复制和搜索文字
+func (f *pdfEmbeddedFont) toUnicode(glyphs []int) string {
+	var builder strings.Builder
+	builder.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(glyphs); start += 100 {
+		end := start + 100
+		if end > len(glyphs) {
+			end = len(glyphs)
+		}
+		fmt.Fprintf(&builder, "%d beginbfchar\n", end-start)
+		for _, gid := range glyphs[start:end] {
+			fmt.Fprintf(&builder, "<%04X> <", gid)
+			for _, unit := range utf16.Encode([]rune{f.used[uint16(gid)]}) {
+				fmt.Fprintf(&builder, "%04X", unit)
+			}
+			builder.WriteString(">\n")
+		}
+		builder.WriteString("endbfchar\n")
+	}
+	builder.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return builder.String()
+}
+
+// pdfPage 一页的内容流
+type pdfPage struct {
+	content  bytes.Buffer
+	numbered bool // 是否显示页码（标题页不显示）
+}
+
+// bullet 在列表项前绘制项目符号，第一级为实心圆点，其余为空心圆点
+func (p *pdfPage) bullet(x float64, y float64, level int) {
+	const radius = 1.8
+	const k = 0.5523 * radius
+	fmt.Fprintf(&p.content, "%.2f %.2f m %.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c %.2f %.2f %.2f %.2f %.2f %.2f c ",
+		x+radius, y,
+		x+radius, y+k, x+k, y+radius, x, y+radius,
+		x-k, y+radius, x-radius, y+k, x-radius, y,
+		x-radius, y-k, x-k, y-radius, x, y-radius,
+		x+k, y-radius, x+radius, y-k, x+radius, y)
+	if level <= 1 {
+		p.content.WriteString("f\n")
+	} else {
+		p.content.WriteString("0.6 w S\n")
+	}
+}
+
+// pdfBookmark PDF书签，一级和二级标题生成书签
+type pdfBookmark struct {
+	title    string
+	level    int
+	page     int
+	y        float64
+	children []*pdfBookmark
+}
+
+// pdfLayout 按页排版文字
+type pdfLayout struct {
+	font      pdfFont
+	pages     []*pdfPage
+	page      *pdfPage
+	y         float64 // 当前位置距页面底边的距离
+	bookmarks []*pdfBookmark
+}
+
+func (l *pdfLayout) newPage(numbered bool) {
+	l.page = &pdfPage{numbered: numbered}
+	l.pages = append(l.pages, l.page)
+	l.y = pdfPageHeight - pdfMarginTop
+}
+
+// ensureSpace 剩余空间不足时换页
+func (l *pdfLayout) ensureSpace(height float64) {
+	if l.page == nil || l.y-height < pdfMarginBottom {
+		l.newPage(true)
+	}
+}
+
+// paragraph 排版一段文字，返回第一行所在的页和基线位置
+func (l *pdfLayout) paragraph(text string, size float64, indent float64, before float64, after float64, center bool) (int, float64) {
+	lineHeight := size * pdfLineSpacing
+	if l.page != nil && l.y < pdfPageHeight-pdfMarginTop {
+		l.y -= before
+	}
+	firstPage, firstY := -1, 0.0
+	for _, line := range l.wrap(text, size, pdfPageWidth-2*pdfMarginX-indent) {
+		l.ensureSpace(lineHeight)
+		baseline := l.y - size - (lineHeight-size)/2
+		x := pdfMarginX + indent
+		if center {
+			x = (pdfPageWidth - l.measure(line, size)) / 2
+		}
+		l.text(x, baseline, size, line)
+		if firstPage < 0 {
+			firstPage, firstY = len(l.pages)-1, baseline
+		}
+		l.y -= lineHeight
+	}
+	l.y -= after
+	return firstPage, firstY
+}
+
+func (l *pdfLayout) text(x float64, y float64, size float64, text string) {
+	fmt.Fprintf(&l.page.content, "BT /F1 %.2f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, l.font.encode(text))
+}
+
+func (l *pdfLayout) measure(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		width += l.font.width(r)
+	}
+	return width * size / 1000
+}
+
+// wrap 按宽度折行：中日韩文字之间可以断行，西文在空格处断行，单词过长时强制断开；
+// 行首不出现逗号、句号等标点，行尾不出现左括号、左引号
+func (l *pdfLayout) wrap(text string, size float64, maxWidth float64) []string {
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	start := 0
+	width := 0.0
+	lastBreak := -1
+	for i := 0; i < len(runes); i++ {
+		if i > start && pdfCanBreakBefore(runes, i) {
+			lastBreak = i
+		}
+		width += l.font.width(runes[i]) * size / 1000
+		if width <= maxWidth || i == start || strings.ContainsRune(pdfNoLineStart, runes[i]) || runes[i] == ' ' {
+			continue
+		}
+
+		breakAt := i
+		if !pdfCanBreakBefore(runes, i) && lastBreak > start {
+			breakAt = lastBreak
+		}
+		lines = append(lines, strings.TrimRight(string(runes[start:breakAt]), " "))
+		for breakAt < len(runes) && runes[breakAt] == ' ' {
+			breakAt++
+		}
+		start = breakAt
+		lastBreak = -1
+		width = 0
+		i = start - 1
+	}
+	if start < len(runes) {
+		lines = append(lines, strings.TrimRight(string(runes[start:]), " "))
+	}
+	return lines
+}
+
+// pdfCanBreakBefore 判断能否在第i个字符之前断行
+func pdfCanBreakBefore(runes []rune, i int) bool {
+	current, previous := runes[i], runes[i-1]
+	if strings.ContainsRune(pdfNoLineStart, current) || strings.ContainsRune(pdfNoLineEnd, previous) {
+		return false
+	}
+	return previous == ' ' || pdfWide(current) || pdfWide(previous)
+}
+
+// pdfWide 中日韩文字及全角标点
+func pdfWide(r rune) bool {
+	return util.IsCJK(r) || (r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFFEF) || strings.ContainsRune("“”‘’…—", r)
+}
+
+// render 输出完整的PDF文件
+func (l *pdfLayout) render(title string) ([]byte, error) {
+	w := &pdfWriter{}
+	catalog := w.reserve()
+	pagesRef := w.reserve()
+
+	// 页码从第一个正文页开始计数
+	number := 0
+	for _, page := range l.pages {
+		if page.numbered {
+			number++
+			label := fmt.Sprintf("%d", number)
+			l.page = page
+			l.text((pdfPageWidth-l.measure(label, 9))/2, pdfMarginBottom/2, 9, label)
+		}
+	}
+
+	fontRef := l.font.write(w)
+	pageRefs := make([]int, len(l.pages))
+	kids := make([]string, len(l.pages))
+	for i, page := range l.pages {
+		content := w.reserve()
+		w.stream(content, "", page.content.Bytes())
+		pageRefs[i] = w.reserve()
+		w.object(pageRefs[i], fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesRef, pdfPageWidth, pdfPageHeight, fontRef, content))
+		kids[i] = fmt.Sprintf("%d 0 R", pageRefs[i])
+	}
+	w.object(pagesRef, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(l.pages)))
+
+	catalogDict := fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /Lang (zh-CN)", pagesRef)
+	if outlines := l.writeBookmarks(w, pageRefs); outlines != 0 {
+		catalogDict += fmt.Sprintf(" /Outlines %d 0 R /PageMode /UseOutlines", outlines)
+	}
+	w.object(catalog, catalogDict+" >>")
+
+	info := w.reserve()
+	w.object(info, fmt.Sprintf("<< /Title %s /CreationDate (D:%s) >>", pdfTextString(title), time.Now().Format("20060102150405")))
+	return w.finish(catalog, info), nil
+}
+
+// writeBookmarks 写入书签，二级标题作为前一个一级标题的子书签，返回书签根对象编号，没有书签时返回0
+func (l *pdfLayout) writeBookmarks(w *pdfWriter, pageRefs []int) int {
+	if len(l.bookmarks) == 0 {
+		return 0
+	}
+	var roots []*pdfBookmark
+	var parent *pdfBookmark
+	for _, bookmark := range l.bookmarks {
+		if bookmark.level > 1 && parent != nil {
+			parent.children = append(parent.children, bookmark)
+			continue
+		}
+		roots = append(roots, bookmark)
+		if bookmark.level == 1 {
+			parent = bookmark
+		}
+	}
+
+	root := w.reserve()
+	first, last, count := l.writeBookmarkList(w, roots, root, pageRefs)
+	w.object(root, fmt.Sprintf("<< /Type /Outlines /First %d 0 R /Last %d 0 R /Count %d >>", first, last, count))
+	return root
+}
+
+func (l *pdfLayout) writeBookmarkList(w *pdfWriter, bookmarks []*pdfBookmark, parent int, pageRefs []int) (int, int, int) {
+	refs := make([]int, len(bookmarks))
+	for i := range bookmarks {
+		refs[i] = w.reserve()
+	}
+	count := len(bookmarks)
+	for i, bookmark := range bookmarks {
+		dict := fmt.Sprintf("<< /Title %s /Parent %d 0 R /Dest [%d 0 R /XYZ 0 %.2f null]",
+			pdfTextString(bookmark.title), parent, pageRefs[bookmark.page], bookmark.y+pdfHeadingSizes[0])
+		if i > 0 {
+			dict += fmt.Sprintf(" /Prev %d 0 R", refs[i-1])
+		}
+		if i < len(refs)-1 {
+			dict += fmt.Sprintf(" /Next %d 0 R", refs[i+1])
+		}
+		if len(bookmark.children) > 0 {
+			first, last, childCount := l.writeBookmarkList(w, bookmark.children, refs[i], pageRefs)
+			dict += fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count %d", first, last, childCount)
+			count += childCount
+		}
+		w.object(refs[i], dict+" >>")
+	}
+	return refs[0], refs[len(refs)-1], count
+}
+
+// pdfTextString 将文字编码为带BOM的UTF-16BE十六进制字符串
+func pdfTextString(text string) string {
+	var builder strings.Builder
+	builder.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(text)) {
+		fmt.Fprintf(&builder, "%04X", unit)
+	}
+	builder.WriteString(">")
+	return builder.String()
+}
+
+// pdfWriter 按对象编号写入PDF对象并生成交叉引用表
+type pdfWriter struct {
+	buffer  bytes.Buffer
+	offsets []int
+}
+
+// reserve 分配一个对象编号，对象可以稍后写入
+func (w *pdfWriter) reserve() int {
+	if w.buffer.Len() == 0 {
+		w.buffer.WriteString("%PDF-1.7\n%\xE2\xE3\xCF\xD3\n")
+	}
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+func (w *pdfWriter) object(id int, body string) {
+	w.offsets[id-1] = w.buffer.Len()
+	fmt.Fprintf(&w.buffer, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// stream 写入压缩后的流对象，dict为流字典中除Length和Filter之外的项
+func (w *pdfWriter) stream(id int, dict string, data []byte) {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	_, _ = writer.Write(data)
+	_ = writer.Close()
+
+	w.offsets[id-1] = w.buffer.Len()
+	fmt.Fprintf(&w.buffer, "%d 0 obj\n<< /Length %d /Filter /FlateDecode %s>>\nstream\n", id, compressed.Len(), strings.TrimSpace(dict)+" ")
+	w.buffer.Write(compressed.Bytes())
+	w.buffer.WriteString("\nendstream\nendobj\n")
+}
+
+func (w *pdfWriter) finish(root int, info int) []byte {
+	xref := w.buffer.Len()
+	fmt.Fprintf(&w.buffer, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buffer, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, root, info, xref)
+	return w.buffer.Bytes()
+}
@@ -0,0 +1,151 @@
+package document
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteText 以纯文本格式导出，分章时输出为每章一个文件的zip压缩包
+func WriteText(w io.Writer, book *Book) error {
+	if book.SplitChapters {
+		return writeChapterArchive(w, book, "txt", renderTextChapter)
+	}
+
+	var builder strings.Builder
+	if book.TitlePage {
+		builder.WriteString(textTitlePage(book))
+	}
+	builder.WriteString(book.Source)
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// WriteMarkdown 以Markdown格式导出，分章时输出为每章一个文件的zip压缩包
+func WriteMarkdown(w io.Writer, book *Book) error {
+	if book.SplitChapters {
+		return writeChapterArchive(w, book, "md", renderMarkdownChapter)
+	}
+
+	var builder strings.Builder
+	if book.TitlePage {
+		builder.WriteString(markdownTitlePage(book))
+	}
+	for _, chapter := range book.Chapters {
+		builder.WriteString(renderMarkdownChapter(chapter))
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// writeChapterArchive 将各章分别渲染后写入zip压缩包，标题页单独保存为00文件
+func writeChapterArchive(w io.Writer, book *Book, ext string, render func(chapter *Chapter) string) error {
+	archive := zip.NewWriter(w)
+	files := make([]string, 0, len(book.Chapters)+1)
+	contents := make([]string, 0, len(book.Chapters)+1)
+	if book.TitlePage {
+		files = append(files, fmt.Sprintf("00_%s.%s", archiveFileName(book.Title, "title"), ext))
+		if ext == "md" {
+			contents = append(contents, markdownTitlePage(book))
+		} else {
+			contents = append(contents, textTitlePage(book))
+		}
+	}
+	for i, chapter := range book.Chapters {
+		files = append(files, fmt.Sprintf("%02d_%s.%s", i+1, archiveFileName(chapter.Title, "chapter"), ext))
+		contents = append(contents, render(chapter))
+	}
+
+	for i, name := range files {
+		file, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, contents[i]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func textTitlePage(book *Book) string {
+	var builder strings.Builder
+	builder.WriteString(book.Title + "\n")
+	if book.Description != "" {
+		builder.WriteString("\n" + book.Description + "\n")
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+func markdownTitlePage(book *Book) string {
+	var builder strings.Builder
+	builder.WriteString("# " + book.Title + "\n\n")
+	if book.Description != "" {
+		for _, line := range strings.Split(book.Description, "\n") {
+			builder.WriteString("> " + strings.TrimSpace(line) + "\n")
+		}
+		builder.WriteString("\n")
+	}
+	if len(book.Chapters) > 0 {
+		builder.WriteString("---\n\n")
+	}
+	return builder.String()
+}
+
+func renderTextChapter(chapter *Chapter) string {
+	var builder strings.Builder
+	for _, block := range chapter.Blocks {
+		switch block.Type {
+		case BlockItem:
+			builder.WriteString(strings.Repeat("  ", block.Level-1) + "- " + block.Text + "\n")
+		default:
+			builder.WriteString(block.Text + "\n")
+		}
+	}
+	return builder.String()
+}
+
+func renderMarkdownChapter(chapter *Chapter) string {
+	var builder strings.Builder
+	previous := ""
+	for _, block := range chapter.Blocks {
+		// 列表项之间不加空行，其他块之间以空行分隔
+		if builder.Len() > 0 && (block.Type != BlockItem || previous != BlockItem) {
+			builder.WriteString("\n")
+		}
+		switch block.Type {
+		case BlockHeading:
+			builder.WriteString(strings.Repeat("#", block.Level) + " " + block.Text + "\n")
+		case BlockItem:
+			builder.WriteString(strings.Repeat("  ", block.Level-1) + "- " + block.Text + "\n")
+		default:
+			builder.WriteString(block.Text + "\n")
+		}
+		previous = block.Type
+	}
+	if builder.Len() > 0 {
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// archiveFileName 将标题转换为可用作文件名的字符串，为空时使用默认名
+func archiveFileName(title string, fallback string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', '\n', '\r', '\t':
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	runes := []rune(name)
+	if len(runes) > 40 {
+		name = string(runes[:40])
+	}
+	if name == "" {
+		return fallback
+	}
+	return name
+}
@@ -0,0 +1,431 @@
+package document
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// TrueTypeFont 用于嵌入PDF的TrueType字体（支持.ttf以及.ttc中的第一个字体）
+// 只支持glyf轮廓的字体，CFF轮廓的OpenType字体（多数.otf）无法嵌入。加载后只读，可在多次导出间共享
+type TrueTypeFont struct {
+	Name       string // PostScript名称
+	unitsPerEm int
+	ascent     int
+	descent    int
+	capHeight  int
+	bbox       [4]int
+	numGlyphs  int
+	longLoca   bool
+	advances   []uint16
+	cmap       map[rune]uint16
+	tables     map[string][]byte
+}
+
+// 嵌入子集字体时保留的表，CIDFontType2不需要cmap等表
+var trueTypeSubsetTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// LoadTrueTypeFont 读取并解析TrueType字体文件
+func LoadTrueTypeFont(path string) (*TrueTypeFont, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取字体文件: %v", err)
+	}
+	return ParseTrueTypeFont(data)
+}
+
+// ParseTrueTypeFont 解析TrueType字体数据，字体集合（.ttc）取第一个字体
+func ParseTrueTypeFont(data []byte) (*TrueTypeFont, error) {
+	offset := 0
+	if len(data) >= 16 && string(data[:4]) == "ttcf" {
+		if binary.BigEndian.Uint32(data[8:]) == 0 {
+			return nil, errors.New("字体集合中没有字体")
+		}
+		offset = int(binary.BigEndian.Uint32(data[12:]))
+	}
+	if offset+12 > len(data) {
+		return nil, errors.New("字体文件格式错误")
+	}
+	switch binary.BigEndian.Uint32(data[offset:]) {
+	case 0x00010000, 0x74727565: // 1.0 或 'true'
+	case 0x4F54544F: // 'OTTO'
+		return nil, errors.New("不支持CFF轮廓的OpenType字体，请使用TrueType字体")
+	default:
+		return nil, errors.New("不是有效的TrueType字体")
+	}
+
+	numTables := int(binary.BigEndian.Uint16(data[offset+4:]))
+	if offset+12+numTables*16 > len(data) {
+		return nil, errors.New("字体文件格式错误")
+	}
+	font := &TrueTypeFont{tables: make(map[string][]byte, numTables)}
+	for i := 0; i < numTables; i++ {
+		record := data[offset+12+i*16:]
+		tag := string(record[:4])
+		start := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if start < 0 || length < 0 || start+length > len(data) {
+			return nil, fmt.Errorf("字体表%s超出文件范围", strings.TrimSpace(tag))
+		}
+		font.tables[tag] = data[start : start+length]
+	}
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf", "cmap"} {
+		if _, ok := font.tables[tag]; !ok {
+			if tag == "glyf" || tag == "loca" {
+				return nil, errors.New("字体不包含TrueType轮廓，无法嵌入")
+			}
+			return nil, fmt.Errorf("字体缺少%s表", tag)
+		}
+	}
+
+	head := font.tables["head"]
+	hhea := font.tables["hhea"]
+	maxp := font.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, errors.New("字体文件格式错误")
+	}
+	font.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	if font.unitsPerEm == 0 {
+		return nil, errors.New("字体文件格式错误")
+	}
+	for i := range font.bbox {
+		font.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+i*2:])))
+	}
+	font.longLoca = binary.BigEndian.Uint16(head[50:]) == 1
+	font.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	font.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	font.capHeight = font.ascent
+	if os2 := font.tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		font.capHeight = int(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	font.numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+
+	numberOfHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := font.tables["hmtx"]
+	if numberOfHMetrics == 0 || len(hmtx) < numberOfHMetrics*4 {
+		return nil, errors.New("字体hmtx表格式错误")
+	}
+	font.advances = make([]uint16, font.numGlyphs)
+	for gid := range font.advances {
+		metric := gid
+		if metric >= numberOfHMetrics {
+			metric = numberOfHMetrics - 1
+		}
+		font.advances[gid] = binary.BigEndian.Uint16(hmtx[metric*4:])
+	}
+
+	cmap, err := parseTrueTypeCmap(font.tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	font.cmap = cmap
+	font.Name = trueTypePostScriptName(font.tables["name"])
+	return font, nil
+}
+
+// Glyph 返回字符对应的字形编号，字体中没有该字符时返回0
+func (f *TrueTypeFont) Glyph(r rune) uint16 {
+	return f.cmap[r]
+}
+
+// HasGlyph 判断字体是否包含该字符
+func (f *TrueTypeFont) HasGlyph(r rune) bool {
+	_, ok := f.cmap[r]
+	return ok
+}
+
+// advance 返回字形宽度（1/1000 em）
+func (f *TrueTypeFont) advance(gid uint16) float64 {
+	if int(gid) >= len(f.advances) {
+		return 0
+	}
+	return float64(f.advances[gid]) * 1000 / float64(f.unitsPerEm)
+}
+
+// scale 将字体单位换算为1/1000 em
+func (f *TrueTypeFont) scale(value int) int {
+	return value * 1000 / f.unitsPerEm
+}
+
+// parseTrueTypeCmap 读取Unicode字符映射，优先使用完整Unicode的格式12子表
+func parseTrueTypeCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, errors.New("字体cmap表格式错误")
+	}
+	var format4, format12 []byte
+	count := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < count && 4+i*8+8 <= len(cmap); i++ {
+		record := cmap[4+i*8:]
+		platform := binary.BigEndian.Uint16(record)
+		encoding := binary.BigEndian.Uint16(record[2:])
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		if offset+4 > len(cmap) {
+			continue
+		}
+		unicode := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicode {
+			continue
+		}
+		switch binary.BigEndian.Uint16(cmap[offset:]) {
+		case 4:
+			if format4 == nil {
+				format4 = cmap[offset:]
+			}
+		case 12:
+			if format12 == nil {
+				format12 = cmap[offset:]
+			}
+		}
+	}
+
+	mapping := make(map[rune]uint16)
+	switch {
+	case format12 != nil && len(format12) >= 16:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for i := 0; i < groups && 16+i*12+12 <= len(format12); i++ {
+			group := format12[16+i*12:]
+			start := binary.BigEndian.Uint32(group)
+			end := binary.BigEndian.Uint32(group[4:])
+			glyph := binary.BigEndian.Uint32(group[8:])
+			if end < start || end > 0x10FFFF {
+				continue
+			}
+			for c := start; c <= end; c++ {
+				if gid := uint16(glyph + c - start); gid != 0 {
+					mapping[rune(c)] = gid
+				}
+			}
+		}
+	case format4 != nil && len(format4) >= 14:
+		segCount := int(binary.BigEndian.Uint16(format4[6:])) / 2
+		if 16+segCount*8 > len(format4) {
+			return nil, errors.New("字体cmap表格式错误")
+		}
+		endCodes := 14
+		startCodes := endCodes + segCount*2 + 2
+		idDeltas := startCodes + segCount*2
+		idRangeOffsets := idDeltas + segCount*2
+		for i := 0; i < segCount; i++ {
+			end := int(binary.BigEndian.Uint16(format4[endCodes+i*2:]))
+			start := int(binary.BigEndian.Uint16(format4[startCodes+i*2:]))
+			delta := binary.BigEndian.Uint16(format4[idDeltas+i*2:])
+			rangeOffset := int(binary.BigEndian.Uint16(format4[idRangeOffsets+i*2:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var glyph uint16
+				if rangeOffset == 0 {
+					glyph = uint16(c) + delta
+				} else {
+					position := idRangeOffsets + i*2 + rangeOffset + (c-start)*2
+					if position+2 > len(format4) {
+						continue
+					}
+					glyph = binary.BigEndian.Uint16(format4[position:])
+					if glyph != 0 {
+						glyph += delta
+					}
+				}
+				if glyph != 0 {
+					mapping[rune(c)] = glyph
+				}
+			}
+		}
+	default:
+		return nil, errors.New("字体没有可用的Unicode字符映射")
+	}
+	return mapping, nil
+}
+
+// trueTypePostScriptName 从name表读取PostScript名称（nameID 6），只保留PDF名称中安全的字符
+func trueTypePostScriptName(table []byte) string {
+	name := ""
+	if len(table) >= 6 {
+		count := int(binary.BigEndian.Uint16(table[2:]))
+		storage := int(binary.BigEndian.Uint16(table[4:]))
+		for i := 0; i < count && 6+i*12+12 <= len(table); i++ {
+			record := table[6+i*12:]
+			platform := binary.BigEndian.Uint16(record)
+			nameId := binary.BigEndian.Uint16(record[6:])
+			length := int(binary.BigEndian.Uint16(record[8:]))
+			offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+			if nameId != 6 || offset+length > len(table) {
+				continue
+			}
+			raw := table[offset : offset+length]
+			if platform == 3 || platform == 0 {
+				units := make([]uint16, len(raw)/2)
+				for j := range units {
+					units[j] = binary.BigEndian.Uint16(raw[j*2:])
+				}
+				name = string(utf16.Decode(units))
+			} else {
+				name = string(raw)
+			}
+			break
+		}
+	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" {
+		return "EmbeddedFont"
+	}
+	return name
+}
+
+// glyphData 返回字形在glyf表中的原始数据
+func (f *TrueTypeFont) glyphData(gid int) []byte {
+	loca := f.tables["loca"]
+	glyf := f.tables["glyf"]
+	var start, end int
+	if f.longLoca {
+		if (gid+2)*4 > len(loca) {
+			return nil
+		}
+		start = int(binary.BigEndian.Uint32(loca[gid*4:]))
+		end = int(binary.BigEndian.Uint32(loca[gid*4+4:]))
+	} else {
+		if (gid+2)*2 > len(loca) {
+			return nil
+		}
+		start = int(binary.BigEndian.Uint16(loca[gid*2:])) * 2
+		end = int(binary.BigEndian.Uint16(loca[gid*2+2:])) * 2
+	}
+	if start >= end || end > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// compositeComponents 返回组合字形引用的字形编号
+func compositeComponents(glyph []byte) []uint16 {
+	if len(glyph) < 10 || int16(binary.BigEndian.Uint16(glyph)) >= 0 {
+		return nil
+	}
+	var components []uint16
+	position := 10
+	for position+4 <= len(glyph) {
+		flags := binary.BigEndian.Uint16(glyph[position:])
+		components = append(components, binary.BigEndian.Uint16(glyph[position+2:]))
+		position += 4
+		if flags&0x0001 != 0 { // ARG_1_AND_2_ARE_WORDS
+			position += 4
+		} else {
+			position += 2
+		}
+		switch {
+		case flags&0x0008 != 0: // WE_HAVE_A_SCALE
+			position += 2
+		case flags&0x0040 != 0: // WE_HAVE_AN_X_AND_Y_SCALE
+			position += 4
+		case flags&0x0080 != 0: // WE_HAVE_A_TWO_BY_TWO
+			position += 8
+		}
+		if flags&0x0020 == 0 { // MORE_COMPONENTS
+			break
+		}
+	}
+	return components
+}
+
+// Subset 生成只包含指定字形的字体程序，字形编号保持不变（未使用的字形为空），用于以Identity方式嵌入PDF
+func (f *TrueTypeFont) Subset(glyphs map[uint16]bool) []byte {
+	// 字形0（.notdef）总是保留，组合字形引用的字形一并保留
+	keep := make(map[uint16]bool, len(glyphs)+1)
+	queue := []uint16{0}
+	for gid := range glyphs {
+		queue = append(queue, gid)
+	}
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		if keep[gid] || int(gid) >= f.numGlyphs {
+			continue
+		}
+		keep[gid] = true
+		queue = append(queue, compositeComponents(f.glyphData(int(gid)))...)
+	}
+
+	var glyf []byte
+	loca := make([]byte, (f.numGlyphs+1)*4)
+	for gid := 0; gid < f.numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(loca[gid*4:], uint32(len(glyf)))
+		if keep[uint16(gid)] {
+			glyf = append(glyf, f.glyphData(gid)...)
+			for len(glyf)%4 != 0 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[f.numGlyphs*4:], uint32(len(glyf)))
+
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)  // checkSumAdjustment，最后重新计算
+	binary.BigEndian.PutUint16(head[50:], 1) // indexToLocFormat，统一使用长格式
+
+	tables := map[string][]byte{"glyf": glyf, "loca": loca, "head": head}
+	for _, tag := range trueTypeSubsetTables {
+		if _, ok := tables[tag]; !ok {
+			if data, ok := f.tables[tag]; ok {
+				tables[tag] = data
+			}
+		}
+	}
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	// 写入表目录和各表，表按4字节对齐
+	numTables := len(tags)
+	searchRange, entrySelector := 1, 0
+	for searchRange*2 <= numTables {
+		searchRange *= 2
+		entrySelector++
+	}
+	header := make([]byte, 12+numTables*16)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange*16))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(numTables*16-searchRange*16))
+
+	var body []byte
+	headOffset := 0
+	for i, tag := range tags {
+		data := tables[tag]
+		offset := len(header) + len(body)
+		if tag == "head" {
+			headOffset = offset
+		}
+		record := header[12+i*16:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], trueTypeChecksum(data))
+		binary.BigEndian.PutUint32(record[8:], uint32(offset))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(data)))
+		body = append(body, data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+	font := append(header, body...)
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xB1B0AFBA-trueTypeChecksum(font))
+	return font
+}
+
+func trueTypeChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gin-template/common"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 响应缓存的系统设置，修改后下一次调用即生效
+const (
+	// OptionCacheTTL 缓存的有效期（秒），为0时不使用缓存
+	OptionCacheTTL = "llm_cache_ttl_seconds"
+	// OptionCacheSize 未启用Redis时内存中最多缓存的响应数，超出时淘汰最久未使用的响应，为0时使用默认值
+	OptionCacheSize = "llm_cache_size"
+)
+
+const (
+	defaultCacheTTL  = 600
+	defaultCacheSize = 1000
+	cacheKeyPrefix   = "llmCache:"
+)
+
+// CachedResponse 缓存的补全结果
+type CachedResponse struct {
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason"`
+	Usage        Usage  `json:"usage"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// CacheKey 按服务商、实际使用的模型、规范化后的消息、温度和最大生成长度计算缓存键，相同的请求得到相同的键
+// scope不为空时计入缓存键，只有同一作用域（如同一用户）的相同请求才共用缓存
+func CacheKey(req ChatRequest, scope string) (string, error) {
+	provider, modelName, err := Resolve(req.Model)
+	if err != nil {
+		return "", err
+	}
+	var builder strings.Builder
+	if scope != "" {
+		builder.WriteString("scope:" + scope + "\n")
+	}
+	builder.WriteString(provider.Name() + "\n" + modelName + "\n")
+	builder.WriteString(strconv.FormatFloat(req.Temperature, 'f', 2, 64) + "\n" + strconv.Itoa(req.MaxTokens) + "\n")
+	for _, message := range req.Messages {
+		builder.WriteString(message.Role + "\n" + normalizeCacheText(message.Content) + "\n\x00")
+	}
+	sum := sha256.Sum256([]byte(builder.String()))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// normalizeCacheText 统一换行符，去掉行尾和首尾的空白，只有这些差别的请求使用同一个缓存
+func normalizeCacheText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// GetCachedResponse 获取缓存的补全结果，没有缓存或缓存已过期时返回nil
+func GetCachedResponse(ctx context.Context, key string) *CachedResponse {
+	if settingInt(OptionCacheTTL, defaultCacheTTL) <= 0 {
+		return nil
+	}
+	if common.RedisEnabled {
+		value, err := common.RDB.Get(ctx, cacheKeyPrefix+key).Result()
+		if err != nil {
+			return nil
+		}
+		var cached CachedResponse
+		if json.Unmarshal([]byte(value), &cached) != nil {
+			return nil
+		}
+		return &cached
+	}
+	return memoryCache.get(key)
+}
+
+// PutCachedResponse 缓存补全结果，没有内容或被截断的结果不缓存
+func PutCachedResponse(ctx context.Context, key string, cached CachedResponse) {
+	ttl := settingInt(OptionCacheTTL, defaultCacheTTL)
+	if ttl <= 0 || cached.Content == "" || cached.FinishReason == "length" {
+		return
+	}
+	cached.CreatedAt = time.Now().Unix()
+	if common.RedisEnabled {
+		data, _ := json.Marshal(cached)
+		if err := common.RDB.Set(ctx, cacheKeyPrefix+key, data, time.Duration(ttl)*time.Second).Err(); err != nil {
+			common.SysError(fmt.Sprintf("[LLMCache] Failed to cache response: %v", err))
+		}
+		return
+	}
+	size := settingInt(OptionCacheSize, defaultCacheSize)
+	if size == 0 {
+		size = defaultCacheSize
+	}
+	memoryCache.put(key, cached, time.Duration(ttl)*time.Second, size)
+}
+
+// lruCache 未启用Redis时使用的内存缓存，按最近使用的顺序淘汰
+type lruCache struct {
+	mutex   sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	value     CachedResponse
+	expiresAt time.Time
+}
+
+var memoryCache = &lruCache{order: list.New(), entries: make(map[string]*list.Element)}
+
+func (c *lruCache) get(key string) *CachedResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+	c.order.MoveToFront(element)
+	value := entry.value
+	return &value
+}
+
+func (c *lruCache) put(key string, value CachedResponse, ttl time.Duration, size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value = &lruEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)}
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	}
+	// 缓存数量的设置调小后在下一次写入时淘汰多出的部分
+	for c.order.Len() > size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
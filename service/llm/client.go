@@ -0,0 +1,465 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-template/common"
+	"gin-template/model"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// 调用服务商的超时、重试、熔断和备用服务商设置，修改后下一次调用即生效
+const (
+	// OptionRequestTimeout 非流式调用和获取模型列表的超时时间（秒）
+	OptionRequestTimeout = "llm_request_timeout_seconds"
+	// OptionStreamIdleTimeout 流式调用在建立连接和相邻两段增量之间的最长等待时间（秒）
+	OptionStreamIdleTimeout = "llm_stream_idle_timeout_seconds"
+	// OptionMaxRetries 每个服务商在429、5xx、超时和网络错误时的最多重试次数
+	OptionMaxRetries = "llm_max_retries"
+	// OptionBreakerFailures 服务商连续失败多少次后熔断，0表示不熔断
+	OptionBreakerFailures = "llm_breaker_failures"
+	// OptionBreakerCooldown 熔断的持续时间（秒），到期后放行一次试探调用
+	OptionBreakerCooldown = "llm_breaker_cooldown_seconds"
+	// OptionFallbackChain 主服务商失败后依次尝试的备用服务商，JSON数组，元素为"服务商"或"服务商:模型"
+	OptionFallbackChain = "llm_fallback_chain"
+)
+
+const (
+	defaultRequestTimeout    = 60
+	defaultStreamIdleTimeout = 30
+	defaultMaxRetries        = 2
+	defaultBreakerFailures   = 5
+	defaultBreakerCooldown   = 30
+
+	// retryBaseDelay 第一次重试前的等待时间，之后每次加倍，实际等待时间在其一半到全部之间随机
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay 重试等待时间的上限
+	retryMaxDelay = 8 * time.Second
+	// maxRetryAfter 服务商要求的Retry-After超过该值时不再重试，直接切换到备用服务商
+	maxRetryAfter = 30 * time.Second
+)
+
+// httpTransport 调用服务商接口使用的连接，只限制建立连接的时间，整体的超时由每次调用的ctx控制
+var httpTransport = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+	IdleConnTimeout:       90 * time.Second,
+	MaxIdleConnsPerHost:   10,
+}
+
+// FallbackTarget 备用服务商，Model为空时使用服务商的默认模型
+type FallbackTarget struct {
+	Provider string
+	Model    string
+}
+
+// ParseFallbackChain 解析并校验备用服务商设置
+func ParseFallbackChain(value string) ([]FallbackTarget, error) {
+	var targets []FallbackTarget
+	if strings.TrimSpace(value) == "" {
+		return targets, nil
+	}
+	var entries []string
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, fmt.Errorf("备用服务商格式错误，应为JSON字符串数组: %v", err)
+	}
+	for _, entry := range entries {
+		name, modelName, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		if !IsProvider(name) {
+			return nil, fmt.Errorf("不支持的备用服务商: %s", entry)
+		}
+		targets = append(targets, FallbackTarget{Provider: name, Model: modelName})
+	}
+	return targets, nil
+}
+
+// TimeoutError 调用超过了设置的超时时间
+type TimeoutError struct {
+	Provider string
+	After    time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("调用%s API超时（%v）", e.Provider, e.After)
+}
+
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+// route 一次调用依次尝试的服务商和模型
+type route struct {
+	provider Provider
+	model    string
+}
+
+// chatRoutes 按模型名选择的服务商在前，之后是备用服务商；未配置密钥的服务商和重复的服务商模型被跳过
+func chatRoutes(modelName string) ([]route, error) {
+	var routes []route
+	seen := make(map[string]bool)
+	add := func(provider Provider, modelName string) {
+		key := provider.Name() + ":" + modelName
+		if !seen[key] {
+			seen[key] = true
+			routes = append(routes, route{provider: provider, model: modelName})
+		}
+	}
+
+	primary, resolved, err := Resolve(modelName)
+	if err == nil {
+		add(primary, resolved)
+	}
+	chain, _ := ParseFallbackChain(model.GetSetting(OptionFallbackChain))
+	mapping := modelProviders()
+	for _, target := range chain {
+		provider, config, providerErr := getProvider(target.Provider, mapping)
+		if providerErr != nil {
+			continue
+		}
+		if target.Model == "" {
+			target.Model = config.DefaultModel
+		}
+		add(provider, target.Model)
+	}
+	if len(routes) == 0 {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// execute 依次在各服务商上调用call：可重试的错误按退避时间重试，重试用尽或服务商熔断时切换到下一个服务商
+// 返回最终成功的服务商、模型和总的调用次数；ctx被取消时立即返回
+func execute(ctx context.Context, req ChatRequest, call func(provider Provider, req ChatRequest) error) (route, int, error) {
+	routes, err := chatRoutes(req.Model)
+	if err != nil {
+		return route{}, 0, err
+	}
+	maxRetries := settingInt(OptionMaxRetries, defaultMaxRetries)
+
+	attempts := 0
+	var lastErr error
+	for i, target := range routes {
+		name := target.provider.Name()
+		targetReq := req
+		targetReq.Model = target.model
+		for retry := 0; ; retry++ {
+			if !breakers.allow(name) {
+				lastErr = fmt.Errorf("%s服务暂时不可用，请稍后重试", name)
+				break
+			}
+			attempts++
+			err := call(target.provider, targetReq)
+			if err == nil {
+				breakers.record(name, false)
+				if attempts > 1 {
+					common.SysLog(fmt.Sprintf("[LLMClient] %s/%s answered on attempt %d", name, target.model, attempts))
+				}
+				return target, attempts, nil
+			}
+			if ctx.Err() != nil {
+				breakers.abort(name)
+				return route{}, attempts, err
+			}
+			lastErr = err
+			retryable := isRetryable(err)
+			if retryable {
+				breakers.record(name, true)
+			} else {
+				// 请求本身有误，与服务商是否可用无关，不改变熔断状态
+				breakers.abort(name)
+			}
+			if !retryable || retry >= maxRetries {
+				break
+			}
+			delay, ok := retryDelay(retry, err)
+			if !ok {
+				break
+			}
+			common.SysError(fmt.Sprintf("[LLMClient] %s/%s attempt %d failed, retrying in %v: %v", name, target.model, attempts, delay, err))
+			if err := sleepContext(ctx, delay); err != nil {
+				return route{}, attempts, err
+			}
+		}
+		if i < len(routes)-1 {
+			next := routes[i+1]
+			common.SysError(fmt.Sprintf("[LLMClient] %s/%s failed, falling back to %s/%s: %v", name, target.model, next.provider.Name(), next.model, lastErr))
+		}
+	}
+	return route{}, attempts, lastErr
+}
+
+// isRetryable 只有408、429、5xx、超时和网络错误可以重试
+// 服务商返回的其他错误说明请求本身有误，配置错误、序列化请求和解析响应失败重试也不会成功
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusRequestTimeout || apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// retryDelay 第retry次重试前的等待时间，优先使用服务商的Retry-After；Retry-After过长时返回false
+func retryDelay(retry int, err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, apiErr.RetryAfter <= maxRetryAfter
+	}
+	delay := retryBaseDelay << retry
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)), true
+}
+
+// parseRetryAfter 解析Retry-After响应头，支持秒数和HTTP日期
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// Chat 生成对话补全，req.Model为空时使用默认服务商的默认模型
+// 每次调用不超过llm_request_timeout_seconds，失败时按设置重试和切换到备用服务商，返回结果中记录最终回答的服务商和调用次数
+func Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	timeout := settingSeconds(OptionRequestTimeout, defaultRequestTimeout)
+	var response *ChatResponse
+	target, attempts, err := execute(ctx, req, func(provider Provider, req ChatRequest) error {
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		result, err := provider.Chat(callCtx, req)
+		if err != nil && callCtx.Err() != nil && ctx.Err() == nil {
+			return &TimeoutError{Provider: provider.Name(), After: timeout}
+		}
+		response = result
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Model == "" {
+		response.Model = target.model
+	}
+	response.Provider = target.provider.Name()
+	response.Attempts = attempts
+	return response, nil
+}
+
+// Stream 带有空闲超时的流式补全，记录最终回答的服务商、模型和调用次数
+type Stream struct {
+	Provider string
+	Model    string
+	Attempts int
+
+	ctx      context.Context
+	stream   ChatStream
+	first    *ChatChunk
+	firstErr error
+	idle     time.Duration
+	timer    *time.Timer
+	timedOut *atomic.Bool
+	cancel   context.CancelFunc
+}
+
+// OpenStream 流式生成对话补全，第一段增量到达后才算调用成功，之前的失败按Chat的规则重试和切换服务商
+// 建立连接和相邻两段增量之间超过llm_stream_idle_timeout_seconds没有响应时中断
+func OpenStream(ctx context.Context, req ChatRequest) (*Stream, error) {
+	idle := settingSeconds(OptionStreamIdleTimeout, defaultStreamIdleTimeout)
+	var result *Stream
+	target, attempts, err := execute(ctx, req, func(provider Provider, req ChatRequest) error {
+		streamCtx, cancel := context.WithCancel(ctx)
+		timedOut := &atomic.Bool{}
+		timer := time.AfterFunc(idle, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+		stream := &Stream{ctx: ctx, idle: idle, timer: timer, timedOut: timedOut, cancel: cancel}
+
+		var err error
+		if stream.stream, err = provider.ChatStream(streamCtx, req); err == nil {
+			stream.first, err = stream.stream.Recv()
+			if err == io.EOF {
+				stream.firstErr, err = io.EOF, nil
+			}
+		}
+		timer.Stop()
+		if err != nil {
+			if stream.stream != nil {
+				_ = stream.stream.Close()
+			}
+			cancel()
+			if timedOut.Load() && ctx.Err() == nil {
+				return &TimeoutError{Provider: provider.Name(), After: idle}
+			}
+			return err
+		}
+		result = stream
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Provider = target.provider.Name()
+	result.Model = target.model
+	result.Attempts = attempts
+	return result, nil
+}
+
+// Recv 返回下一段增量，流正常结束时返回io.EOF
+func (s *Stream) Recv() (*ChatChunk, error) {
+	if s.first != nil || s.firstErr != nil {
+		chunk, err := s.first, s.firstErr
+		s.first, s.firstErr = nil, nil
+		if chunk != nil {
+			return chunk, nil
+		}
+		return nil, err
+	}
+
+	s.timer.Reset(s.idle)
+	chunk, err := s.stream.Recv()
+	s.timer.Stop()
+	if err != nil && err != io.EOF && s.ctx.Err() == nil {
+		if s.timedOut.Load() {
+			err = &TimeoutError{Provider: s.Provider, After: s.idle}
+		}
+		if isRetryable(err) {
+			breakers.record(s.Provider, true)
+		}
+	}
+	return chunk, err
+}
+
+// Close 关闭流并释放连接
+func (s *Stream) Close() error {
+	s.timer.Stop()
+	err := s.stream.Close()
+	s.cancel()
+	return err
+}
+
+// ListModels 获取服务商的模型列表，不超过llm_request_timeout_seconds
+func ListModels(ctx context.Context, provider Provider) ([]ModelInfo, error) {
+	timeout := settingSeconds(OptionRequestTimeout, defaultRequestTimeout)
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	models, err := provider.Models(callCtx)
+	if err != nil && callCtx.Err() != nil && ctx.Err() == nil {
+		return nil, &TimeoutError{Provider: provider.Name(), After: timeout}
+	}
+	return models, err
+}
+
+// circuitBreaker 服务商的熔断状态
+type circuitBreaker struct {
+	failures int
+	openedAt time.Time
+	// probing 熔断到期后正在进行试探调用，试探结束前其他调用仍被拒绝
+	probing bool
+}
+
+type breakerRegistry struct {
+	mutex    sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+var breakers = &breakerRegistry{breakers: make(map[string]*circuitBreaker)}
+
+// allow 判断服务商是否可以调用，熔断到期后只放行一次试探调用
+func (r *breakerRegistry) allow(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	breaker, ok := r.breakers[name]
+	threshold := settingInt(OptionBreakerFailures, defaultBreakerFailures)
+	if !ok || threshold == 0 || breaker.failures < threshold {
+		return true
+	}
+	cooldown := settingSeconds(OptionBreakerCooldown, defaultBreakerCooldown)
+	if breaker.probing || time.Since(breaker.openedAt) < cooldown {
+		return false
+	}
+	breaker.probing = true
+	return true
+}
+
+// record 记录调用结果，failed为false时恢复正常，连续失败达到阈值时熔断
+func (r *breakerRegistry) record(name string, failed bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	breaker, ok := r.breakers[name]
+	if !ok {
+		breaker = &circuitBreaker{}
+		r.breakers[name] = breaker
+	}
+	breaker.probing = false
+	if !failed {
+		breaker.failures = 0
+		return
+	}
+	breaker.failures++
+	threshold := settingInt(OptionBreakerFailures, defaultBreakerFailures)
+	if threshold > 0 && breaker.failures >= threshold {
+		if breaker.failures == threshold {
+			common.SysError(fmt.Sprintf("[LLMClient] Provider %s failed %d times in a row, circuit opened", name, breaker.failures))
+		}
+		breaker.openedAt = time.Now()
+	}
+}
+
+// abort 调用被取消，结束试探但不改变熔断状态
+func (r *breakerRegistry) abort(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if breaker, ok := r.breakers[name]; ok {
+		breaker.probing = false
+	}
+}
+
+// settingInt 读取非负整数设置，未设置或格式错误时使用默认值
+func settingInt(key string, fallback int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(model.GetSetting(key)))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// settingSeconds 读取以秒为单位的时间设置，未设置、格式错误或不大于0时使用默认值
+func settingSeconds(key string, fallback int) time.Duration {
+	seconds := settingInt(key, fallback)
+	if seconds == 0 {
+		seconds = fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
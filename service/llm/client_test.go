@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-template/common"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// useOptions 用给定的设置替换系统设置，测试结束后恢复
+func useOptions(t *testing.T, options map[string]string) {
+	t.Helper()
+	common.OptionMapRWMutex.Lock()
+	previous := common.OptionMap
+	common.OptionMap = options
+	common.OptionMapRWMutex.Unlock()
+	t.Cleanup(func() {
+		common.OptionMapRWMutex.Lock()
+		common.OptionMap = previous
+		common.OptionMapRWMutex.Unlock()
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"400", &APIError{StatusCode: http.StatusBadRequest}, false},
+		{"401", &APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"404", &APIError{StatusCode: http.StatusNotFound}, false},
+		{"408", &APIError{StatusCode: http.StatusRequestTimeout}, true},
+		{"429", &APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"500", &APIError{StatusCode: http.StatusInternalServerError}, true},
+		{"503", &APIError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"包装的API错误", fmt.Errorf("call failed: %w", &APIError{StatusCode: http.StatusBadGateway}), true},
+		{"超时", &TimeoutError{}, true},
+		{"截止时间", context.DeadlineExceeded, true},
+		{"网络错误", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{"连接中断", io.ErrUnexpectedEOF, true},
+		{"连接重置", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"连接被拒绝", syscall.ECONNREFUSED, true},
+		{"取消", context.Canceled, false},
+		{"其他错误", errors.New("invalid character in response"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name  string
+		retry int
+		err   error
+		min   time.Duration
+		max   time.Duration
+		ok    bool
+	}{
+		{name: "第一次重试", retry: 0, err: &TimeoutError{}, min: retryBaseDelay / 2, max: retryBaseDelay, ok: true},
+		{name: "第三次重试", retry: 2, err: &TimeoutError{}, min: retryBaseDelay * 2, max: retryBaseDelay * 4, ok: true},
+		{name: "不超过上限", retry: 10, err: &TimeoutError{}, min: retryMaxDelay / 2, max: retryMaxDelay, ok: true},
+		{name: "移位溢出", retry: 70, err: &TimeoutError{}, min: retryMaxDelay / 2, max: retryMaxDelay, ok: true},
+		{name: "使用Retry-After", retry: 0, err: &APIError{StatusCode: 429, RetryAfter: 3 * time.Second}, min: 3 * time.Second, max: 3 * time.Second, ok: true},
+		{name: "Retry-After过长", retry: 0, err: &APIError{StatusCode: 429, RetryAfter: time.Minute}, min: time.Minute, max: time.Minute, ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				delay, ok := retryDelay(tt.retry, tt.err)
+				if ok != tt.ok || delay < tt.min || delay > tt.max {
+					t.Fatalf("retryDelay(%d, %v) = %v, %v, want %v..%v, %v", tt.retry, tt.err, delay, ok, tt.min, tt.max, tt.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{" 12 ", 12 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0}, // 已经过去的时间
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBreakerRegistry(t *testing.T) {
+	// 每步的操作：allow检查是否放行，fail和ok记录调用结果，abort结束试探，wait等待熔断到期
+	type step struct {
+		action string
+		want   bool
+	}
+	tests := []struct {
+		name     string
+		failures string
+		steps    []step
+	}{
+		{
+			name:     "未达到阈值",
+			failures: "3",
+			steps:    []step{{"fail", false}, {"fail", false}, {"allow", true}, {"ok", false}, {"fail", false}, {"fail", false}, {"allow", true}},
+		},
+		{
+			name:     "达到阈值后熔断",
+			failures: "2",
+			steps:    []step{{"fail", false}, {"fail", false}, {"allow", false}},
+		},
+		{
+			name:     "到期后只放行一次试探",
+			failures: "2",
+			steps:    []step{{"fail", false}, {"fail", false}, {"wait", false}, {"allow", true}, {"allow", false}},
+		},
+		{
+			name:     "试探成功后恢复",
+			failures: "2",
+			steps:    []step{{"fail", false}, {"fail", false}, {"wait", false}, {"allow", true}, {"ok", false}, {"allow", true}, {"allow", true}},
+		},
+		{
+			name:     "试探失败后重新熔断",
+			failures: "2",
+			steps:    []step{{"fail", false}, {"fail", false}, {"wait", false}, {"allow", true}, {"fail", false}, {"allow", false}},
+		},
+		{
+			name:     "试探取消后可以再次试探",
+			failures: "2",
+			steps:    []step{{"fail", false}, {"fail", false}, {"wait", false}, {"allow", true}, {"abort", false}, {"allow", true}},
+		},
+		{
+			name:     "阈值为0时不熔断",
+			failures: "0",
+			steps:    []step{{"fail", false}, {"fail", false}, {"fail", false}, {"allow", true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useOptions(t, map[string]string{OptionBreakerFailures: tt.failures, OptionBreakerCooldown: "1"})
+			registry := &breakerRegistry{breakers: make(map[string]*circuitBreaker)}
+			for i, step := range tt.steps {
+				switch step.action {
+				case "allow":
+					if got := registry.allow("test"); got != step.want {
+						t.Fatalf("step %d: allow() = %v, want %v", i, got, step.want)
+					}
+				case "fail", "ok":
+					registry.record("test", step.action == "fail")
+				case "abort":
+					registry.abort("test")
+				case "wait":
+					// 把熔断时间提前，相当于等待熔断到期
+					registry.breakers["test"].openedAt = time.Now().Add(-2 * time.Second)
+				}
+			}
+		})
+	}
+}
+
+func TestChatRetriesWithFakeProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		fakeErr  string
+		count    string
+		attempts int
+		status   int
+	}{
+		{name: "没有错误", attempts: 1},
+		{name: "500后重试成功", fakeErr: "500", count: "1", attempts: 2},
+		{name: "429重试用尽", fakeErr: "429", count: "0", attempts: 2, status: http.StatusTooManyRequests},
+		{name: "400不重试", fakeErr: "400", count: "0", attempts: 1, status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useOptions(t, map[string]string{
+				OptionDefaultProvider:    ProviderFake,
+				"fake_default_model":     "fake-model",
+				OptionFakeLatency:        "0",
+				OptionFakeError:          tt.fakeErr,
+				OptionFakeErrorCount:     tt.count,
+				OptionMaxRetries:         "1",
+				OptionBreakerFailures:    "0",
+				OptionFakeStreamInterval: "0",
+			})
+			attempts := 0
+			_, _, err := execute(context.Background(), ChatRequest{}, func(provider Provider, req ChatRequest) error {
+				attempts++
+				_, err := provider.Chat(context.Background(), req)
+				return err
+			})
+			if attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+			}
+			var apiErr *APIError
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Errorf("execute() error = %v, want nil", err)
+			case tt.status != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status):
+				t.Errorf("execute() error = %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
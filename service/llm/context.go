@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-template/model"
+	"strings"
+)
+
+// OptionModelContextTokens 模型的上下文长度（token），JSON对象，键以*结尾时按前缀匹配，如{"deepseek-*":65536}
+const OptionModelContextTokens = "llm_model_context_tokens"
+
+// DefaultContextTokens 没有配置上下文长度的模型按该值处理
+const DefaultContextTokens = 8192
+
+// ParseModelContextTokens 解析并校验模型的上下文长度设置
+func ParseModelContextTokens(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	if strings.TrimSpace(value) == "" {
+		return limits, nil
+	}
+	if err := json.Unmarshal([]byte(value), &limits); err != nil {
+		return nil, fmt.Errorf("上下文长度格式错误，应为模型名到token数的JSON对象: %v", err)
+	}
+	for pattern, tokens := range limits {
+		if tokens <= 0 {
+			return nil, fmt.Errorf("模型%s的上下文长度应大于0", pattern)
+		}
+	}
+	return limits, nil
+}
+
+// ContextTokens 获取模型的上下文长度，精确匹配优先，其次是最长的前缀匹配，都不匹配时为DefaultContextTokens
+func ContextTokens(modelName string) int {
+	limits, err := ParseModelContextTokens(model.GetSetting(OptionModelContextTokens))
+	if err != nil || modelName == "" {
+		return DefaultContextTokens
+	}
+	if tokens, ok := limits[modelName]; ok {
+		return tokens
+	}
+
+	matched, longest := DefaultContextTokens, -1
+	for pattern, tokens := range limits {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if prefix == pattern || !strings.HasPrefix(modelName, prefix) {
+			continue
+		}
+		if len(prefix) > longest {
+			matched, longest = tokens, len(prefix)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino-ext/components/model/deepseek"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	deepseekapi "github.com/cohesion-org/deepseek-go"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// EinoProvider 基于eino ChatModel的服务商，DeepSeek和火山方舟（Ark）使用与智能体相同的eino模型实现
+type EinoProvider struct {
+	name   string
+	model  string
+	models []string
+	chat   model.BaseChatModel
+}
+
+// NewDeepSeekProvider 创建DeepSeek服务商
+func NewDeepSeekProvider(config ProviderConfig) (*EinoProvider, error) {
+	chat, err := deepseek.NewChatModel(context.Background(), &deepseek.ChatModelConfig{
+		APIKey:  config.APIKey,
+		BaseURL: config.BaseURL,
+		Model:   config.DefaultModel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化DeepSeek模型失败: %v", err)
+	}
+	return &EinoProvider{name: ProviderDeepSeek, model: config.DefaultModel, models: config.Models, chat: chat}, nil
+}
+
+// NewArkProvider 创建火山方舟服务商，模型名为方舟的推理接入点ID或模型ID
+func NewArkProvider(config ProviderConfig) (*EinoProvider, error) {
+	chat, err := ark.NewChatModel(context.Background(), &ark.ChatModelConfig{
+		APIKey:  config.APIKey,
+		BaseURL: config.BaseURL,
+		Model:   config.DefaultModel,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("初始化Ark模型失败: %v", err)
+	}
+	return &EinoProvider{name: ProviderArk, model: config.DefaultModel, models: config.Models, chat: chat}, nil
+}
+
+func (p *EinoProvider) Name() string {
+	return p.name
+}
+
+// Chat 调用eino模型生成补全
+func (p *EinoProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	messages, opts := p.toEino(req)
+	out, err := p.chat.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, einoError(p.name, err)
+	}
+
+	response := &ChatResponse{Model: req.Model, Content: out.Content}
+	if req.Model == "" {
+		response.Model = p.model
+	}
+	if out.ResponseMeta != nil {
+		response.FinishReason = out.ResponseMeta.FinishReason
+		if usage := toUsage(out.ResponseMeta); usage != nil {
+			response.Usage = *usage
+		}
+	}
+	return response, nil
+}
+
+// ChatStream 调用eino模型流式生成补全
+func (p *EinoProvider) ChatStream(ctx context.Context, req ChatRequest) (ChatStream, error) {
+	messages, opts := p.toEino(req)
+	reader, err := p.chat.Stream(ctx, messages, opts...)
+	if err != nil {
+		return nil, einoError(p.name, err)
+	}
+	modelName := req.Model
+	if modelName == "" {
+		modelName = p.model
+	}
+	return &einoStream{name: p.name, model: modelName, reader: reader}, nil
+}
+
+func (p *EinoProvider) toEino(req ChatRequest) ([]*schema.Message, []model.Option) {
+	if req.Model == "" {
+		req.Model = p.model
+	}
+	messages := make([]*schema.Message, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		messages = append(messages, &schema.Message{Role: schema.RoleType(message.Role), Content: message.Content})
+	}
+	opts := []model.Option{model.WithModel(req.Model)}
+	if req.Temperature != 0 {
+		opts = append(opts, model.WithTemperature(float32(req.Temperature)))
+	}
+	if req.MaxTokens != 0 {
+		opts = append(opts, model.WithMaxTokens(req.MaxTokens))
+	}
+	return messages, opts
+}
+
+// einoError 将SDK返回的HTTP错误转换为APIError，使重试和熔断按状态码处理；其他错误保留原始错误链
+func einoError(name string, err error) error {
+	var deepseekErr *deepseekapi.APIError
+	if errors.As(err, &deepseekErr) && deepseekErr.StatusCode != 0 {
+		return &APIError{Provider: name, StatusCode: deepseekErr.StatusCode, Message: deepseekErr.Message}
+	}
+	var arkErr *arkmodel.APIError
+	if errors.As(err, &arkErr) && arkErr.HTTPStatusCode != 0 {
+		return &APIError{Provider: name, StatusCode: arkErr.HTTPStatusCode, Message: arkErr.Message}
+	}
+	var arkRequestErr *arkmodel.RequestError
+	if errors.As(err, &arkRequestErr) && arkRequestErr.HTTPStatusCode != 0 {
+		return &APIError{Provider: name, StatusCode: arkRequestErr.HTTPStatusCode, Message: fmt.Sprint(arkRequestErr.Err)}
+	}
+	return fmt.Errorf("调用%s API失败: %w", name, err)
+}
+
+func toUsage(meta *schema.ResponseMeta) *Usage {
+	if meta == nil || meta.Usage == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     meta.Usage.PromptTokens,
+		CompletionTokens: meta.Usage.CompletionTokens,
+		TotalTokens:      meta.Usage.TotalTokens,
+	}
+}
+
+// einoStream 将eino的StreamReader转换为ChatStream
+type einoStream struct {
+	name   string
+	model  string
+	reader *schema.StreamReader[*schema.Message]
+}
+
+func (s *einoStream) Recv() (*ChatChunk, error) {
+	message, err := s.reader.Recv()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, einoError(s.name, err)
+	}
+	chunk := &ChatChunk{Model: s.model, Content: message.Content, Usage: toUsage(message.ResponseMeta)}
+	if message.ResponseMeta != nil {
+		chunk.FinishReason = message.ResponseMeta.FinishReason
+	}
+	return chunk, nil
+}
+
+func (s *einoStream) Close() error {
+	s.reader.Close()
+	return nil
+}
+
+// Models eino模型没有模型列表接口，返回默认模型和配置中映射到该服务商的模型
+func (p *EinoProvider) Models(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	seen := make(map[string]bool)
+	for _, id := range append([]string{p.model}, p.models...) {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		models = append(models, ModelInfo{Id: id, OwnedBy: p.name})
+	}
+	return models, nil
+}
@@ -0,0 +1,417 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-template/model"
+	"gin-template/util"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 模拟服务商的系统设置，修改后下一次调用即生效
+const (
+	// OptionFakeLatency 返回结果或第一段流式增量前的等待时间（毫秒）
+	OptionFakeLatency = "fake_latency_ms"
+	// OptionFakeStreamInterval 流式补全相邻两段增量之间的等待时间（毫秒）
+	OptionFakeStreamInterval = "fake_stream_interval_ms"
+	// OptionFakeError 注入的错误：HTTP状态码（如429、500）或timeout，为空时不注入
+	OptionFakeError = "fake_error"
+	// OptionFakeErrorCount 设置fake_error后前多少次调用返回错误，之后恢复正常，0表示一直返回错误
+	OptionFakeErrorCount = "fake_error_count"
+	// OptionFakeScript 脚本化的回复，JSON数组，按顺序匹配，见FakeRule
+	OptionFakeScript = "fake_script"
+)
+
+// FakeErrorTimeout 模拟请求超时
+const FakeErrorTimeout = "timeout"
+
+// fakeReplyTokens 没有匹配的脚本时生成的回复长度上限（token）
+const fakeReplyTokens = 300
+
+// FakeRule 模拟服务商的一条回复规则，Source和Match都满足时生效：
+// Source为空或等于请求的来源，Match为空或请求的任一消息包含Match
+type FakeRule struct {
+	// Source 请求的来源，如continuity_check、moderation_classifier
+	Source string `json:"source"`
+	Match  string `json:"match"`
+	// Reply 回复的内容，超出请求的最大生成长度时截断
+	Reply string `json:"reply"`
+	// Error 返回的错误，取值同fake_error，设置后忽略Reply
+	Error string `json:"error"`
+}
+
+// ParseFakeError 校验注入的错误
+func ParseFakeError(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" || value == FakeErrorTimeout {
+		return nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 400 || code > 599 {
+		return fmt.Errorf("注入的错误应为400到599之间的状态码或timeout: %s", value)
+	}
+	return nil
+}
+
+// ParseFakeScript 解析并校验模拟服务商的回复规则
+func ParseFakeScript(value string) ([]FakeRule, error) {
+	var rules []FakeRule
+	if strings.TrimSpace(value) == "" {
+		return rules, nil
+	}
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("回复规则格式错误，应为JSON数组: %v", err)
+	}
+	for _, rule := range rules {
+		if err := ParseFakeError(rule.Error); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// fakeTimeoutError 模拟的超时错误，实现net.Error的Timeout方法
+type fakeTimeoutError struct{}
+
+func (fakeTimeoutError) Error() string {
+	return "调用fake API失败: 请求超时"
+}
+
+func (fakeTimeoutError) Timeout() bool {
+	return true
+}
+
+func (fakeTimeoutError) Temporary() bool {
+	return true
+}
+
+// FakeProvider 不访问网络的模拟服务商，用于本地开发和测试
+// 回复按fake_script中的规则生成，没有匹配的规则时根据提示词生成确定的内容；相同的请求总是得到相同的回复，
+// 用量按估算的token数返回，可以通过设置注入延迟和错误
+type FakeProvider struct {
+	model  string
+	models []string
+
+	mutex     sync.Mutex
+	errorKey  string
+	errorSeen int
+}
+
+// NewFakeProvider 创建模拟服务商
+func NewFakeProvider(config ProviderConfig) *FakeProvider {
+	return &FakeProvider{model: config.DefaultModel, models: config.Models}
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+// Chat 生成模拟的补全
+func (p *FakeProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return p.prepare(ctx, req)
+}
+
+// ChatStream 将模拟的补全拆成约一个token一段的增量，最后一段带有用量
+func (p *FakeProvider) ChatStream(ctx context.Context, req ChatRequest) (ChatStream, error) {
+	reply, err := p.prepare(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeStream{
+		ctx:      ctx,
+		reply:    reply,
+		pieces:   splitFakeChunks(reply.Content),
+		interval: settingMillis(OptionFakeStreamInterval),
+	}, nil
+}
+
+// Models 返回默认模型和配置中映射到该服务商的模型
+func (p *FakeProvider) Models(ctx context.Context) ([]ModelInfo, error) {
+	var models []ModelInfo
+	seen := make(map[string]bool)
+	for _, id := range append([]string{p.model}, p.models...) {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		models = append(models, ModelInfo{Id: id, OwnedBy: ProviderFake})
+	}
+	return models, nil
+}
+
+// prepare 等待设置的延迟，返回注入的错误或生成的回复
+func (p *FakeProvider) prepare(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = p.model
+	}
+	rules, err := ParseFakeScript(model.GetSetting(OptionFakeScript))
+	if err != nil {
+		return nil, err
+	}
+	rule := matchFakeRule(rules, req)
+
+	injected := p.injectedError()
+	if injected == "" && rule != nil {
+		injected = rule.Error
+	}
+	if injected == FakeErrorTimeout {
+		return nil, waitFakeTimeout(ctx)
+	}
+	if err := sleepContext(ctx, settingMillis(OptionFakeLatency)); err != nil {
+		return nil, err
+	}
+	if injected != "" {
+		code, _ := strconv.Atoi(injected)
+		return nil, &APIError{Provider: ProviderFake, StatusCode: code, Message: fmt.Sprintf("模拟的错误: %s", http.StatusText(code))}
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = fakeReplyTokens
+	}
+	content := ""
+	if rule != nil {
+		content = rule.Reply
+	} else {
+		content = fakeReply(req.Messages, maxTokens)
+	}
+	finishReason := "stop"
+	if truncated := truncateTokens(content, maxTokens); truncated != content {
+		content, finishReason = truncated, "length"
+	}
+
+	promptTokens := EstimatePromptTokens(req.Messages)
+	completionTokens := EstimateTokens(content)
+	return &ChatResponse{
+		Id:           fmt.Sprintf("fake-%x", fakeSeed(req.Messages)),
+		Model:        req.Model,
+		Content:      content,
+		FinishReason: finishReason,
+		Usage: Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}, nil
+}
+
+// injectedError 返回本次调用注入的错误，fake_error或fake_error_count修改后重新计数
+func (p *FakeProvider) injectedError() string {
+	value := strings.TrimSpace(model.GetSetting(OptionFakeError))
+	if ParseFakeError(value) != nil {
+		return ""
+	}
+	count, _ := strconv.Atoi(model.GetSetting(OptionFakeErrorCount))
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := value + "/" + strconv.Itoa(count)
+	if key != p.errorKey {
+		p.errorKey, p.errorSeen = key, 0
+	}
+	if value == "" || (count > 0 && p.errorSeen >= count) {
+		return ""
+	}
+	p.errorSeen++
+	return value
+}
+
+// waitFakeTimeout 模拟超时：ctx有截止时间时一直等到超时或取消，否则等待fake_latency_ms后返回超时错误
+func waitFakeTimeout(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); ok {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if err := sleepContext(ctx, settingMillis(OptionFakeLatency)); err != nil {
+		return err
+	}
+	return fakeTimeoutError{}
+}
+
+func matchFakeRule(rules []FakeRule, req ChatRequest) *FakeRule {
+	for i := range rules {
+		if rules[i].Source != "" && rules[i].Source != req.Source {
+			continue
+		}
+		if rules[i].Match == "" {
+			return &rules[i]
+		}
+		for _, message := range req.Messages {
+			if strings.Contains(message.Content, rules[i].Match) {
+				return &rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// fakeSentences 生成回复时使用的句子
+var fakeSentences = []string{
+	"主角在雨夜回到了阔别多年的小镇。",
+	"一封没有署名的信打乱了他原本的计划。",
+	"旧友的出现让尘封的往事重新浮出水面。",
+	"她在集市上发现了那枚失踪已久的玉佩。",
+	"众人在山门前争执不下，最终决定兵分两路。",
+	"夜色中传来一阵急促的马蹄声。",
+	"老掌柜欲言又止，只留下了一张残缺的地图。",
+	"他终于明白，当年的真相远比想象中复杂。",
+	"城中的流言越传越广，连守卫也开始人心惶惶。",
+	"第二天清晨，所有人都在渡口集合。",
+	"一场突如其来的大火改变了所有人的命运。",
+	"她把秘密藏在心底，决定独自前往北方。",
+}
+
+// fakeReply 根据提示词生成确定的回复，长度不超过maxTokens和fakeReplyTokens
+func fakeReply(messages []Message, maxTokens int) string {
+	if maxTokens > fakeReplyTokens {
+		maxTokens = fakeReplyTokens
+	}
+	random := rand.New(rand.NewSource(int64(fakeSeed(messages))))
+	var builder strings.Builder
+	for section := 1; ; section++ {
+		line := fmt.Sprintf("第%d节：", section)
+		for i := 0; i < 3; i++ {
+			line += fakeSentences[random.Intn(len(fakeSentences))]
+		}
+		if EstimateTokens(builder.String()+line) > maxTokens {
+			break
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(line)
+	}
+	if builder.Len() == 0 {
+		return truncateTokens(fakeSentences[random.Intn(len(fakeSentences))], maxTokens)
+	}
+	return builder.String()
+}
+
+func fakeSeed(messages []Message) uint64 {
+	hash := fnv.New64a()
+	for _, message := range messages {
+		_, _ = hash.Write([]byte(message.Role))
+		_, _ = hash.Write([]byte{0})
+		_, _ = hash.Write([]byte(message.Content))
+		_, _ = hash.Write([]byte{0})
+	}
+	return hash.Sum64()
+}
+
+// truncateTokens 截取文本开头不超过maxTokens个token的部分
+func truncateTokens(text string, maxTokens int) string {
+	if EstimateTokens(text) <= maxTokens {
+		return text
+	}
+	runes := []rune(text)
+	low, high := 0, len(runes)
+	for low < high {
+		mid := (low + high + 1) / 2
+		if EstimateTokens(string(runes[:mid])) <= maxTokens {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return string(runes[:low])
+}
+
+// splitFakeChunks 按估算的token拆分流式增量：每个中日韩文字一段，其他字符最多4个一段
+func splitFakeChunks(content string) []string {
+	var chunks []string
+	start, other := 0, 0
+	for i, r := range content {
+		end := i + utf8.RuneLen(r)
+		switch {
+		case util.IsCJK(r):
+			if start < i {
+				chunks = append(chunks, content[start:i])
+			}
+			chunks = append(chunks, content[i:end])
+			start, other = end, 0
+		case !unicode.IsSpace(r):
+			other++
+			if other == 4 {
+				chunks = append(chunks, content[start:end])
+				start, other = end, 0
+			}
+		}
+	}
+	if start < len(content) {
+		chunks = append(chunks, content[start:])
+	}
+	return chunks
+}
+
+func settingMillis(key string) time.Duration {
+	millis, err := strconv.Atoi(model.GetSetting(key))
+	if err != nil || millis < 0 {
+		return 0
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// fakeStream 按设置的间隔依次返回增量，最后一段带有结束原因和用量
+type fakeStream struct {
+	ctx      context.Context
+	reply    *ChatResponse
+	pieces   []string
+	interval time.Duration
+	sent     int
+	done     bool
+}
+
+func (s *fakeStream) Recv() (*ChatChunk, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	if s.sent > 0 {
+		if err := sleepContext(s.ctx, s.interval); err != nil {
+			return nil, err
+		}
+	} else if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	chunk := &ChatChunk{Id: s.reply.Id, Model: s.reply.Model}
+	if s.sent < len(s.pieces) {
+		chunk.Content = s.pieces[s.sent]
+		s.sent++
+	}
+	if s.sent == len(s.pieces) {
+		usage := s.reply.Usage
+		chunk.FinishReason = s.reply.FinishReason
+		chunk.Usage = &usage
+		s.done = true
+	}
+	return chunk, nil
+}
+
+func (s *fakeStream) Close() error {
+	s.done = true
+	return nil
+}
@@ -0,0 +1,56 @@
+package llm
+
+import "testing"
+
+func TestMatchFakeRule(t *testing.T) {
+	rules := []FakeRule{
+		{Source: "continuity_check", Reply: "continuity"},
+		{Source: "moderation_classifier", Match: "广告", Reply: "flagged"},
+		{Match: "第一章", Reply: "chapter"},
+	}
+	tests := []struct {
+		name    string
+		source  string
+		content string
+		want    string
+	}{
+		{"按来源匹配", "continuity_check", "任意内容", "continuity"},
+		{"来源和内容都满足", "moderation_classifier", "这是广告", "flagged"},
+		{"来源满足内容不满足", "moderation_classifier", "正常内容", ""},
+		{"只按内容匹配", "outline_generation", "第一章 主角出发", "chapter"},
+		{"其他来源不使用带来源的规则", "ai_prompt", "这是广告", ""},
+		{"没有来源", "", "第一章", "chapter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := matchFakeRule(rules, ChatRequest{Source: tt.source, Messages: []Message{{Role: RoleUser, Content: tt.content}}})
+			got := ""
+			if rule != nil {
+				got = rule.Reply
+			}
+			if got != tt.want {
+				t.Errorf("matchFakeRule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFakeScript(t *testing.T) {
+	tests := []struct {
+		value   string
+		rules   int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{`[{"source":"continuity_check","reply":"{\"issues\":[]}"}]`, 1, false},
+		{`[{"match":"a","error":"429"},{"error":"timeout"}]`, 2, false},
+		{`[{"error":"200"}]`, 0, true},
+		{`{"match":"a"}`, 0, true},
+	}
+	for _, tt := range tests {
+		rules, err := ParseFakeScript(tt.value)
+		if (err != nil) != tt.wantErr || len(rules) != tt.rules {
+			t.Errorf("ParseFakeScript(%q) = %d rules, %v, want %d rules, error %v", tt.value, len(rules), err, tt.rules, tt.wantErr)
+		}
+	}
+}
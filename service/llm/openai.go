@@ -0,0 +1,248 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider 兼容OpenAI接口的服务商
+type OpenAIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+// NewOpenAIProvider 创建兼容OpenAI接口的服务商，baseURL不含/v1时自动补全
+func NewOpenAIProvider(name string, config ProviderConfig) *OpenAIProvider {
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if !strings.HasSuffix(baseURL, "/v1") {
+		baseURL += "/v1"
+	}
+	return &OpenAIProvider{
+		name:    name,
+		baseURL: baseURL,
+		apiKey:  config.APIKey,
+		model:   config.DefaultModel,
+		client:  &http.Client{Transport: httpTransport},
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+// Chat 调用/chat/completions接口生成补全
+func (p *OpenAIProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	requestJSON, err := p.chatRequestBody(req, false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.do(ctx, http.MethodPost, "/chat/completions", requestJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Id      string `json:"id"`
+		Model   string `json:"model"`
+		Usage   Usage  `json:"usage"`
+		Choices []struct {
+			Message struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("解析API响应失败: %v", err)
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("API没有返回有效的回复")
+	}
+
+	return &ChatResponse{
+		Id:           response.Id,
+		Model:        response.Model,
+		Content:      response.Choices[0].Message.Content,
+		FinishReason: response.Choices[0].FinishReason,
+		Usage:        response.Usage,
+	}, nil
+}
+
+// ChatStream 以stream模式调用/chat/completions接口，并要求在流结束前返回用量
+func (p *OpenAIProvider) ChatStream(ctx context.Context, req ChatRequest) (ChatStream, error) {
+	requestJSON, err := p.chatRequestBody(req, true)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.send(ctx, http.MethodPost, "/chat/completions", requestJSON)
+	if err != nil {
+		return nil, err
+	}
+	return &openAIStream{provider: p.name, body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+func (p *OpenAIProvider) chatRequestBody(req ChatRequest, stream bool) ([]byte, error) {
+	if req.Model == "" {
+		req.Model = p.model
+	}
+	requestBody := map[string]interface{}{
+		"model":    req.Model,
+		"messages": req.Messages,
+	}
+	if req.Temperature != 0 {
+		requestBody["temperature"] = req.Temperature
+	}
+	if req.MaxTokens != 0 {
+		requestBody["max_tokens"] = req.MaxTokens
+	}
+	if stream {
+		requestBody["stream"] = true
+		requestBody["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+
+	requestJSON, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("无法序列化请求: %v", err)
+	}
+	return requestJSON, nil
+}
+
+// Models 调用/models接口获取模型列表
+func (p *OpenAIProvider) Models(ctx context.Context) ([]ModelInfo, error) {
+	body, err := p.do(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data []struct {
+			Id      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("解析API响应失败: %v", err)
+	}
+
+	models := make([]ModelInfo, 0, len(response.Data))
+	for _, item := range response.Data {
+		models = append(models, ModelInfo{Id: item.Id, OwnedBy: item.OwnedBy})
+	}
+	return models, nil
+}
+
+// do 发送请求并返回响应体
+func (p *OpenAIProvider) do(ctx context.Context, method string, path string, payload []byte) ([]byte, error) {
+	resp, err := p.send(ctx, method, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取API响应失败: %w", err)
+	}
+	return body, nil
+}
+
+// send 发送请求，非200状态码转换为APIError
+func (p *OpenAIProvider) send(ctx context.Context, method string, path string, payload []byte) (*http.Response, error) {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", p.apiKey))
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("调用%s API失败: %w", p.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		errResponse := struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}{}
+		_ = json.Unmarshal(body, &errResponse)
+		return nil, &APIError{Provider: p.name, StatusCode: resp.StatusCode, Message: errResponse.Error.Message,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return resp, nil
+}
+
+// openAIStream 解析SSE格式的流式响应
+type openAIStream struct {
+	provider string
+	body     io.ReadCloser
+	reader   *bufio.Reader
+}
+
+func (s *openAIStream) Recv() (*ChatChunk, error) {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			// 服务商没有发送[DONE]就结束了响应时视为正常结束
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil, io.EOF
+		}
+
+		var event struct {
+			Id      string `json:"id"`
+			Model   string `json:"model"`
+			Usage   *Usage `json:"usage"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
+			} `json:"choices"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("解析API响应失败: %v", err)
+		}
+		if event.Error != nil {
+			return nil, &APIError{Provider: s.provider, StatusCode: http.StatusOK, Message: event.Error.Message}
+		}
+
+		chunk := &ChatChunk{Id: event.Id, Model: event.Model, Usage: event.Usage}
+		if len(event.Choices) > 0 {
+			chunk.Content = event.Choices[0].Delta.Content
+			chunk.FinishReason = event.Choices[0].FinishReason
+		}
+		return chunk, nil
+	}
+}
+
+func (s *openAIStream) Close() error {
+	return s.body.Close()
+}
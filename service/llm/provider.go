@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message 对话消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatRequest 对话补全请求，Model为空时使用服务商的默认模型
+type ChatRequest struct {
+	Model       string
+	Messages    []Message
+	Temperature float64
+	MaxTokens   int
+	// Source 调用的来源，取值见define中的AISource常量，不发送给服务商，模拟服务商按它匹配回复规则
+	Source string
+}
+
+// Usage token用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse 对话补全结果
+type ChatResponse struct {
+	Id           string
+	Model        string
+	Content      string
+	FinishReason string
+	Usage        Usage
+	// Provider 最终回答的服务商，Attempts 包括重试和切换到备用服务商在内的调用次数，由Chat填写
+	Provider string
+	Attempts int
+}
+
+// ChatChunk 流式补全的一段增量
+type ChatChunk struct {
+	Id           string
+	Model        string
+	Content      string
+	FinishReason string
+	// 服务商在流结束前返回的用量，没有返回时为nil
+	Usage *Usage
+}
+
+// ChatStream 流式补全，Recv在流正常结束时返回io.EOF；取消ctx会中断上游请求
+type ChatStream interface {
+	Recv() (*ChatChunk, error)
+	Close() error
+}
+
+// ModelInfo 服务商提供的模型
+type ModelInfo struct {
+	Id      string
+	OwnedBy string
+}
+
+// Provider 大模型服务商
+type Provider interface {
+	// Name 服务商名称，与配置项前缀一致，如openai、deepseek、ark
+	Name() string
+	// Chat 生成对话补全
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+	// ChatStream 流式生成对话补全
+	ChatStream(ctx context.Context, req ChatRequest) (ChatStream, error)
+	// Models 获取可用的模型列表
+	Models(ctx context.Context) ([]ModelInfo, error)
+}
+
+// APIError 服务商接口返回的错误
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// RetryAfter 服务商通过Retry-After要求的等待时间，没有要求时为0
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("API错误: %s", e.Message)
+	}
+	return fmt.Sprintf("API返回错误: %d", e.StatusCode)
+}
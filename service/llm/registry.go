@@ -0,0 +1,218 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-template/model"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 支持的服务商
+const (
+	ProviderOpenAI   = "openai"
+	ProviderDeepSeek = "deepseek"
+	ProviderArk      = "ark"
+	// ProviderFake 不访问网络的模拟服务商，不需要API密钥，见FakeProvider
+	ProviderFake = "fake"
+)
+
+// ProviderNames 支持的服务商名称，按默认的优先顺序排列
+var ProviderNames = []string{ProviderOpenAI, ProviderDeepSeek, ProviderArk, ProviderFake}
+
+// 服务商选择相关的系统设置，每个服务商另有{name}_api_key、{name}_api_base、{name}_default_model三个设置
+const (
+	// OptionDefaultProvider 没有指定模型或模型没有匹配的服务商时使用的服务商
+	OptionDefaultProvider = "llm_default_provider"
+	// OptionModelProviders 模型名到服务商的映射，JSON对象，键以*结尾时按前缀匹配，如{"deepseek-*":"deepseek"}
+	OptionModelProviders = "llm_model_providers"
+)
+
+// ProviderConfig 服务商配置
+type ProviderConfig struct {
+	APIKey       string
+	BaseURL      string
+	DefaultModel string
+	// 映射到该服务商的模型名（不含前缀匹配），用于模型列表
+	Models []string
+}
+
+type cachedProvider struct {
+	config   ProviderConfig
+	provider Provider
+}
+
+var (
+	providersMutex sync.Mutex
+	providers      = make(map[string]*cachedProvider)
+)
+
+// IsProvider 判断是否为支持的服务商
+func IsProvider(name string) bool {
+	for _, provider := range ProviderNames {
+		if provider == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseModelProviders 解析并校验模型到服务商的映射
+func ParseModelProviders(value string) (map[string]string, error) {
+	routes := make(map[string]string)
+	if strings.TrimSpace(value) == "" {
+		return routes, nil
+	}
+	if err := json.Unmarshal([]byte(value), &routes); err != nil {
+		return nil, fmt.Errorf("模型映射格式错误，应为JSON对象: %v", err)
+	}
+	for pattern, provider := range routes {
+		if !IsProvider(provider) {
+			return nil, fmt.Errorf("模型%s映射到了不支持的服务商%s", pattern, provider)
+		}
+	}
+	return routes, nil
+}
+
+// Resolve 根据模型名选择服务商，返回服务商和实际使用的模型名
+// 模型名为空时使用默认服务商的默认模型；精确匹配优先，其次是最长的前缀匹配，都不匹配时使用默认服务商
+func Resolve(modelName string) (Provider, string, error) {
+	routes := modelProviders()
+	name := providerForModel(modelName, routes)
+	provider, config, err := getProvider(name, routes)
+	if err != nil {
+		return nil, "", err
+	}
+	if modelName == "" {
+		modelName = config.DefaultModel
+	}
+	return provider, modelName, nil
+}
+
+// GetProvider 获取指定的服务商，服务商配置在运行时修改后自动重建
+func GetProvider(name string) (Provider, error) {
+	provider, _, err := getProvider(name, modelProviders())
+	return provider, err
+}
+
+// ConfiguredProviders 获取已配置API密钥的服务商，模拟服务商在设为默认服务商或有模型映射到它时包括在内
+func ConfiguredProviders() []Provider {
+	routes := modelProviders()
+	var configured []Provider
+	for _, name := range ProviderNames {
+		if name == ProviderFake {
+			if !fakeSelected(routes) {
+				continue
+			}
+		} else if model.GetSetting(name+"_api_key") == "" {
+			continue
+		}
+		provider, _, err := getProvider(name, routes)
+		if err != nil {
+			continue
+		}
+		configured = append(configured, provider)
+	}
+	return configured
+}
+
+func getProvider(name string, routes map[string]string) (Provider, ProviderConfig, error) {
+	if !IsProvider(name) {
+		return nil, ProviderConfig{}, fmt.Errorf("不支持的模型服务商: %s", name)
+	}
+	config := loadProviderConfig(name, routes)
+	if config.APIKey == "" && name != ProviderFake {
+		return nil, config, fmt.Errorf("未配置%s的API密钥", name)
+	}
+
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	if cached, ok := providers[name]; ok && sameConfig(cached.config, config) {
+		return cached.provider, config, nil
+	}
+
+	var provider Provider
+	var err error
+	switch name {
+	case ProviderDeepSeek:
+		provider, err = NewDeepSeekProvider(config)
+	case ProviderArk:
+		provider, err = NewArkProvider(config)
+	case ProviderFake:
+		provider = NewFakeProvider(config)
+	default:
+		provider = NewOpenAIProvider(name, config)
+	}
+	if err != nil {
+		return nil, config, err
+	}
+	providers[name] = &cachedProvider{config: config, provider: provider}
+	return provider, config, nil
+}
+
+func loadProviderConfig(name string, routes map[string]string) ProviderConfig {
+	config := ProviderConfig{
+		APIKey:       model.GetSetting(name + "_api_key"),
+		BaseURL:      model.GetSetting(name + "_api_base"),
+		DefaultModel: model.GetSetting(name + "_default_model"),
+	}
+	for pattern, provider := range routes {
+		if provider == name && !strings.HasSuffix(pattern, "*") {
+			config.Models = append(config.Models, pattern)
+		}
+	}
+	sort.Strings(config.Models)
+	return config
+}
+
+func sameConfig(a ProviderConfig, b ProviderConfig) bool {
+	return a.APIKey == b.APIKey && a.BaseURL == b.BaseURL && a.DefaultModel == b.DefaultModel &&
+		strings.Join(a.Models, "\n") == strings.Join(b.Models, "\n")
+}
+
+// modelProviders 读取模型映射，设置有误时忽略映射
+func modelProviders() map[string]string {
+	routes, err := ParseModelProviders(model.GetSetting(OptionModelProviders))
+	if err != nil {
+		return map[string]string{}
+	}
+	return routes
+}
+
+func fakeSelected(routes map[string]string) bool {
+	if model.GetSetting(OptionDefaultProvider) == ProviderFake {
+		return true
+	}
+	for _, provider := range routes {
+		if provider == ProviderFake {
+			return true
+		}
+	}
+	return false
+}
+
+func providerForModel(modelName string, routes map[string]string) string {
+	defaultProvider := model.GetSetting(OptionDefaultProvider)
+	if !IsProvider(defaultProvider) {
+		defaultProvider = ProviderOpenAI
+	}
+	if modelName == "" {
+		return defaultProvider
+	}
+	if provider, ok := routes[modelName]; ok {
+		return provider
+	}
+
+	matched, longest := defaultProvider, -1
+	for pattern, provider := range routes {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if prefix == pattern || !strings.HasPrefix(modelName, prefix) {
+			continue
+		}
+		if len(prefix) > longest {
+			matched, longest = provider, len(prefix)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"gin-template/util"
+	"unicode"
+)
+
+// messageOverheadTokens 每条消息的格式开销
+const messageOverheadTokens = 4
+
+// EstimateTokens 估算文本的token数：中日韩文字按每字一个token，其他非空白字符按每4个字符一个token
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		switch {
+		case util.IsCJK(r):
+			cjk++
+		case !unicode.IsSpace(r):
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// EstimatePromptTokens 估算请求消息的token数
+func EstimatePromptTokens(messages []Message) int {
+	total := 0
+	for _, message := range messages {
+		total += messageOverheadTokens + EstimateTokens(message.Content)
+	}
+	return total
+}
+
+// UsageCounter 统计流式补全的用量
+// 服务商在流结束前返回用量时以其为准；流被取消或服务商不返回用量时，提示词按估算计，
+// 生成部分按收到的增量数计（兼容OpenAI的流式接口每个增量对应一个生成的token）
+type UsageCounter struct {
+	promptTokens int
+	chunks       int
+	reported     *Usage
+}
+
+// NewUsageCounter 为请求创建用量统计
+func NewUsageCounter(req ChatRequest) *UsageCounter {
+	return &UsageCounter{promptTokens: EstimatePromptTokens(req.Messages)}
+}
+
+// Add 记录收到的增量
+func (c *UsageCounter) Add(chunk *ChatChunk) {
+	if chunk.Content != "" {
+		c.chunks++
+	}
+	if chunk.Usage != nil && chunk.Usage.TotalTokens > 0 {
+		c.reported = chunk.Usage
+	}
+}
+
+// Usage 返回当前的用量
+func (c *UsageCounter) Usage() Usage {
+	if c.reported != nil {
+		return *c.reported
+	}
+	return Usage{
+		PromptTokens:     c.promptTokens,
+		CompletionTokens: c.chunks,
+		TotalTokens:      c.promptTokens + c.chunks,
+	}
+}
+
+// Reported 服务商是否返回了用量
+func (c *UsageCounter) Reported() bool {
+	return c.reported != nil
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"gin-template/service/moderation"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内容审核的系统设置，修改后下一次调用即生效
+const (
+	// OptionModerationEnabled 是否审核AI调用的提示词和生成内容，为false时不审核
+	OptionModerationEnabled = "moderation_enabled"
+	// OptionModerationLLMStages 使用模型分类的阶段，逗号分隔的prompt和generation，为空时不使用模型分类
+	OptionModerationLLMStages = "moderation_llm_stages"
+	// OptionModerationLLMModel 分类使用的模型，为空时使用默认服务商的默认模型
+	OptionModerationLLMModel = "moderation_llm_model"
+	// OptionModerationLLMAction 模型判定违规时的处理方式，block或flag
+	OptionModerationLLMAction = "moderation_llm_action"
+)
+
+const (
+	// moderationReloadInterval 敏感词列表的刷新间隔，本实例修改敏感词后立即刷新
+	moderationReloadInterval = time.Minute
+	// moderationRecordChars 审核记录中保存的内容的最大字数
+	moderationRecordChars = 5000
+	// moderationClassifierChars 发送给分类模型的内容的最大字数
+	moderationClassifierChars = 6000
+)
+
+var (
+	errPromptBlocked     = errors.New("内容包含违规信息，请修改后重试")
+	errGenerationBlocked = errors.New("生成的内容包含违规信息，已被拦截")
+	errWordExists        = errors.New("敏感词已存在")
+)
+
+// ModerationService 内容审核服务，在调用模型前审核提示词，在返回前审核生成的内容
+type ModerationService struct {
+	moderationRepo *repository.ModerationRepository
+	promptRepo     *repository.PromptTemplateRepository
+}
+
+var moderationService *ModerationService
+
+// SetModerationService 设置AI调用使用的内容审核服务
+func SetModerationService(service *ModerationService) {
+	moderationService = service
+}
+
+// GetModerationService 获取AI调用使用的内容审核服务，未设置时为nil，此时不审核
+func GetModerationService() *ModerationService {
+	return moderationService
+}
+
+func NewModerationService(moderationRepo *repository.ModerationRepository, promptRepo *repository.PromptTemplateRepository) *ModerationService {
+	return &ModerationService{moderationRepo: moderationRepo, promptRepo: promptRepo}
+}
+
+// moderationWords 由敏感词构建的引擎，所有ModerationService实例共用
+var moderationWords struct {
+	sync.Mutex
+	engine   *moderation.KeywordEngine
+	loadedAt time.Time
+}
+
+// keywordEngine 获取敏感词引擎，超过刷新间隔时重新加载，加载失败时继续使用旧的词表
+func (s *ModerationService) keywordEngine() *moderation.KeywordEngine {
+	moderationWords.Lock()
+	defer moderationWords.Unlock()
+	if moderationWords.engine != nil && time.Since(moderationWords.loadedAt) < moderationReloadInterval {
+		return moderationWords.engine
+	}
+
+	words, err := s.moderationRepo.GetAllWords()
+	if err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Failed to load moderation words: %v", err))
+		if moderationWords.engine == nil {
+			moderationWords.engine, _ = moderation.NewKeywordEngine(nil)
+		}
+		moderationWords.loadedAt = time.Now()
+		return moderationWords.engine
+	}
+	rules := make([]moderation.Rule, 0, len(words))
+	for _, word := range words {
+		rules = append(rules, moderation.Rule{Pattern: word.Word, Regex: word.IsRegex, Category: word.Category, Action: word.Action})
+	}
+	engine, err := moderation.NewKeywordEngine(rules)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Skipped invalid moderation words: %v", err))
+	}
+	moderationWords.engine = engine
+	moderationWords.loadedAt = time.Now()
+	return engine
+}
+
+// reloadModerationWords 敏感词修改后在下一次审核时重新加载
+func reloadModerationWords() {
+	moderationWords.Lock()
+	moderationWords.loadedAt = time.Time{}
+	moderationWords.Unlock()
+}
+
+// engines 审核使用的引擎：敏感词，以及按设置启用的模型分类
+func (s *ModerationService) engines() []moderation.Engine {
+	return []moderation.Engine{s.keywordEngine(), &llmClassifier{promptRepo: s.promptRepo}}
+}
+
+func moderationEnabled() bool {
+	return model.GetSetting(OptionModerationEnabled) != "false"
+}
+
+// ModeratePrompt checks the messages of a request before they are sent to the model. Masked words are replaced in the request,
+// flagged and blocked prompts are recorded, and an error is returned when the prompt is blocked.
+func (s *ModerationService) ModeratePrompt(ctx context.Context, req *define.GenerateAIPromptRequest) error {
+	if !moderationEnabled() {
+		return nil
+	}
+	// 所有消息合并为一段文本审核，各部分之间以换行分隔，打码不改变字符数，可以按长度拆回
+	parts := append(append([]string{req.SystemPrompt}, req.ContextData...), req.UserPrompt)
+	result := moderation.Run(ctx, s.engines(), moderation.StagePrompt, strings.Join(parts, "\n"))
+	s.record(*req, moderation.StagePrompt, strings.Join(parts, "\n"), result)
+
+	switch result.Action {
+	case moderation.ActionBlock:
+		common.SysLog(fmt.Sprintf("[ModerationService] Blocked prompt of user %d from %s", req.UserId, req.Source))
+		return errPromptBlocked
+	case moderation.ActionMask:
+		masked := []rune(result.Text)
+		offset := 0
+		for i, part := range parts {
+			length := len([]rune(part))
+			parts[i] = string(masked[offset : offset+length])
+			offset += length + 1
+		}
+		req.SystemPrompt, req.UserPrompt = parts[0], parts[len(parts)-1]
+		req.ContextData = parts[1 : len(parts)-1]
+	}
+	return nil
+}
+
+// ModerateGeneration checks the content generated for a request and returns the content to use, with masked words replaced.
+// Flagged and blocked content is recorded, and an error is returned when the content is blocked.
+func (s *ModerationService) ModerateGeneration(ctx context.Context, req define.GenerateAIPromptRequest, content string) (string, error) {
+	if !moderationEnabled() {
+		return content, nil
+	}
+	result := moderation.Run(ctx, s.engines(), moderation.StageGeneration, content)
+	s.record(req, moderation.StageGeneration, content, result)
+
+	if result.Action == moderation.ActionBlock {
+		common.SysLog(fmt.Sprintf("[ModerationService] Blocked content generated for user %d from %s", req.UserId, req.Source))
+		return "", errGenerationBlocked
+	}
+	return result.Text, nil
+}
+
+// NewGenerationScanner creates the word list scanner of a stream, nil when moderation is disabled
+func (s *ModerationService) NewGenerationScanner() *moderation.StreamScanner {
+	if !moderationEnabled() {
+		return nil
+	}
+	return s.keywordEngine().NewStreamScanner()
+}
+
+// CheckPartialGeneration checks a chunk appended to a stream against the word list only, so that the stream can be stopped
+// as soon as blocked words appear. Only the chunk and the end of the earlier content are scanned; content is the content
+// generated so far and is only used for the record. The complete content is checked with ModerateGeneration.
+// Returns the text that can be sent to the client with masked words replaced; the end of the content that may still form
+// a match with the next chunk is held back by the scanner and returned with a later chunk.
+func (s *ModerationService) CheckPartialGeneration(req define.GenerateAIPromptRequest, scanner *moderation.StreamScanner, chunk string, content string) (string, error) {
+	output, matches := scanner.Scan(chunk)
+	for _, match := range matches {
+		if match.Action == moderation.ActionBlock {
+			s.record(req, moderation.StageGeneration, content, &moderation.Result{Action: moderation.ActionBlock, Matches: matches, Text: content})
+			common.SysLog(fmt.Sprintf("[ModerationService] Stopped stream generated for user %d from %s", req.UserId, req.Source))
+			return "", errGenerationBlocked
+		}
+	}
+	return output, nil
+}
+
+// record 记录被拦截或需要复核的内容，只打码的内容不记录
+func (s *ModerationService) record(req define.GenerateAIPromptRequest, stage string, content string, result *moderation.Result) {
+	status := define.ModerationStatusPending
+	if result.Action == moderation.ActionBlock {
+		status = define.ModerationStatusBlocked
+	} else if !result.Flagged() {
+		return
+	}
+
+	matches, _ := json.Marshal(result.Matches)
+	record := &model.ModerationRecord{
+		UserId:    req.UserId,
+		ProjectId: req.ProjectId,
+		Stage:     stage,
+		Source:    req.Source,
+		Action:    result.Action,
+		Content:   truncateRunes(content, moderationRecordChars),
+		Matches:   string(matches),
+		Status:    status,
+	}
+	if err := s.moderationRepo.CreateRecord(record); err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Failed to save moderation record: %v", err))
+	}
+}
+
+// ListWords 分页获取敏感词
+func (s *ModerationService) ListWords(keyword string, offset, limit int) ([]*model.ModerationWord, int64, error) {
+	return s.moderationRepo.GetWords(strings.TrimSpace(keyword), offset, limit)
+}
+
+// CreateWord adds a word to the word list
+func (s *ModerationService) CreateWord(req define.ModerationWordRequest) (*model.ModerationWord, error) {
+	word := &model.ModerationWord{}
+	if err := s.applyWordRequest(word, req); err != nil {
+		return nil, err
+	}
+	if err := s.moderationRepo.CreateWord(word); err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Failed to create moderation word: %v", err))
+		return nil, err
+	}
+	reloadModerationWords()
+
+	common.SysLog(fmt.Sprintf("[ModerationService] Created moderation word %d", word.Id))
+	return word, nil
+}
+
+// ImportWords adds plain words in bulk with the same category and action, existing words are skipped
+func (s *ModerationService) ImportWords(req define.ModerationWordImportRequest) (*define.ModerationWordImportResponse, error) {
+	response := &define.ModerationWordImportResponse{}
+	for _, text := range req.Words {
+		word := &model.ModerationWord{}
+		err := s.applyWordRequest(word, define.ModerationWordRequest{Word: text, Category: req.Category, Action: req.Action})
+		if err != nil {
+			if strings.TrimSpace(text) == "" || errors.Is(err, errWordExists) {
+				response.Skipped++
+				continue
+			}
+			return nil, err
+		}
+		if err := s.moderationRepo.CreateWord(word); err != nil {
+			common.SysError(fmt.Sprintf("[ModerationService] Failed to import moderation word: %v", err))
+			return nil, err
+		}
+		response.Created++
+	}
+	reloadModerationWords()
+
+	common.SysLog(fmt.Sprintf("[ModerationService] Imported %d moderation words, skipped %d", response.Created, response.Skipped))
+	return response, nil
+}
+
+// UpdateWord updates a word of the word list
+func (s *ModerationService) UpdateWord(id int64, req define.ModerationWordRequest) (*model.ModerationWord, error) {
+	word, err := s.getWord(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyWordRequest(word, req); err != nil {
+		return nil, err
+	}
+	if err := s.moderationRepo.UpdateWord(word); err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Failed to update moderation word %d: %v", id, err))
+		return nil, err
+	}
+	reloadModerationWords()
+
+	common.SysLog(fmt.Sprintf("[ModerationService] Updated moderation word %d", id))
+	return word, nil
+}
+
+// DeleteWord removes a word from the word list
+func (s *ModerationService) DeleteWord(id int64) error {
+	word, err := s.getWord(id)
+	if err != nil {
+		return err
+	}
+	if err := s.moderationRepo.DeleteWord(word); err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Failed to delete moderation word %d: %v", id, err))
+		return err
+	}
+	reloadModerationWords()
+
+	common.SysLog(fmt.Sprintf("[ModerationService] Deleted moderation word %d", id))
+	return nil
+}
+
+func (s *ModerationService) getWord(id int64) (*model.ModerationWord, error) {
+	word, err := s.moderationRepo.GetWordById(id)
+	if err != nil {
+		return nil, err
+	}
+	if word == nil {
+		return nil, fmt.Errorf("敏感词不存在")
+	}
+	return word, nil
+}
+
+// applyWordRequest 校验请求并写入敏感词
+func (s *ModerationService) applyWordRequest(word *model.ModerationWord, req define.ModerationWordRequest) error {
+	rule := moderation.Rule{Pattern: strings.TrimSpace(req.Word), Regex: req.IsRegex, Action: req.Action}
+	if _, err := moderation.CompileRule(rule); err != nil {
+		return err
+	}
+	existing, err := s.moderationRepo.GetWordByText(rule.Pattern)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Id != word.Id {
+		return errWordExists
+	}
+	word.Word = rule.Pattern
+	word.IsRegex = req.IsRegex
+	word.Category = strings.TrimSpace(req.Category)
+	word.Action = req.Action
+	return nil
+}
+
+// ListRecords 分页获取审核记录，status为空时返回所有状态
+func (s *ModerationService) ListRecords(status string, offset, limit int) ([]define.ModerationRecordResponse, int64, error) {
+	records, total, err := s.moderationRepo.GetRecords(status, offset, limit)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Failed to list moderation records: %v", err))
+		return nil, 0, err
+	}
+	responses := make([]define.ModerationRecordResponse, 0, len(records))
+	for _, record := range records {
+		responses = append(responses, toModerationRecordResponse(record))
+	}
+	return responses, total, nil
+}
+
+// ReviewRecord marks a flagged record as approved (no violation) or rejected (violation confirmed)
+func (s *ModerationService) ReviewRecord(reviewerId int64, id int64, req define.ModerationReviewRequest) (*define.ModerationRecordResponse, error) {
+	if req.Status != define.ModerationStatusApproved && req.Status != define.ModerationStatusRejected {
+		return nil, fmt.Errorf("复核结果只能是approved或rejected")
+	}
+	record, err := s.moderationRepo.GetRecordById(id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("审核记录不存在")
+	}
+	if record.Status == define.ModerationStatusBlocked {
+		return nil, fmt.Errorf("已拦截的内容不需要复核")
+	}
+
+	record.Status = req.Status
+	record.ReviewerId = reviewerId
+	record.ReviewNote = strings.TrimSpace(req.Note)
+	record.ReviewedAt = time.Now().Unix()
+	if err := s.moderationRepo.UpdateRecord(record); err != nil {
+		common.SysError(fmt.Sprintf("[ModerationService] Failed to review moderation record %d: %v", id, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[ModerationService] Moderation record %d reviewed by user %d: %s", id, reviewerId, req.Status))
+	response := toModerationRecordResponse(record)
+	return &response, nil
+}
+
+// TestText runs the moderation pipeline on a text without recording anything, so administrators can try out the word list
+func (s *ModerationService) TestText(ctx context.Context, req define.ModerationTestRequest) (*define.ModerationTestResponse, error) {
+	stage := req.Stage
+	if stage == "" {
+		stage = moderation.StageGeneration
+	}
+	if stage != moderation.StagePrompt && stage != moderation.StageGeneration {
+		return nil, fmt.Errorf("审核阶段只能是prompt或generation")
+	}
+	result := moderation.Run(ctx, s.engines(), stage, req.Text)
+	response := &define.ModerationTestResponse{Action: result.Action, Text: result.Text, Matches: []define.ModerationMatch{}}
+	for _, match := range result.Matches {
+		response.Matches = append(response.Matches, define.ModerationMatch(match))
+	}
+	return response, nil
+}
+
+func toModerationRecordResponse(record *model.ModerationRecord) define.ModerationRecordResponse {
+	matches := []define.ModerationMatch{}
+	if record.Matches != "" {
+		_ = json.Unmarshal([]byte(record.Matches), &matches)
+	}
+	return define.ModerationRecordResponse{
+		Id:         record.Id,
+		UserId:     record.UserId,
+		ProjectId:  record.ProjectId,
+		Stage:      record.Stage,
+		Source:     record.Source,
+		Action:     record.Action,
+		Content:    record.Content,
+		Matches:    matches,
+		Status:     record.Status,
+		ReviewerId: record.ReviewerId,
+		ReviewNote: record.ReviewNote,
+		ReviewedAt: record.ReviewedAt,
+		CreatedAt:  record.CreatedAt,
+	}
+}
@@ -0,0 +1,106 @@
+package moderation
+
+import "unicode"
+
+// Matcher Aho-Corasick多模式匹配自动机，一次扫描找出文本中所有词的出现位置，耗时与词表大小无关
+// 匹配不区分大小写，全角字母和数字按半角处理
+type Matcher struct {
+	nodes   []acNode
+	lengths []int // 每个词的字符数
+}
+
+type acNode struct {
+	next map[rune]int32
+	fail int32
+	out  []int32 // 以该节点结尾的词，包括沿失败链可达的词
+}
+
+// Hit 词在文本中的一次出现，Start和End为字符（rune）位置
+type Hit struct {
+	Pattern int
+	Start   int
+	End     int
+}
+
+// NewMatcher 为一组词构建自动机，空词被忽略
+func NewMatcher(patterns []string) *Matcher {
+	m := &Matcher{nodes: []acNode{{}}, lengths: make([]int, len(patterns))}
+	for i, pattern := range patterns {
+		runes := []rune(pattern)
+		m.lengths[i] = len(runes)
+		if len(runes) == 0 {
+			continue
+		}
+		state := int32(0)
+		for _, r := range runes {
+			r = foldRune(r)
+			next, ok := m.nodes[state].next[r]
+			if !ok {
+				m.nodes = append(m.nodes, acNode{})
+				next = int32(len(m.nodes) - 1)
+				if m.nodes[state].next == nil {
+					m.nodes[state].next = make(map[rune]int32)
+				}
+				m.nodes[state].next[r] = next
+			}
+			state = next
+		}
+		m.nodes[state].out = append(m.nodes[state].out, int32(i))
+	}
+
+	// 按层次遍历设置失败指针，并合并失败链上的输出
+	queue := make([]int32, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[state].next {
+			fail := m.nodes[state].fail
+			for fail > 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			m.nodes[child].out = append(m.nodes[child].out, m.nodes[m.nodes[child].fail].out...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// FindAll 返回文本中所有词的出现位置，重叠的出现都会返回
+func (m *Matcher) FindAll(text []rune) []Hit {
+	var hits []Hit
+	state := int32(0)
+	for i, r := range text {
+		r = foldRune(r)
+		for {
+			if next, ok := m.nodes[state].next[r]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = m.nodes[state].fail
+		}
+		for _, pattern := range m.nodes[state].out {
+			hits = append(hits, Hit{Pattern: int(pattern), Start: i + 1 - m.lengths[pattern], End: i + 1})
+		}
+	}
+	return hits
+}
+
+// foldRune 统一大小写，全角ASCII字符转为半角
+func foldRune(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		r -= 0xFEE0
+	}
+	return unicode.ToLower(r)
+}
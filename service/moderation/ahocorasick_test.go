@@ -0,0 +1,84 @@
+package moderation
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMatcherFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     []Hit
+	}{
+		{
+			name:     "没有命中",
+			patterns: []string{"赌博"},
+			text:     "主角在雨夜回到小镇",
+			want:     nil,
+		},
+		{
+			name:     "多个词",
+			patterns: []string{"赌博", "毒品"},
+			text:     "赌博和毒品",
+			want:     []Hit{{Pattern: 0, Start: 0, End: 2}, {Pattern: 1, Start: 3, End: 5}},
+		},
+		{
+			name:     "重叠和包含",
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want: []Hit{
+				{Pattern: 0, Start: 2, End: 4},
+				{Pattern: 1, Start: 1, End: 4},
+				{Pattern: 3, Start: 2, End: 6},
+			},
+		},
+		{
+			name:     "重复出现",
+			patterns: []string{"aa"},
+			text:     "aaaa",
+			want:     []Hit{{Pattern: 0, Start: 0, End: 2}, {Pattern: 0, Start: 1, End: 3}, {Pattern: 0, Start: 2, End: 4}},
+		},
+		{
+			name:     "不区分大小写和全角",
+			patterns: []string{"Spam"},
+			text:     "sPAM ＳＰＡＭ",
+			want:     []Hit{{Pattern: 0, Start: 0, End: 4}, {Pattern: 0, Start: 5, End: 9}},
+		},
+		{
+			name:     "失败链跳转",
+			patterns: []string{"abcd", "bce"},
+			text:     "abce",
+			want:     []Hit{{Pattern: 1, Start: 1, End: 4}},
+		},
+		{
+			name:     "忽略空词",
+			patterns: []string{"", "龙"},
+			text:     "龙",
+			want:     []Hit{{Pattern: 1, Start: 0, End: 1}},
+		},
+		{
+			name:     "没有词",
+			patterns: nil,
+			text:     "任意内容",
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMatcher(tt.patterns).FindAll([]rune(tt.text))
+			// 同一位置结束的多个词顺序不固定
+			sort.Slice(got, func(i, j int) bool {
+				if got[i].End != got[j].End {
+					return got[i].End < got[j].End
+				}
+				return got[i].Pattern < got[j].Pattern
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAll(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
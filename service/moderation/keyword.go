@@ -0,0 +1,126 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Rule 敏感词规则，Regex为true时Pattern为正则表达式
+type Rule struct {
+	Pattern  string
+	Regex    bool
+	Category string
+	Action   string
+}
+
+// KeywordEngine 敏感词引擎，普通词用Aho-Corasick自动机一次扫描匹配，正则规则逐条匹配
+type KeywordEngine struct {
+	words   []Rule
+	matcher *Matcher
+	regexes []*regexp.Regexp
+	rules   []Rule // 与regexes一一对应
+	// 与regexes一一对应，含有^、$、\b等位置断言的正则在内容未结束时无法判断，不参与流式检查
+	streamable []bool
+	span       int // 流式检查时一次命中最多跨越的字符数，按此保留之前内容的末尾
+}
+
+// CompileRule 校验规则，正则规则返回编译结果；正则不区分大小写
+func CompileRule(rule Rule) (*regexp.Regexp, error) {
+	if rule.Pattern == "" {
+		return nil, fmt.Errorf("敏感词不能为空")
+	}
+	if !IsAction(rule.Action) {
+		return nil, fmt.Errorf("不支持的处理方式：%s", rule.Action)
+	}
+	if !rule.Regex {
+		return nil, nil
+	}
+	regex, err := regexp.Compile("(?i)" + rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("正则表达式格式错误: %v", err)
+	}
+	return regex, nil
+}
+
+// NewKeywordEngine 由规则构建敏感词引擎，无效的规则被跳过并返回第一个错误
+func NewKeywordEngine(rules []Rule) (*KeywordEngine, error) {
+	engine := &KeywordEngine{}
+	var patterns []string
+	var firstErr error
+	for _, rule := range rules {
+		regex, err := CompileRule(rule)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %v", rule.Pattern, err)
+			}
+			continue
+		}
+		if regex != nil {
+			engine.regexes = append(engine.regexes, regex)
+			engine.rules = append(engine.rules, rule)
+			span, streamable := regexSpan(regex)
+			engine.streamable = append(engine.streamable, streamable)
+			engine.span = max(engine.span, span)
+			continue
+		}
+		engine.words = append(engine.words, rule)
+		patterns = append(patterns, rule.Pattern)
+		engine.span = max(engine.span, utf8.RuneCountInString(rule.Pattern))
+	}
+	engine.matcher = NewMatcher(patterns)
+	return engine, firstErr
+}
+
+func (e *KeywordEngine) Name() string {
+	return "keyword"
+}
+
+// Size 规则数量
+func (e *KeywordEngine) Size() int {
+	return len(e.words) + len(e.regexes)
+}
+
+// Check 返回文本中所有命中的敏感词
+func (e *KeywordEngine) Check(ctx context.Context, stage string, text string) ([]Match, error) {
+	return e.check([]rune(text), text, false), nil
+}
+
+// check 返回文本中所有命中的敏感词，runes为text的字符；stream为true时只使用适合流式检查的正则
+func (e *KeywordEngine) check(runes []rune, text string, stream bool) []Match {
+	var matches []Match
+	for _, hit := range e.matcher.FindAll(runes) {
+		rule := e.words[hit.Pattern]
+		matches = append(matches, Match{
+			Engine:   e.Name(),
+			Rule:     rule.Pattern,
+			Category: rule.Category,
+			Action:   rule.Action,
+			Text:     string(runes[hit.Start:hit.End]),
+			Start:    hit.Start,
+			End:      hit.End,
+		})
+	}
+	for i, regex := range e.regexes {
+		if stream && !e.streamable[i] {
+			continue
+		}
+		for _, loc := range regex.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			start := utf8.RuneCountInString(text[:loc[0]])
+			matches = append(matches, Match{
+				Engine:   e.Name(),
+				Rule:     e.rules[i].Pattern,
+				Category: e.rules[i].Category,
+				Action:   e.rules[i].Action,
+				Text:     text[loc[0]:loc[1]],
+				Start:    start,
+				End:      start + utf8.RuneCountInString(text[loc[0]:loc[1]]),
+			})
+		}
+	}
+	return matches
+}
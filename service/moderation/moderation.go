@@ -0,0 +1,103 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"gin-template/common"
+	"strings"
+)
+
+// 审核结果的处理方式，按严重程度从低到高
+const (
+	ActionPass  = "pass"
+	ActionFlag  = "flag"  // 放行，记录到审核队列由管理员复核
+	ActionMask  = "mask"  // 命中的文字替换为*后放行
+	ActionBlock = "block" // 拦截，不调用模型或不返回生成的内容
+)
+
+// 审核的阶段
+const (
+	StagePrompt     = "prompt"     // 发送给模型之前的提示词
+	StageGeneration = "generation" // 模型生成的内容
+)
+
+var actionSeverity = map[string]int{ActionPass: 0, ActionFlag: 1, ActionMask: 2, ActionBlock: 3}
+
+// IsAction 是否为支持的处理方式，pass除外
+func IsAction(action string) bool {
+	return action == ActionFlag || action == ActionMask || action == ActionBlock
+}
+
+// Match 一条命中，Start和End为命中文字的字符位置，对整段文本的判断（如模型分类）为-1
+type Match struct {
+	Engine   string `json:"engine"`
+	Rule     string `json:"rule"`
+	Category string `json:"category,omitempty"`
+	Action   string `json:"action"`
+	Text     string `json:"text,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// Engine 审核引擎，返回文本的命中；出错时该引擎的结果被忽略，不影响其他引擎
+type Engine interface {
+	Name() string
+	Check(ctx context.Context, stage string, text string) ([]Match, error)
+}
+
+// Result 审核结果，Action为所有命中中最严重的处理方式，Text为打码后的文本
+type Result struct {
+	Action  string
+	Matches []Match
+	Text    string
+}
+
+// Flagged 是否有需要复核的命中
+func (r *Result) Flagged() bool {
+	for _, match := range r.Matches {
+		if match.Action == ActionFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// Run 依次使用各个引擎审核文本
+func Run(ctx context.Context, engines []Engine, stage string, text string) *Result {
+	result := &Result{Action: ActionPass, Text: text}
+	if strings.TrimSpace(text) == "" {
+		return result
+	}
+	for _, engine := range engines {
+		matches, err := engine.Check(ctx, stage, text)
+		if err != nil {
+			common.SysError(fmt.Sprintf("[Moderation] Engine %s failed, skipping it: %v", engine.Name(), err))
+			continue
+		}
+		for _, match := range matches {
+			if actionSeverity[match.Action] > actionSeverity[result.Action] {
+				result.Action = match.Action
+			}
+		}
+		result.Matches = append(result.Matches, matches...)
+	}
+	if result.Action == ActionMask {
+		result.Text = Mask(text, result.Matches)
+	}
+	return result
+}
+
+// Mask 将需要打码的命中替换为同样数量的*，字符数不变
+func Mask(text string, matches []Match) string {
+	runes := []rune(text)
+	for _, match := range matches {
+		if match.Action != ActionMask || match.Start < 0 {
+			continue
+		}
+		for i := match.Start; i < match.End && i < len(runes); i++ {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
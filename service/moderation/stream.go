@@ -0,0 +1,129 @@
+package moderation
+
+import (
+	"regexp"
+	"regexp/syntax"
+)
+
+// unboundedRegexSpan 可以匹配任意长度的正则（如包含*、+）在流式检查时按此长度保留之前的内容，
+// 跨度更长的命中在流结束后审核完整内容时发现
+const unboundedRegexSpan = 256
+
+// StreamScanner 逐段检查流式生成的内容，每段只扫描新增的内容和之前内容末尾可能与其组成命中的部分，
+// 耗时与已生成内容的长度无关。含有位置断言的正则不参与流式检查，只在流结束后审核完整内容
+// 之前内容的末尾可能与之后的内容组成需要打码的命中，暂不输出，打码后随之后的内容一起输出
+type StreamScanner struct {
+	engine *KeywordEngine
+	tail   []rune // 之前内容的末尾，不超过engine.span-1个字符，尚未输出
+	masked []rune // 打码后的tail，与tail字符数相同
+	offset int    // tail第一个字符在整段内容中的位置
+}
+
+// NewStreamScanner 创建流式检查，使用创建时的规则
+func (e *KeywordEngine) NewStreamScanner() *StreamScanner {
+	return &StreamScanner{engine: e}
+}
+
+// Scan 追加一段内容，返回可以输出的内容和结束位置落在这段内容中的命中，位置相对整段内容
+// 输出的内容已按命中打码，末尾可能与之后的内容组成命中的部分保留到下一次调用；结束位置在之前内容中的命中已由之前的调用返回
+func (s *StreamScanner) Scan(chunk string) (string, []Match) {
+	if chunk == "" {
+		return "", nil
+	}
+	runes := []rune(chunk)
+	window := append(s.tail, runes...)
+	output := append(s.masked, runes...)
+	var matches []Match
+	for _, match := range s.engine.check(window, string(window), true) {
+		if match.End <= len(s.tail) {
+			continue
+		}
+		// 匹配以原文进行，打码只影响输出，避免打码后的文字影响其他规则的命中
+		if match.Action == ActionMask {
+			for i := match.Start; i < match.End; i++ {
+				output[i] = '*'
+			}
+		}
+		match.Start += s.offset
+		match.End += s.offset
+		matches = append(matches, match)
+	}
+
+	keep := min(len(window), max(s.engine.span-1, 0))
+	s.offset += len(window) - keep
+	s.tail = append([]rune(nil), window[len(window)-keep:]...)
+	s.masked = append([]rune(nil), output[len(output)-keep:]...)
+	return string(output[:len(output)-keep]), matches
+}
+
+// regexSpan 正则一次命中最多的字符数，可以匹配任意长度时为unboundedRegexSpan
+// 含有位置断言时命中与之后的内容有关，不能用于流式检查，返回false
+func regexSpan(regex *regexp.Regexp) (int, bool) {
+	parsed, err := syntax.Parse(regex.String(), syntax.Perl)
+	if err != nil || hasAssertion(parsed) {
+		return 0, false
+	}
+	span := maxMatchLength(parsed.Simplify())
+	if span < 0 || span > unboundedRegexSpan {
+		return unboundedRegexSpan, true
+	}
+	return span, true
+}
+
+// hasAssertion 正则是否含有^、$、\b、\B等位置断言
+func hasAssertion(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if hasAssertion(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxMatchLength 正则语法树最多匹配的字符数，没有上限时返回-1
+func maxMatchLength(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune)
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return 1
+	case syntax.OpCapture, syntax.OpQuest:
+		return maxMatchLength(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus:
+		return -1
+	case syntax.OpRepeat:
+		sub := maxMatchLength(re.Sub[0])
+		if re.Max < 0 || sub < 0 {
+			return -1
+		}
+		return re.Max * sub
+	case syntax.OpConcat:
+		total := 0
+		for _, sub := range re.Sub {
+			length := maxMatchLength(sub)
+			if length < 0 {
+				return -1
+			}
+			total += length
+		}
+		return total
+	case syntax.OpAlternate:
+		longest := 0
+		for _, sub := range re.Sub {
+			length := maxMatchLength(sub)
+			if length < 0 {
+				return -1
+			}
+			longest = max(longest, length)
+		}
+		return longest
+	default:
+		// 空匹配和^、$、\b等位置断言不占字符
+		return 0
+	}
+}
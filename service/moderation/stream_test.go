@@ -0,0 +1,148 @@
+package moderation
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sortMatches(matches []Match) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].Rule < matches[j].Rule
+	})
+}
+
+func TestStreamScannerMatchesFullCheck(t *testing.T) {
+	engine, err := NewKeywordEngine([]Rule{
+		{Pattern: "赌博", Action: ActionBlock},
+		{Pattern: "spam", Action: ActionMask},
+		{Pattern: `\d{3}-\d{4}`, Regex: true, Action: ActionFlag},
+		{Pattern: `加.{0,2}微信`, Regex: true, Action: ActionFlag},
+	})
+	if err != nil {
+		t.Fatalf("NewKeywordEngine() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		chunks []string
+	}{
+		{"命中在一段内", []string{"他开始赌博。", "之后没有再来。"}},
+		{"命中跨越两段", []string{"他开始赌", "博。"}},
+		{"命中跨越多段", []string{"联系", "55", "5-1", "2", "34", "，加", "我", "微", "信"}},
+		{"逐字输出", strings.Split("这是SPAM广告，电话123-4567，不要赌博", "")},
+		{"没有命中", []string{"主角在雨夜", "回到了小镇。"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := engine.NewStreamScanner()
+			var got []Match
+			for _, chunk := range tt.chunks {
+				_, matches := scanner.Scan(chunk)
+				got = append(got, matches...)
+			}
+			want, _ := engine.Check(context.Background(), StageGeneration, strings.Join(tt.chunks, ""))
+			sortMatches(got)
+			sortMatches(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Scan() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestStreamScannerMasksOutput(t *testing.T) {
+	engine, err := NewKeywordEngine([]Rule{
+		{Pattern: "张三", Action: ActionMask},
+		{Pattern: "spam", Action: ActionMask},
+		{Pattern: `\d{3}-\d{4}`, Regex: true, Action: ActionMask},
+		// 与打码的词重叠的规则仍按原文命中
+		{Pattern: "三丰", Action: ActionFlag},
+	})
+	if err != nil {
+		t.Fatalf("NewKeywordEngine() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"命中在一段内", []string{"坏人张三逃走了。"}, "坏人**逃走了。"},
+		{"命中跨越两段", []string{"坏人张", "三逃走了。"}, "坏人**逃走了。"},
+		{"逐字输出", strings.Split("电话123-4567，SPAM，张三丰", ""), "电话********，****，**丰"},
+		{"没有命中", []string{"主角在雨夜", "回到了小镇。"}, "主角在雨夜回到了小镇。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := engine.NewStreamScanner()
+			var output strings.Builder
+			var flagged bool
+			for _, chunk := range tt.chunks {
+				text, matches := scanner.Scan(chunk)
+				// 已输出的内容不会再改变，必须已经打码
+				output.WriteString(text)
+				if !strings.HasPrefix(tt.want, output.String()) {
+					t.Fatalf("output after %q = %q, not a prefix of %q", chunk, output.String(), tt.want)
+				}
+				for _, match := range matches {
+					flagged = flagged || match.Action == ActionFlag
+				}
+			}
+			// 保留的末尾在流结束时输出
+			output.WriteString(string(scanner.masked))
+			if output.String() != tt.want {
+				t.Errorf("output = %q, want %q", output.String(), tt.want)
+			}
+			if wantFlagged := strings.Contains(strings.Join(tt.chunks, ""), "三丰"); flagged != wantFlagged {
+				t.Errorf("flagged = %v, want %v", flagged, wantFlagged)
+			}
+		})
+	}
+}
+
+func TestStreamScannerSkipsAssertions(t *testing.T) {
+	engine, err := NewKeywordEngine([]Rule{
+		{Pattern: `^广告`, Regex: true, Action: ActionBlock},
+		{Pattern: `\bbet\b`, Regex: true, Action: ActionBlock},
+	})
+	if err != nil {
+		t.Fatalf("NewKeywordEngine() error = %v", err)
+	}
+	scanner := engine.NewStreamScanner()
+	for _, chunk := range []string{"广告", " bet", "ter"} {
+		if _, matches := scanner.Scan(chunk); len(matches) > 0 {
+			t.Errorf("Scan(%q) = %+v, want no matches", chunk, matches)
+		}
+	}
+}
+
+func TestRegexSpan(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		span       int
+		streamable bool
+	}{
+		{`abc`, 3, true},
+		{`\d{3}-\d{4}`, 8, true},
+		{`加.{0,2}微信`, 5, true},
+		{`a|bcd`, 3, true},
+		{`a+b`, unboundedRegexSpan, true},
+		{`^abc`, 0, false},
+		{`abc$`, 0, false},
+		{`\bbet\b`, 0, false},
+	}
+	for _, tt := range tests {
+		regex, err := CompileRule(Rule{Pattern: tt.pattern, Regex: true, Action: ActionFlag})
+		if err != nil {
+			t.Fatalf("CompileRule(%q) error = %v", tt.pattern, err)
+		}
+		span, streamable := regexSpan(regex)
+		if span != tt.span || streamable != tt.streamable {
+			t.Errorf("regexSpan(%q) = %d, %v, want %d, %v", tt.pattern, span, streamable, tt.span, tt.streamable)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"gin-template/service/llm"
+	"gin-template/service/moderation"
+	"strings"
+)
+
+// classifierMaxTokens 分类结果的最大生成长度
+const classifierMaxTokens = 300
+
+// llmClassifier 使用模型判断整段文本是否违规的审核引擎，按moderation_llm_stages启用
+// 分类调用直接访问模型服务商，不经过审核和缓存，也不向用户计费
+type llmClassifier struct {
+	promptRepo *repository.PromptTemplateRepository
+}
+
+func (c *llmClassifier) Name() string {
+	return "llm"
+}
+
+// classifierStages 启用模型分类的阶段
+func classifierStages() map[string]bool {
+	stages := make(map[string]bool)
+	for _, stage := range strings.Split(model.GetSetting(OptionModerationLLMStages), ",") {
+		if stage = strings.TrimSpace(stage); stage != "" {
+			stages[stage] = true
+		}
+	}
+	return stages
+}
+
+// ParseModerationLLMStages 校验使用模型分类的阶段设置
+func ParseModerationLLMStages(value string) error {
+	for _, stage := range strings.Split(value, ",") {
+		stage = strings.TrimSpace(stage)
+		if stage != "" && stage != moderation.StagePrompt && stage != moderation.StageGeneration {
+			return fmt.Errorf("不支持的审核阶段：%s，只能是prompt或generation", stage)
+		}
+	}
+	return nil
+}
+
+// ParseModerationLLMAction 校验模型判定违规时的处理方式
+func ParseModerationLLMAction(value string) error {
+	if value != moderation.ActionBlock && value != moderation.ActionFlag {
+		return fmt.Errorf("模型判定违规时的处理方式只能是block或flag")
+	}
+	return nil
+}
+
+// Check 调用模型分类，模型判定违规时返回一条针对整段文本的命中
+func (c *llmClassifier) Check(ctx context.Context, stage string, text string) ([]moderation.Match, error) {
+	if !classifierStages()[stage] {
+		return nil, nil
+	}
+	response, err := llm.Chat(ctx, llm.ChatRequest{
+		Model: model.GetSetting(OptionModerationLLMModel),
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: RenderPrompt(c.promptRepo, PromptModerationSystem, nil)},
+			{Role: llm.RoleUser, Content: truncateRunes(text, moderationClassifierChars)},
+		},
+		MaxTokens: classifierMaxTokens,
+		Source:    define.AISourceModeration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 模型可能在JSON前后附带说明或代码块标记
+	start, end := strings.Index(response.Content, "{"), strings.LastIndex(response.Content, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in classifier output")
+	}
+	var verdict struct {
+		Flagged  bool   `json:"flagged"`
+		Category string `json:"category"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(response.Content[start:end+1]), &verdict); err != nil {
+		return nil, err
+	}
+	if !verdict.Flagged {
+		return nil, nil
+	}
+
+	action := model.GetSetting(OptionModerationLLMAction)
+	if action != moderation.ActionBlock {
+		action = moderation.ActionFlag
+	}
+	return []moderation.Match{{
+		Engine:   c.Name(),
+		Rule:     response.Model,
+		Category: strings.TrimSpace(verdict.Category),
+		Action:   action,
+		Reason:   strings.TrimSpace(verdict.Reason),
+		Start:    -1,
+		End:      -1,
+	}}, nil
+}
@@ -1,212 +1,360 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"gin-template/common"
 	"gin-template/define"
-	"gin-template/model"
-	"io/ioutil"
-	"net/http"
+	"gin-template/service/llm"
+	"gin-template/service/moderation"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
-// OpenAI API相关配置
-const (
-	// OpenAI API端点
-	OpenAIAPIURL    = "http://1.12.219.175:3001/v1/chat/completions"
-	OpenAIModelsURL = "http://1.12.219.175:3001/v1/models"
-)
-
-// GenerateAICompletion 调用OpenAI API生成补全
-func GenerateAICompletion(req define.GenerateAIPromptRequest) (define.GenerateResponse, error) {
+// GenerateAICompletion 按模型名选择服务商生成补全，未指定模型时使用默认服务商的默认模型
+// 失败时按设置重试并切换到备用服务商，结果中记录最终回答的服务商和调用次数
+// 相同的请求在缓存有效期内直接返回缓存的结果，req.NoCache为true时不使用缓存
+// 提示词在调用前审核，生成的内容在返回前审核，被拦截时返回错误，打码后的内容才会写入缓存
+func GenerateAICompletion(ctx context.Context, req define.GenerateAIPromptRequest) (define.GenerateResponse, error) {
 	var result define.GenerateResponse
 
-	// 设置默认值
-	if req.Model == "" {
-		req.Model = "gpt-3.5-turbo"
-	}
-	if req.Temperature == 0 {
-		req.Temperature = 0.7
+	if err := moderatePrompt(ctx, &req); err != nil {
+		return result, err
 	}
-	if req.MaxTokens == 0 {
-		req.MaxTokens = 1000
+	chatReq := buildChatRequest(req)
+	cacheKey := completionCacheKey(req, chatReq)
+	if cacheKey != "" && !req.NoCache {
+		if cached := llm.GetCachedResponse(ctx, cacheKey); cached != nil {
+			common.SysLog(fmt.Sprintf("[OpenAIService] Completion served from cache, model: %s", cached.Model))
+			return cachedCompletion(cached), nil
+		}
 	}
 
-	// 组装消息数组
-	messages := []map[string]string{
-		{
-			"role":    "system",
-			"content": req.SystemPrompt,
-		},
+	response, err := llm.Chat(ctx, chatReq)
+	if err != nil {
+		return result, err
 	}
-
-	// 添加上下文信息（如果有）
-	for _, ctx := range req.ContextData {
-		messages = append(messages, map[string]string{
-			"role":    "assistant",
-			"content": ctx,
+	if response.Content, err = moderateGeneration(ctx, req, response.Content); err != nil {
+		return result, err
+	}
+	if cacheKey != "" {
+		llm.PutCachedResponse(ctx, cacheKey, llm.CachedResponse{
+			Provider:     response.Provider,
+			Model:        response.Model,
+			Content:      response.Content,
+			FinishReason: response.FinishReason,
+			Usage:        response.Usage,
 		})
 	}
 
-	// 添加用户消息
-	messages = append(messages, map[string]string{
-		"role":    "user",
-		"content": req.UserPrompt,
-	})
-
-	// 准备OpenAI API请求
-	requestBody := map[string]interface{}{
-		"model":       req.Model,
-		"messages":    messages,
-		"temperature": req.Temperature,
-		"max_tokens":  req.MaxTokens,
+	result = define.GenerateResponse{
+		Content:          response.Content,
+		TokensUsed:       response.Usage.TotalTokens,
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		Model:            response.Model,
+		RequestID:        response.Id,
+		Provider:         response.Provider,
+		Attempts:         response.Attempts,
 	}
+	return result, nil
+}
 
-	requestJSON, err := json.Marshal(requestBody)
-	if err != nil {
-		return result, fmt.Errorf("无法序列化请求: %v", err)
+// moderatePrompt 使用内容审核服务审核提示词，未设置审核服务时不审核
+func moderatePrompt(ctx context.Context, req *define.GenerateAIPromptRequest) error {
+	if service := GetModerationService(); service != nil {
+		return service.ModeratePrompt(ctx, req)
 	}
+	return nil
+}
 
-	// 获取OpenAI API密钥
-	apiKey := model.GetSetting("openai_api_key")
-	if apiKey == "" {
-		return result, fmt.Errorf("未配置OpenAI API密钥")
+// moderateGeneration 使用内容审核服务审核生成的内容，未设置审核服务时原样返回
+func moderateGeneration(ctx context.Context, req define.GenerateAIPromptRequest, content string) (string, error) {
+	if service := GetModerationService(); service != nil {
+		return service.ModerateGeneration(ctx, req, content)
 	}
+	return content, nil
+}
 
-	// 创建HTTP请求
-	httpReq, err := http.NewRequest("POST", OpenAIAPIURL, bytes.NewBuffer(requestJSON))
+// completionCacheKey 请求的缓存键，无法确定服务商时为空，此时不使用缓存
+// /ai/prompt的提示词完全由调用者提供，缓存按用户隔离，不同用户的相同请求不共用结果
+func completionCacheKey(req define.GenerateAIPromptRequest, chatReq llm.ChatRequest) string {
+	scope := ""
+	if req.Source == define.AISourcePrompt {
+		scope = "user:" + strconv.FormatInt(req.UserId, 10)
+	}
+	key, err := llm.CacheKey(chatReq, scope)
 	if err != nil {
-		return result, fmt.Errorf("创建HTTP请求失败: %v", err)
+		return ""
 	}
+	return key
+}
 
-	// 设置请求头
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return result, fmt.Errorf("调用OpenAI API失败: %v", err)
+// cachedCompletion 由缓存的结果组装补全结果
+func cachedCompletion(cached *llm.CachedResponse) define.GenerateResponse {
+	return define.GenerateResponse{
+		Content:          cached.Content,
+		TokensUsed:       cached.Usage.TotalTokens,
+		PromptTokens:     cached.Usage.PromptTokens,
+		CompletionTokens: cached.Usage.CompletionTokens,
+		Model:            cached.Model,
+		Provider:         cached.Provider,
+		Cached:           true,
 	}
-	defer resp.Body.Close()
+}
 
-	// 处理响应
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return result, fmt.Errorf("读取API响应失败: %v", err)
-	}
-
-	// 检查响应状态码
-	if resp.StatusCode != http.StatusOK {
-		errResponse := struct {
-			Error struct {
-				Message string `json:"message"`
-				Type    string `json:"type"`
-			} `json:"error"`
-		}{}
-		if err := json.Unmarshal(body, &errResponse); err == nil && errResponse.Error.Message != "" {
-			return result, fmt.Errorf("API错误: %s", errResponse.Error.Message)
-		} else {
-			return result, fmt.Errorf("API返回错误: %d", resp.StatusCode)
+// AICompletionStream 流式补全，记录已生成的内容和用量
+type AICompletionStream struct {
+	Provider string
+	Model    string
+	Attempts int
+	Cached   bool // 是否为缓存的结果，此时整段内容作为一段增量返回
+	stream   *llm.Stream
+	counter  *llm.UsageCounter
+	content  strings.Builder
+	scanner  *moderation.StreamScanner // 逐段检查敏感词，未启用审核时为nil
+	output   strings.Builder           // 已通过Recv返回的内容，打码后的文字
+	emitted  int                       // output的字符数
+	done     bool                      // 流已结束，剩余的内容已返回
+
+	ctx          context.Context
+	request      define.GenerateAIPromptRequest
+	cacheKey     string
+	cached       *llm.CachedResponse
+	finishReason string
+}
+
+// StreamAICompletion 按模型名选择服务商流式生成补全，取消ctx会中断上游请求
+// 缓存规则与GenerateAICompletion相同，只有正常结束的流会写入缓存
+// 生成过程中出现需要拦截的敏感词时中断并返回错误；流结束时审核完整的内容，打码后的内容通过Content获取
+func StreamAICompletion(ctx context.Context, req define.GenerateAIPromptRequest) (*AICompletionStream, error) {
+	if err := moderatePrompt(ctx, &req); err != nil {
+		return nil, err
+	}
+	chatReq := buildChatRequest(req)
+	cacheKey := completionCacheKey(req, chatReq)
+	if cacheKey != "" && !req.NoCache {
+		if cached := llm.GetCachedResponse(ctx, cacheKey); cached != nil {
+			common.SysLog(fmt.Sprintf("[OpenAIService] Streaming completion served from cache, model: %s", cached.Model))
+			return &AICompletionStream{Provider: cached.Provider, Model: cached.Model, Cached: true, cached: cached}, nil
 		}
 	}
 
-	// 解析响应
-	var response struct {
-		ID      string `json:"id"`
-		Object  string `json:"object"`
-		Created int64  `json:"created"`
-		Model   string `json:"model"`
-		Usage   struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		} `json:"usage"`
-		Choices []struct {
-			Message struct {
-				Role    string `json:"role"`
-				Content string `json:"content"`
-			} `json:"message"`
-			FinishReason string `json:"finish_reason"`
-			Index        int    `json:"index"`
-		} `json:"choices"`
+	stream, err := llm.OpenStream(ctx, chatReq)
+	if err != nil {
+		return nil, err
 	}
+	completion := &AICompletionStream{
+		Provider: stream.Provider,
+		Model:    stream.Model,
+		Attempts: stream.Attempts,
+		stream:   stream,
+		counter:  llm.NewUsageCounter(chatReq),
+		ctx:      ctx,
+		request:  req,
+		cacheKey: cacheKey,
+	}
+	if service := GetModerationService(); service != nil {
+		completion.scanner = service.NewGenerationScanner()
+	}
+	return completion, nil
+}
 
-	if err := json.Unmarshal(body, &response); err != nil {
-		return result, fmt.Errorf("解析API响应失败: %v", err)
+// Recv 返回下一段生成的内容，流结束时返回io.EOF
+// 启用审核时返回的内容已打码，可能与之后的内容组成敏感词的末尾暂不返回，流结束并审核完整的内容后再返回
+func (s *AICompletionStream) Recv() (string, error) {
+	if s.Cached {
+		if s.content.Len() > 0 {
+			return "", io.EOF
+		}
+		s.content.WriteString(s.cached.Content)
+		return s.emit(s.cached.Content), nil
+	}
+	if s.done {
+		return "", io.EOF
 	}
 
-	// 处理空响应
-	if len(response.Choices) == 0 {
-		return result, fmt.Errorf("API没有返回有效的回复")
+	chunk, err := s.stream.Recv()
+	if err == io.EOF {
+		if err := s.finish(); err != nil {
+			return "", err
+		}
+		// 返回暂未返回的末尾，使用审核完整内容后打码的结果
+		s.done = true
+		if rest := string([]rune(s.Content())[s.emitted:]); rest != "" {
+			return s.emit(rest), nil
+		}
+		return "", io.EOF
+	}
+	if err != nil {
+		return "", err
+	}
+	s.counter.Add(chunk)
+	s.content.WriteString(chunk.Content)
+	if chunk.Model != "" {
+		s.Model = chunk.Model
 	}
+	if chunk.FinishReason != "" {
+		s.finishReason = chunk.FinishReason
+	}
+	if s.scanner != nil && chunk.Content != "" {
+		output, err := GetModerationService().CheckPartialGeneration(s.request, s.scanner, chunk.Content, s.Content())
+		if err != nil {
+			return "", err
+		}
+		return s.emit(output), nil
+	}
+	return s.emit(chunk.Content), nil
+}
 
-	result = define.GenerateResponse{
-		Content:    response.Choices[0].Message.Content,
-		TokensUsed: response.Usage.TotalTokens,
-		Model:      response.Model,
-		RequestID:  response.ID,
-		StatusCode: resp.StatusCode,
+// emit 记录返回给调用者的内容
+func (s *AICompletionStream) emit(text string) string {
+	s.output.WriteString(text)
+	s.emitted += utf8.RuneCountInString(text)
+	return text
+}
+
+// finish 流正常结束时审核完整的内容并写入缓存，内容被拦截时返回错误
+func (s *AICompletionStream) finish() error {
+	content, err := moderateGeneration(s.ctx, s.request, s.Content())
+	if err != nil {
+		return err
+	}
+	if content != s.Content() {
+		s.content.Reset()
+		s.content.WriteString(content)
+	}
+	if s.cacheKey != "" {
+		llm.PutCachedResponse(s.ctx, s.cacheKey, llm.CachedResponse{
+			Provider:     s.Provider,
+			Model:        s.Model,
+			Content:      s.Content(),
+			FinishReason: s.finishReason,
+			Usage:        s.Usage(),
+		})
 	}
+	return nil
+}
 
-	return result, nil
+// Content 已生成的全部内容
+func (s *AICompletionStream) Content() string {
+	return s.content.String()
 }
 
-// GetAvailableModels 获取可用的模型列表
-func GetAvailableModels() (define.ModelsResponse, error) {
-	var result define.ModelsResponse
+// Usage 当前的用量，流没有正常结束时为已消耗的用量；缓存的结果为原始调用的用量
+func (s *AICompletionStream) Usage() llm.Usage {
+	if s.Cached {
+		return s.cached.Usage
+	}
+	return s.counter.Usage()
+}
 
-	// 获取OpenAI API密钥
-	apiKey := model.GetSetting("openai_api_key")
-	if apiKey == "" {
-		return result, fmt.Errorf("未配置OpenAI API密钥")
+// Response 按补全结果的格式返回已生成的内容和用量；流没有正常结束时内容为已通过Recv返回的部分，未经完整审核的内容不会返回
+func (s *AICompletionStream) Response() define.GenerateResponse {
+	usage := s.Usage()
+	content := s.Content()
+	if !s.Cached && !s.done {
+		content = s.output.String()
 	}
+	return define.GenerateResponse{
+		Content:          content,
+		TokensUsed:       usage.TotalTokens,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Model:            s.Model,
+		Provider:         s.Provider,
+		Attempts:         s.Attempts,
+		Cached:           s.Cached,
+	}
+}
 
-	// 创建HTTP请求
-	httpReq, err := http.NewRequest("GET", OpenAIModelsURL, nil)
-	if err != nil {
-		return result, fmt.Errorf("创建HTTP请求失败: %v", err)
+// Close 关闭流
+func (s *AICompletionStream) Close() {
+	if s.stream != nil {
+		_ = s.stream.Close()
 	}
+}
 
-	// 设置请求头
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+// 请求未指定时的默认参数
+const (
+	defaultTemperature = 0.7
+	defaultMaxTokens   = 1000
+)
 
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return result, fmt.Errorf("调用OpenAI API失败: %v", err)
+// responseUsage 补全结果的用量
+func responseUsage(response define.GenerateResponse) llm.Usage {
+	return llm.Usage{
+		PromptTokens:     response.PromptTokens,
+		CompletionTokens: response.CompletionTokens,
+		TotalTokens:      response.TokensUsed,
 	}
-	defer resp.Body.Close()
+}
 
-	// 读取响应
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return result, fmt.Errorf("读取API响应失败: %v", err)
+// buildChatRequest 组装对话请求，服务商在调用时按模型名选择
+func buildChatRequest(req define.GenerateAIPromptRequest) llm.ChatRequest {
+	// 设置默认值
+	if req.Temperature == 0 {
+		req.Temperature = defaultTemperature
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = defaultMaxTokens
 	}
 
-	// 检查响应状态码
-	if resp.StatusCode != http.StatusOK {
-		return result, fmt.Errorf("API返回错误: %d", resp.StatusCode)
+	return llm.ChatRequest{
+		Model:       req.Model,
+		Messages:    chatMessages(req),
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Source:      req.Source,
 	}
+}
 
-	// 解析响应
-	var modelsResponse struct {
-		Object string `json:"object"`
-		Data   []struct {
-			ID      string `json:"id"`
-			Object  string `json:"object"`
-			OwnedBy string `json:"owned_by"`
-		} `json:"data"`
+// chatMessages 组装消息数组
+func chatMessages(req define.GenerateAIPromptRequest) []llm.Message {
+	messages := []llm.Message{
+		{Role: llm.RoleSystem, Content: req.SystemPrompt},
 	}
 
-	if err := json.Unmarshal(body, &modelsResponse); err != nil {
-		return result, fmt.Errorf("解析API响应失败: %v", err)
+	// 添加上下文信息（如果有）
+	for _, contextData := range req.ContextData {
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: contextData})
 	}
 
-	result.Data = modelsResponse.Data
-	result.StatusCode = resp.StatusCode
+	// 添加用户消息
+	return append(messages, llm.Message{Role: llm.RoleUser, Content: req.UserPrompt})
+}
+
+// GetAvailableModels 获取所有已配置服务商的可用模型列表
+func GetAvailableModels(ctx context.Context) (define.ModelsResponse, error) {
+	var result define.ModelsResponse
+
+	providers := llm.ConfiguredProviders()
+	if len(providers) == 0 {
+		return result, fmt.Errorf("未配置任何模型服务商的API密钥")
+	}
 
+	var lastErr error
+	for _, provider := range providers {
+		models, err := llm.ListModels(ctx, provider)
+		if err != nil {
+			// 单个服务商失败时仍返回其他服务商的模型
+			common.SysError(fmt.Sprintf("[OpenAIService] Failed to list models of provider %s: %v", provider.Name(), err))
+			lastErr = err
+			continue
+		}
+		for _, item := range models {
+			result.Data = append(result.Data, define.ModelInfo{
+				ID:       item.Id,
+				Object:   "model",
+				OwnedBy:  item.OwnedBy,
+				Provider: provider.Name(),
+			})
+		}
+	}
+	if result.Data == nil && lastErr != nil {
+		return result, lastErr
+	}
 	return result, nil
 }
@@ -1,31 +1,47 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"gin-template/common"
 	"gin-template/define"
 	"gin-template/model"
 	"gin-template/repository"
+	"gin-template/service/document"
 	"gin-template/util"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 )
 
 type OutlineService struct {
-	tokenRepo   *repository.TokenRepository
-	reconRepo   *repository.TokenReconciliationRepository
-	outlineRepo *repository.OutlineRepository
+	tokenRepo     *repository.TokenRepository
+	reconRepo     *repository.TokenReconciliationRepository
+	outlineRepo   *repository.OutlineRepository
+	draftRepo     *repository.OutlineDraftRepository
+	presetRepo    *repository.StylePresetRepository
+	promptRepo    *repository.PromptTemplateRepository
+	candidateRepo *repository.AICandidateRepository
+	summaryRepo   *repository.OutlineSummaryRepository
+	bibleRepo     *repository.StoryBibleRepository
+	reportRepo    *repository.ContinuityReportRepository
 }
 
-func NewOutlineService(tokenRepo *repository.TokenRepository, reconRepo *repository.TokenReconciliationRepository, outlineRepo *repository.OutlineRepository) *OutlineService {
+func NewOutlineService(tokenRepo *repository.TokenRepository, reconRepo *repository.TokenReconciliationRepository, outlineRepo *repository.OutlineRepository, draftRepo *repository.OutlineDraftRepository, presetRepo *repository.StylePresetRepository, promptRepo *repository.PromptTemplateRepository, candidateRepo *repository.AICandidateRepository, summaryRepo *repository.OutlineSummaryRepository, bibleRepo *repository.StoryBibleRepository, reportRepo *repository.ContinuityReportRepository) *OutlineService {
 	common.SysLog("[OutlineService] Initializing OutlineService")
 	return &OutlineService{
-		tokenRepo:   tokenRepo,
-		reconRepo:   reconRepo,
-		outlineRepo: outlineRepo,
+		tokenRepo:     tokenRepo,
+		reconRepo:     reconRepo,
+		outlineRepo:   outlineRepo,
+		draftRepo:     draftRepo,
+		presetRepo:    presetRepo,
+		promptRepo:    promptRepo,
+		candidateRepo: candidateRepo,
+		summaryRepo:   summaryRepo,
+		bibleRepo:     bibleRepo,
+		reportRepo:    reportRepo,
 	}
 }
 
@@ -51,10 +67,16 @@ func (s *OutlineService) ParseOutlineFile(filePath string, fileExt string) (stri
 		common.SysLog(logMsg)
 		return string(data), nil
 	} else if fileExt == ".docx" {
-		// TODO: Implement docx parsing
-		logMsg := "[OutlineService] Docx format parsing is not supported yet"
+		// Convert Word paragraphs, headings and lists into outline text
+		content, err := document.ParseDocx(filePath)
+		if err != nil {
+			logMsg := fmt.Sprintf("[OutlineService] Failed to parse docx file: %v", err)
+			common.SysError(logMsg)
+			return "", fmt.Errorf(logMsg)
+		}
+		logMsg := fmt.Sprintf("[OutlineService] Successfully parsed docx file: %s", filePath)
 		common.SysLog(logMsg)
-		return "", fmt.Errorf(logMsg)
+		return content, nil
 	}
 
 	logMsg := fmt.Sprintf("[OutlineService] Unsupported file format: %s", fileExt)
@@ -64,7 +86,7 @@ func (s *OutlineService) ParseOutlineFile(filePath string, fileExt string) (stri
 
 // ValidateOutlineFile validates if the outline file format is valid
 func (s *OutlineService) ValidateOutlineFile(filename string) (string, bool) {
-	fileExt := filepath.Ext(filename)
+	fileExt := strings.ToLower(filepath.Ext(filename))
 	if fileExt != ".txt" && fileExt != ".docx" {
 		logMsg := fmt.Sprintf("[OutlineService] Invalid file format: %s", filename)
 		common.SysLog(logMsg)
@@ -75,10 +97,10 @@ func (s *OutlineService) ValidateOutlineFile(filename string) (string, bool) {
 	return fileExt, true
 }
 
-// GetOutlineByProjectId retrieves the outline by project ID
+// GetOutlineByProjectId retrieves the outline by project ID, together with the unsaved draft if there is one
 func (s *OutlineService) GetOutlineByProjectId(projectId int64) (interface{}, error) {
 	outline, err := s.outlineRepo.GetOutlineByProjectId(projectId)
-	if err != nil {
+	if err != nil || outline == nil {
 		// Empty outline for new projects
 		logMsg := fmt.Sprintf("[OutlineService] No outline found for project %d, returning empty outline", projectId)
 		common.SysLog(logMsg)
@@ -89,21 +111,41 @@ func (s *OutlineService) GetOutlineByProjectId(projectId int64) (interface{}, er
 			"current_version": 0,
 			"created_at":      "",
 			"updated_at":      "",
+			"draft":           s.draftBrief(projectId, nil),
 		}
 		return emptyOutline, nil
 	}
 
 	logMsg := fmt.Sprintf("[OutlineService] Successfully retrieved outline for project %d", projectId)
 	common.SysLog(logMsg)
-	return outline, nil
+	return &define.OutlineDetail{
+		Id:             outline.Id,
+		ProjectId:      outline.ProjectId,
+		Content:        outline.Content,
+		CurrentVersion: outline.CurrentVersion,
+		CurrentBranch:  outline.CurrentBranch,
+		CreatedAt:      outline.CreatedAt,
+		UpdatedAt:      outline.UpdatedAt,
+		Draft:          s.draftBrief(projectId, outline),
+	}, nil
 }
 
-// SaveOutlineContent saves the outline content
-func (s *OutlineService) SaveOutlineContent(projectId int64, content string) (*model.Outline, error) {
+// SaveOutlineContent saves the outline content and drops the autosaved draft it supersedes
+// When baseVersion is given the save is rejected if the outline changed after that version,
+// unless autoMerge is set and a three-way merge with the current content has no conflicts
+func (s *OutlineService) SaveOutlineContent(projectId int64, content string, baseVersion *int, autoMerge bool) (*define.SaveOutlineResponse, error) {
 	// Save outline content, create new version, not AI-generated
 	logMsg := fmt.Sprintf("[OutlineService] Saving outline content for project %d", projectId)
 	common.SysLog(logMsg)
-	outline, err := s.outlineRepo.SaveOutline(projectId, content, false, "", 0, 0)
+	if baseVersion != nil {
+		response, err := s.saveOutlineFromBase(projectId, *baseVersion, content, autoMerge, false, 0, 0, 0)
+		if err == nil && !response.Conflict {
+			s.clearDraft(projectId)
+		}
+		return response, err
+	}
+
+	outline, err := s.outlineRepo.SaveOutline(projectId, content, false, 0, 0, 0)
 	if err != nil {
 		logMsg := fmt.Sprintf("[OutlineService] Failed to save outline content for project %d: %v", projectId, err)
 		common.SysError(logMsg)
@@ -119,18 +161,19 @@ func (s *OutlineService) SaveOutlineContent(projectId int64, content string) (*m
 	//	common.SysLog(logMsg)
 	//}
 
-	return outline, nil
+	s.clearDraft(projectId)
+	return toSaveOutlineResponse(outline), nil
 }
 
 // GetVersionHistory retrieves version history
-func (s *OutlineService) GetVersionHistory(projectId int64, limit int) ([]*model.Version, error) {
+func (s *OutlineService) GetVersionHistory(projectId int64, branch string, limit int) ([]*model.Version, error) {
 	if limit <= 0 {
 		limit = 10
 		logMsg := fmt.Sprintf("[OutlineService] Version history query limit set to default: %d", limit)
 		common.SysLog(logMsg)
 	}
 
-	versions, err := s.outlineRepo.GetVersionHistory(projectId, limit)
+	versions, err := s.outlineRepo.GetVersionHistory(projectId, branch, limit)
 	if err != nil {
 		// No version history for new projects
 		logMsg := fmt.Sprintf("[OutlineService] No version history found for project %d", projectId)
@@ -144,153 +187,368 @@ func (s *OutlineService) GetVersionHistory(projectId int64, limit int) ([]*model
 }
 
 // GenerateOutlineWithAI generates outline content using AI
-func (s *OutlineService) GenerateOutlineWithAI(userId int64, projectId int64, content string, style string, wordLimit int) (map[string]interface{}, error) {
+// When req.NodeId is set, only that node of the structured outline is continued and the result is inserted at its end.
+// Cancelling ctx (the client disconnecting) stops the upstream request, nothing is saved and the hold is released.
+func (s *OutlineService) GenerateOutlineWithAI(ctx context.Context, userId int64, projectId int64, req define.AIGenerateRequest) (map[string]interface{}, error) {
+	return s.generateOutlineWithAI(ctx, userId, projectId, req, nil)
+}
+
+// GenerateOutlineForJob runs an AI continuation for an asynchronous job, cancelling ctx stops the generation.
+// The result is saved and billed only when the job has not been cancelled by the time the generation finishes.
+// The generated content, billing and saving are recorded through progress, a job run again after an interruption
+// does not call the AI again once the content was generated and is not charged twice.
+func (s *OutlineService) GenerateOutlineForJob(ctx context.Context, userId int64, projectId int64, req define.AIGenerateRequest, progress define.AIJobProgress) (map[string]interface{}, error) {
+	return s.generateOutlineWithAI(ctx, userId, projectId, req, progress)
+}
+
+func (s *OutlineService) generateOutlineWithAI(ctx context.Context, userId int64, projectId int64, req define.AIGenerateRequest, progress define.AIJobProgress) (map[string]interface{}, error) {
 	logMsg := fmt.Sprintf("[OutlineService] Starting AI outline generation for project %d", projectId)
 	common.SysLog(logMsg)
 
-	// Construct AI request
-	systemPrompt := "You are a professional content creation assistant skilled at continuing and expanding on provided outlines."
-	if style != "" {
-		systemPrompt += fmt.Sprintf(" Please use a %s writing style.", style)
+	generation, err := s.prepareAIGeneration(userId, projectId, req)
+	if err != nil {
+		return nil, err
 	}
-
-	userPrompt := "Please continue and expand on the following outline content:"
-	if wordLimit > 0 {
-		userPrompt += fmt.Sprintf(" The continuation should be approximately %d words.", wordLimit)
+	generation.progress = progress
+	// A job interrupted after generating reuses the recorded content, only the charge and the save are repeated
+	var openaiResp define.GenerateResponse
+	generated, err := ResumeGenerated(progress, &openaiResp)
+	if err != nil {
+		return nil, err
 	}
-	userPrompt += "\n\n" + content
-
-	// Prepare OpenAI request
-	openaiReq := define.GenerateAIPromptRequest{
-		SystemPrompt: systemPrompt,
-		UserPrompt:   userPrompt,
-		Model:        "gpt-3.5-turbo", // Can be obtained from settings or user selection
-		MaxTokens:    2000,            // Adjust based on word limit
-		Temperature:  0.7,             // Creativity parameter
+	if _, settled := generation.settled(); !settled {
+		if err := s.reserveAITokens(userId, projectId, generation); err != nil {
+			return nil, err
+		}
+	}
+	if generated {
+		logMsg := fmt.Sprintf("[OutlineService] Reusing the content generated before the job for project %d was interrupted", projectId)
+		common.SysLog(logMsg)
+		return s.finishAIGeneration(userId, projectId, generation, openaiResp)
 	}
-
-	// Generate unique transaction ID for idempotency control
-	transactionUUID := util.GetUUIDGenerator().Generate(util.BusinessAIWriting)
 
 	// Call AI service for continuation
-	openaiResp, err := GenerateAICompletion(openaiReq)
+	openaiResp, err = GenerateAICompletion(ctx, generation.request)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
 	if err != nil {
+		s.releaseAITokens(generation)
+		if ctx.Err() != nil {
+			logMsg := fmt.Sprintf("[OutlineService] AI generation for project %d cancelled", projectId)
+			common.SysLog(logMsg)
+			return nil, ctx.Err()
+		}
 		logMsg := fmt.Sprintf("[OutlineService] AI generation failed: %v", err)
 		common.SysError(logMsg)
 		return nil, fmt.Errorf(logMsg)
 	}
+	if progress != nil {
+		progress.MarkGenerated(openaiResp)
+	}
 
-	// Get generated content
-	aiGeneratedContent := openaiResp.Content
-	tokensUsed := openaiResp.TokensUsed
+	return s.finishAIGeneration(userId, projectId, generation, openaiResp)
+}
 
-	// Save outline content, create new version, mark as AI-generated
-	logMsg = fmt.Sprintf("[OutlineService] Saving AI-generated outline content, Project ID: %d, Tokens used: %d", projectId, tokensUsed)
-	common.SysLog(logMsg)
-	_, err = s.outlineRepo.SaveOutline(projectId, content+"\n\n"+aiGeneratedContent, true, style, wordLimit, tokensUsed)
+// aiGeneration 一次AI续写的上下文
+type aiGeneration struct {
+	content     string
+	preset      *model.StylePreset // 为nil时不使用风格预设
+	style       string             // 没有对应预设的旧版自由风格文本，按原方式拼接到系统提示词
+	wordLimit   int
+	baseVersion int
+	roots       []*util.OutlineSection
+	target      *util.OutlineSection
+	request     define.GenerateAIPromptRequest
+	holdUUID    string // 调用AI前预扣Token的ID
+	candidates  int    // 生成的候选数量，为0时表示单次续写
+	// 异步任务的扣费和保存进度，同步调用时为nil
+	progress define.AIJobProgress
+}
+
+// settled 异步任务之前的执行已扣除的Token，尚未扣费或不是异步任务时返回false
+func (g *aiGeneration) settled() (int64, bool) {
+	if g.progress == nil {
+		return 0, false
+	}
+	return g.progress.Settled()
+}
+
+// prepareAIGeneration loads the outline being continued, resolves the style preset and builds the AI request
+func (s *OutlineService) prepareAIGeneration(userId int64, projectId int64, req define.AIGenerateRequest) (*aiGeneration, error) {
+	preset, err := resolveStylePreset(s.presetRepo, userId, req.StyleId, req.Style)
 	if err != nil {
-		logMsg = fmt.Sprintf("[OutlineService] Failed to save AI-generated outline content: %v", err)
-		common.SysError(logMsg)
 		return nil, err
 	}
+	content, nodeId := req.Content, req.NodeId
+	wordLimit := presetWordLimit(preset, req.WordLimit)
+
+	// Load the target node together with the whole outline structure
+	// The version seen here is the merge base when saving, so edits made during generation are kept
+	generation := &aiGeneration{content: content, preset: preset, wordLimit: wordLimit}
+	if style := strings.TrimSpace(req.Style); preset == nil && style != "" && style != "default" {
+		generation.style = style
+	}
+	var current *model.Outline
+	if nodeId > 0 {
+		current, generation.roots, err = s.loadOutlineTree(projectId)
+		if err != nil {
+			return nil, err
+		}
+		generation.target, _, _ = locateSection(&generation.roots, nodeId)
+		if generation.target == nil {
+			return nil, fmt.Errorf("节点不存在")
+		}
+	} else {
+		current, err = s.outlineRepo.GetOutlineByProjectId(projectId)
+		if err != nil {
+			logMsg := fmt.Sprintf("[OutlineService] Failed to get outline for project %d: %v", projectId, err)
+			common.SysError(logMsg)
+			return nil, err
+		}
+	}
+	if current != nil {
+		generation.baseVersion = current.CurrentVersion
+	}
 
-	//// Update project's last edit time
-	//project, err := model.GetProjectById(projectId)
-	//if err == nil {
-	//	project.LastEditedAt = time.Now().Format("2006-01-02T15:04:05Z")
-	//	project.Update()
-	//	logMsg := fmt.Sprintf("[OutlineService] Updated last edit time for project %d", projectId)
-	//	common.SysLog(logMsg)
-	//}
+	// Construct AI request, the prompts come from the active versions in the prompt template registry
+	var systemPrompt string
+	temperature := 0.7 // Creativity parameter
+	if preset != nil {
+		systemPrompt = stylePresetPrompt(preset)
+		if preset.Temperature > 0 {
+			temperature = preset.Temperature
+		}
+	} else {
+		systemPrompt = RenderPrompt(s.promptRepo, PromptOutlineSystem, nil)
+		if generation.style != "" {
+			systemPrompt += fmt.Sprintf(" Please use a %s writing style.", generation.style)
+		}
+	}
 
-	// Deduct user tokens
-	description := fmt.Sprintf("AI outline continuation for project [%d]", projectId)
+	// Add the story bible entries mentioned in the part being continued, so names and facts stay consistent
+	continuedText := string([]rune(content)[recentWindowStart(content):])
+	if generation.target != nil {
+		continuedText = util.RenderOutline([]*util.OutlineSection{generation.target})
+	}
+	if bible := storyBiblePrompt(s.bibleRepo, s.promptRepo, projectId, continuedText); bible != "" {
+		systemPrompt += "\n\n" + bible
+	}
 
-	// Use TokenService to deduct user tokens
-	service := GetTokenService()
-	userToken, err := service.DebitToken(
-		userId,
-		int64(tokensUsed),
-		transactionUUID,
-		"ai_generation_debit",
-		description,
-		"project",
-		strconv.FormatInt(projectId, 10),
-	)
+	// Prepare OpenAI request
+	generation.request = define.GenerateAIPromptRequest{
+		SystemPrompt: systemPrompt,
+		MaxTokens:    2000, // Adjust based on word limit
+		Temperature:  temperature,
+		NoCache:      req.NoCache,
+		UserId:       userId,
+		ProjectId:    projectId,
+		Source:       define.AISourceOutline,
+	}
 
+	// Fit the outline into the context window of the model, long outlines are sent as summaries plus the recent part
+	promptValues := map[string]string{"content": content, "toc": "", "word_limit": ""}
+	if generation.target != nil {
+		promptValues["toc"] = outlineTableOfContents(generation.roots)
+		budget := continuationBudget(generation.request, promptValues["toc"])
+		promptValues["content"] = fitTail(continuedText, budget)
+	} else {
+		promptValues["content"] = s.buildContinuationContext(projectId, content, continuationBudget(generation.request, ""))
+	}
+	if wordLimit > 0 {
+		promptValues["word_limit"] = strconv.Itoa(wordLimit)
+	}
+	generation.request.UserPrompt = RenderPrompt(s.promptRepo, PromptOutlineUser, promptValues)
+	return generation, nil
+}
+
+// presetId 续写使用的风格预设ID，未使用预设时为0
+func (g *aiGeneration) presetId() int64 {
+	if g.preset == nil {
+		return 0
+	}
+	return g.preset.Id
+}
+
+// continuedContent 续写内容合入后的完整大纲，不修改generation本身，同一次生成的多个候选可以分别计算
+func (g *aiGeneration) continuedContent(generated string) string {
+	if g.target == nil {
+		return g.content + "\n\n" + generated
+	}
+	last := g.target
+	for len(last.Children) > 0 {
+		last = last.Children[len(last.Children)-1]
+	}
+	body := last.Body
+	appendToSection(g.target, generated)
+	content := util.RenderOutline(g.roots)
+	last.Body = body
+	return content
+}
+
+// stylePresetPrompt 由风格预设生成系统提示词，附带示例片段
+func stylePresetPrompt(preset *model.StylePreset) string {
+	var prompt strings.Builder
+	prompt.WriteString("你是一名专业的网文大纲创作助手，擅长续写和扩展大纲。")
+	prompt.WriteString(preset.SystemPrompt)
+	for i, example := range preset.ExampleList() {
+		if i == 0 {
+			prompt.WriteString("\n\n以下是该风格的示例片段，请参考其语言和节奏，不要照搬内容：")
+		}
+		prompt.WriteString(fmt.Sprintf("\n\n示例%d：\n%s", i+1, example))
+	}
+	return prompt.String()
+}
+
+// reserveAITokens places a hold for the maximum cost of the AI call, so the call is not made when the user cannot pay for it
+func (s *OutlineService) reserveAITokens(userId int64, projectId int64, generation *aiGeneration) error {
+	estimatedTokens := GetPricingService().Estimate(userId, generation.request)
+	if generation.candidates > 1 {
+		// 每个候选都是一次完整的调用，按候选数量预扣
+		estimatedTokens *= int64(generation.candidates)
+	}
+	description := fmt.Sprintf("AI outline continuation for project [%d]", projectId)
+	hold, err := holdAITokens(userId, estimatedTokens, description, "project", strconv.FormatInt(projectId, 10))
 	if err != nil {
-		// Log error but return generated content if deduction fails (needs actual error handling strategy)
-		logMsg = fmt.Sprintf("[OutlineService] Failed to deduct user tokens: %v", err)
+		return err
+	}
+	generation.holdUUID = hold.HoldUUID
+	return nil
+}
+
+// holdAITokens reserves the estimated cost of an AI call
+func holdAITokens(userId int64, amount int64, description string, relatedEntityType string, relatedEntityID string) (*model.TokenHold, error) {
+	holdUUID := util.GetUUIDGenerator().Generate(util.BusinessAIWriting)
+	hold, err := GetTokenService().ReserveToken(userId, amount, holdUUID, description, relatedEntityType, relatedEntityID)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to reserve %d tokens for user %d (%s): %v", amount, userId, description, err)
 		common.SysError(logMsg)
-		return map[string]interface{}{
-			"content":       aiGeneratedContent,
-			"tokens_used":   tokensUsed,
-			"token_balance": 0, // Failed to get balance
-			"error":         "Token deduction failed, please contact support",
-		}, nil
+		if available, balanceErr := GetTokenService().GetAvailableBalance(userId); balanceErr == nil && available < amount {
+			return nil, fmt.Errorf("Token余额不足，请充值")
+		}
+		return nil, fmt.Errorf("预扣Token失败，请稍后重试")
 	}
+	return hold, nil
+}
 
-	// Get user's latest token balance
-	tokenBalance := userToken.Balance
+// releaseAITokens releases the hold when nothing is charged for the AI call
+func (s *OutlineService) releaseAITokens(generation *aiGeneration) {
+	if generation.holdUUID != "" {
+		_ = GetTokenService().ReleaseToken(generation.holdUUID)
+	}
+}
 
-	logMsg = fmt.Sprintf("[OutlineService] AI outline generation successful, Project ID: %d, Tokens used: %d, Remaining tokens: %d", projectId, tokensUsed, tokenBalance)
-	common.SysLog(logMsg)
-	return map[string]interface{}{
-		"content":       aiGeneratedContent,
-		"tokens_used":   tokensUsed,
-		"token_balance": tokenBalance,
-	}, nil
+// settleAITokens settles a hold to the charged amount before the generated content is used.
+// When settling fails the hold is released and the content must be discarded, so results are never delivered unpaid.
+func settleAITokens(holdUUID string, amount int64, transactionType string, description string) (*model.UserToken, error) {
+	return settleAITokensAs(aiTransactionUUID(nil), holdUUID, amount, transactionType, description)
 }
 
-// ExportOutlineToFile exports the outline to a file
-func (s *OutlineService) ExportOutlineToFile(projectId int64, format string) (map[string]interface{}, error) {
-	logMsg := fmt.Sprintf("[OutlineService] Starting to export outline for project %d to file, format: %s", projectId, format)
-	common.SysLog(logMsg)
+// aiTransactionUUID 扣费的交易UUID，异步任务使用任务的交易UUID，否则生成新的UUID
+func aiTransactionUUID(progress define.AIJobProgress) string {
+	if progress != nil {
+		return progress.TransactionUUID()
+	}
+	return util.GetUUIDGenerator().Generate(util.BusinessAIWriting)
+}
 
-	// Check format
-	if format != "txt" && format != "docx" && format != "pdf" {
-		logMsg := fmt.Sprintf("[OutlineService] Unsupported export format: %s", format)
+// settleAITokensAs settles a hold like settleAITokens with the given transaction UUID, so that a job run again after an interruption
+// cannot be charged twice
+func settleAITokensAs(transactionUUID string, holdUUID string, amount int64, transactionType string, description string) (*model.UserToken, error) {
+	userToken, err := GetTokenService().SettleToken(holdUUID, amount, transactionUUID, transactionType, description)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to deduct user tokens: %v", err)
 		common.SysError(logMsg)
-		return nil, fmt.Errorf("Unsupported export format, only txt, docx, and pdf are allowed")
+		_ = GetTokenService().ReleaseToken(holdUUID)
+		return nil, fmt.Errorf("扣除Token失败，请稍后重试")
 	}
+	return userToken, nil
+}
 
-	// Get outline content
-	outline, err := s.outlineRepo.GetOutlineByProjectId(projectId)
+// refundAITokens returns tokens already charged for content that could not be saved
+func refundAITokens(userId int64, projectId int64, amount int64, description string) {
+	if amount <= 0 {
+		return
+	}
+	transactionUUID := util.GetUUIDGenerator().Generate(util.BusinessAIWriting)
+	_, err := GetTokenService().CreditTokenWithCompensation(userId, amount, transactionUUID, "ai_generation_refund", description,
+		"project", strconv.FormatInt(projectId, 10))
 	if err != nil {
-		logMsg := fmt.Sprintf("[OutlineService] Failed to get outline content for project %d: %v", projectId, err)
+		logMsg := fmt.Sprintf("[OutlineService] Failed to refund %d tokens to user %d: %v", amount, userId, err)
 		common.SysError(logMsg)
-		return nil, fmt.Errorf("Failed to get outline content")
 	}
+}
 
-	// Generate file name
-	fileName := fmt.Sprintf("outline_%d_%s.%s", projectId, time.Now().Format("20060102"), format)
-	filePath := filepath.Join(common.UploadPath, fileName)
-
-	// Write file
-	if format == "txt" {
-		err = ioutil.WriteFile(filePath, []byte(outline.Content), 0644)
-		if err != nil {
-			logMsg := fmt.Sprintf("[OutlineService] Failed to generate file: %v", err)
-			common.SysError(logMsg)
-			return nil, fmt.Errorf(logMsg)
-		}
+// finishAIGeneration settles the token hold to the cost of the tokens used and saves the generated content as a new AI version.
+// The hold is settled first: when settling fails nothing is saved or returned, when saving fails the charge is refunded.
+func (s *OutlineService) finishAIGeneration(userId int64, projectId int64, generation *aiGeneration, response define.GenerateResponse) (map[string]interface{}, error) {
+	aiGeneratedContent := response.Content
+	tokensUsed := response.TokensUsed
+
+	// Deduct user tokens, priced by the model used and the user's package, cached results by the cache policy
+	charge := GetPricingService().ChargeResponse(userId, response)
+	var userToken *model.UserToken
+	var err error
+	if amount, settled := generation.settled(); settled {
+		// The job was charged before it was interrupted, only the recorded content is saved again
+		charge.Amount = amount
+		userToken, err = GetTokenService().GetUserToken(userId)
 	} else {
-		// TODO: Handle docx and pdf exports
-		logMsg := "[OutlineService] Docx and pdf format exports are not supported yet"
+		description := chargeDescription(fmt.Sprintf("AI outline continuation for project [%d]", projectId), charge)
+		userToken, err = settleAITokensAs(aiTransactionUUID(generation.progress), generation.holdUUID, charge.Amount, "ai_generation_debit", description)
+		if err == nil && generation.progress != nil {
+			generation.progress.MarkSettled(charge.Amount)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Save outline content, create new version, mark as AI-generated
+	logMsg := fmt.Sprintf("[OutlineService] Saving AI-generated outline content, Project ID: %d, Tokens used: %d", projectId, tokensUsed)
+	common.SysLog(logMsg)
+	newContent := generation.continuedContent(aiGeneratedContent)
+	saved, err := s.saveOutlineFromBase(projectId, generation.baseVersion, newContent, true, true, generation.presetId(), generation.wordLimit, tokensUsed)
+	if err != nil {
+		logMsg = fmt.Sprintf("[OutlineService] Failed to save AI-generated outline content: %v", err)
+		common.SysError(logMsg)
+		refundAITokens(userId, projectId, charge.Amount, fmt.Sprintf("Refund of AI outline continuation for project [%d], content not saved", projectId))
+		return nil, err
+	}
+	if saved.Conflict {
+		// The outline was edited in a conflicting way during generation, keep the user's edits and only return the generated text
+		logMsg = fmt.Sprintf("[OutlineService] AI-generated content for project %d not saved because of conflicting edits", projectId)
 		common.SysLog(logMsg)
-		return nil, fmt.Errorf(logMsg)
 	}
 
-	// Return file path
-	fileUrl := "/upload/" + fileName
-	fileSize := len(outline.Content)
+	//// Update project's last edit time
+	//project, err := model.GetProjectById(projectId)
+	//if err == nil {
+	//	project.LastEditedAt = time.Now().Format("2006-01-02T15:04:05Z")
+	//	project.Update()
+	//	logMsg := fmt.Sprintf("[OutlineService] Updated last edit time for project %d", projectId)
+	//	common.SysLog(logMsg)
+	//}
+
+	// Get user's latest token balance
+	tokenBalance := userToken.Balance
 
-	logMsg = fmt.Sprintf("[OutlineService] Successfully exported outline for project %d to file: %s", projectId, fileName)
+	logMsg = fmt.Sprintf("[OutlineService] AI outline generation successful, Project ID: %d, Tokens used: %d, Tokens charged: %d, Remaining tokens: %d",
+		projectId, tokensUsed, charge.Amount, tokenBalance)
 	common.SysLog(logMsg)
-	return map[string]interface{}{
-		"file_url":  fileUrl,
-		"file_size": fileSize,
-	}, nil
+	result := map[string]interface{}{
+		"content":         aiGeneratedContent,
+		"tokens_used":     tokensUsed,
+		"tokens_charged":  charge.Amount,
+		"cached":          response.Cached, // true when the result was served from the response cache
+		"token_balance":   tokenBalance,
+		"saved":           !saved.Conflict, // false when conflicting edits were made during generation
+		"current_version": saved.CurrentVersion,
+	}
+	if generation.progress != nil {
+		generation.progress.MarkSaved(result)
+	}
+	return result, nil
 }
 
 // UploadAndParseOutlineFile uploads and parses an outline file
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/util"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBranchNameLength 分支名最大长度（按字符计算）
+const maxBranchNameLength = 50
+
+// ListBranches lists all branches of a project's outline
+func (s *OutlineService) ListBranches(projectId int64) (*define.BranchListResponse, error) {
+	outline, branches, err := s.outlineRepo.GetBranches(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get branches for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if outline == nil {
+		// 新项目还没有大纲，只有一个空的默认分支
+		return &define.BranchListResponse{
+			CurrentBranch: model.DefaultBranchName,
+			Branches:      []define.BranchInfo{{Name: model.DefaultBranchName, IsCurrent: true}},
+		}, nil
+	}
+
+	graph, err := s.outlineRepo.GetVersionGraph(outline.Id)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get version graph for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	versionNumbers := make(map[int64]int, len(graph))
+	for _, v := range graph {
+		versionNumbers[v.Id] = v.VersionNumber
+	}
+
+	infos := make([]define.BranchInfo, 0, len(branches))
+	for _, branch := range branches {
+		infos = append(infos, define.BranchInfo{
+			Id:          branch.Id,
+			Name:        branch.Name,
+			HeadVersion: versionNumbers[branch.HeadVersionId],
+			BaseVersion: versionNumbers[branch.BaseVersionId],
+			IsCurrent:   branch.Name == outline.CurrentBranch,
+			CreatedAt:   branch.CreatedAt,
+			UpdatedAt:   branch.UpdatedAt,
+		})
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Successfully retrieved %d branches for project %d", len(infos), projectId)
+	common.SysLog(logMsg)
+	return &define.BranchListResponse{
+		CurrentBranch: outline.CurrentBranch,
+		Branches:      infos,
+	}, nil
+}
+
+// CreateBranch creates a new branch from the given version (0 means the head of the current branch)
+func (s *OutlineService) CreateBranch(projectId int64, name string, fromVersion int) (*define.BranchInfo, error) {
+	name, err := normalizeBranchName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Creating branch %s for project %d from version %d", name, projectId, fromVersion)
+	common.SysLog(logMsg)
+
+	branch, err := s.outlineRepo.CreateBranch(projectId, name, fromVersion)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to create branch %s for project %d: %v", name, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("大纲或版本不存在")
+	}
+
+	info := &define.BranchInfo{
+		Id:        branch.Id,
+		Name:      branch.Name,
+		CreatedAt: branch.CreatedAt,
+		UpdatedAt: branch.UpdatedAt,
+	}
+	if branch.BaseVersionId != 0 {
+		base, err := s.outlineRepo.GetVersionById(branch.BaseVersionId)
+		if err == nil && base != nil {
+			info.HeadVersion = base.VersionNumber
+			info.BaseVersion = base.VersionNumber
+		}
+	}
+	return info, nil
+}
+
+// SwitchBranch switches the current branch of a project's outline
+func (s *OutlineService) SwitchBranch(projectId int64, name string) (*model.Outline, error) {
+	logMsg := fmt.Sprintf("[OutlineService] Switching project %d to branch %s", projectId, name)
+	common.SysLog(logMsg)
+
+	outline, err := s.outlineRepo.SwitchBranch(projectId, strings.TrimSpace(name))
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to switch project %d to branch %s: %v", projectId, name, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if outline == nil {
+		return nil, fmt.Errorf("大纲不存在")
+	}
+	return outline, nil
+}
+
+// DeleteBranch deletes a branch, versions created on it are kept in history
+func (s *OutlineService) DeleteBranch(projectId int64, name string) error {
+	logMsg := fmt.Sprintf("[OutlineService] Deleting branch %s of project %d", name, projectId)
+	common.SysLog(logMsg)
+
+	if err := s.outlineRepo.DeleteBranch(projectId, strings.TrimSpace(name)); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to delete branch %s of project %d: %v", name, projectId, err)
+		common.SysError(logMsg)
+		return err
+	}
+	return nil
+}
+
+// MergeBranch merges the source branch into the target branch with a three-way merge.
+// Conflicting regions are returned without writing anything unless resolved content is provided.
+func (s *OutlineService) MergeBranch(projectId int64, req define.MergeBranchRequest) (*define.MergeBranchResponse, error) {
+	outline, branches, err := s.outlineRepo.GetBranches(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get branches for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if outline == nil {
+		return nil, fmt.Errorf("大纲不存在")
+	}
+
+	sourceName := strings.TrimSpace(req.Source)
+	targetName := strings.TrimSpace(req.Target)
+	if targetName == "" {
+		targetName = outline.CurrentBranch
+	}
+	if sourceName == targetName {
+		return nil, fmt.Errorf("不能将分支合并到自身")
+	}
+
+	var source, target *model.OutlineBranch
+	for _, branch := range branches {
+		switch branch.Name {
+		case sourceName:
+			source = branch
+		case targetName:
+			target = branch
+		}
+	}
+	if source == nil || target == nil {
+		return nil, fmt.Errorf("分支不存在")
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Merging branch %s into %s for project %d", sourceName, targetName, projectId)
+	common.SysLog(logMsg)
+
+	response := &define.MergeBranchResponse{
+		Source: sourceName,
+		Target: targetName,
+	}
+
+	graph, err := s.outlineRepo.GetVersionGraph(outline.Id)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get version graph for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	parents := make(map[int64][]int64, len(graph))
+	for _, v := range graph {
+		parents[v.Id] = []int64{v.ParentId, v.MergeParentId}
+	}
+
+	// 源分支头已经是目标分支的祖先，无需合并
+	if source.HeadVersionId == 0 || versionAncestors(parents, target.HeadVersionId)[source.HeadVersionId] {
+		response.UpToDate = true
+		return response, nil
+	}
+
+	var mergedContent string
+	if req.ResolvedContent != nil {
+		mergedContent = *req.ResolvedContent
+	} else {
+		baseContent, err := s.versionContent(commonAncestor(parents, source.HeadVersionId, target.HeadVersionId))
+		if err != nil {
+			return nil, err
+		}
+		sourceContent, err := s.versionContent(source.HeadVersionId)
+		if err != nil {
+			return nil, err
+		}
+		targetContent, err := s.versionContent(target.HeadVersionId)
+		if err != nil {
+			return nil, err
+		}
+
+		var conflicts []util.MergeConflict
+		mergedContent, conflicts = util.MergeLines(baseContent, targetContent, sourceContent)
+		if len(conflicts) > 0 {
+			logMsg := fmt.Sprintf("[OutlineService] Merge of %s into %s for project %d has %d conflicts", sourceName, targetName, projectId, len(conflicts))
+			common.SysLog(logMsg)
+			response.Conflicts = toMergeConflicts(conflicts)
+			return response, nil
+		}
+	}
+
+	_, version, err := s.outlineRepo.MergeIntoBranch(outline.Id, targetName, target.HeadVersionId, source.HeadVersionId, mergedContent)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to merge branch %s into %s for project %d: %v", sourceName, targetName, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+
+	logMsg = fmt.Sprintf("[OutlineService] Merged branch %s into %s for project %d as version %d", sourceName, targetName, projectId, version.VersionNumber)
+	common.SysLog(logMsg)
+	brief := toVersionBrief(version)
+	response.Merged = true
+	response.Version = &brief
+	return response, nil
+}
+
+// versionContent 获取版本内容，versionId为0时返回空内容
+func (s *OutlineService) versionContent(versionId int64) (string, error) {
+	if versionId == 0 {
+		return "", nil
+	}
+	version, err := s.outlineRepo.GetVersionById(versionId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get version %d: %v", versionId, err)
+		common.SysError(logMsg)
+		return "", err
+	}
+	if version == nil {
+		return "", fmt.Errorf("版本不存在")
+	}
+	return version.Content, nil
+}
+
+// versionAncestors 返回指定版本及其所有祖先版本的集合
+func versionAncestors(parents map[int64][]int64, versionId int64) map[int64]bool {
+	ancestors := make(map[int64]bool)
+	queue := []int64{versionId}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == 0 || ancestors[id] {
+			continue
+		}
+		ancestors[id] = true
+		queue = append(queue, parents[id]...)
+	}
+	return ancestors
+}
+
+// commonAncestor 从b开始按广度优先查找第一个同时是a祖先的版本，找不到时返回0
+func commonAncestor(parents map[int64][]int64, a int64, b int64) int64 {
+	ancestorsOfA := versionAncestors(parents, a)
+	visited := make(map[int64]bool)
+	queue := []int64{b}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == 0 || visited[id] {
+			continue
+		}
+		if ancestorsOfA[id] {
+			return id
+		}
+		visited[id] = true
+		queue = append(queue, parents[id]...)
+	}
+	return 0
+}
+
+// normalizeBranchName 去除首尾空白并校验分支名
+func normalizeBranchName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("分支名不能为空")
+	}
+	if utf8.RuneCountInString(name) > maxBranchNameLength {
+		return "", fmt.Errorf("分支名不能超过%d个字符", maxBranchNameLength)
+	}
+	return name, nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/util"
+	"sync"
+	"time"
+)
+
+// defaultAICandidateCount 请求未指定数量时生成的候选数量
+const defaultAICandidateCount = 2
+
+// GenerateAICandidates generates several continuations for the same request in parallel and stores them as pending candidates.
+// No version is created until one of them is accepted; every candidate that was generated is billed.
+// Cancelling ctx, e.g. when the client disconnects, stops the calls that are still running.
+func (s *OutlineService) GenerateAICandidates(ctx context.Context, userId int64, projectId int64, req define.AICandidateRequest) (*define.AICandidatesResponse, error) {
+	count := req.Count
+	if count <= 0 {
+		count = defaultAICandidateCount
+	}
+	if count > common.AIMaxCandidates {
+		return nil, fmt.Errorf("一次最多生成%d个候选", common.AIMaxCandidates)
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Starting AI candidate generation for project %d, count: %d", projectId, count)
+	common.SysLog(logMsg)
+
+	generation, err := s.prepareAIGeneration(userId, projectId, req.AIGenerateRequest)
+	if err != nil {
+		return nil, err
+	}
+	generation.candidates = count
+	// 相同的请求会命中同一个缓存，候选需要各自调用模型
+	generation.request.NoCache = true
+	if err := s.reserveAITokens(userId, projectId, generation); err != nil {
+		return nil, err
+	}
+
+	// 每个候选是一次独立的调用，并行生成
+	results := make([]define.GenerateResponse, count)
+	errs := make([]error, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = GenerateAICompletion(ctx, generation.request)
+		}(i)
+	}
+	wg.Wait()
+
+	batchId := util.GetUUIDGenerator().Generate(util.BusinessAIWriting)
+	expiresAt := time.Now().Add(time.Duration(common.AICandidateExpireHours) * time.Hour).Unix()
+	var candidates []*model.AICandidate
+	var lastErr error
+	var charges []define.AICharge
+	tokensUsed := 0
+	for i := 0; i < count; i++ {
+		if errs[i] != nil {
+			lastErr = errs[i]
+			common.SysError(fmt.Sprintf("[OutlineService] AI candidate %d of batch %s failed: %v", i+1, batchId, errs[i]))
+			continue
+		}
+		tokensUsed += results[i].TokensUsed
+		charges = append(charges, GetPricingService().ChargeResponse(userId, results[i]))
+		candidates = append(candidates, &model.AICandidate{
+			BatchId:        batchId,
+			UserId:         userId,
+			ProjectId:      projectId,
+			Seq:            len(candidates) + 1,
+			Content:        results[i].Content,
+			OutlineContent: generation.continuedContent(results[i].Content),
+			BaseVersion:    generation.baseVersion,
+			NodeId:         req.NodeId,
+			StylePresetId:  generation.presetId(),
+			WordLimit:      generation.wordLimit,
+			TokensUsed:     results[i].TokensUsed,
+			Model:          results[i].Model,
+			Status:         define.AICandidateStatusPending,
+			ExpiresAt:      expiresAt,
+		})
+	}
+	if len(candidates) == 0 {
+		s.releaseAITokens(generation)
+		logMsg = fmt.Sprintf("[OutlineService] AI generation failed: %v", lastErr)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf(logMsg)
+	}
+
+	// 先结算预扣，扣除失败时不保存也不返回候选
+	tokensCharged := totalCharge(charges)
+	description := chargeDescription(fmt.Sprintf("AI outline continuation for project [%d], %d candidates", projectId, len(candidates)), charges...)
+	userToken, err := settleAITokens(generation.holdUUID, tokensCharged, "ai_generation_debit", description)
+	if err != nil {
+		return nil, err
+	}
+
+	// 候选保存失败时内容已经无法取回，退还扣除的token
+	if err := s.candidateRepo.CreateCandidates(candidates); err != nil {
+		logMsg = fmt.Sprintf("[OutlineService] Failed to save AI candidates for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		refundAITokens(userId, projectId, tokensCharged, fmt.Sprintf("Refund of AI outline continuation for project [%d], candidates not saved", projectId))
+		return nil, err
+	}
+
+	response := &define.AICandidatesResponse{
+		BatchId:       batchId,
+		Candidates:    toAICandidates(candidates),
+		Failed:        count - len(candidates),
+		TokensUsed:    tokensUsed,
+		TokensCharged: tokensCharged,
+		TokenBalance:  userToken.Balance,
+	}
+
+	logMsg = fmt.Sprintf("[OutlineService] AI candidate generation successful, Project ID: %d, Batch: %s, Candidates: %d, Tokens used: %d, Tokens charged: %d",
+		projectId, batchId, len(candidates), tokensUsed, response.TokensCharged)
+	common.SysLog(logMsg)
+	return response, nil
+}
+
+// GetAICandidates returns the candidates of the project that have not expired, newest batch first
+func (s *OutlineService) GetAICandidates(projectId int64) ([]define.AICandidate, error) {
+	candidates, err := s.candidateRepo.GetProjectCandidates(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get AI candidates for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	return toAICandidates(candidates), nil
+}
+
+// AcceptAICandidate saves a pending candidate as a new AI version, merged with edits made since it was generated.
+// The other candidates of the same batch are marked rejected. When the merge conflicts the candidate stays pending.
+func (s *OutlineService) AcceptAICandidate(projectId int64, candidateId int64) (*define.AICandidateAcceptResponse, error) {
+	candidate, err := s.candidateRepo.GetCandidateById(candidateId)
+	if err != nil {
+		return nil, err
+	}
+	if candidate == nil || candidate.ProjectId != projectId {
+		return nil, fmt.Errorf("候选不存在")
+	}
+
+	claimed, err := s.candidateRepo.ClaimCandidate(candidateId)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		if candidate.Status == define.AICandidateStatusPending {
+			return nil, fmt.Errorf("候选已过期")
+		}
+		return nil, fmt.Errorf("候选已被处理")
+	}
+
+	saved, err := s.saveOutlineFromBase(projectId, candidate.BaseVersion, candidate.OutlineContent, true, true,
+		candidate.StylePresetId, candidate.WordLimit, candidate.TokensUsed)
+	if err != nil || saved.Conflict {
+		if unclaimErr := s.candidateRepo.UnclaimCandidate(candidateId); unclaimErr != nil {
+			logMsg := fmt.Sprintf("[OutlineService] Failed to restore AI candidate %d: %v", candidateId, unclaimErr)
+			common.SysError(logMsg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		logMsg := fmt.Sprintf("[OutlineService] AI candidate %d for project %d not accepted because of conflicting edits", candidateId, projectId)
+		common.SysLog(logMsg)
+		return &define.AICandidateAcceptResponse{Candidate: toAICandidate(candidate), Outline: saved}, nil
+	}
+
+	candidate.Status = define.AICandidateStatusAccepted
+	candidate.AcceptedVersion = saved.CurrentVersion
+	if err := s.candidateRepo.FinishAccept(candidate, saved.CurrentVersion); err != nil {
+		// 版本已经保存，只记录错误
+		logMsg := fmt.Sprintf("[OutlineService] Failed to update AI candidate batch %s: %v", candidate.BatchId, err)
+		common.SysError(logMsg)
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Accepted AI candidate %d for project %d as version %d", candidateId, projectId, saved.CurrentVersion)
+	common.SysLog(logMsg)
+	return &define.AICandidateAcceptResponse{Candidate: toAICandidate(candidate), Outline: saved}, nil
+}
+
+func toAICandidates(candidates []*model.AICandidate) []define.AICandidate {
+	result := make([]define.AICandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, toAICandidate(candidate))
+	}
+	return result
+}
+
+func toAICandidate(candidate *model.AICandidate) define.AICandidate {
+	return define.AICandidate{
+		Id:              candidate.Id,
+		BatchId:         candidate.BatchId,
+		Seq:             candidate.Seq,
+		Content:         candidate.Content,
+		BaseVersion:     candidate.BaseVersion,
+		NodeId:          candidate.NodeId,
+		StylePresetId:   candidate.StylePresetId,
+		TokensUsed:      candidate.TokensUsed,
+		Model:           candidate.Model,
+		Status:          candidate.Status,
+		AcceptedVersion: candidate.AcceptedVersion,
+		ExpiresAt:       candidate.ExpiresAt,
+		CreatedAt:       candidate.CreatedAt,
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/repository"
+	"sync"
+	"time"
+)
+
+var aiCandidateCleanupService *AICandidateCleanupService
+
+// AICandidateCleanupService 定期删除到期的续写候选
+type AICandidateCleanupService struct {
+	running       bool
+	mutex         sync.Mutex
+	stopChan      chan struct{}
+	interval      time.Duration
+	candidateRepo *repository.AICandidateRepository
+}
+
+func NewAICandidateCleanupService(interval time.Duration, candidateRepo *repository.AICandidateRepository) *AICandidateCleanupService {
+	return &AICandidateCleanupService{
+		stopChan:      make(chan struct{}),
+		interval:      interval,
+		candidateRepo: candidateRepo,
+	}
+}
+
+// Start 启动候选清理任务
+func (s *AICandidateCleanupService) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+
+	go s.cleanupLoop()
+	common.SysLog(fmt.Sprintf("[AICandidateCleanup] Started, interval: %v", s.interval))
+}
+
+// Stop 停止候选清理任务
+func (s *AICandidateCleanupService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	common.SysLog("[AICandidateCleanup] Stopped")
+}
+
+func (s *AICandidateCleanupService) cleanupLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.DeleteExpired()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// DeleteExpired deletes the candidates past their expiry, accepted and rejected ones included
+func (s *AICandidateCleanupService) DeleteExpired() {
+	deleted, err := s.candidateRepo.DeleteExpiredCandidates()
+	if err != nil {
+		common.SysError(fmt.Sprintf("[AICandidateCleanup] Failed to delete expired AI candidates: %v", err))
+		return
+	}
+	if deleted > 0 {
+		common.SysLog(fmt.Sprintf("[AICandidateCleanup] Deleted %d expired AI candidates", deleted))
+	}
+}
+
+// InitAICandidateCleanupService 初始化并启动候选清理任务，AICandidateCleanupInterval为0时不启动
+func InitAICandidateCleanupService(candidateRepo *repository.AICandidateRepository) {
+	if common.AICandidateCleanupInterval <= 0 {
+		common.SysLog("[AICandidateCleanup] Disabled, AI_CANDIDATE_CLEANUP_INTERVAL is 0")
+		return
+	}
+
+	aiCandidateCleanupService = NewAICandidateCleanupService(time.Duration(common.AICandidateCleanupInterval)*time.Minute, candidateRepo)
+	aiCandidateCleanupService.Start()
+}
+
+// StopAICandidateCleanupService 停止候选清理任务
+func StopAICandidateCleanupService() {
+	if aiCandidateCleanupService != nil {
+		aiCandidateCleanupService.Stop()
+	}
+}
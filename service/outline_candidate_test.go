@@ -0,0 +1,43 @@
+package service
+
+import (
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDeleteExpiredCandidates 到期的候选无论是否采纳都被删除，仍在采纳中的候选保留到采纳完成
+func TestDeleteExpiredCandidates(t *testing.T) {
+	_, db := setupFakeEnvironment(t)
+	now := time.Now().Unix()
+	candidates := []model.AICandidate{
+		{Content: "未到期", Status: define.AICandidateStatusPending, ExpiresAt: now + 3600},
+		{Content: "到期待采纳", Status: define.AICandidateStatusPending, ExpiresAt: now - 10},
+		{Content: "到期未采纳", Status: define.AICandidateStatusRejected, ExpiresAt: now - 10},
+		{Content: "到期已采纳", Status: define.AICandidateStatusAccepted, AcceptedVersion: 3, ExpiresAt: now - 10},
+		{Content: "采纳中", Status: define.AICandidateStatusAccepted, ExpiresAt: now - 10},
+		{Content: "采纳中断", Status: define.AICandidateStatusAccepted, ExpiresAt: now - 7200},
+	}
+	if err := db.Create(&candidates).Error; err != nil {
+		t.Fatalf("create candidates: %v", err)
+	}
+
+	NewAICandidateCleanupService(time.Hour, repository.NewAICandidateRepository(db)).DeleteExpired()
+
+	var remaining []model.AICandidate
+	db.Find(&remaining)
+	var got []string
+	for _, candidate := range remaining {
+		got = append(got, candidate.Content)
+	}
+	sort.Strings(got)
+	want := []string{"未到期", "采纳中"}
+	sort.Strings(want)
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("remaining candidates = %q, want %q", got, want)
+	}
+}
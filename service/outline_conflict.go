@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"gin-template/util"
+	"strings"
+)
+
+// maxSaveMergeAttempts 自动合并后保存时再次遇到并发修改的最大重试次数
+const maxSaveMergeAttempts = 3
+
+// saveOutlineFromBase saves content that was edited on top of baseVersion.
+// If the outline has moved on, the save is either reported as a conflict or, with autoMerge,
+// three-way merged against the base version and retried with the merged content.
+func (s *OutlineService) saveOutlineFromBase(projectId int64, baseVersion int, content string, autoMerge bool, isAiGenerated bool, stylePresetId int64, wordLimit int, tokensUsed int) (*define.SaveOutlineResponse, error) {
+	expectedVersion := baseVersion
+	saving := content
+	for attempt := 1; ; attempt++ {
+		outline, err := s.outlineRepo.SaveOutlineFromBase(projectId, expectedVersion, saving, isAiGenerated, stylePresetId, wordLimit, tokensUsed)
+		if err == nil {
+			response := toSaveOutlineResponse(outline)
+			response.BaseVersion = baseVersion
+			response.Merged = expectedVersion != baseVersion
+			if response.Merged {
+				logMsg := fmt.Sprintf("[OutlineService] Merged concurrent changes for project %d, base version %d, saved as version %d", projectId, baseVersion, outline.CurrentVersion)
+				common.SysLog(logMsg)
+			}
+			return response, nil
+		}
+		if err != repository.ErrOutlineVersionConflict {
+			logMsg := fmt.Sprintf("[OutlineService] Failed to save outline content for project %d: %v", projectId, err)
+			common.SysError(logMsg)
+			return nil, err
+		}
+
+		current, err := s.outlineRepo.GetOutlineByProjectId(projectId)
+		if err != nil {
+			logMsg := fmt.Sprintf("[OutlineService] Failed to get outline for project %d: %v", projectId, err)
+			common.SysError(logMsg)
+			return nil, err
+		}
+		if current == nil {
+			return nil, fmt.Errorf("大纲不存在")
+		}
+		logMsg := fmt.Sprintf("[OutlineService] Save conflict for project %d: base version %d, current version %d", projectId, baseVersion, current.CurrentVersion)
+		common.SysLog(logMsg)
+
+		response := toSaveOutlineResponse(current)
+		response.BaseVersion = baseVersion
+		response.Conflict = true
+		if !autoMerge {
+			return response, nil
+		}
+		if attempt >= maxSaveMergeAttempts {
+			logMsg := fmt.Sprintf("[OutlineService] Giving up merging for project %d after %d attempts", projectId, attempt)
+			common.SysError(logMsg)
+			return response, nil
+		}
+
+		baseContent, err := s.versionContentByNumber(projectId, baseVersion)
+		if err != nil {
+			return nil, err
+		}
+		merged, conflicts := util.MergeLines(baseContent, current.Content, content)
+		if len(conflicts) > 0 {
+			logMsg := fmt.Sprintf("[OutlineService] Merge for project %d has %d conflicts", projectId, len(conflicts))
+			common.SysLog(logMsg)
+			response.Conflicts = toMergeConflicts(conflicts)
+			return response, nil
+		}
+		saving = merged
+		expectedVersion = current.CurrentVersion
+	}
+}
+
+// versionContentByNumber 获取指定版本号的内容，版本号为0表示还没有任何版本，内容为空
+func (s *OutlineService) versionContentByNumber(projectId int64, versionNumber int) (string, error) {
+	if versionNumber == 0 {
+		return "", nil
+	}
+	version, err := s.outlineRepo.GetVersionByNumber(projectId, versionNumber)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get version %d for project %d: %v", versionNumber, projectId, err)
+		common.SysError(logMsg)
+		return "", err
+	}
+	if version == nil {
+		return "", fmt.Errorf("基础版本不存在")
+	}
+	return version.Content, nil
+}
+
+// toMergeConflicts 将行级合并冲突转换为接口返回格式，ours对应target，theirs对应source
+func toMergeConflicts(conflicts []util.MergeConflict) []define.MergeConflict {
+	result := make([]define.MergeConflict, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		result = append(result, define.MergeConflict{
+			BaseStart:   conflict.BaseStart,
+			TargetStart: conflict.OursStart,
+			SourceStart: conflict.TheirsStart,
+			Base:        strings.Join(conflict.Base, "\n"),
+			Target:      strings.Join(conflict.Ours, "\n"),
+			Source:      strings.Join(conflict.Theirs, "\n"),
+		})
+	}
+	return result
+}
+
+func toSaveOutlineResponse(outline *model.Outline) *define.SaveOutlineResponse {
+	return &define.SaveOutlineResponse{
+		Id:             outline.Id,
+		ProjectId:      outline.ProjectId,
+		Content:        outline.Content,
+		CurrentVersion: outline.CurrentVersion,
+		CurrentBranch:  outline.CurrentBranch,
+		CreatedAt:      outline.CreatedAt,
+		UpdatedAt:      outline.UpdatedAt,
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/service/llm"
+	"gin-template/util"
+	"strings"
+)
+
+const (
+	// contextPromptReserve 用户提示词模板本身和消息格式预留的token数
+	contextPromptReserve = 200
+	// minContextTokens 上下文预算的下限，模型的上下文长度配置过小时至少发送这么多内容
+	minContextTokens = 500
+)
+
+// continuationBudget 续写时大纲内容可用的token数：模型的上下文长度减去生成长度、系统提示词和其他提示词内容
+func continuationBudget(request define.GenerateAIPromptRequest, extra string) int {
+	modelName := request.Model
+	if _, resolved, err := llm.Resolve(request.Model); err == nil {
+		modelName = resolved
+	}
+	maxTokens := request.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultMaxTokens
+	}
+	budget := llm.ContextTokens(modelName) - maxTokens - llm.EstimateTokens(request.SystemPrompt) -
+		llm.EstimateTokens(extra) - contextPromptReserve
+	if budget < minContextTokens {
+		budget = minContextTokens
+	}
+	return budget
+}
+
+// buildContinuationContext 整份续写时组装发送给模型的大纲内容。放得下时原样发送；
+// 放不下时依次为整份大纲的概要、较早部分各节点的摘要和原样发送的末尾部分，仍然放不下时依次去掉最早的摘要、概要，最后截短末尾部分
+func (s *OutlineService) buildContinuationContext(projectId int64, content string, budget int) string {
+	if llm.EstimateTokens(content) <= budget {
+		return content
+	}
+
+	summaries := make(map[string]string)
+	if rows, err := s.summaryRepo.GetSummaries(projectId); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get summaries for project %d, using excerpts: %v", projectId, err)
+		common.SysError(logMsg)
+	} else {
+		for _, row := range rows {
+			summaries[row.Path] = row.Summary
+		}
+	}
+
+	windowStart := recentWindowStart(content)
+	tail := string([]rune(content)[windowStart:])
+	var sectionLines []string
+	for _, section := range splitSummarySections(content) {
+		if section.start >= windowStart {
+			break
+		}
+		// 还没有刷新过摘要的节点摘录原文
+		summary := summaries[section.path]
+		if summary == "" {
+			summary = extractSummary(section.text, sectionSummaryChars)
+		}
+		sectionLines = append(sectionLines, section.title+"："+summary)
+	}
+	overview := summaries[""]
+
+	compose := func() string {
+		var parts []string
+		if overview != "" {
+			parts = append(parts, "Summary of the outline so far:\n"+overview)
+		}
+		if len(sectionLines) > 0 {
+			parts = append(parts, "Summaries of earlier sections:\n"+strings.Join(sectionLines, "\n"))
+		}
+		parts = append(parts, "Most recent part of the outline (verbatim):\n"+tail)
+		return strings.Join(parts, "\n\n")
+	}
+
+	result := compose()
+	for llm.EstimateTokens(result) > budget && len(sectionLines) > 0 {
+		sectionLines = sectionLines[1:]
+		result = compose()
+	}
+	if llm.EstimateTokens(result) > budget && overview != "" {
+		overview = ""
+		result = compose()
+	}
+	if over := llm.EstimateTokens(result) - budget; over > 0 {
+		tail = fitTail(tail, llm.EstimateTokens(tail)-over)
+		result = compose()
+	}
+	return result
+}
+
+// fitTail 保留文本末尾不超过budget个token的部分，尽量从行首开始
+func fitTail(text string, budget int) string {
+	if llm.EstimateTokens(text) <= budget {
+		return text
+	}
+	if budget <= 0 {
+		return ""
+	}
+
+	lines := util.SplitLines(text)
+	kept, used := len(lines), 0
+	for kept > 0 {
+		tokens := llm.EstimateTokens(lines[kept-1]) + 1
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		kept--
+	}
+	if kept < len(lines) {
+		return strings.Join(lines[kept:], "\n")
+	}
+
+	// 最后一行就放不下，从行中截取
+	runes := []rune(lines[len(lines)-1])
+	low, high := 0, len(runes)
+	for low < high {
+		mid := (low + high) / 2
+		if llm.EstimateTokens(string(runes[mid:])) <= budget {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return string(runes[low:])
+}
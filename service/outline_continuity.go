@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/util"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// continuityAddedChars 参与检查的新增内容的最大字数，超出部分不检查
+	continuityAddedChars = 8000
+	// continuityMaxTokens 检查结果的最大生成长度
+	continuityMaxTokens = 1500
+)
+
+// continuityIssueTypes 支持的问题类型，模型返回其他类型时归为other
+var continuityIssueTypes = map[string]bool{
+	define.ContinuityTimeline:  true,
+	define.ContinuityCharacter: true,
+	define.ContinuityDeath:     true,
+	define.ContinuityPlace:     true,
+	define.ContinuityOther:     true,
+}
+
+// GetContinuityReport returns the cached continuity report of a version, nil when the version has not been checked
+func (s *OutlineService) GetContinuityReport(projectId int64, versionNumber int) (*define.ContinuityReport, error) {
+	report, err := s.reportRepo.GetReport(projectId, versionNumber)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get continuity report of version %d for project %d: %v", versionNumber, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if report == nil {
+		return nil, nil
+	}
+	version, err := s.outlineRepo.GetVersionByNumber(projectId, versionNumber)
+	if err != nil {
+		return nil, err
+	}
+	return toContinuityReport(report, version, true), nil
+}
+
+// CheckContinuity compares the content added in a version with the content of its parent version and reports suspected contradictions.
+// The result is cached per version, so checking the same version again is free unless force is set.
+func (s *OutlineService) CheckContinuity(ctx context.Context, userId int64, projectId int64, req define.ContinuityCheckRequest) (*define.ContinuityReport, error) {
+	if !req.Force {
+		cached, err := s.GetContinuityReport(projectId, req.VersionNumber)
+		if err != nil || cached != nil {
+			return cached, err
+		}
+	}
+
+	version, err := s.outlineRepo.GetVersionByNumber(projectId, req.VersionNumber)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get version %d for project %d: %v", req.VersionNumber, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if version == nil {
+		return nil, fmt.Errorf("版本不存在")
+	}
+	var parent *model.Version
+	if version.ParentId > 0 {
+		if parent, err = s.outlineRepo.GetVersionById(version.ParentId); err != nil {
+			return nil, err
+		}
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("该版本没有更早的内容可供对比")
+	}
+
+	report := &model.ContinuityReport{ProjectId: projectId, VersionNumber: version.VersionNumber, BaseVersion: parent.VersionNumber}
+	added := strings.TrimSpace(addedLines(parent.Content, version.Content))
+	if added == "" {
+		// 没有新增内容时不调用模型
+		return s.saveContinuityReport(report, nil, version)
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Checking continuity of version %d against version %d for project %d", version.VersionNumber, parent.VersionNumber, projectId)
+	common.SysLog(logMsg)
+
+	added = truncateRunes(added, continuityAddedChars)
+	systemPrompt := RenderPrompt(s.promptRepo, PromptContinuitySystem, nil)
+	if bible := storyBiblePrompt(s.bibleRepo, s.promptRepo, projectId, added); bible != "" {
+		systemPrompt += "\n\n" + bible
+	}
+	request := define.GenerateAIPromptRequest{
+		SystemPrompt: systemPrompt,
+		MaxTokens:    continuityMaxTokens,
+		Temperature:  0.2,
+		NoCache:      req.Force, // 强制重新检查时不使用缓存的模型结果
+		UserId:       userId,
+		ProjectId:    projectId,
+		Source:       define.AISourceContinuity,
+	}
+	// 之前的内容按续写的方式放入上下文，过长时使用滚动摘要
+	previous := s.buildContinuationContext(projectId, parent.Content, continuationBudget(request, added))
+	request.UserPrompt = RenderPrompt(s.promptRepo, PromptContinuityUser, map[string]string{"previous": previous, "added": added})
+
+	holdUUID := util.GetUUIDGenerator().Generate(util.BusinessAIWriting)
+	description := fmt.Sprintf("AI continuity check of version %d for project [%d]", version.VersionNumber, projectId)
+	estimatedTokens := GetPricingService().Estimate(userId, request)
+	if _, err := GetTokenService().ReserveToken(userId, estimatedTokens, holdUUID, description, "project", strconv.FormatInt(projectId, 10)); err != nil {
+		logMsg = fmt.Sprintf("[OutlineService] Failed to reserve %d tokens for project %d: %v", estimatedTokens, projectId, err)
+		common.SysError(logMsg)
+		if available, balanceErr := GetTokenService().GetAvailableBalance(userId); balanceErr == nil && available < estimatedTokens {
+			return nil, fmt.Errorf("Token余额不足，请充值")
+		}
+		return nil, fmt.Errorf("预扣Token失败，请稍后重试")
+	}
+
+	response, err := GenerateAICompletion(ctx, request)
+	if err != nil {
+		_ = GetTokenService().ReleaseToken(holdUUID)
+		if ctx.Err() != nil {
+			logMsg = fmt.Sprintf("[OutlineService] AI continuity check for project %d cancelled", projectId)
+			common.SysLog(logMsg)
+			return nil, ctx.Err()
+		}
+		logMsg = fmt.Sprintf("[OutlineService] AI continuity check failed: %v", err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf(logMsg)
+	}
+	// 结果无法解析时视为调用失败，不扣减
+	issues, err := parseContinuityIssues(response.Content, version.Content, parent.Content)
+	if err != nil {
+		_ = GetTokenService().ReleaseToken(holdUUID)
+		logMsg = fmt.Sprintf("[OutlineService] Failed to parse continuity check result for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("检查结果解析失败，请重试")
+	}
+
+	// 先结算预扣，扣除失败时不保存也不返回检查结果
+	charge := GetPricingService().ChargeResponse(userId, response)
+	userToken, err := settleAITokens(holdUUID, charge.Amount, "ai_continuity_debit", chargeDescription(description, charge))
+	if err != nil {
+		return nil, err
+	}
+
+	report.TokensUsed = response.TokensUsed
+	report.Model = response.Model
+	result, err := s.saveContinuityReport(report, issues, version)
+	if err != nil {
+		refundAITokens(userId, projectId, charge.Amount, fmt.Sprintf("Refund of %s, report not saved", description))
+		return nil, err
+	}
+	result.TokensCharged = charge.Amount
+	result.TokenBalance = userToken.Balance
+
+	logMsg = fmt.Sprintf("[OutlineService] Continuity check of version %d for project %d found %d issues, Tokens used: %d",
+		version.VersionNumber, projectId, len(issues), response.TokensUsed)
+	common.SysLog(logMsg)
+	return result, nil
+}
+
+func (s *OutlineService) saveContinuityReport(report *model.ContinuityReport, issues []define.ContinuityIssue, version *model.Version) (*define.ContinuityReport, error) {
+	if issues == nil {
+		issues = []define.ContinuityIssue{}
+	}
+	data, _ := json.Marshal(issues)
+	report.Issues = string(data)
+	if err := s.reportRepo.SaveReport(report); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to save continuity report of version %d for project %d: %v", report.VersionNumber, report.ProjectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	return toContinuityReport(report, version, false), nil
+}
+
+// parseContinuityIssues 解析模型返回的JSON，并在两个版本的内容中定位引文
+func parseContinuityIssues(output string, newContent string, previousContent string) ([]define.ContinuityIssue, error) {
+	// 模型可能在JSON前后附带说明或代码块标记
+	start, end := strings.Index(output, "{"), strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object in output")
+	}
+	var parsed struct {
+		Issues []struct {
+			Type        string `json:"type"`
+			Description string `json:"description"`
+			Evidence    []struct {
+				Source string `json:"source"`
+				Quote  string `json:"quote"`
+			} `json:"evidence"`
+		} `json:"issues"`
+	}
+	if err := json.Unmarshal([]byte(output[start:end+1]), &parsed); err != nil {
+		return nil, err
+	}
+
+	issues := make([]define.ContinuityIssue, 0, len(parsed.Issues))
+	for _, item := range parsed.Issues {
+		issue := define.ContinuityIssue{
+			Type:        strings.ToLower(strings.TrimSpace(item.Type)),
+			Description: strings.TrimSpace(item.Description),
+			Evidence:    []define.ContinuityEvidence{},
+		}
+		if !continuityIssueTypes[issue.Type] {
+			issue.Type = define.ContinuityOther
+		}
+		for _, evidence := range item.Evidence {
+			quote := strings.Trim(strings.TrimSpace(evidence.Quote), "\"“”「」")
+			if quote == "" {
+				continue
+			}
+			content := previousContent
+			if evidence.Source == "new" {
+				content = newContent
+			}
+			issue.Evidence = append(issue.Evidence, locateQuote(evidence.Source, quote, content))
+		}
+		if issue.Description != "" {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// locateQuote 在内容中查找引文，返回字符位置和行号
+func locateQuote(source string, quote string, content string) define.ContinuityEvidence {
+	evidence := define.ContinuityEvidence{Source: source, Quote: quote, Start: -1, End: -1}
+	if source != "new" && source != "previous" {
+		return evidence
+	}
+	index := strings.Index(content, quote)
+	if index < 0 {
+		return evidence
+	}
+	evidence.Found = true
+	evidence.Start = utf8.RuneCountInString(content[:index])
+	evidence.End = evidence.Start + utf8.RuneCountInString(quote)
+	evidence.Line = strings.Count(content[:index], "\n") + 1
+	return evidence
+}
+
+func toContinuityReport(report *model.ContinuityReport, version *model.Version, cached bool) *define.ContinuityReport {
+	issues := []define.ContinuityIssue{}
+	if report.Issues != "" {
+		_ = json.Unmarshal([]byte(report.Issues), &issues)
+	}
+	result := &define.ContinuityReport{
+		VersionNumber: report.VersionNumber,
+		BaseVersion:   report.BaseVersion,
+		Issues:        issues,
+		TokensUsed:    report.TokensUsed,
+		Model:         report.Model,
+		Cached:        cached,
+		CreatedAt:     report.CreatedAt,
+	}
+	if version != nil {
+		result.IsAiGenerated = version.IsAiGenerated
+	}
+	return result
+}
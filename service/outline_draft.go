@@ -0,0 +1,289 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"sync"
+	"time"
+)
+
+const (
+	// draftPromotionInterval 检查空闲草稿的间隔
+	draftPromotionInterval = time.Minute
+	// draftPromotionBatchSize 每次最多提交的空闲草稿数量
+	draftPromotionBatchSize = 100
+)
+
+// errDraftChanged 提交前草稿已被新的自动保存更新
+var errDraftChanged = errors.New("草稿已更新，请重新提交")
+
+// AutosaveDraft stores the latest unsaved content of an outline without creating a version
+func (s *OutlineService) AutosaveDraft(projectId int64, req define.DraftRequest) (*define.DraftBrief, error) {
+	outline, err := s.outlineRepo.GetOutlineByProjectId(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get outline for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+
+	baseVersion, branch := 0, model.DefaultBranchName
+	if outline != nil {
+		baseVersion, branch = outline.CurrentVersion, outline.CurrentBranch
+	}
+	if req.BaseVersion != nil {
+		baseVersion = *req.BaseVersion
+	}
+
+	draft, err := s.draftRepo.SaveDraft(projectId, req.Content, baseVersion, branch)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to autosave draft for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("草稿保存失败")
+	}
+	return toDraftBrief(draft, outline), nil
+}
+
+// GetDraft retrieves the unsaved draft of an outline
+func (s *OutlineService) GetDraft(projectId int64) (*define.DraftResponse, error) {
+	draft, err := s.draftRepo.GetDraft(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get draft for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("获取草稿失败")
+	}
+	if draft == nil {
+		return nil, fmt.Errorf("没有未提交的草稿")
+	}
+
+	outline, err := s.outlineRepo.GetOutlineByProjectId(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get outline for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	return &define.DraftResponse{
+		DraftBrief: *toDraftBrief(draft, outline),
+		Content:    draft.Content,
+	}, nil
+}
+
+// CommitDraft saves the draft as a new version on top of the version it was based on
+func (s *OutlineService) CommitDraft(projectId int64, autoMerge bool) (*define.SaveOutlineResponse, error) {
+	logMsg := fmt.Sprintf("[OutlineService] Committing draft for project %d", projectId)
+	common.SysLog(logMsg)
+
+	draft, err := s.draftRepo.GetDraft(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get draft for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("获取草稿失败")
+	}
+	if draft == nil {
+		return nil, fmt.Errorf("没有未提交的草稿")
+	}
+	return s.promoteDraft(draft, autoMerge)
+}
+
+// DiscardDraft deletes the unsaved draft of an outline
+func (s *OutlineService) DiscardDraft(projectId int64) error {
+	if err := s.draftRepo.DeleteDraft(projectId); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to discard draft for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return fmt.Errorf("删除草稿失败")
+	}
+	logMsg := fmt.Sprintf("[OutlineService] Discarded draft for project %d", projectId)
+	common.SysLog(logMsg)
+	return nil
+}
+
+// promoteDraft 将草稿保存为正式版本
+// 先按修订号取走草稿，避免与提交期间的自动保存互相覆盖；保存失败或冲突时放回草稿，冲突的草稿不再自动提交
+func (s *OutlineService) promoteDraft(draft *model.OutlineDraft, autoMerge bool) (*define.SaveOutlineResponse, error) {
+	taken, err := s.draftRepo.TakeDraft(draft.ProjectId, draft.Revision)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to take draft for project %d: %v", draft.ProjectId, err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("提交草稿失败")
+	}
+	if !taken {
+		return nil, errDraftChanged
+	}
+
+	outline, err := s.outlineRepo.GetOutlineByProjectId(draft.ProjectId)
+	if err != nil {
+		s.restoreDraft(draft)
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get outline for project %d: %v", draft.ProjectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if outline != nil && draft.Branch != "" && outline.CurrentBranch != draft.Branch {
+		draft.Conflict = true
+		s.restoreDraft(draft)
+		return nil, fmt.Errorf("草稿基于分支%s，请切换回该分支后再提交", draft.Branch)
+	}
+
+	response, err := s.saveOutlineFromBase(draft.ProjectId, draft.BaseVersion, draft.Content, autoMerge, false, 0, 0, 0)
+	if err != nil {
+		s.restoreDraft(draft)
+		return nil, err
+	}
+	if response.Conflict {
+		draft.Conflict = true
+		s.restoreDraft(draft)
+		return response, nil
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Committed draft revision %d for project %d as version %d", draft.Revision, draft.ProjectId, response.CurrentVersion)
+	common.SysLog(logMsg)
+	return response, nil
+}
+
+func (s *OutlineService) restoreDraft(draft *model.OutlineDraft) {
+	if err := s.draftRepo.RestoreDraft(draft); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to restore draft for project %d: %v", draft.ProjectId, err)
+		common.SysError(logMsg)
+	}
+}
+
+// draftBrief 获取大纲的草稿摘要，没有草稿或读取失败时返回nil
+func (s *OutlineService) draftBrief(projectId int64, outline *model.Outline) *define.DraftBrief {
+	draft, err := s.draftRepo.GetDraft(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get draft for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil
+	}
+	if draft == nil {
+		return nil
+	}
+	return toDraftBrief(draft, outline)
+}
+
+// clearDraft 大纲手动保存后草稿已过时，删除草稿
+func (s *OutlineService) clearDraft(projectId int64) {
+	if err := s.draftRepo.DeleteDraft(projectId); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to clear draft for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+	}
+}
+
+func toDraftBrief(draft *model.OutlineDraft, outline *model.Outline) *define.DraftBrief {
+	brief := &define.DraftBrief{
+		ProjectId:   draft.ProjectId,
+		BaseVersion: draft.BaseVersion,
+		Branch:      draft.Branch,
+		Revision:    draft.Revision,
+		Conflict:    draft.Conflict,
+		CreatedAt:   draft.CreatedAt,
+		UpdatedAt:   draft.UpdatedAt,
+	}
+	if outline != nil {
+		brief.Outdated = outline.CurrentVersion != draft.BaseVersion || outline.CurrentBranch != draft.Branch
+	}
+	return brief
+}
+
+var draftPromotionService *DraftPromotionService
+
+// DraftPromotionService 定期把长时间没有自动保存的草稿提交为正式版本
+type DraftPromotionService struct {
+	running        bool
+	mutex          sync.Mutex
+	stopChan       chan struct{}
+	idle           time.Duration
+	outlineService *OutlineService
+}
+
+func NewDraftPromotionService(idle time.Duration, outlineService *OutlineService) *DraftPromotionService {
+	return &DraftPromotionService{
+		stopChan:       make(chan struct{}),
+		idle:           idle,
+		outlineService: outlineService,
+	}
+}
+
+// Start 启动空闲草稿提交
+func (s *DraftPromotionService) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+
+	go s.promotionLoop()
+	common.SysLog(fmt.Sprintf("[DraftPromotion] Started, drafts idle for %v are committed", s.idle))
+}
+
+// Stop 停止空闲草稿提交
+func (s *DraftPromotionService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	common.SysLog("[DraftPromotion] Stopped")
+}
+
+func (s *DraftPromotionService) promotionLoop() {
+	ticker := time.NewTicker(draftPromotionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.PromoteIdleDrafts(time.Now())
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// PromoteIdleDrafts commits every draft that has not been autosaved since the idle period, merging with newer versions when possible
+func (s *DraftPromotionService) PromoteIdleDrafts(now time.Time) {
+	drafts, err := s.outlineService.draftRepo.GetIdleDrafts(now.Add(-s.idle).Unix(), draftPromotionBatchSize)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[DraftPromotion] Failed to list idle drafts: %v", err))
+		return
+	}
+
+	for _, draft := range drafts {
+		response, err := s.outlineService.promoteDraft(draft, true)
+		if err == errDraftChanged {
+			continue
+		}
+		if err != nil {
+			common.SysError(fmt.Sprintf("[DraftPromotion] Failed to commit draft for project %d: %v", draft.ProjectId, err))
+			continue
+		}
+		if response.Conflict {
+			common.SysLog(fmt.Sprintf("[DraftPromotion] Draft for project %d conflicts with version %d, left for the user to resolve", draft.ProjectId, response.CurrentVersion))
+		}
+	}
+}
+
+// InitDraftPromotionService 初始化并启动空闲草稿提交，DraftIdleMinutes为0时草稿只在手动提交时保存
+func InitDraftPromotionService(outlineService *OutlineService) {
+	if common.DraftIdleMinutes <= 0 {
+		common.SysLog("[DraftPromotion] Disabled, DRAFT_IDLE_MINUTES is 0")
+		return
+	}
+
+	draftPromotionService = NewDraftPromotionService(time.Duration(common.DraftIdleMinutes)*time.Minute, outlineService)
+	draftPromotionService.Start()
+}
+
+// StopDraftPromotionService 停止空闲草稿提交
+func StopDraftPromotionService() {
+	if draftPromotionService != nil {
+		draftPromotionService.Stop()
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"gin-template/service/llm"
+	"gin-template/util"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupFakeEnvironment 使用内存SQLite和模拟服务商，返回续写服务和数据库
+func setupFakeEnvironment(t *testing.T) (*OutlineService, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	err = db.AutoMigrate(&model.Option{}, &model.Outline{}, &model.Version{}, &model.OutlineBranch{}, &model.OutlineNode{},
+		&model.OutlineDraft{}, &model.AICandidate{}, &model.OutlineSummary{}, &model.ContinuityReport{}, &model.StoryEntity{},
+		&model.StoryRelation{}, &model.SearchDocument{}, &model.SearchPosting{}, &model.StylePreset{}, &model.PromptTemplate{},
+		&model.PromptTemplateVersion{}, &model.UserToken{}, &model.TokenTransaction{}, &model.TokenHold{}, &model.Package{},
+		&model.Subscription{}, &model.ModerationWord{}, &model.ModerationRecord{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	previousDB := model.DB
+	model.DB = db
+	common.RedisEnabled = false
+
+	common.OptionMapRWMutex.Lock()
+	previousOptions := common.OptionMap
+	common.OptionMap = map[string]string{
+		llm.OptionDefaultProvider:    llm.ProviderFake,
+		"fake_default_model":         "fake-model",
+		llm.OptionFakeLatency:        "0",
+		llm.OptionFakeStreamInterval: "0",
+		"llm_cache_ttl_seconds":      "0",
+		"moderation_enabled":         "false",
+	}
+	common.OptionMapRWMutex.Unlock()
+	t.Cleanup(func() {
+		common.OptionMapRWMutex.Lock()
+		common.OptionMap = previousOptions
+		common.OptionMapRWMutex.Unlock()
+		model.DB = previousDB
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	util.SetUUIDGenerator(util.NewHybridGenerator(1))
+	tokenRepo := repository.NewTokenRepository(db)
+	SetTokenService(NewTokenService(tokenRepo))
+	SetPricingService(NewPricingService(repository.NewPackageRepository(db)))
+	outlineService := NewOutlineService(tokenRepo, nil, repository.NewOutlineRepository(db), repository.NewOutlineDraftRepository(db),
+		repository.NewStylePresetRepository(db), repository.NewPromptTemplateRepository(db), repository.NewAICandidateRepository(db),
+		repository.NewOutlineSummaryRepository(db), repository.NewStoryBibleRepository(db), repository.NewContinuityReportRepository(db))
+	return outlineService, db
+}
+
+// TestGenerateOutlineWithFakeProvider 续写、保存新版本、结算预扣到扣除余额的完整流程
+func TestGenerateOutlineWithFakeProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		content string // 期望的续写内容，为空时只检查不为空
+	}{
+		{name: "生成的回复"},
+		{name: "脚本化的回复", script: `[{"source":"outline_generation","reply":"主角在渡口遇到了旧友。"}]`, content: "主角在渡口遇到了旧友。"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outlineService, db := setupFakeEnvironment(t)
+			common.OptionMap[llm.OptionFakeScript] = tt.script
+			const userId, projectId, initialBalance = int64(7), int64(1), int64(10000)
+			if _, err := GetTokenService().InitUserTokenAccount(userId, initialBalance); err != nil {
+				t.Fatalf("InitUserTokenAccount() error = %v", err)
+			}
+			initial, err := outlineService.SaveOutlineContent(projectId, "# 第一章\n主角出发。", nil, false)
+			if err != nil {
+				t.Fatalf("SaveOutlineContent() error = %v", err)
+			}
+
+			result, err := outlineService.GenerateOutlineWithAI(context.Background(), userId, projectId, define.AIGenerateRequest{Content: "# 第一章\n主角出发。"})
+			if err != nil {
+				t.Fatalf("GenerateOutlineWithAI() error = %v", err)
+			}
+			content, _ := result["content"].(string)
+			if content == "" || (tt.content != "" && content != tt.content) {
+				t.Errorf("content = %q, want %q", content, tt.content)
+			}
+			if saved, _ := result["saved"].(bool); !saved {
+				t.Errorf("saved = false, want true")
+			}
+
+			// 新版本包含续写的内容
+			outline, err := repository.NewOutlineRepository(db).GetOutlineByProjectId(projectId)
+			if err != nil || outline == nil {
+				t.Fatalf("GetOutlineByProjectId() = %v, %v", outline, err)
+			}
+			if outline.CurrentVersion != initial.CurrentVersion+1 || result["current_version"] != outline.CurrentVersion {
+				t.Errorf("current version = %d, result %v, want %d", outline.CurrentVersion, result["current_version"], initial.CurrentVersion+1)
+			}
+			if !strings.Contains(outline.Content, content) {
+				t.Errorf("outline content %q does not contain %q", outline.Content, content)
+			}
+
+			// 预扣已结算，扣除的Token与返回的一致
+			var holds []model.TokenHold
+			db.Where("user_id = ?", userId).Find(&holds)
+			if len(holds) != 1 || holds[0].Status != "settled" {
+				t.Fatalf("holds = %+v, want one settled hold", holds)
+			}
+			charged, _ := result["tokens_charged"].(int64)
+			if charged <= 0 || holds[0].SettledAmount != charged {
+				t.Errorf("tokens_charged = %d, settled amount = %d", charged, holds[0].SettledAmount)
+			}
+			var transactions []model.TokenTransaction
+			db.Where("user_id = ? AND type = ?", userId, "ai_generation_debit").Find(&transactions)
+			if len(transactions) != 1 || transactions[0].Amount != -charged {
+				t.Errorf("transactions = %+v, want one debit of %d", transactions, charged)
+			}
+
+			userToken, err := GetTokenService().GetUserToken(userId)
+			if err != nil {
+				t.Fatalf("GetUserToken() error = %v", err)
+			}
+			if userToken.Balance != initialBalance-charged || result["token_balance"] != userToken.Balance {
+				t.Errorf("balance = %d, result %v, want %d", userToken.Balance, result["token_balance"], initialBalance-charged)
+			}
+		})
+	}
+}
+
+// TestStreamOutlineMasksWords 流式续写中需要打码的词在发送给客户端之前打码，跨越两段增量的词也不会泄露
+func TestStreamOutlineMasksWords(t *testing.T) {
+	outlineService, db := setupFakeEnvironment(t)
+	common.OptionMap[llm.OptionFakeScript] = `[{"source":"outline_generation","reply":"坏人张三逃走了，张三丰追了上去。"}]`
+	common.OptionMap[OptionModerationEnabled] = "true"
+	if err := db.Create(&model.ModerationWord{Word: "张三", Action: "mask"}).Error; err != nil {
+		t.Fatalf("create moderation word: %v", err)
+	}
+	previousModeration := GetModerationService()
+	SetModerationService(NewModerationService(repository.NewModerationRepository(db), repository.NewPromptTemplateRepository(db)))
+	reloadModerationWords()
+	t.Cleanup(func() {
+		SetModerationService(previousModeration)
+		reloadModerationWords()
+	})
+
+	const userId, projectId = int64(7), int64(1)
+	if _, err := GetTokenService().InitUserTokenAccount(userId, 10000); err != nil {
+		t.Fatalf("InitUserTokenAccount() error = %v", err)
+	}
+	if _, err := outlineService.SaveOutlineContent(projectId, "# 第一章\n主角出发。", nil, false); err != nil {
+		t.Fatalf("SaveOutlineContent() error = %v", err)
+	}
+
+	var deltas []string
+	result, err := outlineService.StreamOutlineWithAI(context.Background(), "stream-1", userId, projectId,
+		define.AIGenerateRequest{Content: "# 第一章\n主角出发。"}, func(delta string) {
+			deltas = append(deltas, delta)
+		})
+	if err != nil {
+		t.Fatalf("StreamOutlineWithAI() error = %v", err)
+	}
+
+	const want = "坏人**逃走了，**丰追了上去。"
+	for _, delta := range deltas {
+		if strings.ContainsAny(delta, "张三") {
+			t.Errorf("delta %q contains a masked word", delta)
+		}
+	}
+	if got := strings.Join(deltas, ""); got != want {
+		t.Errorf("deltas = %q, want %q", got, want)
+	}
+	if result["content"] != want {
+		t.Errorf("content = %v, want %q", result["content"], want)
+	}
+	outline, err := repository.NewOutlineRepository(db).GetOutlineByProjectId(projectId)
+	if err != nil || outline == nil {
+		t.Fatalf("GetOutlineByProjectId() = %v, %v", outline, err)
+	}
+	if strings.Contains(outline.Content, "张三") || !strings.Contains(outline.Content, want) {
+		t.Errorf("outline content = %q, want masked %q", outline.Content, want)
+	}
+}
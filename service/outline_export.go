@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/service/document"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	pdfFontOnce sync.Once
+	pdfFont     *document.TrueTypeFont
+)
+
+// ExportOutlineToFile exports the current outline (or a specific version) to a file under the upload directory
+func (s *OutlineService) ExportOutlineToFile(project *model.Project, req define.ExportRequest) (*define.ExportResponse, error) {
+	logMsg := fmt.Sprintf("[OutlineService] Starting to export outline for project %d to file, format: %s, version: %d", project.Id, req.Format, req.VersionNumber)
+	common.SysLog(logMsg)
+
+	var writer func(w io.Writer, book *document.Book) error
+	ext := req.Format
+	switch req.Format {
+	case "txt":
+		writer = document.WriteText
+	case "md":
+		writer = document.WriteMarkdown
+	case "docx":
+		writer = document.WriteDocx
+	case "pdf":
+		font := loadPDFFont()
+		writer = func(w io.Writer, book *document.Book) error {
+			return document.WritePDF(w, book, font)
+		}
+	case "epub":
+		writer = document.WriteEpub
+	default:
+		logMsg := fmt.Sprintf("[OutlineService] Unsupported export format: %s", req.Format)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("不支持的导出格式，仅支持txt、md、docx、pdf和epub")
+	}
+	// 纯文本和Markdown分章导出时打包为zip
+	if req.SplitChapters && (req.Format == "txt" || req.Format == "md") {
+		ext = "zip"
+	}
+
+	content, versionNumber, err := s.exportContent(project.Id, req.VersionNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// 文件名带随机部分，避免通过下载地址猜到其他项目的导出文件
+	fileName := fmt.Sprintf("outline_%d_v%d_%s.%s", project.Id, versionNumber, common.GetUUID(), ext)
+	filePath := filepath.Join(common.UploadPath, fileName)
+	book := document.NewBook(project.Title, project.Description, content, req.TitlePage, req.SplitChapters)
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to create export file: %v", err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("导出文件创建失败")
+	}
+	err = writer(file, book)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(filePath)
+		logMsg := fmt.Sprintf("[OutlineService] Failed to generate %s file for project %d: %v", req.Format, project.Id, err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("导出文件生成失败")
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to stat export file %s: %v", filePath, err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf("导出文件生成失败")
+	}
+
+	logMsg = fmt.Sprintf("[OutlineService] Successfully exported outline for project %d to file: %s", project.Id, fileName)
+	common.SysLog(logMsg)
+	return &define.ExportResponse{
+		FileUrl:       "/upload/" + fileName,
+		FileName:      fileName,
+		FileSize:      info.Size(),
+		Format:        req.Format,
+		VersionNumber: versionNumber,
+	}, nil
+}
+
+// exportContent 获取要导出的大纲内容，versionNumber为0时返回当前内容
+func (s *OutlineService) exportContent(projectId int64, versionNumber int) (string, int, error) {
+	if versionNumber > 0 {
+		version, err := s.outlineRepo.GetVersionByNumber(projectId, versionNumber)
+		if err != nil {
+			logMsg := fmt.Sprintf("[OutlineService] Failed to get version %d for project %d: %v", versionNumber, projectId, err)
+			common.SysError(logMsg)
+			return "", 0, fmt.Errorf("获取版本失败")
+		}
+		if version == nil {
+			return "", 0, fmt.Errorf("版本不存在")
+		}
+		return version.Content, version.VersionNumber, nil
+	}
+
+	outline, err := s.outlineRepo.GetOutlineByProjectId(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get outline content for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return "", 0, fmt.Errorf("获取大纲内容失败")
+	}
+	if outline == nil {
+		return "", 0, fmt.Errorf("大纲不存在")
+	}
+	return outline.Content, outline.CurrentVersion, nil
+}
+
+// loadPDFFont 加载PDF_FONT_PATH指定的TrueType字体，只加载一次；未配置或加载失败时返回nil，使用阅读器内置字体
+func loadPDFFont() *document.TrueTypeFont {
+	pdfFontOnce.Do(func() {
+		if common.PDFFontPath == "" {
+			common.SysLog("[OutlineService] PDF_FONT_PATH not set, PDF export uses the built-in STSong-Light font")
+			return
+		}
+		font, err := document.LoadTrueTypeFont(common.PDFFontPath)
+		if err != nil {
+			logMsg := fmt.Sprintf("[OutlineService] Failed to load PDF font %s, falling back to STSong-Light: %v", common.PDFFontPath, err)
+			common.SysError(logMsg)
+			return
+		}
+		pdfFont = font
+		logMsg := fmt.Sprintf("[OutlineService] Loaded PDF font %s (%s)", common.PDFFontPath, font.Name)
+		common.SysLog(logMsg)
+	})
+	return pdfFont
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExportOutlineVersion 导出指定版本时使用该版本的内容，版本号为0时导出当前内容
+func TestExportOutlineVersion(t *testing.T) {
+	outlineService, _ := setupFakeEnvironment(t)
+	previousUploadPath := common.UploadPath
+	common.UploadPath = t.TempDir()
+	t.Cleanup(func() { common.UploadPath = previousUploadPath })
+
+	project := &model.Project{Id: 1, Title: "长夜将尽"}
+	contents := []string{"# 第一章 归乡\n主角在雨夜回到了小镇。\n", "# 第一章 归乡\n主角在清晨离开了小镇。\n"}
+	for _, content := range contents {
+		if _, err := outlineService.SaveOutlineContent(project.Id, content, nil, false); err != nil {
+			t.Fatalf("SaveOutlineContent() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		versionNumber int
+		wantVersion   int
+		wantContent   string
+		wantErr       bool
+	}{
+		{name: "当前内容", versionNumber: 0, wantVersion: 2, wantContent: contents[1]},
+		{name: "历史版本", versionNumber: 1, wantVersion: 1, wantContent: contents[0]},
+		{name: "最新版本", versionNumber: 2, wantVersion: 2, wantContent: contents[1]},
+		{name: "版本不存在", versionNumber: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := outlineService.ExportOutlineToFile(project, define.ExportRequest{Format: "txt", VersionNumber: tt.versionNumber})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExportOutlineToFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if result.VersionNumber != tt.wantVersion || !strings.Contains(result.FileName, fmt.Sprintf("_v%d_", tt.wantVersion)) {
+				t.Errorf("version = %d, file name = %s, want version %d", result.VersionNumber, result.FileName, tt.wantVersion)
+			}
+			data, err := os.ReadFile(filepath.Join(common.UploadPath, result.FileName))
+			if err != nil {
+				t.Fatalf("read export file: %v", err)
+			}
+			if string(data) != tt.wantContent || result.FileSize != int64(len(data)) {
+				t.Errorf("export file = %q (size %d), want %q", data, result.FileSize, tt.wantContent)
+			}
+		})
+	}
+}
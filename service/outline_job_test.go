@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/service/llm"
+	"strings"
+	"testing"
+)
+
+// fakeJobProgress 记录在内存中的异步任务进度
+type fakeJobProgress struct {
+	generated     string
+	settledAmount int64
+	settled       bool
+	saved         bool
+	steps         []string // 依次记录的步骤
+}
+
+func (p *fakeJobProgress) TransactionUUID() string {
+	return "job-1"
+}
+
+func (p *fakeJobProgress) Generated(output interface{}) bool {
+	return p.generated != "" && json.Unmarshal([]byte(p.generated), output) == nil
+}
+
+func (p *fakeJobProgress) MarkGenerated(output interface{}) {
+	data, _ := json.Marshal(output)
+	p.generated = string(data)
+	p.steps = append(p.steps, "generated")
+}
+
+func (p *fakeJobProgress) Settled() (int64, bool) {
+	return p.settledAmount, p.settled
+}
+
+func (p *fakeJobProgress) MarkSettled(amount int64) {
+	p.settledAmount, p.settled = amount, true
+	p.steps = append(p.steps, "settled")
+}
+
+func (p *fakeJobProgress) MarkSaved(result interface{}) {
+	p.saved = true
+	p.steps = append(p.steps, "saved")
+}
+
+// TestGenerateOutlineForJobResume 中断后重新执行的任务使用扣费前记录的内容，不再调用AI，已扣费时不再扣费
+func TestGenerateOutlineForJobResume(t *testing.T) {
+	recorded, _ := json.Marshal(define.GenerateResponse{Content: "主角在渡口等到了天亮。", TokensUsed: 30, PromptTokens: 20, CompletionTokens: 10, Model: "fake-model"})
+	tests := []struct {
+		name         string
+		progress     *fakeJobProgress
+		wantContent  string
+		wantCharged  int64 // 为-1时只检查大于0
+		wantBalance  int64 // 为-1时只检查扣除了wantCharged
+		wantSteps    string
+		wantErr      bool
+		wantVersions int // 期望的版本数
+	}{
+		{name: "首次执行", progress: &fakeJobProgress{}, wantContent: "主角在渡口遇到了旧友。", wantCharged: -1, wantBalance: -1, wantSteps: "generated|settled|saved", wantVersions: 2},
+		{name: "已生成未扣费", progress: &fakeJobProgress{generated: string(recorded)}, wantContent: "主角在渡口等到了天亮。", wantCharged: 30, wantBalance: 9970, wantSteps: "settled|saved", wantVersions: 2},
+		{name: "已扣费未保存", progress: &fakeJobProgress{generated: string(recorded), settledAmount: 30, settled: true}, wantContent: "主角在渡口等到了天亮。", wantCharged: 30, wantBalance: 10000, wantSteps: "saved", wantVersions: 2},
+		{name: "已扣费但没有记录内容", progress: &fakeJobProgress{settledAmount: 30, settled: true}, wantErr: true, wantBalance: 10000, wantVersions: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outlineService, db := setupFakeEnvironment(t)
+			common.OptionMap[llm.OptionFakeScript] = `[{"source":"outline_generation","reply":"主角在渡口遇到了旧友。"}]`
+			const userId, projectId = int64(7), int64(1)
+			if _, err := GetTokenService().InitUserTokenAccount(userId, 10000); err != nil {
+				t.Fatalf("InitUserTokenAccount() error = %v", err)
+			}
+			if _, err := outlineService.SaveOutlineContent(projectId, "# 第一章\n主角出发。", nil, false); err != nil {
+				t.Fatalf("SaveOutlineContent() error = %v", err)
+			}
+
+			result, err := outlineService.GenerateOutlineForJob(context.Background(), userId, projectId, define.AIGenerateRequest{Content: "# 第一章\n主角出发。"}, tt.progress)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateOutlineForJob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			userToken, _ := GetTokenService().GetUserToken(userId)
+			var versions int64
+			db.Model(&model.Version{}).Count(&versions)
+			if int(versions) != tt.wantVersions {
+				t.Errorf("versions = %d, want %d", versions, tt.wantVersions)
+			}
+			if tt.wantErr {
+				if userToken.Balance != tt.wantBalance {
+					t.Errorf("balance = %d, want %d", userToken.Balance, tt.wantBalance)
+				}
+				return
+			}
+
+			if content, _ := result["content"].(string); content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			charged, _ := result["tokens_charged"].(int64)
+			if (tt.wantCharged < 0 && charged <= 0) || (tt.wantCharged >= 0 && charged != tt.wantCharged) {
+				t.Errorf("tokens_charged = %d, want %d", charged, tt.wantCharged)
+			}
+			wantBalance := tt.wantBalance
+			if wantBalance < 0 {
+				wantBalance = 10000 - charged
+			}
+			if userToken.Balance != wantBalance {
+				t.Errorf("balance = %d, want %d", userToken.Balance, wantBalance)
+			}
+			if steps := strings.Join(tt.progress.steps, "|"); steps != tt.wantSteps {
+				t.Errorf("steps = %q, want %q", steps, tt.wantSteps)
+			}
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/util"
+	"sort"
+	"strings"
+)
+
+// GetOutlineTree returns the hierarchical structure of a project's outline
+func (s *OutlineService) GetOutlineTree(projectId int64) (*define.OutlineTreeResponse, error) {
+	outline, roots, err := s.loadOutlineTree(projectId)
+	if err != nil {
+		return nil, err
+	}
+	return buildOutlineTreeResponse(projectId, outline, roots, 0), nil
+}
+
+// CreateOutlineNode inserts a new node into the outline structure and saves the outline as a new version
+func (s *OutlineService) CreateOutlineNode(projectId int64, req define.CreateOutlineNodeRequest) (*define.OutlineTreeResponse, error) {
+	if strings.ContainsAny(req.Title, "\r\n") {
+		return nil, fmt.Errorf("标题不能包含换行")
+	}
+
+	current, roots, err := s.loadOutlineTree(projectId)
+	if err != nil {
+		return nil, err
+	}
+	schemes := util.DetectOutlineNumbering(roots)
+
+	container := &roots
+	parentLevel := 0
+	if req.ParentId != 0 {
+		parent, _, _ := locateSection(&roots, req.ParentId)
+		if parent == nil {
+			return nil, fmt.Errorf("父节点不存在")
+		}
+		container = &parent.Children
+		parentLevel = parent.Level
+	}
+
+	level, marker := newSectionStyle(roots, req.Kind, parentLevel)
+	section := &util.OutlineSection{
+		Kind:   req.Kind,
+		Level:  level,
+		Marker: marker,
+		Title:  req.Title,
+		Body:   util.NormalizeOutlineBody(req.Body),
+	}
+	insertSection(container, section, req.Position)
+	util.RenumberOutline(roots, schemes)
+
+	logMsg := fmt.Sprintf("[OutlineService] Creating %s node for project %d", req.Kind, projectId)
+	common.SysLog(logMsg)
+	outline, err := s.saveOutlineTree(projectId, current, roots)
+	if err != nil {
+		return nil, err
+	}
+	return buildOutlineTreeResponse(projectId, outline, roots, section.Id), nil
+}
+
+// UpdateOutlineNode updates the title and/or body of a node
+func (s *OutlineService) UpdateOutlineNode(projectId int64, nodeId int64, req define.UpdateOutlineNodeRequest) (*define.OutlineTreeResponse, error) {
+	current, roots, err := s.loadOutlineTree(projectId)
+	if err != nil {
+		return nil, err
+	}
+	section, _, _ := locateSection(&roots, nodeId)
+	if section == nil {
+		return nil, fmt.Errorf("节点不存在")
+	}
+
+	if req.Title != nil {
+		if section.Kind == util.OutlineKindPreamble {
+			return nil, fmt.Errorf("开头部分没有标题")
+		}
+		if strings.ContainsAny(*req.Title, "\r\n") {
+			return nil, fmt.Errorf("标题不能包含换行")
+		}
+		section.Title = *req.Title
+	}
+	if req.Body != nil {
+		section.Body = util.NormalizeOutlineBody(*req.Body)
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Updating node %d for project %d", nodeId, projectId)
+	common.SysLog(logMsg)
+	outline, err := s.saveOutlineTree(projectId, current, roots)
+	if err != nil {
+		return nil, err
+	}
+	return buildOutlineTreeResponse(projectId, outline, roots, section.Id), nil
+}
+
+// DeleteOutlineNode removes a node together with all of its children
+func (s *OutlineService) DeleteOutlineNode(projectId int64, nodeId int64) (*define.OutlineTreeResponse, error) {
+	current, roots, err := s.loadOutlineTree(projectId)
+	if err != nil {
+		return nil, err
+	}
+	schemes := util.DetectOutlineNumbering(roots)
+
+	section, container, index := locateSection(&roots, nodeId)
+	if section == nil {
+		return nil, fmt.Errorf("节点不存在")
+	}
+	*container = append((*container)[:index], (*container)[index+1:]...)
+	if len(roots) == 0 {
+		roots = util.ParseOutline("")
+	}
+	util.RenumberOutline(roots, schemes)
+
+	logMsg := fmt.Sprintf("[OutlineService] Deleting node %d for project %d", nodeId, projectId)
+	common.SysLog(logMsg)
+	outline, err := s.saveOutlineTree(projectId, current, roots)
+	if err != nil {
+		return nil, err
+	}
+	return buildOutlineTreeResponse(projectId, outline, roots, 0), nil
+}
+
+// MoveOutlineNode moves a node (with its children) to a new parent and/or position
+func (s *OutlineService) MoveOutlineNode(projectId int64, nodeId int64, req define.MoveOutlineNodeRequest) (*define.OutlineTreeResponse, error) {
+	current, roots, err := s.loadOutlineTree(projectId)
+	if err != nil {
+		return nil, err
+	}
+	schemes := util.DetectOutlineNumbering(roots)
+
+	section, container, index := locateSection(&roots, nodeId)
+	if section == nil {
+		return nil, fmt.Errorf("节点不存在")
+	}
+	if req.ParentId != 0 {
+		if found, _, _ := locateSection(&section.Children, req.ParentId); found != nil || req.ParentId == nodeId {
+			return nil, fmt.Errorf("不能将节点移动到自身或其子节点下")
+		}
+	}
+	*container = append((*container)[:index], (*container)[index+1:]...)
+
+	target := &roots
+	if req.ParentId != 0 {
+		parent, _, _ := locateSection(&roots, req.ParentId)
+		if parent == nil {
+			return nil, fmt.Errorf("父节点不存在")
+		}
+		target = &parent.Children
+	}
+	insertSection(target, section, req.Position)
+	util.RenumberOutline(roots, schemes)
+
+	logMsg := fmt.Sprintf("[OutlineService] Moving node %d for project %d", nodeId, projectId)
+	common.SysLog(logMsg)
+	outline, err := s.saveOutlineTree(projectId, current, roots)
+	if err != nil {
+		return nil, err
+	}
+	return buildOutlineTreeResponse(projectId, outline, roots, section.Id), nil
+}
+
+// loadOutlineTree 读取大纲结构
+// 结构节点与大纲内容不一致时（整体保存、AI续写、切换分支等），重新解析内容并尽量沿用原有节点ID
+func (s *OutlineService) loadOutlineTree(projectId int64) (*model.Outline, []*util.OutlineSection, error) {
+	outline, err := s.outlineRepo.GetOutlineByProjectId(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get outline for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, nil, err
+	}
+	if outline == nil {
+		return nil, nil, nil
+	}
+
+	nodes, err := s.outlineRepo.GetOutlineNodes(outline.Id)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get outline nodes for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, nil, err
+	}
+	roots := buildOutlineSections(nodes)
+	content := strings.ReplaceAll(outline.Content, "\r\n", "\n")
+	if len(nodes) > 0 && util.RenderOutline(roots) == content {
+		return outline, roots, nil
+	}
+
+	logMsg := fmt.Sprintf("[OutlineService] Rebuilding outline structure for project %d", projectId)
+	common.SysLog(logMsg)
+	parsed := util.ParseOutline(content)
+	util.ReconcileOutlineIds(roots, parsed)
+	if err := s.outlineRepo.SaveOutlineNodes(outline.Id, parsed); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to save outline nodes for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, nil, err
+	}
+	return outline, parsed, nil
+}
+
+// saveOutlineTree 将修改后的节点树写回大纲内容（在当前分支创建新版本），并同步结构节点
+// current为读取节点树时的大纲，期间大纲被其他请求修改时拒绝保存
+func (s *OutlineService) saveOutlineTree(projectId int64, current *model.Outline, roots []*util.OutlineSection) (*model.Outline, error) {
+	content := util.RenderOutline(roots)
+	// 渲染后重新解析必须得到相同的结构，否则说明层级不合法，例如章节挂在情节点下，或正文中包含标题行
+	if !util.EqualOutline(util.ParseOutline(content), roots) {
+		return nil, fmt.Errorf("节点层级不合法，请检查标题层级以及正文中是否包含标题行")
+	}
+
+	baseVersion := 0
+	if current != nil {
+		baseVersion = current.CurrentVersion
+	}
+	outline, err := s.outlineRepo.SaveOutlineFromBase(projectId, baseVersion, content, false, 0, 0, 0)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to save outline content for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if err := s.outlineRepo.SaveOutlineNodes(outline.Id, roots); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to save outline nodes for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	return outline, nil
+}
+
+// buildOutlineSections 将数据库中的节点组装为节点树
+func buildOutlineSections(nodes []*model.OutlineNode) []*util.OutlineSection {
+	children := make(map[int64][]*model.OutlineNode)
+	for _, node := range nodes {
+		children[node.ParentId] = append(children[node.ParentId], node)
+	}
+
+	var build func(parentId int64) []*util.OutlineSection
+	build = func(parentId int64) []*util.OutlineSection {
+		list := children[parentId]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].SortOrder < list[j].SortOrder })
+		sections := make([]*util.OutlineSection, 0, len(list))
+		for _, node := range list {
+			sections = append(sections, &util.OutlineSection{
+				Id:       node.Id,
+				Kind:     node.Kind,
+				Level:    node.Level,
+				Marker:   node.Marker,
+				Title:    node.Title,
+				Body:     node.Body,
+				Children: build(node.Id),
+			})
+		}
+		return sections
+	}
+	return build(0)
+}
+
+func buildOutlineTreeResponse(projectId int64, outline *model.Outline, roots []*util.OutlineSection, nodeId int64) *define.OutlineTreeResponse {
+	response := &define.OutlineTreeResponse{
+		ProjectId:     projectId,
+		CurrentBranch: model.DefaultBranchName,
+		NodeId:        nodeId,
+		Nodes:         toOutlineNodeInfos(roots, 0),
+	}
+	if outline != nil {
+		response.OutlineId = outline.Id
+		response.CurrentVersion = outline.CurrentVersion
+		response.CurrentBranch = outline.CurrentBranch
+	}
+	return response
+}
+
+func toOutlineNodeInfos(sections []*util.OutlineSection, parentId int64) []define.OutlineNodeInfo {
+	infos := make([]define.OutlineNodeInfo, 0, len(sections))
+	for _, section := range sections {
+		infos = append(infos, define.OutlineNodeInfo{
+			Id:       section.Id,
+			ParentId: parentId,
+			Kind:     section.Kind,
+			Level:    section.Level,
+			Heading:  section.Heading(),
+			Title:    section.Title,
+			Body:     section.Body,
+			Children: toOutlineNodeInfos(section.Children, section.Id),
+		})
+	}
+	return infos
+}
+
+// locateSection 查找节点，返回节点、节点所在的同级列表以及在列表中的位置
+func locateSection(sections *[]*util.OutlineSection, id int64) (*util.OutlineSection, *[]*util.OutlineSection, int) {
+	for i, section := range *sections {
+		if section.Id == id {
+			return section, sections, i
+		}
+		if found, container, index := locateSection(&section.Children, id); found != nil {
+			return found, container, index
+		}
+	}
+	return nil, nil, -1
+}
+
+// insertSection 将节点插入同级列表的指定位置，position为空或越界时追加到末尾
+func insertSection(sections *[]*util.OutlineSection, section *util.OutlineSection, position *int) {
+	if position == nil || *position < 0 || *position >= len(*sections) {
+		*sections = append(*sections, section)
+		return
+	}
+	*sections = append(*sections, nil)
+	copy((*sections)[*position+1:], (*sections)[*position:])
+	(*sections)[*position] = section
+}
+
+// newSectionStyle 决定新节点的层级和标题前缀
+// 优先沿用大纲中同类节点的写法（例如“## 第一章 ”），没有可参考的节点时使用默认写法
+func newSectionStyle(roots []*util.OutlineSection, kind string, parentLevel int) (int, string) {
+	level, marker := 0, ""
+	util.WalkOutline(roots, func(section *util.OutlineSection, _ *util.OutlineSection) {
+		if marker == "" && section.Kind == kind && section.Level > parentLevel {
+			level, marker = section.Level, section.Marker
+		}
+	})
+	if marker != "" {
+		return level, marker
+	}
+	level = util.DefaultOutlineLevel(kind, parentLevel)
+	return level, util.DefaultOutlineMarker(kind, level)
+}
+
+// outlineTableOfContents 生成大纲目录（不含开头部分和情节点），用作AI续写的上下文
+func outlineTableOfContents(roots []*util.OutlineSection) string {
+	var lines []string
+	var walk func(sections []*util.OutlineSection, depth int)
+	walk = func(sections []*util.OutlineSection, depth int) {
+		for _, section := range sections {
+			if section.Kind == util.OutlineKindPreamble || section.Kind == util.OutlineKindBeat {
+				continue
+			}
+			lines = append(lines, strings.Repeat("  ", depth)+strings.TrimSpace(section.Heading()))
+			walk(section.Children, depth+1)
+		}
+	}
+	walk(roots, 0)
+	return strings.Join(lines, "\n")
+}
+
+// appendToSection 将文字追加到节点（含子节点）的末尾
+func appendToSection(section *util.OutlineSection, text string) {
+	for len(section.Children) > 0 {
+		section = section.Children[len(section.Children)-1]
+	}
+	body := util.NormalizeOutlineBody(section.Body)
+	if body == "" {
+		section.Body = util.NormalizeOutlineBody(text)
+		return
+	}
+	section.Body = body + "\n" + util.NormalizeOutlineBody(text)
+}
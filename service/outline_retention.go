@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/model"
+	"gin-template/repository"
+	"sort"
+	"sync"
+	"time"
+)
+
+// retentionBatchSize 每批处理的大纲数量
+const retentionBatchSize = 100
+
+var versionRetentionService *VersionRetentionService
+
+// VersionRetentionService 按保留策略定期清理大纲历史版本
+type VersionRetentionService struct {
+	running     bool
+	mutex       sync.Mutex
+	stopChan    chan struct{}
+	interval    time.Duration
+	outlineRepo *repository.OutlineRepository
+}
+
+func NewVersionRetentionService(interval time.Duration, outlineRepo *repository.OutlineRepository) *VersionRetentionService {
+	return &VersionRetentionService{
+		stopChan:    make(chan struct{}),
+		interval:    interval,
+		outlineRepo: outlineRepo,
+	}
+}
+
+// Start 启动定期清理
+func (s *VersionRetentionService) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+
+	go s.retentionLoop()
+	common.SysLog(fmt.Sprintf("[VersionRetention] Started, interval: %v", s.interval))
+}
+
+// Stop 停止定期清理
+func (s *VersionRetentionService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	common.SysLog("[VersionRetention] Stopped")
+}
+
+func (s *VersionRetentionService) retentionLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.ThinAllOutlines()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// ThinAllOutlines applies the retention policy to the version history of every outline
+func (s *VersionRetentionService) ThinAllOutlines() {
+	common.SysLog("[VersionRetention] Starting version thinning")
+	startTime := time.Now()
+
+	var afterId int64
+	outlines, deleted := 0, 0
+	for {
+		ids, err := s.outlineRepo.GetOutlineIdsAfter(afterId, retentionBatchSize)
+		if err != nil {
+			common.SysError(fmt.Sprintf("[VersionRetention] Failed to list outlines after %d: %v", afterId, err))
+			return
+		}
+		for _, id := range ids {
+			count, err := s.ThinOutline(id, time.Now())
+			if err != nil {
+				common.SysError(fmt.Sprintf("[VersionRetention] Failed to thin versions of outline %d: %v", id, err))
+				continue
+			}
+			outlines++
+			deleted += count
+		}
+		if len(ids) < retentionBatchSize {
+			break
+		}
+		afterId = ids[len(ids)-1]
+	}
+
+	common.SysLog(fmt.Sprintf("[VersionRetention] Thinned %d outlines, deleted %d versions, took %v", outlines, deleted, time.Since(startTime)))
+}
+
+// ThinOutline deletes the versions of one outline that fall outside the retention policy
+func (s *VersionRetentionService) ThinOutline(outlineId int64, now time.Time) (int, error) {
+	versions, err := s.outlineRepo.GetVersionGraph(outlineId)
+	if err != nil {
+		return 0, err
+	}
+	ids := selectVersionsToThin(versions, now)
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return s.outlineRepo.ThinVersions(outlineId, ids)
+}
+
+// selectVersionsToThin 按保留策略选出可以删除的版本
+// VersionKeepAllDays天内的版本全部保留；VersionKeepDailyDays天内每个分支每天保留最新的一个，更早的每周保留最新的一个。
+// AI生成和固定的版本不参与清理，分支头等结构上需要的版本由仓储层另外保留
+func selectVersionsToThin(versions []*model.Version, now time.Time) []int64 {
+	keepAllSince := now.AddDate(0, 0, -common.VersionKeepAllDays).Unix()
+	keepDailySince := now.AddDate(0, 0, -common.VersionKeepDailyDays).Unix()
+
+	sorted := make([]*model.Version, len(versions))
+	copy(sorted, versions)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].VersionNumber > sorted[j].VersionNumber
+	})
+
+	kept := make(map[string]bool)
+	var ids []int64
+	for _, version := range sorted {
+		if version.Pinned || version.IsAiGenerated || version.CreatedAt >= keepAllSince {
+			continue
+		}
+		created := time.Unix(version.CreatedAt, 0)
+		var bucket string
+		if version.CreatedAt >= keepDailySince {
+			bucket = created.Format("2006-01-02")
+		} else {
+			year, week := created.ISOWeek()
+			bucket = fmt.Sprintf("%d-W%02d", year, week)
+		}
+		// 从新到旧遍历，每个分支的每个时间段保留遇到的第一个（最新的）版本
+		key := version.BranchName + "/" + bucket
+		if kept[key] {
+			ids = append(ids, version.Id)
+			continue
+		}
+		kept[key] = true
+	}
+	return ids
+}
+
+// InitVersionRetentionService 初始化并启动历史版本清理任务，VersionRetentionInterval为0时不启动
+func InitVersionRetentionService(outlineRepo *repository.OutlineRepository) {
+	if common.VersionRetentionInterval <= 0 {
+		common.SysLog("[VersionRetention] Disabled, VERSION_RETENTION_INTERVAL is 0")
+		return
+	}
+
+	interval := time.Duration(common.VersionRetentionInterval) * time.Hour
+	versionRetentionService = NewVersionRetentionService(interval, outlineRepo)
+	versionRetentionService.Start()
+	common.SysLog(fmt.Sprintf("[VersionRetention] Keep all versions for %d days, daily versions for %d days, weekly versions afterwards",
+		common.VersionKeepAllDays, common.VersionKeepDailyDays))
+}
+
+// StopVersionRetentionService 停止历史版本清理任务
+func StopVersionRetentionService() {
+	if versionRetentionService != nil {
+		versionRetentionService.Stop()
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"io"
+	"sync"
+	"time"
+)
+
+// aiStream 正在进行的流式续写
+type aiStream struct {
+	userId    int64
+	projectId int64
+	cancel    context.CancelFunc
+}
+
+var (
+	aiStreamsMutex sync.Mutex
+	aiStreams      = make(map[string]*aiStream)
+)
+
+// StreamOutlineWithAI continues the outline like GenerateOutlineWithAI, passing the generated text to onDelta as it arrives.
+// The version is saved and tokens are deducted only after the stream completes. When ctx is cancelled or CancelAIStream is
+// called, the upstream request is stopped, nothing is saved and only the tokens consumed so far are deducted. When the
+// stream fails after content was already delivered, the tokens consumed so far are deducted as well; the hold is released
+// only if nothing was sent.
+func (s *OutlineService) StreamOutlineWithAI(ctx context.Context, streamId string, userId int64, projectId int64, req define.AIGenerateRequest, onDelta func(delta string)) (map[string]interface{}, error) {
+	logMsg := fmt.Sprintf("[OutlineService] Starting streaming AI outline generation %s for project %d", streamId, projectId)
+	common.SysLog(logMsg)
+
+	generation, err := s.prepareAIGeneration(userId, projectId, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reserveAITokens(userId, projectId, generation); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	registerAIStream(streamId, &aiStream{userId: userId, projectId: projectId, cancel: cancel})
+	defer removeAIStream(streamId)
+
+	stream, err := StreamAICompletion(ctx, generation.request)
+	if err != nil {
+		s.releaseAITokens(generation)
+		logMsg := fmt.Sprintf("[OutlineService] AI generation failed: %v", err)
+		common.SysError(logMsg)
+		return nil, fmt.Errorf(logMsg)
+	}
+	defer stream.Close()
+
+	// 流式续写可能超过预扣的有效期，接收过程中定期延长预扣，避免结算时预扣已过期
+	renewAt := time.Now().Add(tokenHoldDuration() / 2)
+	delivered := false
+	for {
+		if time.Now().After(renewAt) {
+			_ = GetTokenService().ExtendToken(generation.holdUUID)
+			renewAt = time.Now().Add(tokenHoldDuration() / 2)
+		}
+		delta, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return s.cancelAIGeneration(streamId, userId, projectId, generation, stream)
+			}
+			logMsg := fmt.Sprintf("[OutlineService] Streaming AI generation %s failed: %v", streamId, err)
+			common.SysError(logMsg)
+			if !delivered {
+				s.releaseAITokens(generation)
+			} else if _, _, settleErr := s.settleAIStreamUsage(userId, projectId, generation, stream, "failed"); settleErr != nil {
+				return nil, settleErr
+			}
+			return nil, fmt.Errorf("[OutlineService] AI generation failed: %v", err)
+		}
+		if delta != "" {
+			delivered = true
+			onDelta(delta)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return s.cancelAIGeneration(streamId, userId, projectId, generation, stream)
+	}
+
+	return s.finishAIGeneration(userId, projectId, generation, stream.Response())
+}
+
+// CancelAIStream stops a streaming AI generation started by the user for the project.
+// Running streams are tracked in a process-local map, so the cancel request must reach the instance serving the stream;
+// with multiple instances, route /ai/generate/{id}/stream and /ai/generate/{id}/cancel with session affinity.
+// Closing the SSE connection stops the stream on any deployment.
+func (s *OutlineService) CancelAIStream(userId int64, projectId int64, streamId string) error {
+	aiStreamsMutex.Lock()
+	defer aiStreamsMutex.Unlock()
+
+	stream, ok := aiStreams[streamId]
+	if !ok || stream.userId != userId || stream.projectId != projectId {
+		return fmt.Errorf("续写任务不存在或已结束")
+	}
+	stream.cancel()
+	return nil
+}
+
+// cancelAIGeneration 续写被取消时不保存内容，预扣只按已消耗的token结算
+func (s *OutlineService) cancelAIGeneration(streamId string, userId int64, projectId int64, generation *aiGeneration, stream *AICompletionStream) (map[string]interface{}, error) {
+	response := stream.Response()
+	logMsg := fmt.Sprintf("[OutlineService] Streaming AI generation %s for project %d cancelled, Tokens used: %d", streamId, projectId, response.TokensUsed)
+	common.SysLog(logMsg)
+
+	charge, userToken, err := s.settleAIStreamUsage(userId, projectId, generation, stream, "cancelled")
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"content":        response.Content,
+		"tokens_used":    response.TokensUsed,
+		"tokens_charged": charge.Amount,
+		"cached":         response.Cached,
+		"token_balance":  userToken.Balance,
+		"saved":          false,
+		"cancelled":      true,
+	}, nil
+}
+
+// settleAIStreamUsage 流式续写未正常完成时按已消耗的token结算预扣，不保存内容
+func (s *OutlineService) settleAIStreamUsage(userId int64, projectId int64, generation *aiGeneration, stream *AICompletionStream, reason string) (define.AICharge, *model.UserToken, error) {
+	charge := GetPricingService().ChargeResponse(userId, stream.Response())
+	description := chargeDescription(fmt.Sprintf("AI outline continuation for project [%d] (%s)", projectId, reason), charge)
+	userToken, err := settleAITokens(generation.holdUUID, charge.Amount, "ai_generation_debit", description)
+	return charge, userToken, err
+}
+
+func registerAIStream(streamId string, stream *aiStream) {
+	aiStreamsMutex.Lock()
+	defer aiStreamsMutex.Unlock()
+	aiStreams[streamId] = stream
+}
+
+func removeAIStream(streamId string) {
+	aiStreamsMutex.Lock()
+	defer aiStreamsMutex.Unlock()
+	delete(aiStreams, streamId)
+}
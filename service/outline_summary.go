@@ -0,0 +1,513 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/util"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// summaryBatchSize 每次刷新的大纲数量
+	summaryBatchSize = 20
+	// summarySourceChars 直接摘要的最大原文字数，更长且有下级节点的节点由下级节点的摘要汇总
+	summarySourceChars = 6000
+	// summaryChunkChars 没有结构的大纲按此字数分段摘要
+	summaryChunkChars = 2000
+	// 摘要的最大字数
+	sectionSummaryChars  = 300
+	overviewSummaryChars = 800
+)
+
+// summarySection 参与摘要的大纲片段
+type summarySection struct {
+	path     string
+	title    string
+	level    int
+	text     string // 片段原文，包括下级节点
+	body     string // 标题之后、下级节点之前的正文
+	start    int    // 片段在整份大纲中的起始位置（字数）
+	children []*summarySection
+}
+
+// splitSummarySections 按大纲结构切分参与摘要的片段，过长的节点继续按下级节点切分；没有结构的大纲按字数分段
+func splitSummarySections(content string) []*summarySection {
+	roots := util.ParseOutline(content)
+	if len(roots) == 1 && roots[0].Kind == util.OutlineKindPreamble {
+		return chunkSummarySections(content)
+	}
+
+	var sections []*summarySection
+	names := make(map[string]int)
+	offset := 0
+	for _, root := range roots {
+		section := newSummarySection(root, "", 1, names)
+		section.start = offset
+		offset += utf8.RuneCountInString(section.text) + 1
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+func newSummarySection(node *util.OutlineSection, parentPath string, level int, names map[string]int) *summarySection {
+	title := strings.TrimSpace(node.Heading())
+	if node.Kind == util.OutlineKindPreamble {
+		title = "前言"
+	}
+	path := parentPath + "/" + title
+	names[path]++
+	if n := names[path]; n > 1 {
+		// 同名节点按出现顺序区分
+		path = fmt.Sprintf("%s#%d", path, n)
+	}
+
+	section := &summarySection{
+		path:  path,
+		title: title,
+		level: level,
+		text:  util.RenderOutline([]*util.OutlineSection{node}),
+		body:  node.Body,
+	}
+	if utf8.RuneCountInString(section.text) > summarySourceChars {
+		for _, child := range node.Children {
+			if child.Kind == util.OutlineKindBeat {
+				continue
+			}
+			section.children = append(section.children, newSummarySection(child, path, level+1, names))
+		}
+	}
+	return section
+}
+
+// chunkSummarySections 按行将没有结构的大纲切分为字数相近的片段
+func chunkSummarySections(content string) []*summarySection {
+	var sections []*summarySection
+	var lines []string
+	offset, size := 0, 0
+	flush := func() {
+		if len(lines) == 0 {
+			return
+		}
+		text := strings.Join(lines, "\n")
+		title := fmt.Sprintf("第%d段", len(sections)+1)
+		sections = append(sections, &summarySection{path: "/" + title, title: title, level: 1, text: text, body: text, start: offset})
+		offset += utf8.RuneCountInString(text) + 1
+		lines, size = nil, 0
+	}
+	for _, line := range util.SplitLines(content) {
+		lines = append(lines, line)
+		size += utf8.RuneCountInString(line) + 1
+		if size >= summaryChunkChars {
+			flush()
+		}
+	}
+	flush()
+	return sections
+}
+
+// recentWindowStart 续写时原样发送的末尾内容的起始位置（字数），从该位置所在行的下一行开始
+func recentWindowStart(content string) int {
+	runes := []rune(content)
+	start := len(runes) - common.AIContextRecentChars
+	if start <= 0 {
+		return 0
+	}
+	for i := start; i < len(runes); i++ {
+		if runes[i-1] == '\n' {
+			return i
+		}
+	}
+	return start
+}
+
+// summaryRefresh 一次摘要刷新的过程
+type summaryRefresh struct {
+	service   *OutlineService
+	userId    int64
+	projectId int64
+	existing  map[string]*model.OutlineSummary
+	results   []*model.OutlineSummary
+	useAI     bool
+}
+
+// summarize 返回片段的摘要，原文没有变化时沿用已有的摘要
+func (r *summaryRefresh) summarize(section *summarySection) string {
+	hash := summaryHash(section.text)
+	row := r.existing[section.path]
+	if row == nil {
+		row = &model.OutlineSummary{ProjectId: r.projectId, Path: section.path}
+	}
+	row.Level, row.Title = section.level, section.title
+	row.SortOrder = len(r.results) + 1
+	r.results = append(r.results, row)
+
+	if row.Id != 0 && (row.SourceHash == hash || row.Edited) {
+		if row.Edited {
+			// 作者修改摘要时记录的是当时的原文，之后原文变化时标记为过时
+			if row.SourceHash == "" {
+				row.SourceHash = hash
+			}
+			row.Stale = row.SourceHash != hash
+		}
+		r.keepChildren(section)
+		return row.Summary
+	}
+
+	input := truncateRunes(section.text, summarySourceChars)
+	if len(section.children) > 0 {
+		parts := []string{section.title}
+		if body := strings.TrimSpace(section.body); body != "" {
+			parts = append(parts, truncateRunes(body, summaryChunkChars))
+		}
+		for _, child := range section.children {
+			parts = append(parts, child.title+"："+r.summarize(child))
+		}
+		input = strings.Join(parts, "\n")
+	}
+	row.Summary = r.generate(section.title, input, sectionSummaryChars)
+	row.SourceHash = hash
+	row.Stale = false
+	return row.Summary
+}
+
+// keepChildren 沿用节点的摘要时保留其下级节点已有的摘要
+func (r *summaryRefresh) keepChildren(section *summarySection) {
+	for _, child := range section.children {
+		if row := r.existing[child.path]; row != nil {
+			row.SortOrder = len(r.results) + 1
+			r.results = append(r.results, row)
+			r.keepChildren(child)
+		}
+	}
+}
+
+// overview 由顶层节点的摘要生成整份大纲的概要
+func (r *summaryRefresh) overview(sectionSummaries []string, version int) {
+	input := strings.Join(sectionSummaries, "\n")
+	hash := summaryHash(input)
+	row := r.existing[""]
+	if row == nil {
+		row = &model.OutlineSummary{ProjectId: r.projectId}
+	}
+	row.SourceVersion = version
+	r.results = append(r.results, row)
+
+	switch {
+	case row.Edited:
+		if row.SourceHash == "" {
+			row.SourceHash = hash
+		}
+		row.Stale = row.SourceHash != hash
+	case input == "":
+		row.Summary, row.SourceHash = "", hash
+	case row.Id == 0 || row.SourceHash != hash:
+		row.Summary = r.generate("", input, overviewSummaryChars)
+		row.SourceHash = hash
+	}
+}
+
+// generate 调用模型生成摘要，余额不足或调用失败时本次刷新的其余部分改为摘录原文
+func (r *summaryRefresh) generate(title string, input string, maxChars int) string {
+	if r.useAI {
+		summary, err := r.service.generateSummary(r.userId, r.projectId, title, input, maxChars)
+		if err == nil && summary != "" {
+			return summary
+		}
+		logMsg := fmt.Sprintf("[OutlineService] Failed to generate summary for project %d, falling back to excerpts: %v", r.projectId, err)
+		common.SysError(logMsg)
+		r.useAI = false
+	}
+	return extractSummary(input, maxChars)
+}
+
+// generateSummary calls the model to summarize a part of the outline, billed to the project owner like a continuation
+func (s *OutlineService) generateSummary(userId int64, projectId int64, title string, input string, maxChars int) (string, error) {
+	request := define.GenerateAIPromptRequest{
+		SystemPrompt: RenderPrompt(s.promptRepo, PromptSummarySystem, nil),
+		UserPrompt: RenderPrompt(s.promptRepo, PromptSummaryUser, map[string]string{
+			"title":     title,
+			"content":   input,
+			"max_chars": strconv.Itoa(maxChars),
+		}),
+		MaxTokens:   maxChars * 2,
+		Temperature: 0.3,
+		UserId:      userId,
+		ProjectId:   projectId,
+		Source:      define.AISourceSummary,
+	}
+
+	holdUUID := util.GetUUIDGenerator().Generate(util.BusinessAIWriting)
+	description := fmt.Sprintf("AI outline summary for project [%d]", projectId)
+	if _, err := GetTokenService().ReserveToken(userId, GetPricingService().Estimate(userId, request), holdUUID, description, "project", strconv.FormatInt(projectId, 10)); err != nil {
+		return "", err
+	}
+	// 摘要是项目共享的数据，由后台任务和立即刷新接口共用；不跟随请求取消，避免客户端断开后本次刷新余下的章节都改为摘录原文
+	response, err := GenerateAICompletion(context.Background(), request)
+	if err != nil {
+		_ = GetTokenService().ReleaseToken(holdUUID)
+		return "", err
+	}
+	// 扣除失败时不使用生成的摘要
+	charge := GetPricingService().ChargeResponse(userId, response)
+	if _, err := settleAITokens(holdUUID, charge.Amount, "ai_summary_debit", chargeDescription(description, charge)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response.Content), nil
+}
+
+// refreshingSummaries 正在刷新摘要的项目，同一项目同时只有一次刷新
+var refreshingSummaries sync.Map
+
+// refreshOutlineSummaries regenerates the rolling summaries of sections whose text changed.
+// Sections that lie entirely within the recent part sent verbatim are not summarized.
+func (s *OutlineService) refreshOutlineSummaries(userId int64, projectId int64) error {
+	if _, running := refreshingSummaries.LoadOrStore(projectId, true); running {
+		return fmt.Errorf("摘要正在刷新，请稍后再试")
+	}
+	defer refreshingSummaries.Delete(projectId)
+
+	outline, err := s.outlineRepo.GetOutlineByProjectId(projectId)
+	if err != nil {
+		return err
+	}
+	if outline == nil {
+		return fmt.Errorf("大纲不存在")
+	}
+	existing, err := s.summaryRepo.GetSummaries(projectId)
+	if err != nil {
+		return err
+	}
+
+	refresh := &summaryRefresh{
+		service:   s,
+		userId:    userId,
+		projectId: projectId,
+		existing:  make(map[string]*model.OutlineSummary, len(existing)),
+		useAI:     true,
+	}
+	for _, summary := range existing {
+		refresh.existing[summary.Path] = summary
+	}
+
+	windowStart := recentWindowStart(outline.Content)
+	var sectionSummaries []string
+	for _, section := range splitSummarySections(outline.Content) {
+		if section.start >= windowStart {
+			break
+		}
+		sectionSummaries = append(sectionSummaries, section.title+"："+refresh.summarize(section))
+	}
+	refresh.overview(sectionSummaries, outline.CurrentVersion)
+
+	if err := s.summaryRepo.ReplaceSummaries(projectId, refresh.results); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to save summaries for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return err
+	}
+	return nil
+}
+
+// GetOutlineSummaries returns the rolling summaries of the outline, the overview first and the sections in outline order
+func (s *OutlineService) GetOutlineSummaries(projectId int64) ([]define.OutlineSummary, error) {
+	summaries, err := s.summaryRepo.GetSummaries(projectId)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get summaries for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	result := make([]define.OutlineSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, toOutlineSummary(summary))
+	}
+	return result, nil
+}
+
+// UpdateOutlineSummary lets the author rewrite a summary, which is then never overwritten automatically.
+// An empty summary hands it back to automatic generation at the next refresh.
+func (s *OutlineService) UpdateOutlineSummary(projectId int64, summaryId int64, req define.UpdateOutlineSummaryRequest) (*define.OutlineSummary, error) {
+	summary, err := s.summaryRepo.GetSummaryById(summaryId)
+	if err != nil {
+		return nil, err
+	}
+	if summary == nil || summary.ProjectId != projectId {
+		return nil, fmt.Errorf("摘要不存在")
+	}
+
+	text := strings.TrimSpace(req.Summary)
+	summary.Edited = text != ""
+	summary.Stale = false
+	// 清空哈希：作者修改的摘要在下次刷新时记录当时的原文，自动生成的摘要在下次刷新时重新生成
+	summary.SourceHash = ""
+	if summary.Edited {
+		summary.Summary = text
+	}
+	if err := s.summaryRepo.UpdateSummary(summary); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to update summary %d for project %d: %v", summaryId, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if err := s.summaryRepo.InvalidateSummaries(projectId); err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to schedule summary refresh for project %d: %v", projectId, err)
+		common.SysError(logMsg)
+	}
+
+	result := toOutlineSummary(summary)
+	return &result, nil
+}
+
+// RefreshOutlineSummaries refreshes the summaries right away instead of waiting for the background task
+func (s *OutlineService) RefreshOutlineSummaries(userId int64, projectId int64) ([]define.OutlineSummary, error) {
+	if err := s.refreshOutlineSummaries(userId, projectId); err != nil {
+		return nil, err
+	}
+	return s.GetOutlineSummaries(projectId)
+}
+
+func toOutlineSummary(summary *model.OutlineSummary) define.OutlineSummary {
+	return define.OutlineSummary{
+		Id:            summary.Id,
+		Path:          summary.Path,
+		Level:         summary.Level,
+		Title:         summary.Title,
+		Summary:       summary.Summary,
+		SourceVersion: summary.SourceVersion,
+		Edited:        summary.Edited,
+		Stale:         summary.Stale,
+		UpdatedAt:     summary.UpdatedAt,
+	}
+}
+
+// extractSummary 不调用模型时摘录原文的开头作为摘要
+func extractSummary(text string, maxChars int) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return truncateRunes(strings.Join(lines, " "), maxChars)
+}
+
+// truncateRunes 截断到maxChars个字符，截断时以省略号结尾
+func truncateRunes(text string, maxChars int) string {
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars]) + "…"
+}
+
+func summaryHash(text string) string {
+	sum := sha1.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+var outlineSummaryService *OutlineSummaryService
+
+// OutlineSummaryService 定期为有新版本提交的长大纲刷新滚动摘要
+type OutlineSummaryService struct {
+	running        bool
+	mutex          sync.Mutex
+	stopChan       chan struct{}
+	interval       time.Duration
+	outlineService *OutlineService
+}
+
+func NewOutlineSummaryService(interval time.Duration, outlineService *OutlineService) *OutlineSummaryService {
+	return &OutlineSummaryService{
+		stopChan:       make(chan struct{}),
+		interval:       interval,
+		outlineService: outlineService,
+	}
+}
+
+// Start 启动摘要刷新任务
+func (s *OutlineSummaryService) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+
+	go s.refreshLoop()
+	common.SysLog(fmt.Sprintf("[OutlineSummary] Started, interval: %v", s.interval))
+}
+
+// Stop 停止摘要刷新任务
+func (s *OutlineSummaryService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	common.SysLog("[OutlineSummary] Stopped")
+}
+
+func (s *OutlineSummaryService) refreshLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.RefreshAll()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// RefreshAll refreshes the summaries of a batch of long outlines that have new versions since their last refresh
+func (s *OutlineSummaryService) RefreshAll() {
+	targets, err := s.outlineService.summaryRepo.GetOutlinesToSummarize(common.AIContextRecentChars, summaryBatchSize)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[OutlineSummary] Failed to get outlines to summarize: %v", err))
+		return
+	}
+
+	startTime := time.Now()
+	refreshed := 0
+	for _, target := range targets {
+		if err := s.outlineService.refreshOutlineSummaries(target.UserId, target.ProjectId); err != nil {
+			common.SysError(fmt.Sprintf("[OutlineSummary] Failed to refresh summaries for project %d: %v", target.ProjectId, err))
+			continue
+		}
+		refreshed++
+	}
+	if refreshed > 0 {
+		common.SysLog(fmt.Sprintf("[OutlineSummary] Refreshed summaries of %d outlines, took %v", refreshed, time.Since(startTime)))
+	}
+}
+
+// InitOutlineSummaryService 初始化并启动摘要刷新任务，OutlineSummaryInterval为0时不启动
+func InitOutlineSummaryService(outlineService *OutlineService) {
+	if common.OutlineSummaryInterval <= 0 {
+		common.SysLog("[OutlineSummary] Disabled, OUTLINE_SUMMARY_INTERVAL is 0")
+		return
+	}
+
+	outlineSummaryService = NewOutlineSummaryService(time.Duration(common.OutlineSummaryInterval)*time.Second, outlineService)
+	outlineSummaryService.Start()
+}
+
+// StopOutlineSummaryService 停止摘要刷新任务
+func StopOutlineSummaryService() {
+	if outlineSummaryService != nil {
+		outlineSummaryService.Stop()
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/util"
+	"strings"
+	"unicode/utf8"
+)
+
+// DiffVersions compares two versions of a project's outline
+func (s *OutlineService) DiffVersions(projectId int64, fromVersion int, toVersion int, changesOnly bool) (*define.VersionDiffResponse, error) {
+	logMsg := fmt.Sprintf("[OutlineService] Comparing versions %d and %d for project %d", fromVersion, toVersion, projectId)
+	common.SysLog(logMsg)
+
+	from, err := s.outlineRepo.GetVersionByNumber(projectId, fromVersion)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get version %d for project %d: %v", fromVersion, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	to, err := s.outlineRepo.GetVersionByNumber(projectId, toVersion)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to get version %d for project %d: %v", toVersion, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if from == nil || to == nil {
+		return nil, fmt.Errorf("版本不存在")
+	}
+
+	paragraphs, stats := diffParagraphs(from.Content, to.Content, changesOnly)
+	return &define.VersionDiffResponse{
+		From:       toVersionBrief(from),
+		To:         toVersionBrief(to),
+		Paragraphs: paragraphs,
+		Stats:      stats,
+	}, nil
+}
+
+func toVersionBrief(v *model.Version) define.VersionBrief {
+	return define.VersionBrief{
+		VersionNumber: v.VersionNumber,
+		IsAiGenerated: v.IsAiGenerated,
+		AiStyle:       v.AiStyle,
+		StylePresetId: v.StylePresetId,
+		TokensUsed:    v.TokensUsed,
+		BranchName:    v.BranchName,
+		Pinned:        v.Pinned,
+		CreatedAt:     v.CreatedAt,
+
+		RestoredFromVersion: v.RestoredFromVersion,
+	}
+}
+
+// RestoreVersion restores a previous version as the new current version
+func (s *OutlineService) RestoreVersion(projectId int64, versionNumber int) (*define.RestoreVersionResponse, error) {
+	logMsg := fmt.Sprintf("[OutlineService] Restoring version %d for project %d", versionNumber, projectId)
+	common.SysLog(logMsg)
+
+	outline, version, err := s.outlineRepo.RestoreVersion(projectId, versionNumber)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to restore version %d for project %d: %v", versionNumber, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if outline == nil {
+		return nil, fmt.Errorf("版本不存在")
+	}
+
+	logMsg = fmt.Sprintf("[OutlineService] Restored version %d for project %d as version %d", versionNumber, projectId, version.VersionNumber)
+	common.SysLog(logMsg)
+	return &define.RestoreVersionResponse{
+		OutlineId:      outline.Id,
+		ProjectId:      outline.ProjectId,
+		Content:        outline.Content,
+		CurrentVersion: outline.CurrentVersion,
+		Version:        toVersionBrief(version),
+	}, nil
+}
+
+// PinVersion pins or unpins a version, pinned versions are never removed by the retention policy
+func (s *OutlineService) PinVersion(projectId int64, versionNumber int, pinned bool) (*define.VersionBrief, error) {
+	logMsg := fmt.Sprintf("[OutlineService] Setting pinned=%t on version %d for project %d", pinned, versionNumber, projectId)
+	common.SysLog(logMsg)
+
+	version, err := s.outlineRepo.SetVersionPinned(projectId, versionNumber, pinned)
+	if err != nil {
+		logMsg := fmt.Sprintf("[OutlineService] Failed to pin version %d for project %d: %v", versionNumber, projectId, err)
+		common.SysError(logMsg)
+		return nil, err
+	}
+	if version == nil {
+		return nil, fmt.Errorf("版本不存在")
+	}
+
+	brief := toVersionBrief(version)
+	return &brief, nil
+}
+
+// diffParagraphs 先按段落（行）比较，再对成对修改的段落做字符级比较
+func diffParagraphs(oldText string, newText string, changesOnly bool) ([]define.ParagraphDiff, define.DiffStats) {
+	oldLines := util.SplitLines(oldText)
+	newLines := util.SplitLines(newText)
+	ops := util.Diff(oldLines, newLines)
+
+	paragraphs := make([]define.ParagraphDiff, 0, len(ops))
+	var stats define.DiffStats
+
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		switch op.Type {
+		case util.DiffEqual:
+			if changesOnly {
+				continue
+			}
+			for k := 0; k < op.AEnd-op.AStart; k++ {
+				paragraphs = append(paragraphs, define.ParagraphDiff{
+					Type:     string(util.DiffEqual),
+					OldIndex: op.AStart + k,
+					NewIndex: op.BStart + k,
+					NewText:  newLines[op.BStart+k],
+				})
+			}
+		case util.DiffDelete:
+			// 删除紧跟插入时，按位置两两配对视为修改
+			var insert *util.DiffOp
+			if i+1 < len(ops) && ops[i+1].Type == util.DiffInsert {
+				insert = &ops[i+1]
+				i++
+			}
+			deleted := op.AEnd - op.AStart
+			inserted := 0
+			if insert != nil {
+				inserted = insert.BEnd - insert.BStart
+			}
+			for k := 0; k < deleted || k < inserted; k++ {
+				switch {
+				case k < deleted && k < inserted:
+					oldLine := oldLines[op.AStart+k]
+					newLine := newLines[insert.BStart+k]
+					segments, added, removed := diffChars(oldLine, newLine)
+					paragraphs = append(paragraphs, define.ParagraphDiff{
+						Type:     "modify",
+						OldIndex: op.AStart + k,
+						NewIndex: insert.BStart + k,
+						OldText:  oldLine,
+						NewText:  newLine,
+						Segments: segments,
+					})
+					stats.ParagraphsModified++
+					stats.CharsAdded += added
+					stats.CharsRemoved += removed
+				case k < deleted:
+					oldLine := oldLines[op.AStart+k]
+					paragraphs = append(paragraphs, define.ParagraphDiff{
+						Type:     string(util.DiffDelete),
+						OldIndex: op.AStart + k,
+						NewIndex: -1,
+						OldText:  oldLine,
+					})
+					stats.ParagraphsRemoved++
+					stats.CharsRemoved += utf8.RuneCountInString(oldLine)
+				default:
+					newLine := newLines[insert.BStart+k]
+					paragraphs = append(paragraphs, define.ParagraphDiff{
+						Type:     string(util.DiffInsert),
+						OldIndex: -1,
+						NewIndex: insert.BStart + k,
+						NewText:  newLine,
+					})
+					stats.ParagraphsAdded++
+					stats.CharsAdded += utf8.RuneCountInString(newLine)
+				}
+			}
+		case util.DiffInsert:
+			for k := op.BStart; k < op.BEnd; k++ {
+				paragraphs = append(paragraphs, define.ParagraphDiff{
+					Type:     string(util.DiffInsert),
+					OldIndex: -1,
+					NewIndex: k,
+					NewText:  newLines[k],
+				})
+				stats.ParagraphsAdded++
+				stats.CharsAdded += utf8.RuneCountInString(newLines[k])
+			}
+		}
+	}
+	return paragraphs, stats
+}
+
+// diffChars 计算段落内的字符级差异，返回差异片段以及新增、删除的字符数
+func diffChars(oldText string, newText string) ([]define.DiffSegment, int, int) {
+	oldTokens := util.TokenizeText(oldText)
+	newTokens := util.TokenizeText(newText)
+	ops := util.Diff(oldTokens, newTokens)
+
+	segments := make([]define.DiffSegment, 0, len(ops))
+	added, removed := 0, 0
+	for _, op := range ops {
+		var text string
+		switch op.Type {
+		case util.DiffEqual, util.DiffInsert:
+			text = strings.Join(newTokens[op.BStart:op.BEnd], "")
+		case util.DiffDelete:
+			text = strings.Join(oldTokens[op.AStart:op.AEnd], "")
+		}
+		switch op.Type {
+		case util.DiffInsert:
+			added += utf8.RuneCountInString(text)
+		case util.DiffDelete:
+			removed += utf8.RuneCountInString(text)
+		}
+		segments = append(segments, define.DiffSegment{Type: string(op.Type), Text: text})
+	}
+	return segments, added, removed
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"gin-template/define"
+	"reflect"
+	"testing"
+)
+
+func TestDiffChars(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		segments []define.DiffSegment
+		added    int
+		removed  int
+	}{
+		{
+			name:     "相同",
+			old:      "主角出发",
+			new:      "主角出发",
+			segments: []define.DiffSegment{{Type: "equal", Text: "主角出发"}},
+		},
+		{
+			name:     "插入中文",
+			old:      "主角出发",
+			new:      "主角在雨夜出发",
+			segments: []define.DiffSegment{{Type: "equal", Text: "主角"}, {Type: "insert", Text: "在雨夜"}, {Type: "equal", Text: "出发"}},
+			added:    3,
+		},
+		{
+			name:     "替换中文",
+			old:      "他回到小镇",
+			new:      "她回到小镇",
+			segments: []define.DiffSegment{{Type: "delete", Text: "他"}, {Type: "insert", Text: "她"}, {Type: "equal", Text: "回到小镇"}},
+			added:    1,
+			removed:  1,
+		},
+		{
+			name:     "英文按词比较",
+			old:      "the old man",
+			new:      "the young man",
+			segments: []define.DiffSegment{{Type: "equal", Text: "the "}, {Type: "delete", Text: "old"}, {Type: "insert", Text: "young"}, {Type: "equal", Text: " man"}},
+			added:    5,
+			removed:  3,
+		},
+		{
+			name:     "清空",
+			old:      "结束",
+			new:      "",
+			segments: []define.DiffSegment{{Type: "delete", Text: "结束"}},
+			removed:  2,
+		},
+		{
+			name:     "都为空",
+			segments: []define.DiffSegment{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments, added, removed := diffChars(tt.old, tt.new)
+			if !reflect.DeepEqual(segments, tt.segments) || added != tt.added || removed != tt.removed {
+				t.Errorf("diffChars(%q, %q) = %+v, %d, %d, want %+v, %d, %d",
+					tt.old, tt.new, segments, added, removed, tt.segments, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestDiffParagraphs(t *testing.T) {
+	tests := []struct {
+		name        string
+		old         string
+		new         string
+		changesOnly bool
+		paragraphs  []define.ParagraphDiff
+		stats       define.DiffStats
+	}{
+		{
+			name: "相同",
+			old:  "第一章\n主角出发",
+			new:  "第一章\n主角出发",
+			paragraphs: []define.ParagraphDiff{
+				{Type: "equal", OldIndex: 0, NewIndex: 0, NewText: "第一章"},
+				{Type: "equal", OldIndex: 1, NewIndex: 1, NewText: "主角出发"},
+			},
+		},
+		{
+			name:        "只返回变化的段落",
+			old:         "第一章\n主角出发",
+			new:         "第一章\n主角出发\n遇到旧友",
+			changesOnly: true,
+			paragraphs: []define.ParagraphDiff{
+				{Type: "insert", OldIndex: -1, NewIndex: 2, NewText: "遇到旧友"},
+			},
+			stats: define.DiffStats{ParagraphsAdded: 1, CharsAdded: 4},
+		},
+		{
+			name:        "修改的段落做字符级比较",
+			old:         "第一章\n主角出发",
+			new:         "第一章\n主角在雨夜出发",
+			changesOnly: true,
+			paragraphs: []define.ParagraphDiff{
+				{Type: "modify", OldIndex: 1, NewIndex: 1, OldText: "主角出发", NewText: "主角在雨夜出发", Segments: []define.DiffSegment{
+					{Type: "equal", Text: "主角"}, {Type: "insert", Text: "在雨夜"}, {Type: "equal", Text: "出发"},
+				}},
+			},
+			stats: define.DiffStats{ParagraphsModified: 1, CharsAdded: 3},
+		},
+		{
+			name:        "删除的段落多于插入时其余记为删除",
+			old:         "开头\n甲\n乙\n结尾",
+			new:         "开头\n丙\n结尾",
+			changesOnly: true,
+			paragraphs: []define.ParagraphDiff{
+				{Type: "modify", OldIndex: 1, NewIndex: 1, OldText: "甲", NewText: "丙", Segments: []define.DiffSegment{
+					{Type: "delete", Text: "甲"}, {Type: "insert", Text: "丙"},
+				}},
+				{Type: "delete", OldIndex: 2, NewIndex: -1, OldText: "乙"},
+			},
+			stats: define.DiffStats{ParagraphsRemoved: 1, ParagraphsModified: 1, CharsAdded: 1, CharsRemoved: 2},
+		},
+		{
+			name:        "插入的段落多于删除时其余记为新增",
+			old:         "开头\n甲\n结尾",
+			new:         "开头\n乙\n丙\n结尾",
+			changesOnly: true,
+			paragraphs: []define.ParagraphDiff{
+				{Type: "modify", OldIndex: 1, NewIndex: 1, OldText: "甲", NewText: "乙", Segments: []define.DiffSegment{
+					{Type: "delete", Text: "甲"}, {Type: "insert", Text: "乙"},
+				}},
+				{Type: "insert", OldIndex: -1, NewIndex: 2, NewText: "丙"},
+			},
+			stats: define.DiffStats{ParagraphsAdded: 1, ParagraphsModified: 1, CharsAdded: 2, CharsRemoved: 1},
+		},
+		{
+			name: "统一换行符",
+			old:  "第一章\r\n主角出发",
+			new:  "第一章\n主角出发",
+			paragraphs: []define.ParagraphDiff{
+				{Type: "equal", OldIndex: 0, NewIndex: 0, NewText: "第一章"},
+				{Type: "equal", OldIndex: 1, NewIndex: 1, NewText: "主角出发"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paragraphs, stats := diffParagraphs(tt.old, tt.new, tt.changesOnly)
+			if !reflect.DeepEqual(paragraphs, tt.paragraphs) {
+				t.Errorf("diffParagraphs() paragraphs = %+v, want %+v", paragraphs, tt.paragraphs)
+			}
+			if stats != tt.stats {
+				t.Errorf("diffParagraphs() stats = %+v, want %+v", stats, tt.stats)
+			}
+		})
+	}
+}
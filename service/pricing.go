@@ -0,0 +1,261 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"gin-template/service/llm"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 计费相关的系统设置，修改后下一次调用即生效
+const (
+	// OptionModelPrices 模型的计费规则，JSON对象，键以*结尾时按前缀匹配，如{"gpt-4o*":{"prompt":2,"completion":8,"min_charge":10}}
+	OptionModelPrices = "ai_model_prices"
+	// OptionPackageDiscounts 套餐的AI调用折扣，套餐ID到倍率的JSON对象，如{"2":0.8}，没有配置的套餐不打折
+	OptionPackageDiscounts = "ai_package_discounts"
+	// OptionCacheHitRate 命中响应缓存时按正常扣费的多少倍扣费，0表示免费
+	OptionCacheHitRate = "ai_cache_hit_rate"
+)
+
+// defaultModelPrice 没有匹配规则的模型按模型用量一比一扣费
+var defaultModelPrice = define.ModelPrice{PromptRate: 1, CompletionRate: 1}
+
+// PricingService 按模型计费规则和用户套餐折扣计算AI调用的扣费
+type PricingService struct {
+	packageRepo *repository.PackageRepository
+}
+
+var pricingService *PricingService
+
+// SetPricingService 设置AI调用使用的计费服务
+func SetPricingService(service *PricingService) {
+	pricingService = service
+}
+
+// GetPricingService 获取AI调用使用的计费服务
+func GetPricingService() *PricingService {
+	return pricingService
+}
+
+func NewPricingService(packageRepo *repository.PackageRepository) *PricingService {
+	return &PricingService{packageRepo: packageRepo}
+}
+
+// ParseModelPrices 解析并校验模型的计费规则
+func ParseModelPrices(value string) (map[string]define.ModelPrice, error) {
+	prices := make(map[string]define.ModelPrice)
+	if strings.TrimSpace(value) == "" {
+		return prices, nil
+	}
+	if err := json.Unmarshal([]byte(value), &prices); err != nil {
+		return nil, fmt.Errorf("计费规则格式错误，应为模型名到计费规则的JSON对象: %v", err)
+	}
+	for pattern, price := range prices {
+		if price.PromptRate < 0 || price.CompletionRate < 0 || price.MinCharge < 0 {
+			return nil, fmt.Errorf("模型%s的倍率和最低扣费不能为负数", pattern)
+		}
+	}
+	return prices, nil
+}
+
+// ParsePackageDiscounts 解析并校验套餐折扣
+func ParsePackageDiscounts(value string) (map[int64]float64, error) {
+	discounts := make(map[int64]float64)
+	if strings.TrimSpace(value) == "" {
+		return discounts, nil
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("套餐折扣格式错误，应为套餐ID到倍率的JSON对象: %v", err)
+	}
+	for key, discount := range raw {
+		packageId, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的套餐ID: %s", key)
+		}
+		if discount <= 0 || discount > 1 {
+			return nil, fmt.Errorf("套餐%s的折扣应大于0且不大于1", key)
+		}
+		discounts[packageId] = discount
+	}
+	return discounts, nil
+}
+
+// modelPrice 获取模型的计费规则，精确匹配优先，其次是最长的前缀匹配，都不匹配时按一比一计费
+func modelPrice(modelName string) define.ModelPrice {
+	prices, err := ParseModelPrices(model.GetSetting(OptionModelPrices))
+	if err != nil {
+		return defaultModelPrice
+	}
+	if price, ok := prices[modelName]; ok {
+		return price
+	}
+
+	matched, longest := defaultModelPrice, -1
+	for pattern, price := range prices {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if prefix == pattern || !strings.HasPrefix(modelName, prefix) {
+			continue
+		}
+		if len(prefix) > longest {
+			matched, longest = price, len(prefix)
+		}
+	}
+	return matched
+}
+
+// ParseCacheHitRate 解析并校验命中缓存时的扣费倍率
+func ParseCacheHitRate(value string) (float64, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("缓存扣费倍率应为0到1之间的数字")
+	}
+	return rate, nil
+}
+
+// userPackageId 获取用户当前订阅的套餐，没有有效订阅时为免费版
+func (s *PricingService) userPackageId(userId int64) int64 {
+	subscription, err := s.packageRepo.GetUserCurrentSubscription(userId)
+	if err != nil || subscription == nil {
+		return model.FreePackage.Id
+	}
+	return subscription.PackageId
+}
+
+// packageDiscount 获取套餐的折扣，没有配置时为1
+func packageDiscount(packageId int64) float64 {
+	discounts, err := ParsePackageDiscounts(model.GetSetting(OptionPackageDiscounts))
+	if err != nil {
+		return 1
+	}
+	if discount, ok := discounts[packageId]; ok {
+		return discount
+	}
+	return 1
+}
+
+// Charge calculates the platform tokens charged for the usage of an AI call: prompt and completion tokens are multiplied by the rates of the model,
+// the user's package discount is applied and the result is raised to the minimum charge of the model. Nothing is charged when no tokens were used.
+func (s *PricingService) Charge(userId int64, modelName string, usage llm.Usage) define.AICharge {
+	promptTokens, completionTokens := usage.PromptTokens, usage.CompletionTokens
+	if promptTokens+completionTokens < usage.TotalTokens {
+		// 服务商只返回总用量时，差额按生成内容计
+		completionTokens = usage.TotalTokens - promptTokens
+	}
+	price := modelPrice(modelName)
+	charge := define.AICharge{
+		Model:            modelName,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		PromptRate:       price.PromptRate,
+		CompletionRate:   price.CompletionRate,
+		Discount:         packageDiscount(s.userPackageId(userId)),
+		MinCharge:        price.MinCharge,
+	}
+	if promptTokens+completionTokens == 0 {
+		return charge
+	}
+	cost := (float64(promptTokens)*price.PromptRate + float64(completionTokens)*price.CompletionRate) * charge.Discount
+	charge.Amount = int64(math.Ceil(cost - 1e-9))
+	if charge.Amount < price.MinCharge {
+		charge.Amount = price.MinCharge
+	}
+	return charge
+}
+
+// ChargeResponse calculates the charge of a completion like Charge. Results served from the response cache are charged
+// the normal amount multiplied by the cache hit rate, so they are free by default.
+func (s *PricingService) ChargeResponse(userId int64, response define.GenerateResponse) define.AICharge {
+	charge := s.Charge(userId, response.Model, responseUsage(response))
+	if !response.Cached {
+		return charge
+	}
+	rate, err := ParseCacheHitRate(model.GetSetting(OptionCacheHitRate))
+	if err != nil {
+		rate = 0
+	}
+	charge.Cached = true
+	charge.CacheRate = rate
+	charge.Amount = int64(math.Ceil(float64(charge.Amount)*rate - 1e-9))
+	return charge
+}
+
+// Estimate returns the most platform tokens an AI request can cost, priced like Charge from the estimated prompt and the maximum completion length.
+// It is used to place the hold before the call.
+func (s *PricingService) Estimate(userId int64, req define.GenerateAIPromptRequest) int64 {
+	modelName := req.Model
+	if _, resolved, err := llm.Resolve(req.Model); err == nil {
+		modelName = resolved
+	}
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultMaxTokens
+	}
+	promptTokens := llm.EstimatePromptTokens(chatMessages(req))
+	charge := s.Charge(userId, modelName, llm.Usage{PromptTokens: promptTokens, CompletionTokens: maxTokens, TotalTokens: promptTokens + maxTokens})
+	return charge.Amount
+}
+
+// GetUserPricing returns the pricing rules and the package discount that apply to the user
+func (s *PricingService) GetUserPricing(userId int64) (*define.AIPricingResponse, error) {
+	prices, err := ParseModelPrices(model.GetSetting(OptionModelPrices))
+	if err != nil {
+		common.SysError(fmt.Sprintf("[PricingService] Invalid model prices: %v", err))
+		return nil, fmt.Errorf("计费规则配置有误，请联系管理员")
+	}
+	packageId := s.userPackageId(userId)
+	response := &define.AIPricingResponse{
+		Prices:       make([]define.ModelPriceInfo, 0, len(prices)),
+		DefaultPrice: defaultModelPrice,
+		PackageId:    packageId,
+		Discount:     packageDiscount(packageId),
+	}
+	for pattern, price := range prices {
+		response.Prices = append(response.Prices, define.ModelPriceInfo{Model: pattern, ModelPrice: price})
+	}
+	sort.Slice(response.Prices, func(i, j int) bool { return response.Prices[i].Model < response.Prices[j].Model })
+	return response, nil
+}
+
+// chargeDescription 在交易描述后附上扣费明细，多次调用合并扣费时逐个列出
+func chargeDescription(description string, charges ...define.AICharge) string {
+	var details []string
+	for _, charge := range charges {
+		detail := fmt.Sprintf("model %s: prompt %d × %s + completion %d × %s",
+			charge.Model, charge.PromptTokens, formatRate(charge.PromptRate), charge.CompletionTokens, formatRate(charge.CompletionRate))
+		if charge.Discount != 1 {
+			detail += fmt.Sprintf(", discount %s", formatRate(charge.Discount))
+		}
+		if charge.MinCharge > 0 {
+			detail += fmt.Sprintf(", minimum %d", charge.MinCharge)
+		}
+		if charge.Cached {
+			detail += fmt.Sprintf(", cached × %s", formatRate(charge.CacheRate))
+		}
+		details = append(details, detail+fmt.Sprintf(" = %d", charge.Amount))
+	}
+	return description + " | " + strings.Join(details, "; ")
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// totalCharge 多次调用的扣费之和
+func totalCharge(charges []define.AICharge) int64 {
+	var total int64
+	for _, charge := range charges {
+		total += charge.Amount
+	}
+	return total
+}
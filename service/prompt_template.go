@@ -0,0 +1,419 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	agentconfig "gin-template/service/agent/config"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// 内置提示词模板的名称
+const (
+	PromptOutlineSystem    = "outline_continuation_system"
+	PromptOutlineUser      = "outline_continuation_user"
+	PromptSummarySystem    = "outline_summary_system"
+	PromptSummaryUser      = "outline_summary_user"
+	PromptStoryBible       = "story_bible"
+	PromptContinuitySystem = "continuity_check_system"
+	PromptContinuityUser   = "continuity_check_user"
+	PromptModerationSystem = "moderation_classifier_system"
+	PromptAgentPlanner     = "agent_planner"
+	PromptAgentExecutor    = "agent_executor"
+	PromptAgentReviser     = "agent_reviser"
+)
+
+// defaultPromptTemplate 内置的默认提示词，首次启动时作为模板的第一个版本写入，数据库不可用时也用作兜底
+type defaultPromptTemplate struct {
+	description string
+	content     string
+	variables   []model.PromptVariable
+}
+
+var defaultPromptTemplates = map[string]defaultPromptTemplate{
+	PromptOutlineSystem: {
+		description: "大纲续写的系统提示词，使用风格预设时不生效",
+		content:     "You are a professional content creation assistant skilled at continuing and expanding on provided outlines.",
+	},
+	PromptOutlineUser: {
+		description: "大纲续写的用户提示词",
+		content: "{{if .toc}}Here is the table of contents of the whole outline:\n\n{{.toc}}\n\n" +
+			"Please continue and expand on the following part of the outline only:" +
+			"{{else}}Please continue and expand on the following outline content:{{end}}" +
+			"{{if .word_limit}} The continuation should be approximately {{.word_limit}} words.{{end}}\n\n{{.content}}",
+		variables: []model.PromptVariable{
+			{Name: "content", Description: "需要续写的大纲内容", Required: true},
+			{Name: "toc", Description: "按节点续写时整份大纲的目录，整份续写时为空"},
+			{Name: "word_limit", Description: "续写字数，不限制时为空"},
+		},
+	},
+	PromptStoryBible: {
+		description: "附加在续写和智能体系统提示词之后的故事设定，只包含常驻条目和正文中提到的条目",
+		content: "The following story bible entries are canon. Keep names, ages, abilities, relationships and world rules " +
+			"consistent with them, and do not contradict them:\n\n{{.entries}}",
+		variables: []model.PromptVariable{
+			{Name: "entries", Description: "相关的设定条目和关系", Required: true},
+		},
+	},
+	PromptContinuitySystem: {
+		description: "连贯性检查的系统提示词，要求模型以JSON返回疑似矛盾",
+		content: "You are a continuity editor for novel outlines. Compare the content added in a new version with the earlier content " +
+			"and report contradictions only: timeline errors (type \"timeline\"), changed character traits such as age, appearance, " +
+			"personality or abilities (\"character\"), characters who died or left appearing again without explanation (\"death\"), " +
+			"renamed or inconsistent places (\"place\") and other factual contradictions (\"other\"). Reply with JSON only, in the form " +
+			"{\"issues\":[{\"type\":\"timeline\",\"description\":\"...\",\"evidence\":[{\"source\":\"new\",\"quote\":\"...\"}," +
+			"{\"source\":\"previous\",\"quote\":\"...\"}]}]}. Quotes must be copied exactly from the text, \"new\" quotes from the added " +
+			"content and \"previous\" quotes from the earlier content. Write descriptions in the language of the outline. " +
+			"Reply {\"issues\":[]} when there is no contradiction.",
+	},
+	PromptContinuityUser: {
+		description: "连贯性检查的用户提示词",
+		content:     "Earlier content of the outline:\n\n{{.previous}}\n\nContent added in the new version:\n\n{{.added}}",
+		variables: []model.PromptVariable{
+			{Name: "previous", Description: "父版本的内容，过长时为摘要加上末尾部分", Required: true},
+			{Name: "added", Description: "新版本相对父版本新增的内容", Required: true},
+		},
+	},
+	PromptModerationSystem: {
+		description: "内容审核分类的系统提示词，要求模型以JSON返回是否违规",
+		content: "You are a content moderator for a Chinese web novel platform. Decide whether the text violates Chinese laws and " +
+			"platform rules: pornography, gambling, drugs, graphic violence or terrorism, politically sensitive content, " +
+			"discrimination, or advertising and spam. Fictional conflict, crime and romance are allowed when not explicit. " +
+			"Reply with JSON only, in the form {\"flagged\":true,\"category\":\"...\",\"reason\":\"...\"}, " +
+			"or {\"flagged\":false} when the text is acceptable. Write the reason in Chinese.",
+	},
+	PromptSummarySystem: {
+		description: "大纲滚动摘要的系统提示词",
+		content: "You are an assistant that writes concise summaries of novel outlines. Keep character names, key events, " +
+			"foreshadowing and unresolved plot threads. Reply with the summary only, in the same language as the outline.",
+	},
+	PromptSummaryUser: {
+		description: "大纲滚动摘要的用户提示词，较长的节点由下级节点的摘要汇总",
+		content:     "Summarize the following part of the outline{{if .title}} (\"{{.title}}\"){{end}} in no more than {{.max_chars}} characters:\n\n{{.content}}",
+		variables: []model.PromptVariable{
+			{Name: "content", Description: "需要摘要的大纲原文或下级节点的摘要", Required: true},
+			{Name: "title", Description: "节点标题，整份大纲的概要为空"},
+			{Name: "max_chars", Description: "摘要的最大字数", Default: "300"},
+		},
+	},
+	PromptAgentPlanner: {
+		description: "多智能体Planner的系统提示词",
+		content:     agentconfig.DefaultPlannerPrompt,
+	},
+	PromptAgentExecutor: {
+		description: "多智能体Executor的系统提示词",
+		content:     agentconfig.DefaultExecutorPrompt,
+	},
+	PromptAgentReviser: {
+		description: "多智能体Reviser的系统提示词",
+		content:     agentconfig.DefaultReviserPrompt,
+	},
+}
+
+// promptVariableName 变量名需要能在模板中以 {{.name}} 的形式引用
+var promptVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PromptTemplateService 提示词模板服务
+type PromptTemplateService struct {
+	promptRepo *repository.PromptTemplateRepository
+}
+
+// NewPromptTemplateService 创建提示词模板服务实例
+func NewPromptTemplateService(promptRepo *repository.PromptTemplateRepository) *PromptTemplateService {
+	return &PromptTemplateService{promptRepo: promptRepo}
+}
+
+// ListTemplates returns all prompt templates without their versions
+func (s *PromptTemplateService) ListTemplates() ([]define.PromptTemplateBrief, error) {
+	templates, err := s.promptRepo.GetTemplates()
+	if err != nil {
+		common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to list prompt templates: %v", err))
+		return nil, err
+	}
+	briefs := make([]define.PromptTemplateBrief, 0, len(templates))
+	for _, t := range templates {
+		briefs = append(briefs, toPromptTemplateBrief(t))
+	}
+	return briefs, nil
+}
+
+// GetTemplate returns a prompt template with its whole version history
+func (s *PromptTemplateService) GetTemplate(name string) (*define.PromptTemplateResponse, error) {
+	t, err := s.getTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.promptRepo.GetVersions(t.Id)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to get versions of prompt template %s: %v", name, err))
+		return nil, err
+	}
+
+	response := &define.PromptTemplateResponse{
+		PromptTemplateBrief: toPromptTemplateBrief(t),
+		Versions:            make([]define.PromptTemplateVersionResponse, 0, len(versions)),
+	}
+	for _, v := range versions {
+		response.Versions = append(response.Versions, define.PromptTemplateVersionResponse{
+			Version:   v.Version,
+			Content:   v.Content,
+			Variables: toDefinePromptVariables(v.VariableList()),
+			Comment:   v.Comment,
+			CreatedBy: v.CreatedBy,
+			CreatedAt: v.CreatedAt,
+			Active:    v.Version == t.ActiveVersion,
+		})
+	}
+	return response, nil
+}
+
+// CreateVersion appends a new version to the template, creating the template when the name is new.
+// The content is test-rendered first so a broken template can never become active.
+func (s *PromptTemplateService) CreateVersion(userId int64, name string, req define.PromptTemplateVersionRequest) (*define.PromptTemplateBrief, error) {
+	name = strings.TrimSpace(name)
+	if !promptVariableName.MatchString(name) || len(name) > 100 {
+		return nil, fmt.Errorf("模板名称只能包含字母、数字和下划线")
+	}
+	variables, err := toModelPromptVariables(req.Variables)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPromptTemplate(req.Content, variables); err != nil {
+		return nil, err
+	}
+
+	t := &model.PromptTemplate{Name: name, Description: strings.TrimSpace(req.Description)}
+	templateVersion := &model.PromptTemplateVersion{
+		Content:   req.Content,
+		Comment:   strings.TrimSpace(req.Comment),
+		CreatedBy: userId,
+	}
+	templateVersion.SetVariables(variables)
+	// 新模板没有可用的旧版本，第一个版本总是立即生效
+	existing, err := s.promptRepo.GetTemplateByName(name)
+	if err != nil {
+		return nil, err
+	}
+	activate := req.Activate || existing == nil
+	if err := s.promptRepo.CreateVersion(t, templateVersion, activate); err != nil {
+		common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to create version of prompt template %s: %v", name, err))
+		return nil, err
+	}
+	if description := strings.TrimSpace(req.Description); description != "" && description != t.Description {
+		t.Description = description
+		if err := s.promptRepo.UpdateDescription(t); err != nil {
+			return nil, err
+		}
+	}
+
+	common.SysLog(fmt.Sprintf("[PromptTemplateService] User %d created version %d of prompt template %s (active: %v)",
+		userId, templateVersion.Version, name, activate))
+	brief := toPromptTemplateBrief(t)
+	return &brief, nil
+}
+
+// ActivateVersion points the template at an existing version, which is also how a change is rolled back
+func (s *PromptTemplateService) ActivateVersion(name string, version int) (*define.PromptTemplateBrief, error) {
+	t, err := s.getTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	templateVersion, err := s.promptRepo.GetVersion(t.Id, version)
+	if err != nil {
+		return nil, err
+	}
+	if templateVersion == nil {
+		return nil, fmt.Errorf("模板版本不存在")
+	}
+	if err := s.promptRepo.SetActiveVersion(t, version); err != nil {
+		common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to activate version %d of prompt template %s: %v", version, name, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[PromptTemplateService] Activated version %d of prompt template %s", version, name))
+	brief := toPromptTemplateBrief(t)
+	return &brief, nil
+}
+
+// Render previews a saved version of the template, or unsaved content when req.Content is set
+func (s *PromptTemplateService) Render(name string, req define.PromptTemplateRenderRequest) (*define.PromptTemplateRenderResponse, error) {
+	response := &define.PromptTemplateRenderResponse{Name: name}
+	content := req.Content
+	var variables []model.PromptVariable
+	if content != "" {
+		var err error
+		if variables, err = toModelPromptVariables(req.Definitions); err != nil {
+			return nil, err
+		}
+	} else {
+		t, err := s.getTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		version := req.Version
+		if version <= 0 {
+			version = t.ActiveVersion
+		}
+		templateVersion, err := s.promptRepo.GetVersion(t.Id, version)
+		if err != nil {
+			return nil, err
+		}
+		if templateVersion == nil {
+			return nil, fmt.Errorf("模板版本不存在")
+		}
+		content, variables = templateVersion.Content, templateVersion.VariableList()
+		response.Version = version
+	}
+
+	rendered, err := renderPromptTemplate(content, variables, req.Variables)
+	if err != nil {
+		return nil, err
+	}
+	response.Content = rendered
+	return response, nil
+}
+
+func (s *PromptTemplateService) getTemplate(name string) (*model.PromptTemplate, error) {
+	t, err := s.promptRepo.GetTemplateByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("提示词模板不存在")
+	}
+	return t, nil
+}
+
+// RenderPrompt 渲染指定名称模板的生效版本
+// 提示词是AI调用的必要输入，模板缺失或渲染失败时记录错误并退回内置的默认提示词，不影响调用本身
+func RenderPrompt(promptRepo *repository.PromptTemplateRepository, name string, values map[string]string) string {
+	fallback := defaultPromptTemplates[name]
+	templateVersion, err := promptRepo.GetActiveVersion(name)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to load prompt template %s, using built-in default: %v", name, err))
+	} else if templateVersion != nil {
+		rendered, err := renderPromptTemplate(templateVersion.Content, templateVersion.VariableList(), values)
+		if err == nil {
+			return rendered
+		}
+		common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to render version %d of prompt template %s, using built-in default: %v",
+			templateVersion.Version, name, err))
+	}
+
+	rendered, err := renderPromptTemplate(fallback.content, fallback.variables, values)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to render built-in prompt template %s: %v", name, err))
+	}
+	return rendered
+}
+
+// InitPromptTemplates 将内置的默认提示词写入尚不存在的模板，已存在的模板不受影响
+func InitPromptTemplates(promptRepo *repository.PromptTemplateRepository) {
+	for name, defaults := range defaultPromptTemplates {
+		existing, err := promptRepo.GetTemplateByName(name)
+		if err != nil {
+			common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to check prompt template %s: %v", name, err))
+			continue
+		}
+		if existing != nil {
+			continue
+		}
+
+		t := &model.PromptTemplate{Name: name, Description: defaults.description}
+		templateVersion := &model.PromptTemplateVersion{Content: defaults.content, Comment: "内置默认版本"}
+		templateVersion.SetVariables(defaults.variables)
+		if err := promptRepo.CreateVersion(t, templateVersion, true); err != nil {
+			common.SysError(fmt.Sprintf("[PromptTemplateService] Failed to seed prompt template %s: %v", name, err))
+			continue
+		}
+		common.SysLog(fmt.Sprintf("[PromptTemplateService] Seeded prompt template %s", name))
+	}
+}
+
+// renderPromptTemplate 用变量渲染模板，未传入的变量使用默认值，引用未定义的变量或未传入必填变量时返回错误
+func renderPromptTemplate(content string, variables []model.PromptVariable, values map[string]string) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("模板语法错误: %v", err)
+	}
+
+	data := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		value, ok := values[variable.Name]
+		if !ok {
+			if variable.Required {
+				return "", fmt.Errorf("缺少模板变量%s", variable.Name)
+			}
+			value = variable.Default
+		}
+		data[variable.Name] = value
+	}
+
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("模板渲染失败: %v", err)
+	}
+	return rendered.String(), nil
+}
+
+// checkPromptTemplate 用示例值试渲染模板，确保保存的模板只引用已定义的变量
+func checkPromptTemplate(content string, variables []model.PromptVariable) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("模板内容不能为空")
+	}
+	samples := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		samples[variable.Name] = variable.Name
+	}
+	_, err := renderPromptTemplate(content, variables, samples)
+	return err
+}
+
+func toModelPromptVariables(variables []define.PromptVariable) ([]model.PromptVariable, error) {
+	result := make([]model.PromptVariable, 0, len(variables))
+	seen := make(map[string]bool, len(variables))
+	for _, variable := range variables {
+		name := strings.TrimSpace(variable.Name)
+		if !promptVariableName.MatchString(name) {
+			return nil, fmt.Errorf("变量名%s只能包含字母、数字和下划线，且不能以数字开头", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("变量%s重复定义", name)
+		}
+		seen[name] = true
+		result = append(result, model.PromptVariable{
+			Name:        name,
+			Description: strings.TrimSpace(variable.Description),
+			Default:     variable.Default,
+			Required:    variable.Required,
+		})
+	}
+	return result, nil
+}
+
+func toDefinePromptVariables(variables []model.PromptVariable) []define.PromptVariable {
+	result := make([]define.PromptVariable, 0, len(variables))
+	for _, variable := range variables {
+		result = append(result, define.PromptVariable{
+			Name:        variable.Name,
+			Description: variable.Description,
+			Default:     variable.Default,
+			Required:    variable.Required,
+		})
+	}
+	return result
+}
+
+func toPromptTemplateBrief(t *model.PromptTemplate) define.PromptTemplateBrief {
+	return define.PromptTemplateBrief{
+		Id:            t.Id,
+		Name:          t.Name,
+		Description:   t.Description,
+		ActiveVersion: t.ActiveVersion,
+		UpdatedAt:     t.UpdatedAt,
+	}
+}
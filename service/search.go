@@ -0,0 +1,266 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"gin-template/util"
+	"html"
+	"sort"
+	"strings"
+)
+
+const (
+	// searchCandidateLimit 从索引中取出的最大候选文档数，候选文档再按原文校验和排序
+	searchCandidateLimit = 1000
+	// searchMaxSnippets 每个结果最多返回的片段数
+	searchMaxSnippets = 3
+	// snippetBefore、snippetAfter 片段中命中位置前后保留的字符数
+	snippetBefore = 30
+	snippetAfter  = 50
+)
+
+// searchDocTypeBoost 不同文档类型的排序加权，当前大纲和项目信息优先于历史版本
+var searchDocTypeBoost = map[string]int{
+	model.SearchDocOutline: 5,
+	model.SearchDocProject: 3,
+	model.SearchDocVersion: 0,
+}
+
+// SearchService 全文搜索服务
+type SearchService struct {
+	searchRepo *repository.SearchRepository
+}
+
+// NewSearchService 创建全文搜索服务实例
+func NewSearchService(searchRepo *repository.SearchRepository) *SearchService {
+	return &SearchService{searchRepo: searchRepo}
+}
+
+// searchMatch 命中的关键词在文档中的字符区间
+type searchMatch struct {
+	start int
+	end   int
+}
+
+type searchHit struct {
+	document *model.SearchDocument
+	matches  []searchMatch
+	score    int
+}
+
+// Search finds the user's projects, outlines and outline versions containing every term of the query
+func (s *SearchService) Search(userId int64, req define.SearchRequest) (*define.SearchResponse, error) {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.Limit < 1 || req.Limit > 50 {
+		req.Limit = 10
+	}
+
+	terms := strings.Fields(req.Query)
+	var grams []string
+	seen := make(map[string]bool)
+	normalizedTerms := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		termGrams := util.QueryGrams(term)
+		if len(termGrams) == 0 {
+			// 只包含标点的关键词无法索引，忽略
+			continue
+		}
+		for _, gram := range termGrams {
+			if !seen[gram] {
+				seen[gram] = true
+				grams = append(grams, gram)
+			}
+		}
+		normalizedTerms = append(normalizedTerms, util.NormalizeText(term))
+	}
+	if len(grams) == 0 {
+		return nil, fmt.Errorf("搜索关键词无效")
+	}
+
+	filter := repository.SearchFilter{UserId: userId, ProjectId: req.ProjectId, AiOnly: req.AiOnly}
+	documents, err := s.searchRepo.Search(filter, grams, searchCandidateLimit)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[SearchService] Failed to search for user %d: %v", userId, err))
+		return nil, fmt.Errorf("搜索失败")
+	}
+	documents, err = s.searchRepo.RemoveStaleDocuments(documents)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[SearchService] Failed to remove stale search documents: %v", err))
+		return nil, fmt.Errorf("搜索失败")
+	}
+
+	// 索引词命中不代表关键词连续出现，按原文逐个校验
+	var hits []*searchHit
+	for _, document := range documents {
+		matches := findMatches(util.NormalizeText(document.Content), normalizedTerms)
+		if matches == nil {
+			continue
+		}
+		hits = append(hits, &searchHit{
+			document: document,
+			matches:  matches,
+			score:    len(matches) + searchDocTypeBoost[document.DocType],
+		})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].document.SourceUpdatedAt > hits[j].document.SourceUpdatedAt
+	})
+
+	total := int64(len(hits))
+	start := (req.Page - 1) * req.Limit
+	if start > len(hits) {
+		start = len(hits)
+	}
+	end := start + req.Limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	pageHits := hits[start:end]
+
+	projectIds := make([]int64, 0, len(pageHits))
+	for _, hit := range pageHits {
+		projectIds = append(projectIds, hit.document.ProjectId)
+	}
+	titles := make(map[int64]string)
+	if len(projectIds) > 0 {
+		titles, err = s.searchRepo.GetProjectTitles(projectIds)
+		if err != nil {
+			common.SysError(fmt.Sprintf("[SearchService] Failed to get project titles: %v", err))
+			return nil, fmt.Errorf("搜索失败")
+		}
+	}
+
+	results := make([]define.SearchResult, 0, len(pageHits))
+	for _, hit := range pageHits {
+		document := hit.document
+		title, ok := titles[document.ProjectId]
+		if !ok {
+			title = document.Title
+		}
+		results = append(results, define.SearchResult{
+			DocType:       document.DocType,
+			ProjectId:     document.ProjectId,
+			ProjectTitle:  title,
+			VersionNumber: document.VersionNumber,
+			IsAiGenerated: document.IsAiGenerated,
+			BranchName:    document.BranchName,
+			Snippets:      buildSnippets([]rune(document.Content), hit.matches),
+			MatchCount:    len(hit.matches),
+			Score:         hit.score,
+			UpdatedAt:     document.SourceUpdatedAt,
+		})
+	}
+
+	return &define.SearchResponse{
+		Data: results,
+		Pagination: define.Pagination{
+			Total: total,
+			Page:  req.Page,
+			Limit: req.Limit,
+			Pages: (total + int64(req.Limit) - 1) / int64(req.Limit),
+		},
+	}, nil
+}
+
+// findMatches 查找每个关键词在归一化文本中的全部出现位置，按位置排序；有关键词没有出现时返回nil
+func findMatches(text []rune, terms [][]rune) []searchMatch {
+	var matches []searchMatch
+	for _, term := range terms {
+		found := false
+		for i := 0; i+len(term) <= len(text); i++ {
+			if equalRunes(text[i:i+len(term)], term) {
+				matches = append(matches, searchMatch{start: i, end: i + len(term)})
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+	return matches
+}
+
+func equalRunes(a []rune, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// buildSnippets 截取命中位置附近的片段，HTML转义后用<mark>标记命中的关键词
+// 归一化按字符一一对应，命中区间可以直接用于原文
+func buildSnippets(text []rune, matches []searchMatch) []string {
+	var snippets []string
+	windowEnd := -1
+	for i, match := range matches {
+		if match.start < windowEnd {
+			// 已包含在上一个片段中
+			continue
+		}
+		if len(snippets) == searchMaxSnippets {
+			break
+		}
+
+		start := match.start - snippetBefore
+		if start < 0 {
+			start = 0
+		}
+		if start < windowEnd {
+			start = windowEnd
+		}
+		end := match.end + snippetAfter
+		if end > len(text) {
+			end = len(text)
+		}
+
+		var builder strings.Builder
+		if start > 0 {
+			builder.WriteString("…")
+		}
+		pos := start
+		for _, m := range matches[i:] {
+			if m.start >= end {
+				break
+			}
+			if m.start < pos {
+				// 与前一个命中重叠
+				continue
+			}
+			if m.end > end {
+				end = m.end
+			}
+			builder.WriteString(snippetText(text[pos:m.start]))
+			builder.WriteString("<mark>")
+			builder.WriteString(snippetText(text[m.start:m.end]))
+			builder.WriteString("</mark>")
+			pos = m.end
+		}
+		builder.WriteString(snippetText(text[pos:end]))
+		if end < len(text) {
+			builder.WriteString("…")
+		}
+		snippets = append(snippets, builder.String())
+		windowEnd = end
+	}
+	return snippets
+}
+
+// snippetNewlines 片段中的换行替换为空格
+var snippetNewlines = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+func snippetText(text []rune) string {
+	return html.EscapeString(snippetNewlines.Replace(string(text)))
+}
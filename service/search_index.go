@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/model"
+	"gin-template/repository"
+	"gin-template/util"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// searchIndexBatchSize 每批索引的记录数
+	searchIndexBatchSize = 200
+	// versionSettleDelay 版本创建后等待的时间，避免还未提交的事务中ID更小的版本被跳过
+	versionSettleDelay = 10 * time.Second
+)
+
+var searchIndexService *SearchIndexService
+
+// SearchIndexService 定期为新增和修改过的项目、大纲和历史版本建立全文搜索索引
+type SearchIndexService struct {
+	running     bool
+	mutex       sync.Mutex
+	stopChan    chan struct{}
+	interval    time.Duration
+	searchRepo  *repository.SearchRepository
+	outlineRepo *repository.OutlineRepository
+}
+
+func NewSearchIndexService(interval time.Duration, searchRepo *repository.SearchRepository, outlineRepo *repository.OutlineRepository) *SearchIndexService {
+	return &SearchIndexService{
+		stopChan:    make(chan struct{}),
+		interval:    interval,
+		searchRepo:  searchRepo,
+		outlineRepo: outlineRepo,
+	}
+}
+
+// Start 启动索引任务
+func (s *SearchIndexService) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+
+	go s.indexLoop()
+	common.SysLog(fmt.Sprintf("[SearchIndex] Started, interval: %v", s.interval))
+}
+
+// Stop 停止索引任务
+func (s *SearchIndexService) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	common.SysLog("[SearchIndex] Stopped")
+}
+
+func (s *SearchIndexService) indexLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.IndexAll()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// IndexAll indexes every project, outline and version that is new or changed since it was last indexed
+func (s *SearchIndexService) IndexAll() {
+	startTime := time.Now()
+	projects, err := s.indexProjects()
+	if err != nil {
+		common.SysError(fmt.Sprintf("[SearchIndex] Failed to index projects: %v", err))
+	}
+	outlines, err := s.indexOutlines()
+	if err != nil {
+		common.SysError(fmt.Sprintf("[SearchIndex] Failed to index outlines: %v", err))
+	}
+	versions, err := s.indexVersions(startTime.Add(-versionSettleDelay).Unix())
+	if err != nil {
+		common.SysError(fmt.Sprintf("[SearchIndex] Failed to index versions: %v", err))
+	}
+
+	if projects+outlines+versions > 0 {
+		common.SysLog(fmt.Sprintf("[SearchIndex] Indexed %d projects, %d outlines, %d versions, took %v",
+			projects, outlines, versions, time.Since(startTime)))
+	}
+}
+
+func (s *SearchIndexService) indexProjects() (int, error) {
+	count := 0
+	for {
+		projects, err := s.searchRepo.GetProjectsToIndex(searchIndexBatchSize)
+		if err != nil {
+			return count, err
+		}
+		for _, project := range projects {
+			content := project.Title + "\n" + project.Description
+			document := &model.SearchDocument{
+				UserId:          project.UserId,
+				ProjectId:       project.Id,
+				DocType:         model.SearchDocProject,
+				SourceId:        project.Id,
+				Title:           project.Title,
+				Content:         content,
+				SourceUpdatedAt: project.UpdatedAt,
+			}
+			if err := s.searchRepo.SaveDocument(document, util.NGrams(content)); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(projects) < searchIndexBatchSize {
+			return count, nil
+		}
+	}
+}
+
+func (s *SearchIndexService) indexOutlines() (int, error) {
+	count := 0
+	for {
+		outlines, err := s.searchRepo.GetOutlinesToIndex(searchIndexBatchSize)
+		if err != nil {
+			return count, err
+		}
+		for _, outline := range outlines {
+			document := &model.SearchDocument{
+				UserId:          outline.UserId,
+				ProjectId:       outline.ProjectId,
+				DocType:         model.SearchDocOutline,
+				SourceId:        outline.Id,
+				VersionNumber:   outline.CurrentVersion,
+				BranchName:      outline.CurrentBranch,
+				Content:         outline.Content,
+				SourceUpdatedAt: outline.UpdatedAt,
+			}
+			if err := s.searchRepo.SaveDocument(document, util.NGrams(outline.Content)); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(outlines) < searchIndexBatchSize {
+			return count, nil
+		}
+	}
+}
+
+// indexVersions 索引历史版本相对父版本新增的内容，同一段内容只在最早写入它的版本中被索引
+func (s *SearchIndexService) indexVersions(createdBefore int64) (int, error) {
+	count := 0
+	for {
+		versions, err := s.searchRepo.GetVersionsToIndex(createdBefore, searchIndexBatchSize)
+		if err != nil {
+			return count, err
+		}
+		contents := make(map[int64]string, len(versions))
+		for _, version := range versions {
+			contents[version.Id] = version.Content
+		}
+
+		for _, version := range versions {
+			parentContent, err := s.parentContent(version.ParentId, contents)
+			if err != nil {
+				return count, err
+			}
+			content := addedLines(parentContent, version.Content)
+			document := &model.SearchDocument{
+				UserId:          version.UserId,
+				ProjectId:       version.ProjectId,
+				DocType:         model.SearchDocVersion,
+				SourceId:        version.Id,
+				VersionNumber:   version.VersionNumber,
+				IsAiGenerated:   version.IsAiGenerated,
+				BranchName:      version.BranchName,
+				Content:         content,
+				SourceUpdatedAt: version.CreatedAt,
+			}
+			// 没有新增内容的版本也保存文档，记录索引进度
+			if err := s.searchRepo.SaveDocument(document, util.NGrams(content)); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(versions) < searchIndexBatchSize {
+			return count, nil
+		}
+	}
+}
+
+// parentContent 获取父版本内容，父版本不存在时返回空内容
+func (s *SearchIndexService) parentContent(parentId int64, contents map[int64]string) (string, error) {
+	if parentId == 0 {
+		return "", nil
+	}
+	if content, ok := contents[parentId]; ok {
+		return content, nil
+	}
+	parent, err := s.outlineRepo.GetVersionById(parentId)
+	if err != nil || parent == nil {
+		return "", err
+	}
+	contents[parentId] = parent.Content
+	return parent.Content, nil
+}
+
+// addedLines 返回新内容中相对旧内容新增的行
+func addedLines(oldText string, newText string) string {
+	newLines := util.SplitLines(newText)
+	var added []string
+	for _, op := range util.Diff(util.SplitLines(oldText), newLines) {
+		if op.Type != util.DiffInsert {
+			continue
+		}
+		for _, line := range newLines[op.BStart:op.BEnd] {
+			if strings.TrimSpace(line) != "" {
+				added = append(added, line)
+			}
+		}
+	}
+	return strings.Join(added, "\n")
+}
+
+// InitSearchIndexService 初始化并启动全文搜索索引任务，SearchIndexInterval为0时不启动
+func InitSearchIndexService(searchRepo *repository.SearchRepository, outlineRepo *repository.OutlineRepository) {
+	if common.SearchIndexInterval <= 0 {
+		common.SysLog("[SearchIndex] Disabled, SEARCH_INDEX_INTERVAL is 0")
+		return
+	}
+
+	interval := time.Duration(common.SearchIndexInterval) * time.Second
+	searchIndexService = NewSearchIndexService(interval, searchRepo, outlineRepo)
+	searchIndexService.Start()
+}
+
+// StopSearchIndexService 停止全文搜索索引任务
+func StopSearchIndexService() {
+	if searchIndexService != nil {
+		searchIndexService.Stop()
+	}
+}
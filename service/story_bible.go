@@ -0,0 +1,454 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxStoryEntities 每个项目最多可创建的设定条目数量
+	maxStoryEntities = 500
+	// storyBibleMaxEntries 每次续写最多带上的设定条目数量
+	storyBibleMaxEntries = 15
+	// storyBibleDescriptionChars 带入提示词的条目描述的最大字数
+	storyBibleDescriptionChars = 300
+	// minStoryNameChars 短于该字数的名称和别名容易误匹配，不参与匹配
+	minStoryNameChars = 2
+)
+
+// storyEntityTypeNames 设定条目类型在提示词中的名称
+var storyEntityTypeNames = map[string]string{
+	define.StoryEntityCharacter: "人物",
+	define.StoryEntityLocation:  "地点",
+	define.StoryEntityFaction:   "势力",
+	define.StoryEntityItem:      "物品",
+	define.StoryEntityRule:      "设定",
+}
+
+// StoryBibleService 故事设定集服务
+type StoryBibleService struct {
+	bibleRepo  *repository.StoryBibleRepository
+	promptRepo *repository.PromptTemplateRepository
+}
+
+// NewStoryBibleService 创建故事设定集服务实例
+func NewStoryBibleService(bibleRepo *repository.StoryBibleRepository, promptRepo *repository.PromptTemplateRepository) *StoryBibleService {
+	return &StoryBibleService{bibleRepo: bibleRepo, promptRepo: promptRepo}
+}
+
+// ListEntities returns the entities of the project, optionally filtered by type
+func (s *StoryBibleService) ListEntities(projectId int64, entityType string) ([]define.StoryEntity, error) {
+	entities, err := s.bibleRepo.GetEntities(projectId, entityType)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to list entities for project %d: %v", projectId, err))
+		return nil, err
+	}
+	result := make([]define.StoryEntity, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, toStoryEntity(entity))
+	}
+	return result, nil
+}
+
+// GetEntity returns an entity together with its relations
+func (s *StoryBibleService) GetEntity(projectId int64, entityId int64) (*define.StoryEntity, error) {
+	entity, err := s.getEntity(projectId, entityId)
+	if err != nil {
+		return nil, err
+	}
+	relations, names, err := s.loadRelations(projectId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toStoryEntity(entity)
+	response.Relations = []define.StoryRelation{}
+	for _, relation := range relations {
+		if relation.SourceId == entity.Id || relation.TargetId == entity.Id {
+			response.Relations = append(response.Relations, toStoryRelation(relation, names))
+		}
+	}
+	return &response, nil
+}
+
+// CreateEntity adds an entity to the story bible of the project
+func (s *StoryBibleService) CreateEntity(projectId int64, req define.StoryEntityRequest) (*define.StoryEntity, error) {
+	count, err := s.bibleRepo.CountEntities(projectId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxStoryEntities {
+		return nil, fmt.Errorf("每个项目最多只能创建%d个设定条目", maxStoryEntities)
+	}
+
+	entity := &model.StoryEntity{ProjectId: projectId}
+	if err := applyStoryEntityRequest(entity, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkEntityNames(entity); err != nil {
+		return nil, err
+	}
+	if err := s.bibleRepo.CreateEntity(entity); err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to create entity for project %d: %v", projectId, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[StoryBibleService] Created %s entity %d (%s) for project %d", entity.Type, entity.Id, entity.Name, projectId))
+	response := toStoryEntity(entity)
+	return &response, nil
+}
+
+// UpdateEntity updates an entity, its relations are kept
+func (s *StoryBibleService) UpdateEntity(projectId int64, entityId int64, req define.StoryEntityRequest) (*define.StoryEntity, error) {
+	entity, err := s.getEntity(projectId, entityId)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyStoryEntityRequest(entity, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkEntityNames(entity); err != nil {
+		return nil, err
+	}
+	if err := s.bibleRepo.UpdateEntity(entity); err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to update entity %d: %v", entityId, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[StoryBibleService] Updated entity %d for project %d", entityId, projectId))
+	response := toStoryEntity(entity)
+	return &response, nil
+}
+
+// DeleteEntity deletes an entity and all relations it takes part in
+func (s *StoryBibleService) DeleteEntity(projectId int64, entityId int64) error {
+	entity, err := s.getEntity(projectId, entityId)
+	if err != nil {
+		return err
+	}
+	if err := s.bibleRepo.DeleteEntity(entity); err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to delete entity %d: %v", entityId, err))
+		return err
+	}
+
+	common.SysLog(fmt.Sprintf("[StoryBibleService] Deleted entity %d for project %d", entityId, projectId))
+	return nil
+}
+
+// ListRelations returns all relations between the entities of the project
+func (s *StoryBibleService) ListRelations(projectId int64) ([]define.StoryRelation, error) {
+	relations, names, err := s.loadRelations(projectId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]define.StoryRelation, 0, len(relations))
+	for _, relation := range relations {
+		result = append(result, toStoryRelation(relation, names))
+	}
+	return result, nil
+}
+
+// CreateRelation records that the source entity is the given type of the target entity
+func (s *StoryBibleService) CreateRelation(projectId int64, req define.StoryRelationRequest) (*define.StoryRelation, error) {
+	if req.SourceId == req.TargetId {
+		return nil, fmt.Errorf("不能创建条目与自身的关系")
+	}
+	relationType := strings.TrimSpace(req.Type)
+	if relationType == "" {
+		return nil, fmt.Errorf("关系类型不能为空")
+	}
+	source, err := s.getEntity(projectId, req.SourceId)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.getEntity(projectId, req.TargetId)
+	if err != nil {
+		return nil, err
+	}
+
+	relations, err := s.bibleRepo.GetRelations(projectId)
+	if err != nil {
+		return nil, err
+	}
+	for _, relation := range relations {
+		if relation.SourceId == source.Id && relation.TargetId == target.Id && relation.Type == relationType {
+			return nil, fmt.Errorf("关系已存在")
+		}
+	}
+
+	relation := &model.StoryRelation{
+		ProjectId:   projectId,
+		SourceId:    source.Id,
+		TargetId:    target.Id,
+		Type:        relationType,
+		Description: strings.TrimSpace(req.Description),
+	}
+	if err := s.bibleRepo.CreateRelation(relation); err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to create relation for project %d: %v", projectId, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[StoryBibleService] Created relation %d for project %d", relation.Id, projectId))
+	response := toStoryRelation(relation, map[int64]string{source.Id: source.Name, target.Id: target.Name})
+	return &response, nil
+}
+
+// DeleteRelation deletes a relation
+func (s *StoryBibleService) DeleteRelation(projectId int64, relationId int64) error {
+	relation, err := s.bibleRepo.GetRelationById(relationId)
+	if err != nil {
+		return err
+	}
+	if relation == nil || relation.ProjectId != projectId {
+		return fmt.Errorf("关系不存在")
+	}
+	if err := s.bibleRepo.DeleteRelation(relation); err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to delete relation %d: %v", relationId, err))
+		return err
+	}
+	return nil
+}
+
+// StoryBiblePrompt returns the story bible entries relevant to the text, rendered for the system prompt.
+// It is empty when the project has no pinned entity and none is mentioned in the text.
+func (s *StoryBibleService) StoryBiblePrompt(projectId int64, text string) string {
+	return storyBiblePrompt(s.bibleRepo, s.promptRepo, projectId, text)
+}
+
+func (s *StoryBibleService) getEntity(projectId int64, entityId int64) (*model.StoryEntity, error) {
+	entity, err := s.bibleRepo.GetEntityById(entityId)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.ProjectId != projectId {
+		return nil, fmt.Errorf("设定条目不存在")
+	}
+	return entity, nil
+}
+
+// loadRelations 获取项目的关系和条目名称
+func (s *StoryBibleService) loadRelations(projectId int64) ([]*model.StoryRelation, map[int64]string, error) {
+	relations, err := s.bibleRepo.GetRelations(projectId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to list relations for project %d: %v", projectId, err))
+		return nil, nil, err
+	}
+	entities, err := s.bibleRepo.GetEntities(projectId, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make(map[int64]string, len(entities))
+	for _, entity := range entities {
+		names[entity.Id] = entity.Name
+	}
+	return relations, names, nil
+}
+
+// checkEntityNames 同一项目中条目的名称和别名不能重复，否则无法判断正文提到的是哪个条目
+func (s *StoryBibleService) checkEntityNames(entity *model.StoryEntity) error {
+	entities, err := s.bibleRepo.GetEntities(entity.ProjectId, "")
+	if err != nil {
+		return err
+	}
+	used := make(map[string]string)
+	for _, other := range entities {
+		if other.Id == entity.Id {
+			continue
+		}
+		for _, name := range storyEntityNames(other) {
+			used[name] = other.Name
+		}
+	}
+	for _, name := range storyEntityNames(entity) {
+		if owner, ok := used[name]; ok {
+			return fmt.Errorf("名称%s已被条目%s使用", name, owner)
+		}
+	}
+	return nil
+}
+
+// applyStoryEntityRequest 校验请求并写入设定条目
+func applyStoryEntityRequest(entity *model.StoryEntity, req define.StoryEntityRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("条目名称不能为空")
+	}
+	if _, ok := storyEntityTypeNames[req.Type]; !ok {
+		return fmt.Errorf("不支持的条目类型%s", req.Type)
+	}
+
+	var aliases []string
+	seen := map[string]bool{name: true}
+	for _, alias := range req.Aliases {
+		if alias = strings.TrimSpace(alias); alias != "" && !seen[alias] {
+			seen[alias] = true
+			aliases = append(aliases, alias)
+		}
+	}
+	var attributes []model.StoryAttribute
+	for _, attribute := range req.Attributes {
+		key := strings.TrimSpace(attribute.Key)
+		if key == "" {
+			continue
+		}
+		attributes = append(attributes, model.StoryAttribute{Key: key, Value: strings.TrimSpace(attribute.Value)})
+	}
+
+	entity.Type = req.Type
+	entity.Name = name
+	entity.SetAliases(aliases)
+	entity.Description = strings.TrimSpace(req.Description)
+	entity.SetAttributes(attributes)
+	entity.Pinned = req.Pinned
+	return nil
+}
+
+// storyEntityNames 条目的名称和别名
+func storyEntityNames(entity *model.StoryEntity) []string {
+	return append([]string{entity.Name}, entity.AliasList()...)
+}
+
+// storyBiblePrompt 组装与text相关的设定条目，没有相关条目时返回空字符串。读取失败时只记录错误，不影响续写
+func storyBiblePrompt(bibleRepo *repository.StoryBibleRepository, promptRepo *repository.PromptTemplateRepository, projectId int64, text string) string {
+	entities, err := bibleRepo.GetEntities(projectId, "")
+	if err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to load story bible for project %d: %v", projectId, err))
+		return ""
+	}
+	selected := selectStoryEntities(entities, text, storyBibleMaxEntries)
+	if len(selected) == 0 {
+		return ""
+	}
+	relations, err := bibleRepo.GetRelations(projectId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[StoryBibleService] Failed to load story relations for project %d: %v", projectId, err))
+	}
+
+	names := make(map[int64]string, len(entities))
+	for _, entity := range entities {
+		names[entity.Id] = entity.Name
+	}
+	isSelected := make(map[int64]bool, len(selected))
+	entries := make([]string, 0, len(selected)+1)
+	for _, entity := range selected {
+		isSelected[entity.Id] = true
+		entries = append(entries, formatStoryEntity(entity))
+	}
+
+	// 只带上涉及已选条目的关系，另一方没有选中时也写出其名称
+	var relationLines []string
+	for _, relation := range relations {
+		if !isSelected[relation.SourceId] && !isSelected[relation.TargetId] {
+			continue
+		}
+		line := fmt.Sprintf("%s是%s的%s", names[relation.SourceId], names[relation.TargetId], relation.Type)
+		if relation.Description != "" {
+			line += "（" + relation.Description + "）"
+		}
+		relationLines = append(relationLines, line)
+	}
+	if len(relationLines) > 0 {
+		entries = append(entries, "【关系】\n"+strings.Join(relationLines, "\n"))
+	}
+
+	return RenderPrompt(promptRepo, PromptStoryBible, map[string]string{"entries": strings.Join(entries, "\n\n")})
+}
+
+// selectStoryEntities 选出常驻的条目和text中提到的条目，常驻的在前，提到的按最后一次出现的位置从后往前排列
+func selectStoryEntities(entities []*model.StoryEntity, text string, limit int) []*model.StoryEntity {
+	type mention struct {
+		entity   *model.StoryEntity
+		position int
+	}
+	var pinned []*model.StoryEntity
+	var mentioned []mention
+	for _, entity := range entities {
+		if entity.Pinned {
+			pinned = append(pinned, entity)
+			continue
+		}
+		position := -1
+		for _, name := range storyEntityNames(entity) {
+			if utf8.RuneCountInString(name) < minStoryNameChars {
+				continue
+			}
+			if index := strings.LastIndex(text, name); index > position {
+				position = index
+			}
+		}
+		if position >= 0 {
+			mentioned = append(mentioned, mention{entity: entity, position: position})
+		}
+	}
+	sort.SliceStable(mentioned, func(i, j int) bool {
+		return mentioned[i].position > mentioned[j].position
+	})
+
+	selected := pinned
+	for _, m := range mentioned {
+		selected = append(selected, m.entity)
+	}
+	if len(selected) > limit {
+		selected = selected[:limit]
+	}
+	return selected
+}
+
+// formatStoryEntity 条目在提示词中的格式，如“【人物】张三（别名：小三）”，下面是描述和属性
+func formatStoryEntity(entity *model.StoryEntity) string {
+	var builder strings.Builder
+	builder.WriteString("【" + storyEntityTypeNames[entity.Type] + "】" + entity.Name)
+	if aliases := entity.AliasList(); len(aliases) > 0 {
+		builder.WriteString("（别名：" + strings.Join(aliases, "、") + "）")
+	}
+	if entity.Description != "" {
+		builder.WriteString("\n" + truncateRunes(entity.Description, storyBibleDescriptionChars))
+	}
+	if attributes := entity.AttributeList(); len(attributes) > 0 {
+		parts := make([]string, 0, len(attributes))
+		for _, attribute := range attributes {
+			parts = append(parts, attribute.Key+"："+attribute.Value)
+		}
+		builder.WriteString("\n" + strings.Join(parts, "；"))
+	}
+	return builder.String()
+}
+
+func toStoryEntity(entity *model.StoryEntity) define.StoryEntity {
+	aliases := entity.AliasList()
+	if aliases == nil {
+		aliases = []string{}
+	}
+	attributes := make([]define.StoryAttribute, 0)
+	for _, attribute := range entity.AttributeList() {
+		attributes = append(attributes, define.StoryAttribute{Key: attribute.Key, Value: attribute.Value})
+	}
+	return define.StoryEntity{
+		Id:          entity.Id,
+		Type:        entity.Type,
+		Name:        entity.Name,
+		Aliases:     aliases,
+		Description: entity.Description,
+		Attributes:  attributes,
+		Pinned:      entity.Pinned,
+		UpdatedAt:   entity.UpdatedAt,
+	}
+}
+
+func toStoryRelation(relation *model.StoryRelation, names map[int64]string) define.StoryRelation {
+	return define.StoryRelation{
+		Id:          relation.Id,
+		SourceId:    relation.SourceId,
+		SourceName:  names[relation.SourceId],
+		TargetId:    relation.TargetId,
+		TargetName:  names[relation.TargetId],
+		Type:        relation.Type,
+		Description: relation.Description,
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/repository"
+	"strings"
+)
+
+// maxUserStylePresets 每个用户最多可创建的私有预设数量
+const maxUserStylePresets = 20
+
+// StylePresetService 写作风格预设服务
+type StylePresetService struct {
+	presetRepo *repository.StylePresetRepository
+}
+
+// NewStylePresetService 创建风格预设服务实例
+func NewStylePresetService(presetRepo *repository.StylePresetRepository) *StylePresetService {
+	return &StylePresetService{presetRepo: presetRepo}
+}
+
+// ListPresets 获取启用的公共预设和用户的私有预设，公共预设在前
+func (s *StylePresetService) ListPresets(userId int64) ([]define.StylePresetResponse, error) {
+	presets, err := s.presetRepo.GetAvailablePresets(userId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("[StylePresetService] Failed to list style presets for user %d: %v", userId, err))
+		return nil, err
+	}
+	return toStylePresetResponses(presets), nil
+}
+
+// CreateUserPreset 创建只有用户自己可见的私有预设
+func (s *StylePresetService) CreateUserPreset(userId int64, req define.StylePresetRequest) (*define.StylePresetResponse, error) {
+	count, err := s.presetRepo.CountUserPresets(userId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxUserStylePresets {
+		return nil, fmt.Errorf("最多只能创建%d个私有风格预设", maxUserStylePresets)
+	}
+
+	preset := &model.StylePreset{UserId: userId, Enabled: true}
+	if err := applyStylePresetRequest(preset, req); err != nil {
+		return nil, err
+	}
+	// 私有预设没有标识，只能按ID使用
+	preset.Code = ""
+	if err := s.presetRepo.CreatePreset(preset); err != nil {
+		common.SysError(fmt.Sprintf("[StylePresetService] Failed to create style preset for user %d: %v", userId, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[StylePresetService] User %d created style preset %d", userId, preset.Id))
+	response := toStylePresetResponse(preset)
+	return &response, nil
+}
+
+// ListPublicPresets 获取全部公共预设（包括已禁用的），供管理员使用
+func (s *StylePresetService) ListPublicPresets() ([]define.StylePresetResponse, error) {
+	presets, err := s.presetRepo.GetPublicPresets()
+	if err != nil {
+		return nil, err
+	}
+	return toStylePresetResponses(presets), nil
+}
+
+// CreatePublicPreset 创建所有用户可用的公共预设
+func (s *StylePresetService) CreatePublicPreset(req define.StylePresetRequest) (*define.StylePresetResponse, error) {
+	preset := &model.StylePreset{Enabled: true}
+	if err := applyStylePresetRequest(preset, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkPresetCode(preset); err != nil {
+		return nil, err
+	}
+	if err := s.presetRepo.CreatePreset(preset); err != nil {
+		common.SysError(fmt.Sprintf("[StylePresetService] Failed to create public style preset: %v", err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[StylePresetService] Created public style preset %d (%s)", preset.Id, preset.Name))
+	response := toStylePresetResponse(preset)
+	return &response, nil
+}
+
+// UpdatePublicPreset 更新公共预设
+func (s *StylePresetService) UpdatePublicPreset(id int64, req define.StylePresetRequest) (*define.StylePresetResponse, error) {
+	preset, err := s.getPublicPreset(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyStylePresetRequest(preset, req); err != nil {
+		return nil, err
+	}
+	if err := s.checkPresetCode(preset); err != nil {
+		return nil, err
+	}
+	if err := s.presetRepo.UpdatePreset(preset); err != nil {
+		common.SysError(fmt.Sprintf("[StylePresetService] Failed to update public style preset %d: %v", id, err))
+		return nil, err
+	}
+
+	common.SysLog(fmt.Sprintf("[StylePresetService] Updated public style preset %d", id))
+	response := toStylePresetResponse(preset)
+	return &response, nil
+}
+
+// DeletePublicPreset 删除公共预设，使用该预设生成的版本仍保留预设ID
+func (s *StylePresetService) DeletePublicPreset(id int64) error {
+	preset, err := s.getPublicPreset(id)
+	if err != nil {
+		return err
+	}
+	if err := s.presetRepo.DeletePreset(preset); err != nil {
+		common.SysError(fmt.Sprintf("[StylePresetService] Failed to delete public style preset %d: %v", id, err))
+		return err
+	}
+
+	common.SysLog(fmt.Sprintf("[StylePresetService] Deleted public style preset %d", id))
+	return nil
+}
+
+func (s *StylePresetService) getPublicPreset(id int64) (*model.StylePreset, error) {
+	preset, err := s.presetRepo.GetPresetById(id)
+	if err != nil {
+		return nil, err
+	}
+	if preset == nil || preset.UserId != 0 {
+		return nil, fmt.Errorf("风格预设不存在")
+	}
+	return preset, nil
+}
+
+// checkPresetCode 公共预设的标识不能重复
+func (s *StylePresetService) checkPresetCode(preset *model.StylePreset) error {
+	if preset.Code == "" {
+		return nil
+	}
+	presets, err := s.presetRepo.GetPublicPresets()
+	if err != nil {
+		return err
+	}
+	for _, other := range presets {
+		if other.Code == preset.Code && other.Id != preset.Id {
+			return fmt.Errorf("风格标识%s已被预设%s使用", preset.Code, other.Name)
+		}
+	}
+	return nil
+}
+
+// applyStylePresetRequest 校验请求并写入预设
+func applyStylePresetRequest(preset *model.StylePreset, req define.StylePresetRequest) error {
+	name := strings.TrimSpace(req.Name)
+	systemPrompt := strings.TrimSpace(req.SystemPrompt)
+	if name == "" || systemPrompt == "" {
+		return fmt.Errorf("风格名称和提示词不能为空")
+	}
+	if req.Temperature < 0 || req.Temperature > 2 {
+		return fmt.Errorf("温度应在0到2之间")
+	}
+	if req.MaxWordLimit > 0 && req.MinWordLimit > req.MaxWordLimit {
+		return fmt.Errorf("最小字数不能大于最大字数")
+	}
+	if req.DefaultWordLimit > 0 && (req.DefaultWordLimit < req.MinWordLimit || (req.MaxWordLimit > 0 && req.DefaultWordLimit > req.MaxWordLimit)) {
+		return fmt.Errorf("默认字数应在最小字数和最大字数之间")
+	}
+
+	var examples []string
+	for _, example := range req.Examples {
+		if example = strings.TrimSpace(example); example != "" {
+			examples = append(examples, example)
+		}
+	}
+
+	preset.Code = strings.TrimSpace(req.Code)
+	preset.Name = name
+	preset.Description = strings.TrimSpace(req.Description)
+	preset.SystemPrompt = systemPrompt
+	preset.SetExamples(examples)
+	preset.Temperature = req.Temperature
+	preset.DefaultWordLimit = req.DefaultWordLimit
+	preset.MinWordLimit = req.MinWordLimit
+	preset.MaxWordLimit = req.MaxWordLimit
+	preset.SortOrder = req.SortOrder
+	if req.Enabled != nil && preset.UserId == 0 {
+		preset.Enabled = *req.Enabled
+	}
+	return nil
+}
+
+// resolveStylePreset 查找续写使用的风格预设：优先按ID查找用户可用的预设，其次按标识查找公共预设
+// 两者都未指定时返回nil，表示不使用预设；标识没有对应的公共预设时也返回nil，由调用方按旧版的自由风格文本处理
+func resolveStylePreset(presetRepo *repository.StylePresetRepository, userId int64, styleId int64, code string) (*model.StylePreset, error) {
+	if styleId > 0 {
+		preset, err := presetRepo.GetPresetById(styleId)
+		if err != nil {
+			return nil, err
+		}
+		if preset == nil || !preset.Enabled || (preset.UserId != 0 && preset.UserId != userId) {
+			return nil, fmt.Errorf("风格预设不存在")
+		}
+		return preset, nil
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" || code == "default" {
+		return nil, nil
+	}
+	return presetRepo.GetPublicPresetByCode(code)
+}
+
+// presetWordLimit 请求未指定字数时使用预设的默认字数，超出预设范围时截断
+func presetWordLimit(preset *model.StylePreset, wordLimit int) int {
+	if preset == nil {
+		return wordLimit
+	}
+	if wordLimit <= 0 {
+		return preset.DefaultWordLimit
+	}
+	if wordLimit < preset.MinWordLimit {
+		return preset.MinWordLimit
+	}
+	if preset.MaxWordLimit > 0 && wordLimit > preset.MaxWordLimit {
+		return preset.MaxWordLimit
+	}
+	return wordLimit
+}
+
+func toStylePresetResponses(presets []*model.StylePreset) []define.StylePresetResponse {
+	responses := make([]define.StylePresetResponse, 0, len(presets))
+	for _, preset := range presets {
+		responses = append(responses, toStylePresetResponse(preset))
+	}
+	return responses
+}
+
+func toStylePresetResponse(preset *model.StylePreset) define.StylePresetResponse {
+	examples := preset.ExampleList()
+	if examples == nil {
+		examples = []string{}
+	}
+	return define.StylePresetResponse{
+		Id:               preset.Id,
+		Code:             preset.Code,
+		Name:             preset.Name,
+		Description:      preset.Description,
+		SystemPrompt:     preset.SystemPrompt,
+		Examples:         examples,
+		Temperature:      preset.Temperature,
+		DefaultWordLimit: preset.DefaultWordLimit,
+		MinWordLimit:     preset.MinWordLimit,
+		MaxWordLimit:     preset.MaxWordLimit,
+		SortOrder:        preset.SortOrder,
+		Enabled:          preset.Enabled,
+		IsPrivate:        preset.UserId != 0,
+		UpdatedAt:        preset.UpdatedAt,
+	}
+}
@@ -0,0 +1,55 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-template/define"
+	"gin-template/model"
+	"gin-template/service"
+	"gin-template/service/agent"
+	"gin-template/task"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// OutlineGenerationJob 执行异步AI续写，结果与同步续写接口的返回相同，只在成功时保存版本并扣费
+func OutlineGenerationJob(outlineService *service.OutlineService) task.JobHandler {
+	return func(ctx context.Context, job model.AIJob, progress *task.JobProgress) (interface{}, error) {
+		var req define.AIGenerateRequest
+		if err := json.Unmarshal([]byte(job.Payload), &req); err != nil {
+			return nil, fmt.Errorf("参数解析失败: %w", err)
+		}
+		return outlineService.GenerateOutlineForJob(ctx, job.UserId, job.ProjectId, req, progress)
+	}
+}
+
+// AgentChatJob 执行异步智能体对话并扣费，指定项目时带上消息中提到的设定条目
+func AgentChatJob(bibleService *service.StoryBibleService) task.JobHandler {
+	return func(ctx context.Context, job model.AIJob, progress *task.JobProgress) (interface{}, error) {
+		var req define.AgentChatJobRequest
+		if err := json.Unmarshal([]byte(job.Payload), &req); err != nil {
+			return nil, fmt.Errorf("参数解析失败: %w", err)
+		}
+		agentService := agent.GetGlobalAgentService()
+		if agentService == nil {
+			return nil, fmt.Errorf("智能体服务未启用")
+		}
+
+		var systemMessages []*schema.Message
+		if req.ProjectId > 0 {
+			if bible := bibleService.StoryBiblePrompt(req.ProjectId, req.Message); bible != "" {
+				systemMessages = append(systemMessages, schema.SystemMessage(bible))
+			}
+		}
+		result, err := agentService.GenerateWithBilling(ctx, job.UserId, &define.GenerateRequest{
+			SessionID:      req.SessionID,
+			Messages:       []*schema.Message{schema.UserMessage(req.Message)},
+			SystemMessages: systemMessages,
+		}, progress)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"gin-template/common"
+	"gin-template/define"
 	"gin-template/model"
 	"gin-template/repository"
 	"gin-template/task"
@@ -141,6 +142,86 @@ func (s *TokenService) DebitToken(userID int64, amount int64, transactionUUID st
 	return userToken, nil
 }
 
+// GetAvailableBalance retrieves the balance a user can spend, excluding active holds
+func (s *TokenService) GetAvailableBalance(userID int64) (int64, error) {
+	balance, err := s.tokenRepo.GetAvailableBalance(userID)
+	if err != nil {
+		common.SysError(tokenServiceLogPrefix + fmt.Sprintf("Failed to get available token balance for user %d: %v", userID, err))
+		return 0, err
+	}
+	return balance, nil
+}
+
+// ReserveToken places a hold on a user's tokens before an AI call
+// The hold is excluded from the available balance until it is settled, released or expires after common.TokenHoldMinutes
+func (s *TokenService) ReserveToken(userID int64, amount int64, holdUUID string, description string, relatedEntityType string, relatedEntityID string) (*model.TokenHold, error) {
+	if amount <= 0 {
+		common.SysError(tokenServiceLogPrefix + fmt.Sprintf("Token reserve amount must be positive, user: %d, amount: %d", userID, amount))
+		return nil, fmt.Errorf("reserve amount must be positive")
+	}
+
+	expiresAt := time.Now().Add(tokenHoldDuration()).Unix()
+
+	common.SysLog(tokenServiceLogPrefix + fmt.Sprintf("Attempting to reserve %d tokens for user %d, hold ID: %s", amount, userID, holdUUID))
+
+	hold, err := s.tokenRepo.ReserveUserToken(userID, amount, holdUUID, description, relatedEntityType, relatedEntityID, expiresAt)
+	if err != nil {
+		common.SysError(tokenServiceLogPrefix + fmt.Sprintf("Failed to reserve tokens for user %d: %v", userID, err))
+		return nil, err
+	}
+
+	common.SysLog(tokenServiceLogPrefix + fmt.Sprintf("Tokens reserved successfully for user %d, hold ID: %s, amount: %d", userID, hold.HoldUUID, hold.Amount))
+	return hold, nil
+}
+
+// SettleToken settles a hold to the actual amount used, deducting it from the user's balance
+// The full amount is deducted even when it exceeds the hold, which may leave the balance negative
+func (s *TokenService) SettleToken(holdUUID string, actualAmount int64, transactionUUID string, transactionType string, description string) (*model.UserToken, error) {
+	common.SysLog(tokenServiceLogPrefix + fmt.Sprintf("Attempting to settle hold %s with %d tokens, transaction ID: %s, type: %s", holdUUID, actualAmount, transactionUUID, transactionType))
+
+	userToken, hold, err := s.tokenRepo.SettleTokenHold(holdUUID, actualAmount, transactionUUID, transactionType, description)
+	if err != nil {
+		common.SysError(tokenServiceLogPrefix + fmt.Sprintf("Failed to settle hold %s: %v", holdUUID, err))
+		return nil, err
+	}
+	if hold.SettledAmount > hold.Amount {
+		common.SysError(tokenServiceLogPrefix + fmt.Sprintf("Hold %s settled %d tokens, %d over the hold of %d, user %d balance: %d",
+			holdUUID, hold.SettledAmount, hold.SettledAmount-hold.Amount, hold.Amount, hold.UserID, userToken.Balance))
+	}
+
+	common.SysLog(tokenServiceLogPrefix + fmt.Sprintf("Hold %s settled successfully, user %d new balance: %d", holdUUID, userToken.UserID, userToken.Balance))
+	return userToken, nil
+}
+
+// ExtendToken pushes the expiry of an active hold to common.TokenHoldMinutes from now, so a long AI stream keeps its hold until it settles
+func (s *TokenService) ExtendToken(holdUUID string) error {
+	if err := s.tokenRepo.ExtendTokenHold(holdUUID, time.Now().Add(tokenHoldDuration()).Unix()); err != nil {
+		common.SysError(tokenServiceLogPrefix + fmt.Sprintf("Failed to extend hold %s: %v", holdUUID, err))
+		return err
+	}
+	return nil
+}
+
+// tokenHoldDuration 预扣的有效期，至少1分钟
+func tokenHoldDuration() time.Duration {
+	holdMinutes := common.TokenHoldMinutes
+	if holdMinutes < 1 {
+		holdMinutes = 1
+	}
+	return time.Duration(holdMinutes) * time.Minute
+}
+
+// ReleaseToken releases a hold without deducting any tokens
+func (s *TokenService) ReleaseToken(holdUUID string) error {
+	if err := s.tokenRepo.ReleaseTokenHold(holdUUID); err != nil {
+		common.SysError(tokenServiceLogPrefix + fmt.Sprintf("Failed to release hold %s: %v", holdUUID, err))
+		return err
+	}
+
+	common.SysLog(tokenServiceLogPrefix + fmt.Sprintf("Hold %s released", holdUUID))
+	return nil
+}
+
 func (s *TokenService) CreditTokenWithCompensation(userID int64, amount int64, transactionUUID string,
 	transactionType string, description string, relatedEntityType string, relatedEntityID string) (*model.UserToken, error) {
 
@@ -227,6 +308,45 @@ func (s *TokenService) DebitTokenWithCompensation(userID int64, amount int64, tr
 	return nil, fmt.Errorf("操作已进入补偿流程，请稍后查询结果")
 }
 
+// GetUserTokenSummary retrieves a user's balance, available balance and a page of transactions
+func (s *TokenService) GetUserTokenSummary(userID int64, page, limit int) (*define.UserTokenResponse, error) {
+	userToken, err := s.GetUserToken(userID)
+	if err != nil {
+		return nil, err
+	}
+	available, err := s.GetAvailableBalance(userID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, total, err := s.GetUserTransactions(userID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]define.TokenRecord, 0, len(transactions))
+	for _, transaction := range transactions {
+		records = append(records, define.TokenRecord{
+			Id:          transaction.ID,
+			Amount:      transaction.Amount,
+			Balance:     transaction.BalanceAfter,
+			Type:        transaction.Type,
+			Description: transaction.Description,
+			CreatedAt:   transaction.CreatedAt,
+		})
+	}
+	return &define.UserTokenResponse{
+		Balance:          userToken.Balance,
+		AvailableBalance: available,
+		Records:          records,
+		Pagination: define.Pagination{
+			Total: total,
+			Page:  page,
+			Limit: limit,
+			Pages: (total + int64(limit) - 1) / int64(limit),
+		},
+	}, nil
+}
+
 // GetUserTransactions retrieves a user's transaction history
 func (s *TokenService) GetUserTransactions(userID int64, page, limit int) ([]model.TokenTransaction, int64, error) {
 	common.SysLog(tokenServiceLogPrefix + fmt.Sprintf("Retrieving transactions for user %d, page: %d, limit: %d", userID, page, limit))
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"gin-template/model"
+	"testing"
+	"time"
+)
+
+// TestSettleTokenOverHold 结算按实际用量全额扣减，超出预扣的部分允许余额为负
+func TestSettleTokenOverHold(t *testing.T) {
+	tests := []struct {
+		name        string
+		balance     int64
+		hold        int64
+		actual      int64
+		wantBalance int64
+	}{
+		{name: "不超过预扣", balance: 100, hold: 50, actual: 30, wantBalance: 70},
+		{name: "超过预扣", balance: 100, hold: 50, actual: 80, wantBalance: 20},
+		{name: "超过余额", balance: 100, hold: 100, actual: 150, wantBalance: -50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, db := setupFakeEnvironment(t)
+			const userId = int64(7)
+			if _, err := GetTokenService().InitUserTokenAccount(userId, tt.balance); err != nil {
+				t.Fatalf("InitUserTokenAccount() error = %v", err)
+			}
+			hold, err := GetTokenService().ReserveToken(userId, tt.hold, "", "test", "project", "1")
+			if err != nil {
+				t.Fatalf("ReserveToken() error = %v", err)
+			}
+			userToken, err := GetTokenService().SettleToken(hold.HoldUUID, tt.actual, "", "ai_generation_debit", "test")
+			if err != nil {
+				t.Fatalf("SettleToken() error = %v", err)
+			}
+			if userToken.Balance != tt.wantBalance {
+				t.Errorf("balance = %d, want %d", userToken.Balance, tt.wantBalance)
+			}
+
+			// 预扣记录保留预扣金额和实际扣减的金额，交易流水与实际扣减一致
+			var settled model.TokenHold
+			db.Where("hold_uuid = ?", hold.HoldUUID).First(&settled)
+			if settled.Amount != tt.hold || settled.SettledAmount != tt.actual {
+				t.Errorf("hold amount = %d, settled = %d, want %d, %d", settled.Amount, settled.SettledAmount, tt.hold, tt.actual)
+			}
+			var transaction model.TokenTransaction
+			db.Where("transaction_uuid = ?", settled.TransactionUUID).First(&transaction)
+			if transaction.Amount != -tt.actual || transaction.BalanceAfter != tt.wantBalance {
+				t.Errorf("transaction = %+v, want amount %d", transaction, -tt.actual)
+			}
+
+			// 再次结算不重复扣减
+			userToken, err = GetTokenService().SettleToken(hold.HoldUUID, tt.actual, "", "ai_generation_debit", "test")
+			if err != nil || userToken.Balance != tt.wantBalance {
+				t.Errorf("SettleToken() again = %v, %v, want balance %d", userToken, err, tt.wantBalance)
+			}
+		})
+	}
+}
+
+// TestExtendToken 只延长未过期的预扣
+func TestExtendToken(t *testing.T) {
+	_, db := setupFakeEnvironment(t)
+	const userId = int64(7)
+	if _, err := GetTokenService().InitUserTokenAccount(userId, 100); err != nil {
+		t.Fatalf("InitUserTokenAccount() error = %v", err)
+	}
+	active, err := GetTokenService().ReserveToken(userId, 10, "", "test", "project", "1")
+	if err != nil {
+		t.Fatalf("ReserveToken() error = %v", err)
+	}
+	expired, err := GetTokenService().ReserveToken(userId, 10, "", "test", "project", "1")
+	if err != nil {
+		t.Fatalf("ReserveToken() error = %v", err)
+	}
+	now := time.Now().Unix()
+	db.Model(&model.TokenHold{}).Where("hold_uuid = ?", active.HoldUUID).Update("expires_at", now+5)
+	db.Model(&model.TokenHold{}).Where("hold_uuid = ?", expired.HoldUUID).Update("expires_at", now-5)
+
+	for _, holdUUID := range []string{active.HoldUUID, expired.HoldUUID} {
+		if err := GetTokenService().ExtendToken(holdUUID); err != nil {
+			t.Fatalf("ExtendToken() error = %v", err)
+		}
+	}
+
+	var hold model.TokenHold
+	db.Where("hold_uuid = ?", active.HoldUUID).First(&hold)
+	if hold.ExpiresAt < now+int64(tokenHoldDuration()/time.Second)-5 {
+		t.Errorf("active hold expires at %d, want extended", hold.ExpiresAt)
+	}
+	var expiredHold model.TokenHold
+	db.Where("hold_uuid = ?", expired.HoldUUID).First(&expiredHold)
+	if expiredHold.ExpiresAt != now-5 {
+		t.Errorf("expired hold expires at %d, want unchanged %d", expiredHold.ExpiresAt, now-5)
+	}
+}
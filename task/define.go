@@ -5,3 +5,9 @@ const (
 	TokenCreditCompensationTask   = "token_credit_compensation"
 	TokenDebitCompensationTask    = "token_debit_compensation"
 )
+
+// 异步AI任务的类型
+const (
+	OutlineGenerationJob = "outline_generation" // AI续写
+	AgentChatJob         = "agent_chat"         // 智能体对话
+)
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-template/common"
+	"gin-template/model"
+	"time"
+)
+
+// JobProgress 记录执行中任务的扣费和保存进度，实现define.AIJobProgress
+type JobProgress struct {
+	queue *DBJobQueue
+	job   model.AIJob
+}
+
+func (p *JobProgress) TransactionUUID() string {
+	return p.job.JobID
+}
+
+func (p *JobProgress) Generated(output interface{}) bool {
+	if p.job.Generated == "" {
+		return false
+	}
+	if err := json.Unmarshal([]byte(p.job.Generated), output); err != nil {
+		common.SysError(fmt.Sprintf("解析异步AI任务 %s 的生成结果失败：%v", p.job.JobID, err))
+		return false
+	}
+	return true
+}
+
+func (p *JobProgress) MarkGenerated(output interface{}) {
+	data, _ := json.Marshal(output)
+	p.job.Generated = string(data)
+	if err := p.queue.MarkJobGenerated(p.job.JobID, p.job.Generated); err != nil {
+		common.SysError(fmt.Sprintf("记录异步AI任务 %s 的生成结果失败：%v", p.job.JobID, err))
+	}
+}
+
+func (p *JobProgress) Settled() (int64, bool) {
+	return p.job.SettledAmount, p.job.SettledAt > 0
+}
+
+func (p *JobProgress) MarkSettled(amount int64) {
+	p.job.SettledAt, p.job.SettledAmount = time.Now().Unix(), amount
+	if err := p.queue.MarkJobSettled(p.job.JobID, amount); err != nil {
+		common.SysError(fmt.Sprintf("记录异步AI任务 %s 已扣费失败：%v", p.job.JobID, err))
+	}
+}
+
+func (p *JobProgress) MarkSaved(result interface{}) {
+	data, _ := json.Marshal(result)
+	if err := p.queue.MarkJobSaved(p.job.JobID, string(data)); err != nil {
+		common.SysError(fmt.Sprintf("记录异步AI任务 %s 已保存失败：%v", p.job.JobID, err))
+	}
+}
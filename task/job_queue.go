@@ -0,0 +1,208 @@
+package task
+
+import (
+	"fmt"
+	"gin-template/common"
+	"gin-template/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// DBJobQueue 保存在数据库中的异步AI任务队列
+type DBJobQueue struct {
+	db *gorm.DB
+}
+
+func NewDBJobQueue(db *gorm.DB) (*DBJobQueue, error) {
+	return &DBJobQueue{db: db}, nil
+}
+
+func (d *DBJobQueue) AddJob(job *model.AIJob) error {
+	job.Status = model.AIJobQueued
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = 3
+	}
+	return d.db.Create(job).Error
+}
+
+// GetJob 获取任务，不存在时返回nil
+func (d *DBJobQueue) GetJob(jobId string) (*model.AIJob, error) {
+	var job model.AIJob
+	err := d.db.Where("job_id = ?", jobId).First(&job).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetUserJobs 分页获取用户的任务，新任务在前，status为空时返回所有状态
+func (d *DBJobQueue) GetUserJobs(userId int64, status string, offset, limit int) ([]*model.AIJob, int64, error) {
+	var jobs []*model.AIJob
+	var total int64
+	query := d.db.Model(&model.AIJob{}).Where("user_id = ?", userId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+	return jobs, total, err
+}
+
+// CountActiveJobs 统计用户排队中和执行中的任务数
+func (d *DBJobQueue) CountActiveJobs(userId int64) (int64, error) {
+	var count int64
+	err := d.db.Model(&model.AIJob{}).
+		Where("user_id = ? AND status IN ?", userId, []model.AIJobStatus{model.AIJobQueued, model.AIJobRunning}).
+		Count(&count).Error
+	return count, err
+}
+
+// ClaimQueuedJobs 按提交顺序领取最多limit个排队中的任务，领取的任务标记为执行中
+func (d *DBJobQueue) ClaimQueuedJobs(limit int) []model.AIJob {
+	var jobs []model.AIJob
+	if limit <= 0 {
+		return jobs
+	}
+	now := time.Now().Unix()
+
+	// 使用事务保证数据一致性
+	var claimed []model.AIJob
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定选中的行防止多个实例重复领取
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ?", model.AIJobQueued).
+			Order("created_at ASC").Limit(limit).
+			Find(&jobs).Error; err != nil {
+			return err
+		}
+
+		// 不支持行锁的数据库（如SQLite）上其他实例可能同时选中同一任务，只领取确实由排队中改为执行中的任务
+		for _, job := range jobs {
+			result := tx.Model(&model.AIJob{}).
+				Where("job_id = ? AND status = ?", job.JobID, model.AIJobQueued).
+				Updates(map[string]interface{}{
+					"status":       model.AIJobRunning,
+					"attempts":     gorm.Expr("attempts + 1"),
+					"started_at":   now,
+					"heartbeat_at": now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			job.Status = model.AIJobRunning
+			job.Attempts++
+			job.StartedAt = now
+			job.HeartbeatAt = now
+			claimed = append(claimed, job)
+		}
+		return nil
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("领取排队的异步AI任务失败：%v", err))
+		return nil
+	}
+	return claimed
+}
+
+// Heartbeat 更新执行中任务的心跳时间，返回任务是否已被取消
+func (d *DBJobQueue) Heartbeat(jobId string) (bool, error) {
+	if err := d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ?", jobId, model.AIJobRunning).
+		Update("heartbeat_at", time.Now().Unix()).Error; err != nil {
+		return false, err
+	}
+	var job model.AIJob
+	if err := d.db.Select("cancel_requested").Where("job_id = ?", jobId).First(&job).Error; err != nil {
+		return false, err
+	}
+	return job.CancelRequested, nil
+}
+
+// FinishJob 记录执行中任务的最终状态，任务已不在执行中时不修改
+func (d *DBJobQueue) FinishJob(jobId string, status model.AIJobStatus, result string, lastError string) error {
+	return d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ?", jobId, model.AIJobRunning).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"result":      result,
+			"last_error":  lastError,
+			"finished_at": time.Now().Unix(),
+		}).Error
+}
+
+// MarkJobGenerated 记录执行中的任务在扣费前的生成结果，之后重新执行时直接使用该结果
+func (d *DBJobQueue) MarkJobGenerated(jobId string, generated string) error {
+	return d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ?", jobId, model.AIJobRunning).
+		Update("generated", generated).Error
+}
+
+// MarkJobSettled 记录执行中的任务已扣费
+func (d *DBJobQueue) MarkJobSettled(jobId string, amount int64) error {
+	return d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ?", jobId, model.AIJobRunning).
+		Updates(map[string]interface{}{
+			"settled_at":     time.Now().Unix(),
+			"settled_amount": amount,
+		}).Error
+}
+
+// MarkJobSaved 记录执行中的任务已保存结果，之后重新执行时直接以该结果结束
+func (d *DBJobQueue) MarkJobSaved(jobId string, result string) error {
+	return d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ?", jobId, model.AIJobRunning).
+		Updates(map[string]interface{}{
+			"saved_at": time.Now().Unix(),
+			"result":   result,
+		}).Error
+}
+
+// CancelJob 取消任务：排队中的任务直接取消，执行中的任务标记为待取消，由执行任务的工作协程中断
+func (d *DBJobQueue) CancelJob(jobId string) error {
+	result := d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ?", jobId, model.AIJobQueued).
+		Updates(map[string]interface{}{
+			"status":      model.AIJobCancelled,
+			"finished_at": time.Now().Unix(),
+		})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ?", jobId, model.AIJobRunning).
+		Update("cancel_requested", true).Error
+}
+
+// GetStaleJobs 获取心跳时间早于before的执行中任务，这些任务所在的进程已退出
+func (d *DBJobQueue) GetStaleJobs(before int64) ([]model.AIJob, error) {
+	var jobs []model.AIJob
+	err := d.db.Where("status = ? AND heartbeat_at < ?", model.AIJobRunning, before).Find(&jobs).Error
+	return jobs, err
+}
+
+// RecoverStaleJob 将中断的任务重新排队，已被取消或重试次数用尽的任务结束
+// 只修改心跳时间仍早于before的任务，避免与其他实例同时处理
+func (d *DBJobQueue) RecoverStaleJob(job model.AIJob, before int64) (model.AIJobStatus, error) {
+	updates := map[string]interface{}{"status": model.AIJobQueued}
+	status := model.AIJobQueued
+	if job.CancelRequested {
+		status = model.AIJobCancelled
+		updates = map[string]interface{}{"status": status, "finished_at": time.Now().Unix()}
+	} else if job.Attempts >= job.MaxAttempts {
+		status = model.AIJobFailed
+		updates = map[string]interface{}{"status": status, "last_error": "任务执行中断，重试次数用尽", "finished_at": time.Now().Unix()}
+	}
+	err := d.db.Model(&model.AIJob{}).
+		Where("job_id = ? AND status = ? AND heartbeat_at < ?", job.JobID, model.AIJobRunning, before).
+		Updates(updates).Error
+	return status, err
+}
@@ -0,0 +1,166 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-template/common"
+	"gin-template/model"
+	"time"
+)
+
+// JobHandler 执行一个异步AI任务，返回的结果以JSON保存；任务被取消时ctx被取消，处理器应尽快返回ctx.Err()
+// 处理器只在成功时扣费，失败或取消时释放预扣；生成、扣费和保存结果后通过progress记录，进程中断后重新执行时不再重复
+type JobHandler func(ctx context.Context, job model.AIJob, progress *JobProgress) (interface{}, error)
+
+// DBJobScheduler 异步AI任务调度器，定期从队列领取任务交给固定数量的工作协程执行
+type DBJobScheduler struct {
+	queue    *DBJobQueue
+	handlers map[string]JobHandler
+	workers  int
+	interval time.Duration
+	slots    chan struct{} // 空闲的工作协程
+}
+
+// jobStaleIntervals 执行中的任务超过多少个扫描间隔没有心跳时视为中断
+const jobStaleIntervals = 10
+
+func NewDBJobScheduler(queue *DBJobQueue, workers int, interval time.Duration) *DBJobScheduler {
+	common.SysLog(fmt.Sprintf("初始化异步AI任务调度器，工作协程数：%d，扫描间隔：%v", workers, interval))
+	d := &DBJobScheduler{
+		queue:    queue,
+		handlers: make(map[string]JobHandler),
+		workers:  workers,
+		interval: interval,
+	}
+	if workers > 0 {
+		d.slots = make(chan struct{}, workers)
+	}
+	return d
+}
+
+func (d *DBJobScheduler) RegisterHandler(jobType string, handler JobHandler) {
+	d.handlers[jobType] = handler
+	common.SysLog(fmt.Sprintf("注册异步AI任务处理器，类型：%s", jobType))
+}
+
+func (d *DBJobScheduler) Start() {
+	if d.workers <= 0 {
+		common.SysLog("异步AI任务调度器未启用")
+		return
+	}
+	common.SysLog("异步AI任务调度器启动")
+
+	ticker := time.NewTicker(d.interval)
+	go func() {
+		d.recoverStaleJobs()
+		d.dispatchJobs()
+		for range ticker.C {
+			d.recoverStaleJobs()
+			d.dispatchJobs()
+		}
+	}()
+}
+
+// 中断的任务（所在进程已退出）重新排队
+func (d *DBJobScheduler) recoverStaleJobs() {
+	before := time.Now().Add(-jobStaleIntervals * d.interval).Unix()
+	jobs, err := d.queue.GetStaleJobs(before)
+	if err != nil {
+		common.SysError(fmt.Sprintf("查询中断的异步AI任务失败：%v", err))
+		return
+	}
+	for _, job := range jobs {
+		status, err := d.queue.RecoverStaleJob(job, before)
+		if err != nil {
+			common.SysError(fmt.Sprintf("恢复异步AI任务 %s 失败：%v", job.JobID, err))
+			continue
+		}
+		common.SysLog(fmt.Sprintf("异步AI任务 %s 执行中断，状态改为 %s", job.JobID, status))
+	}
+}
+
+// 按空闲的工作协程数领取任务
+func (d *DBJobScheduler) dispatchJobs() {
+	jobs := d.queue.ClaimQueuedJobs(d.workers - len(d.slots))
+	for _, job := range jobs {
+		d.slots <- struct{}{}
+		go d.runJob(job)
+	}
+}
+
+func (d *DBJobScheduler) runJob(job model.AIJob) {
+	defer func() { <-d.slots }()
+
+	if job.SavedAt > 0 {
+		// 进程中断前已保存结果，直接以该结果结束，不再重新执行和扣费
+		d.finishJob(job, model.AIJobSucceeded, job.Result, "")
+		common.SysLog(fmt.Sprintf("异步AI任务 %s 在中断前已保存结果，直接结束", job.JobID))
+		return
+	}
+
+	handler, exists := d.handlers[job.JobType]
+	if !exists {
+		d.finishJob(job, model.AIJobFailed, "", "no handler registered")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan struct{})
+	defer close(stop)
+	go d.watchJob(job.JobID, cancel, stop)
+
+	common.SysLog(fmt.Sprintf("开始执行异步AI任务 %s，类型：%s，第 %d 次执行", job.JobID, job.JobType, job.Attempts))
+	start := time.Now()
+	result, err := d.callHandler(ctx, handler, job)
+	switch {
+	case err == nil:
+		data, _ := json.Marshal(result)
+		d.finishJob(job, model.AIJobSucceeded, string(data), "")
+		common.SysLog(fmt.Sprintf("异步AI任务 %s 执行成功，耗时：%v", job.JobID, time.Since(start)))
+	case ctx.Err() != nil:
+		d.finishJob(job, model.AIJobCancelled, "", "")
+		common.SysLog(fmt.Sprintf("异步AI任务 %s 已取消", job.JobID))
+	default:
+		d.finishJob(job, model.AIJobFailed, "", err.Error())
+		common.SysError(fmt.Sprintf("异步AI任务 %s 执行失败：%v", job.JobID, err))
+	}
+}
+
+// callHandler 调用处理器，处理器panic时视为执行失败
+func (d *DBJobScheduler) callHandler(ctx context.Context, handler JobHandler, job model.AIJob) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job, &JobProgress{queue: d.queue, job: job})
+}
+
+// watchJob 定期更新任务的心跳，任务被取消时取消ctx
+func (d *DBJobScheduler) watchJob(jobId string, cancel context.CancelFunc, stop chan struct{}) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cancelled, err := d.queue.Heartbeat(jobId)
+			if err != nil {
+				common.SysError(fmt.Sprintf("更新异步AI任务 %s 心跳失败：%v", jobId, err))
+				continue
+			}
+			if cancelled {
+				cancel()
+			}
+		}
+	}
+}
+
+func (d *DBJobScheduler) finishJob(job model.AIJob, status model.AIJobStatus, result string, lastError string) {
+	if err := d.queue.FinishJob(job.JobID, status, result, lastError); err != nil {
+		common.SysError(fmt.Sprintf("更新异步AI任务 %s 状态失败：%v", job.JobID, err))
+	}
+}
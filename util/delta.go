@@ -0,0 +1,123 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// 差异编码的操作类型
+const (
+	deltaFormatVersion byte = 1
+	deltaOpCopy        byte = 'c' // 从基础文本复制连续的若干行
+	deltaOpInsert      byte = 'i' // 插入若干新行
+)
+
+var errInvalidDelta = errors.New("invalid delta")
+
+// EncodeDelta 计算把base变为target的行级差异，编码后用deflate压缩
+// 行按"\n"精确拆分，ApplyDelta可以逐字节还原target
+func EncodeDelta(base string, target string) ([]byte, error) {
+	baseLines := strings.Split(base, "\n")
+	targetLines := strings.Split(target, "\n")
+
+	var raw bytes.Buffer
+	raw.WriteByte(deltaFormatVersion)
+	for _, op := range Diff(baseLines, targetLines) {
+		switch op.Type {
+		case DiffEqual:
+			raw.WriteByte(deltaOpCopy)
+			writeUvarint(&raw, uint64(op.AStart))
+			writeUvarint(&raw, uint64(op.AEnd-op.AStart))
+		case DiffInsert:
+			raw.WriteByte(deltaOpInsert)
+			writeUvarint(&raw, uint64(op.BEnd-op.BStart))
+			for _, line := range targetLines[op.BStart:op.BEnd] {
+				writeUvarint(&raw, uint64(len(line)))
+				raw.WriteString(line)
+			}
+		}
+		// 删除的行不需要记录，没有被复制即被删除
+	}
+
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(raw.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// ApplyDelta 将EncodeDelta生成的差异应用到base上，还原出目标文本
+func ApplyDelta(base string, delta []byte) (string, error) {
+	reader := bufio.NewReader(flate.NewReader(bytes.NewReader(delta)))
+	format, err := reader.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if format != deltaFormatVersion {
+		return "", errInvalidDelta
+	}
+
+	baseLines := strings.Split(base, "\n")
+	var lines []string
+	for {
+		op, err := reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch op {
+		case deltaOpCopy:
+			start, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return "", err
+			}
+			count, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return "", err
+			}
+			if start+count > uint64(len(baseLines)) {
+				return "", errInvalidDelta
+			}
+			lines = append(lines, baseLines[start:start+count]...)
+		case deltaOpInsert:
+			count, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return "", err
+			}
+			for i := uint64(0); i < count; i++ {
+				length, err := binary.ReadUvarint(reader)
+				if err != nil {
+					return "", err
+				}
+				line := make([]byte, length)
+				if _, err := io.ReadFull(reader, line); err != nil {
+					return "", err
+				}
+				lines = append(lines, string(line))
+			}
+		default:
+			return "", errInvalidDelta
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func writeUvarint(buf *bytes.Buffer, value uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], value)
+	buf.Write(scratch[:n])
+}
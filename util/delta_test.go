@@ -0,0 +1,57 @@
+package util
+
+import "testing"
+
+func TestDeltaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   string
+		target string
+	}{
+		{"相同", "第一章\n主角出发。", "第一章\n主角出发。"},
+		{"都为空", "", ""},
+		{"由空生成", "", "第一章\n主角出发。"},
+		{"清空", "第一章\n主角出发。", ""},
+		{"修改和追加", "a\nb\nc", "a\nB\nc\nd"},
+		{"删除中间行", "a\nb\nc\nd", "a\nd"},
+		{"保留末尾换行和空行", "a\n\nb\n", "a\n\n\nb\n\n"},
+		{"保留回车", "a\r\nb", "a\r\nb\r\nc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, err := EncodeDelta(tt.base, tt.target)
+			if err != nil {
+				t.Fatalf("EncodeDelta() error = %v", err)
+			}
+			got, err := ApplyDelta(tt.base, delta)
+			if err != nil {
+				t.Fatalf("ApplyDelta() error = %v", err)
+			}
+			if got != tt.target {
+				t.Errorf("ApplyDelta() = %q, want %q", got, tt.target)
+			}
+		})
+	}
+}
+
+func TestApplyDeltaInvalid(t *testing.T) {
+	delta, err := EncodeDelta("a\nb\nc", "a\nb\nc")
+	if err != nil {
+		t.Fatalf("EncodeDelta() error = %v", err)
+	}
+	tests := []struct {
+		name  string
+		base  string
+		delta []byte
+	}{
+		{"不是压缩数据", "a", []byte("not a delta")},
+		{"基础文本行数不足", "a", delta},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyDelta(tt.base, tt.delta); err == nil {
+				t.Errorf("ApplyDelta() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DiffOpType 差异操作类型
+type DiffOpType string
+
+const (
+	DiffEqual  DiffOpType = "equal"
+	DiffInsert DiffOpType = "insert"
+	DiffDelete DiffOpType = "delete"
+)
+
+// DiffOp 一段连续的差异操作
+// [AStart, AEnd) 为旧序列中的区间，[BStart, BEnd) 为新序列中的区间
+type DiffOp struct {
+	Type   DiffOpType
+	AStart int
+	AEnd   int
+	BStart int
+	BEnd   int
+}
+
+// differ 基于 Myers 线性空间算法（middle snake）计算最短编辑脚本
+type differ[T comparable] struct {
+	a, b     []T
+	changedA []bool
+	changedB []bool
+}
+
+// Diff 计算两个序列之间的差异，返回按顺序排列的操作列表
+func Diff[T comparable](a, b []T) []DiffOp {
+	d := &differ[T]{
+		a:        a,
+		b:        b,
+		changedA: make([]bool, len(a)),
+		changedB: make([]bool, len(b)),
+	}
+	d.compareSeq(0, len(a), 0, len(b))
+
+	var ops []DiffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.changedA[i]:
+			start := i
+			for i < len(a) && d.changedA[i] {
+				i++
+			}
+			ops = append(ops, DiffOp{Type: DiffDelete, AStart: start, AEnd: i, BStart: j, BEnd: j})
+		case j < len(b) && d.changedB[j]:
+			start := j
+			for j < len(b) && d.changedB[j] {
+				j++
+			}
+			ops = append(ops, DiffOp{Type: DiffInsert, AStart: i, AEnd: i, BStart: start, BEnd: j})
+		case i < len(a) && j < len(b):
+			startA, startB := i, j
+			for i < len(a) && j < len(b) && !d.changedA[i] && !d.changedB[j] {
+				i++
+				j++
+			}
+			ops = append(ops, DiffOp{Type: DiffEqual, AStart: startA, AEnd: i, BStart: startB, BEnd: j})
+		case i < len(a):
+			// 理论上不会出现，兜底避免死循环
+			ops = append(ops, DiffOp{Type: DiffDelete, AStart: i, AEnd: len(a), BStart: j, BEnd: j})
+			i = len(a)
+		default:
+			ops = append(ops, DiffOp{Type: DiffInsert, AStart: i, AEnd: i, BStart: j, BEnd: len(b)})
+			j = len(b)
+		}
+	}
+	return ops
+}
+
+// compareSeq 递归比较 a[xoff:xlim] 与 b[yoff:ylim]，标记发生变化的元素
+func (d *differ[T]) compareSeq(xoff, xlim, yoff, ylim int) {
+	// 去掉公共前缀和后缀
+	for xoff < xlim && yoff < ylim && d.a[xoff] == d.b[yoff] {
+		xoff++
+		yoff++
+	}
+	for xlim > xoff && ylim > yoff && d.a[xlim-1] == d.b[ylim-1] {
+		xlim--
+		ylim--
+	}
+
+	switch {
+	case xoff == xlim:
+		for ; yoff < ylim; yoff++ {
+			d.changedB[yoff] = true
+		}
+	case yoff == ylim:
+		for ; xoff < xlim; xoff++ {
+			d.changedA[xoff] = true
+		}
+	default:
+		x, y := d.midSnake(xoff, xlim, yoff, ylim)
+		if x < 0 || (x == xoff && y == yoff) || (x == xlim && y == ylim) {
+			// 没有公共部分（或无法继续拆分），整体视为替换
+			for i := xoff; i < xlim; i++ {
+				d.changedA[i] = true
+			}
+			for j := yoff; j < ylim; j++ {
+				d.changedB[j] = true
+			}
+			return
+		}
+		d.compareSeq(xoff, x, yoff, y)
+		d.compareSeq(x, xlim, y, ylim)
+	}
+}
+
+// midSnake 双向搜索，找到最优编辑路径上的中间拆分点
+func (d *differ[T]) midSnake(xoff, xlim, yoff, ylim int) (int, int) {
+	n := xlim - xoff
+	m := ylim - yoff
+	maxD := (n + m + 1) / 2
+	vOffset := maxD
+	vLength := 2*maxD + 2
+	v1 := make([]int, vLength)
+	v2 := make([]int, vLength)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[vOffset+1] = 0
+	v2[vOffset+1] = 0
+
+	delta := n - m
+	front := delta%2 != 0
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+
+	for step := 0; step < maxD; step++ {
+		// 正向搜索
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1Offset := vOffset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.a[xoff+x1] == d.b[yoff+y1] {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+			if x1 > n {
+				k1end += 2
+			} else if y1 > m {
+				k1start += 2
+			} else if front {
+				k2Offset := vOffset + delta - k1
+				if k2Offset >= 0 && k2Offset < vLength && v2[k2Offset] != -1 {
+					if x1 >= n-v2[k2Offset] {
+						return xoff + x1, yoff + y1
+					}
+				}
+			}
+		}
+
+		// 反向搜索
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2Offset := vOffset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.a[xoff+n-x2-1] == d.b[yoff+m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+			if x2 > n {
+				k2end += 2
+			} else if y2 > m {
+				k2start += 2
+			} else if !front {
+				k1Offset := vOffset + delta - k2
+				if k1Offset >= 0 && k1Offset < vLength && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := vOffset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return xoff + x1, yoff + y1
+					}
+				}
+			}
+		}
+	}
+	return -1, -1
+}
+
+// SplitLines 按行拆分文本，统一处理 \r\n 换行
+func SplitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(text, "\n")
+}
+
+// TokenizeText 将文本切分为字符级比较单元
+// 中日韩文字、标点按单个字符切分；连续的字母数字合并为一个单词，连续空白合并为一个单元，
+// 这样中文按字比较，英文按词比较，避免产生零碎的差异片段
+func TokenizeText(text string) []string {
+	var tokens []string
+	start := -1
+	startKind := 0
+	for i, r := range text {
+		kind := runeKind(r)
+		if start >= 0 && (kind != startKind || kind == runeKindSingle) {
+			tokens = append(tokens, text[start:i])
+			start = -1
+		}
+		if start < 0 {
+			start = i
+			startKind = kind
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+const (
+	runeKindSingle = iota
+	runeKindWord
+	runeKindSpace
+)
+
+func runeKind(r rune) int {
+	switch {
+	case r == utf8.RuneError:
+		return runeKindSingle
+	case unicode.IsSpace(r):
+		return runeKindSpace
+	case IsCJK(r):
+		return runeKindSingle
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return runeKindWord
+	default:
+		return runeKindSingle
+	}
+}
+
+// IsCJK 判断字符是否为中日韩文字
+func IsCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
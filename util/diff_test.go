@@ -0,0 +1,137 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// applyOps 按差异操作由a重建b，用于校验操作列表的正确性
+func applyOps(a, b []string, ops []DiffOp) []string {
+	var result []string
+	for _, op := range ops {
+		switch op.Type {
+		case DiffEqual:
+			result = append(result, a[op.AStart:op.AEnd]...)
+		case DiffInsert:
+			result = append(result, b[op.BStart:op.BEnd]...)
+		}
+	}
+	return result
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []DiffOp
+	}{
+		{
+			name: "相同",
+			a:    "abc",
+			b:    "abc",
+			want: []DiffOp{{Type: DiffEqual, AStart: 0, AEnd: 3, BStart: 0, BEnd: 3}},
+		},
+		{
+			name: "都为空",
+			a:    "",
+			b:    "",
+			want: nil,
+		},
+		{
+			name: "全部插入",
+			a:    "",
+			b:    "ab",
+			want: []DiffOp{{Type: DiffInsert, AStart: 0, AEnd: 0, BStart: 0, BEnd: 2}},
+		},
+		{
+			name: "全部删除",
+			a:    "ab",
+			b:    "",
+			want: []DiffOp{{Type: DiffDelete, AStart: 0, AEnd: 2, BStart: 0, BEnd: 0}},
+		},
+		{
+			name: "中间替换",
+			a:    "abc",
+			b:    "axc",
+			want: []DiffOp{
+				{Type: DiffEqual, AStart: 0, AEnd: 1, BStart: 0, BEnd: 1},
+				{Type: DiffDelete, AStart: 1, AEnd: 2, BStart: 1, BEnd: 1},
+				{Type: DiffInsert, AStart: 2, AEnd: 2, BStart: 1, BEnd: 2},
+				{Type: DiffEqual, AStart: 2, AEnd: 3, BStart: 2, BEnd: 3},
+			},
+		},
+		{
+			name: "末尾追加",
+			a:    "ab",
+			b:    "abcd",
+			want: []DiffOp{
+				{Type: DiffEqual, AStart: 0, AEnd: 2, BStart: 0, BEnd: 2},
+				{Type: DiffInsert, AStart: 2, AEnd: 2, BStart: 2, BEnd: 4},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := strings.Split(tt.a, ""), strings.Split(tt.b, "")
+			got := Diff(a, b)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff(%q, %q) = %+v, want %+v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffReconstructs(t *testing.T) {
+	tests := []struct {
+		a string
+		b string
+	}{
+		{"ABCABBA", "CBABAC"},
+		{"第一章\n主角出发\n遇到旧友", "第一章\n主角在雨夜出发\n遇到旧友\n第二章"},
+		{"a\nb\nc\nd\ne", "e\nd\nc\nb\na"},
+		{"", "x\ny"},
+	}
+	for _, tt := range tests {
+		a, b := SplitLines(tt.a), SplitLines(tt.b)
+		ops := Diff(a, b)
+		if got := applyOps(a, b, ops); !reflect.DeepEqual(got, b) && !(len(got) == 0 && len(b) == 0) {
+			t.Errorf("applying Diff(%q, %q) = %q, want %q", tt.a, tt.b, got, b)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{""}},
+		{"a\nb", []string{"a", "b"}},
+		{"a\r\nb\r\n", []string{"a", "b", ""}},
+	}
+	for _, tt := range tests {
+		if got := SplitLines(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitLines(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"主角出发", []string{"主", "角", "出", "发"}},
+		{"hello world", []string{"hello", " ", "world"}},
+		{"第3章 Chapter12，结束", []string{"第", "3", "章", " ", "Chapter12", "，", "结", "束"}},
+		{"a  \n b", []string{"a", "  \n ", "b"}},
+	}
+	for _, tt := range tests {
+		if got := TokenizeText(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("TokenizeText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
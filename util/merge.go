@@ -0,0 +1,101 @@
+package util
+
+import "strings"
+
+// MergeConflict 三方合并中的一处冲突
+// Start 字段为冲突区域在各自文本中的起始行号（从0开始）
+type MergeConflict struct {
+	BaseStart   int
+	OursStart   int
+	TheirsStart int
+	Base        []string
+	Ours        []string
+	Theirs      []string
+}
+
+// MergeLines 以 base 为共同祖先，按行对 ours 和 theirs 做三方合并
+// 只有一方修改的区域直接采用修改方的内容，双方修改相同的区域视为一致；
+// 双方对同一区域（包括相邻行）做了不同修改时记为冲突，合并结果中该区域保留 ours 的内容
+func MergeLines(base string, ours string, theirs string) (string, []MergeConflict) {
+	baseLines := SplitLines(base)
+	oursLines := SplitLines(ours)
+	theirsLines := SplitLines(theirs)
+
+	matchOurs := matchLines(baseLines, oursLines)
+	matchTheirs := matchLines(baseLines, theirsLines)
+
+	var merged []string
+	var conflicts []MergeConflict
+	i, j, k := 0, 0, 0
+	for i < len(baseLines) || j < len(oursLines) || k < len(theirsLines) {
+		// 三方一致的稳定行直接输出
+		if i < len(baseLines) && matchOurs[i] == j && matchTheirs[i] == k {
+			merged = append(merged, baseLines[i])
+			i++
+			j++
+			k++
+			continue
+		}
+
+		// 找到下一个在两边都保留的基准行，中间部分即为不稳定区域
+		next := i
+		for next < len(baseLines) && (matchOurs[next] < 0 || matchTheirs[next] < 0) {
+			next++
+		}
+		oursEnd, theirsEnd := len(oursLines), len(theirsLines)
+		if next < len(baseLines) {
+			oursEnd, theirsEnd = matchOurs[next], matchTheirs[next]
+		}
+
+		baseChunk := baseLines[i:next]
+		oursChunk := oursLines[j:oursEnd]
+		theirsChunk := theirsLines[k:theirsEnd]
+		switch {
+		case equalLines(oursChunk, baseChunk):
+			merged = append(merged, theirsChunk...)
+		case equalLines(theirsChunk, baseChunk), equalLines(oursChunk, theirsChunk):
+			merged = append(merged, oursChunk...)
+		default:
+			conflicts = append(conflicts, MergeConflict{
+				BaseStart:   i,
+				OursStart:   j,
+				TheirsStart: k,
+				Base:        baseChunk,
+				Ours:        oursChunk,
+				Theirs:      theirsChunk,
+			})
+			merged = append(merged, oursChunk...)
+		}
+		i, j, k = next, oursEnd, theirsEnd
+	}
+	return strings.Join(merged, "\n"), conflicts
+}
+
+// matchLines 返回 base 中每一行在 other 中对应的行号，未保留的行为-1
+func matchLines(base []string, other []string) []int {
+	match := make([]int, len(base))
+	for i := range match {
+		match[i] = -1
+	}
+	for _, op := range Diff(base, other) {
+		if op.Type != DiffEqual {
+			continue
+		}
+		for n := 0; n < op.AEnd-op.AStart; n++ {
+			match[op.AStart+n] = op.BStart + n
+		}
+	}
+	return match
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
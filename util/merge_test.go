@@ -0,0 +1,91 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts []MergeConflict
+	}{
+		{
+			name:   "都未修改",
+			base:   "a\nb\nc",
+			ours:   "a\nb\nc",
+			theirs: "a\nb\nc",
+			want:   "a\nb\nc",
+		},
+		{
+			name:   "只有ours修改",
+			base:   "a\nb\nc",
+			ours:   "a\nB\nc",
+			theirs: "a\nb\nc",
+			want:   "a\nB\nc",
+		},
+		{
+			name:   "只有theirs修改",
+			base:   "a\nb\nc",
+			ours:   "a\nb\nc",
+			theirs: "a\nb\nC",
+			want:   "a\nb\nC",
+		},
+		{
+			name:   "双方修改不同区域",
+			base:   "a\nb\nc\nd\ne",
+			ours:   "A\nb\nc\nd\ne",
+			theirs: "a\nb\nc\nd\nE",
+			want:   "A\nb\nc\nd\nE",
+		},
+		{
+			name:   "双方修改相同",
+			base:   "a\nb\nc",
+			ours:   "a\nX\nc",
+			theirs: "a\nX\nc",
+			want:   "a\nX\nc",
+		},
+		{
+			name:   "双方在末尾追加",
+			base:   "a",
+			ours:   "a\nb",
+			theirs: "a\nc",
+			want:   "a\nb",
+			conflicts: []MergeConflict{
+				{BaseStart: 1, OursStart: 1, TheirsStart: 1, Base: []string{}, Ours: []string{"b"}, Theirs: []string{"c"}},
+			},
+		},
+		{
+			name:   "双方修改同一行",
+			base:   "a\nb\nc",
+			ours:   "a\nX\nc",
+			theirs: "a\nY\nc",
+			want:   "a\nX\nc",
+			conflicts: []MergeConflict{
+				{BaseStart: 1, OursStart: 1, TheirsStart: 1, Base: []string{"b"}, Ours: []string{"X"}, Theirs: []string{"Y"}},
+			},
+		},
+		{
+			name:   "一方删除另一方保留",
+			base:   "a\nb\nc",
+			ours:   "a\nc",
+			theirs: "a\nb\nc",
+			want:   "a\nc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := MergeLines(tt.base, tt.ours, tt.theirs)
+			if got != tt.want {
+				t.Errorf("MergeLines() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("MergeLines() conflicts = %+v, want %+v", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
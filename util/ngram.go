@@ -0,0 +1,101 @@
+package util
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxGramRunes 单个索引词的最大长度，更长的单词截断后索引，命中后再按原文校验
+const maxGramRunes = 32
+
+// NormalizeRune 搜索用的字符归一化：全角字母数字转半角，字母转小写
+// 每个字符只映射为一个字符，归一化后的文本与原文按字符位置一一对应
+func NormalizeRune(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		r -= 0xFEE0
+	} else if r == 0x3000 {
+		r = ' '
+	}
+	return unicode.ToLower(r)
+}
+
+// NormalizeText 按NormalizeRune逐字符归一化文本
+func NormalizeText(text string) []rune {
+	runes := make([]rune, 0, utf8.RuneCountInString(text))
+	for _, r := range text {
+		runes = append(runes, NormalizeRune(r))
+	}
+	return runes
+}
+
+// NGrams 将文本切分为去重后的索引词
+// 中日韩文字之间没有空格分词，连续的中日韩文字生成单字和相邻两字（bigram）；连续的字母数字作为一个单词；标点和空白只作分隔
+func NGrams(text string) []string {
+	seen := make(map[string]bool)
+	var grams []string
+	add := func(gram []rune) {
+		if len(gram) > maxGramRunes {
+			gram = gram[:maxGramRunes]
+		}
+		key := string(gram)
+		if !seen[key] {
+			seen[key] = true
+			grams = append(grams, key)
+		}
+	}
+
+	runes := NormalizeText(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case IsCJK(r):
+			start := i
+			for i < len(runes) && IsCJK(runes[i]) {
+				i++
+			}
+			for k := start; k < i; k++ {
+				add(runes[k : k+1])
+				if k+1 < i {
+					add(runes[k : k+2])
+				}
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && !IsCJK(runes[i]) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			add(runes[start:i])
+		default:
+			i++
+		}
+	}
+	return grams
+}
+
+// QueryGrams 生成查询词必须全部命中的索引词
+// 两个字以上的中日韩文字只需要bigram，单个字才使用单字索引
+func QueryGrams(term string) []string {
+	var grams []string
+	for _, gram := range NGrams(term) {
+		if utf8.RuneCountInString(gram) == 1 && IsCJK([]rune(gram)[0]) && hasAdjacentCJK(term, gram) {
+			continue
+		}
+		grams = append(grams, gram)
+	}
+	return grams
+}
+
+// hasAdjacentCJK 判断单字是否与其他中日韩文字相邻，相邻时已被bigram覆盖
+func hasAdjacentCJK(term string, char string) bool {
+	runes := NormalizeText(term)
+	target := []rune(char)[0]
+	for i, r := range runes {
+		if r != target {
+			continue
+		}
+		if (i > 0 && IsCJK(runes[i-1])) || (i+1 < len(runes) && IsCJK(runes[i+1])) {
+			return true
+		}
+	}
+	return false
+}
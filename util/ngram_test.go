@@ -0,0 +1,62 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRune(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want rune
+	}{
+		{'A', 'a'},
+		{'Ａ', 'a'},
+		{'１', '1'},
+		{'　', ' '},
+		{'龙', '龙'},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRune(tt.r); got != tt.want {
+			t.Errorf("NormalizeRune(%q) = %q, want %q", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestNGrams(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"主角", []string{"主", "主角", "角"}},
+		{"龙龙龙", []string{"龙", "龙龙"}},
+		{"Hello, ＷＯＲＬＤ!", []string{"hello", "world"}},
+		{"第3章", []string{"第", "3", "章"}},
+		{"abc 主角 abc", []string{"abc", "主", "主角", "角"}},
+		{strings.Repeat("a", 40), []string{strings.Repeat("a", maxGramRunes)}},
+	}
+	for _, tt := range tests {
+		if got := NGrams(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NGrams(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQueryGrams(t *testing.T) {
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"龙", []string{"龙"}},
+		{"主角", []string{"主角"}},
+		{"主角出发", []string{"主角", "角出", "出发"}},
+		{"剑 sword", []string{"剑", "sword"}},
+	}
+	for _, tt := range tests {
+		if got := QueryGrams(tt.term); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryGrams(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package util
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 大纲节点类型
+const (
+	OutlineKindPreamble = "preamble" // 第一个标题之前的文字
+	OutlineKindVolume   = "volume"   // 第X卷、第X部
+	OutlineKindChapter  = "chapter"  // 第X章、第X回
+	OutlineKindSection  = "section"  // 第X节
+	OutlineKindHeading  = "heading"  // 普通Markdown标题
+	OutlineKindBeat     = "beat"     // 列表项形式的情节点
+)
+
+// OutlineBeatLevel 情节点的层级，情节点总是叶子节点
+const OutlineBeatLevel = 100
+
+// 不带Markdown标记的卷、章、节标题的默认层级
+var outlineKindLevels = map[string]int{
+	OutlineKindVolume:  1,
+	OutlineKindChapter: 2,
+	OutlineKindSection: 3,
+	OutlineKindBeat:    OutlineBeatLevel,
+}
+
+var (
+	markdownHeadingPattern = regexp.MustCompile(`^(#{1,6})(?:[ \t]+|$)`)
+	numberedHeadingPattern = regexp.MustCompile(`^[ \t　]*第([0-9０-９零〇一二两三四五六七八九十百千]+)(卷|部|章|回|节)(?:[ \t　:：、.．\-—]+|$)`)
+	beatPattern            = regexp.MustCompile(`^(?:[-*+•][ \t]+|[0-9]+(?:[.)][ \t]+|[、）][ \t]*))`)
+)
+
+// OutlineSection 大纲结构树中的一个节点
+// 标题行原文为 Marker + Title，Body 为标题行之后、下一个节点之前的原文，非空时以换行结尾
+type OutlineSection struct {
+	Id       int64
+	Kind     string
+	Level    int
+	Marker   string
+	Title    string
+	Body     string
+	Children []*OutlineSection
+}
+
+// Heading 返回节点标题行原文
+func (s *OutlineSection) Heading() string {
+	return s.Marker + s.Title
+}
+
+// ParseOutline 将大纲文本解析为节点树
+// 支持Markdown标题（#）、第X卷/第X章/第X节标记以及列表形式的情节点，
+// 第一个标题之前的文字保存为preamble节点。解析结果经RenderOutline可还原为原文（换行统一为\n）
+func ParseOutline(content string) []*OutlineSection {
+	lines := SplitLines(content)
+
+	var roots []*OutlineSection
+	var stack []*OutlineSection
+	var current *OutlineSection
+	var body []string
+	flush := func() {
+		if current != nil {
+			current.Body = joinBodyLines(body)
+		}
+		body = nil
+	}
+
+	for _, line := range lines {
+		section := parseHeadingLine(line)
+		if section == nil {
+			if current == nil {
+				current = &OutlineSection{Kind: OutlineKindPreamble}
+				roots = append(roots, current)
+			}
+			body = append(body, line)
+			continue
+		}
+
+		flush()
+		for len(stack) > 0 && stack[len(stack)-1].Level >= section.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, section)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, section)
+		}
+		stack = append(stack, section)
+		current = section
+	}
+	flush()
+	return roots
+}
+
+// RenderOutline 将节点树还原为大纲文本
+func RenderOutline(roots []*OutlineSection) string {
+	var builder strings.Builder
+	WalkOutline(roots, func(section *OutlineSection, _ *OutlineSection) {
+		if section.Kind != OutlineKindPreamble {
+			builder.WriteString(section.Heading())
+			builder.WriteString("\n")
+		}
+		builder.WriteString(NormalizeOutlineBody(section.Body))
+	})
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// WalkOutline 按文档顺序（先序）遍历节点树，回调参数为节点及其父节点（根节点的父节点为nil）
+func WalkOutline(roots []*OutlineSection, fn func(section *OutlineSection, parent *OutlineSection)) {
+	var walk func(sections []*OutlineSection, parent *OutlineSection)
+	walk = func(sections []*OutlineSection, parent *OutlineSection) {
+		for _, section := range sections {
+			fn(section, parent)
+			walk(section.Children, section)
+		}
+	}
+	walk(roots, nil)
+}
+
+// NormalizeOutlineBody 保证非空正文以换行结尾
+func NormalizeOutlineBody(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	if body != "" && !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+	return body
+}
+
+// DefaultOutlineLevel 返回新建节点的默认层级，parentLevel为0表示顶层
+func DefaultOutlineLevel(kind string, parentLevel int) int {
+	if level, ok := outlineKindLevels[kind]; ok {
+		return level
+	}
+	if parentLevel+1 > 6 {
+		return 6
+	}
+	return parentLevel + 1
+}
+
+// DefaultOutlineMarker 返回新建节点的默认标题前缀
+func DefaultOutlineMarker(kind string, level int) string {
+	switch kind {
+	case OutlineKindVolume:
+		return "第一卷 "
+	case OutlineKindChapter:
+		return "第一章 "
+	case OutlineKindSection:
+		return "第一节 "
+	case OutlineKindBeat:
+		return "- "
+	default:
+		return strings.Repeat("#", level) + " "
+	}
+}
+
+// EqualOutline 判断两棵节点树的结构和内容是否一致（不比较节点ID）
+func EqualOutline(a []*OutlineSection, b []*OutlineSection) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Kind != b[i].Kind || a[i].Level != b[i].Level || a[i].Marker != b[i].Marker ||
+			a[i].Title != b[i].Title || NormalizeOutlineBody(a[i].Body) != NormalizeOutlineBody(b[i].Body) {
+			return false
+		}
+		if !EqualOutline(a[i].Children, b[i].Children) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReconcileOutlineIds 将旧节点树的ID迁移到重新解析得到的新节点树上
+// 按文档顺序对比节点的类型和标题，相同的节点沿用原ID；被整体替换的区域中类型相同的节点按位置对应，视为改了标题
+func ReconcileOutlineIds(oldRoots []*OutlineSection, newRoots []*OutlineSection) {
+	oldList := flattenOutline(oldRoots)
+	newList := flattenOutline(newRoots)
+	oldKeys := make([]string, len(oldList))
+	for i, section := range oldList {
+		oldKeys[i] = section.Kind + "\x00" + section.Title
+	}
+	newKeys := make([]string, len(newList))
+	for i, section := range newList {
+		newKeys[i] = section.Kind + "\x00" + section.Title
+	}
+
+	ops := Diff(oldKeys, newKeys)
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		switch op.Type {
+		case DiffEqual:
+			for k := 0; k < op.AEnd-op.AStart; k++ {
+				newList[op.BStart+k].Id = oldList[op.AStart+k].Id
+			}
+		case DiffDelete:
+			if i+1 >= len(ops) || ops[i+1].Type != DiffInsert {
+				continue
+			}
+			insert := ops[i+1]
+			i++
+			for k := 0; op.AStart+k < op.AEnd && insert.BStart+k < insert.BEnd; k++ {
+				if oldList[op.AStart+k].Kind == newList[insert.BStart+k].Kind {
+					newList[insert.BStart+k].Id = oldList[op.AStart+k].Id
+				}
+			}
+		}
+	}
+}
+
+func flattenOutline(roots []*OutlineSection) []*OutlineSection {
+	var list []*OutlineSection
+	WalkOutline(roots, func(section *OutlineSection, _ *OutlineSection) {
+		list = append(list, section)
+	})
+	return list
+}
+
+// parseHeadingLine 解析标题行，不是标题时返回nil
+func parseHeadingLine(line string) *OutlineSection {
+	marker := ""
+	rest := line
+	level := 0
+	if m := markdownHeadingPattern.FindStringSubmatchIndex(rest); m != nil {
+		level = m[3] - m[2]
+		marker = rest[:m[1]]
+		rest = rest[m[1]:]
+	}
+
+	kind := OutlineKindHeading
+	if m := numberedHeadingPattern.FindStringSubmatchIndex(rest); m != nil {
+		switch rest[m[4]:m[5]] {
+		case "卷", "部":
+			kind = OutlineKindVolume
+		case "章", "回":
+			kind = OutlineKindChapter
+		default:
+			kind = OutlineKindSection
+		}
+		marker += rest[:m[1]]
+		rest = rest[m[1]:]
+		if level == 0 {
+			level = outlineKindLevels[kind]
+		}
+	} else if level == 0 {
+		m := beatPattern.FindStringIndex(rest)
+		if m == nil {
+			return nil
+		}
+		kind = OutlineKindBeat
+		level = OutlineBeatLevel
+		marker = rest[:m[1]]
+		rest = rest[m[1]:]
+	}
+
+	return &OutlineSection{
+		Kind:   kind,
+		Level:  level,
+		Marker: marker,
+		Title:  rest,
+	}
+}
+
+func joinBodyLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// 编号方式
+const (
+	OutlineNumberingGlobal = "global" // 全文连续编号
+	OutlineNumberingParent = "parent" // 在上级节点内重新编号
+)
+
+// DetectOutlineNumbering 检测卷、章、节当前使用的编号方式
+// 返回值中不包含的类型表示编号不规律，不应自动重新编号；没有该类型节点时默认全文连续编号
+func DetectOutlineNumbering(roots []*OutlineSection) map[string]string {
+	schemes := make(map[string]string)
+	for _, kind := range []string{OutlineKindVolume, OutlineKindChapter, OutlineKindSection} {
+		matchesGlobal, matchesParent := true, true
+		global := 0
+		perParent := make(map[*OutlineSection]int)
+		WalkOutline(roots, func(section *OutlineSection, parent *OutlineSection) {
+			if section.Kind != kind {
+				return
+			}
+			number, ok := markerNumber(section.Marker)
+			if !ok {
+				matchesGlobal, matchesParent = false, false
+				return
+			}
+			global++
+			perParent[parent]++
+			if number != global {
+				matchesGlobal = false
+			}
+			if number != perParent[parent] {
+				matchesParent = false
+			}
+		})
+		switch {
+		case matchesGlobal:
+			schemes[kind] = OutlineNumberingGlobal
+		case matchesParent:
+			schemes[kind] = OutlineNumberingParent
+		}
+	}
+	return schemes
+}
+
+// RenumberOutline 按给定的编号方式重写卷、章、节标题中的序号，保留原有的数字写法
+func RenumberOutline(roots []*OutlineSection, schemes map[string]string) {
+	global := make(map[string]int)
+	perParent := make(map[*OutlineSection]map[string]int)
+	WalkOutline(roots, func(section *OutlineSection, parent *OutlineSection) {
+		scheme, ok := schemes[section.Kind]
+		if !ok {
+			return
+		}
+		global[section.Kind]++
+		if perParent[parent] == nil {
+			perParent[parent] = make(map[string]int)
+		}
+		perParent[parent][section.Kind]++
+
+		number := global[section.Kind]
+		if scheme == OutlineNumberingParent {
+			number = perParent[parent][section.Kind]
+		}
+		section.Marker = replaceMarkerNumber(section.Marker, number)
+	})
+}
+
+// markerNumber 解析标题前缀中的序号
+func markerNumber(marker string) (int, bool) {
+	start, end := markerNumberRange(marker)
+	if start < 0 {
+		return 0, false
+	}
+	return ParseChineseNumber(marker[start:end])
+}
+
+// replaceMarkerNumber 替换标题前缀中的序号，阿拉伯数字、全角数字和中文数字保持原写法
+func replaceMarkerNumber(marker string, number int) string {
+	start, end := markerNumberRange(marker)
+	if start < 0 {
+		return marker
+	}
+	old := marker[start:end]
+	var formatted string
+	first, _ := utf8.DecodeRuneInString(old)
+	switch {
+	case first >= '0' && first <= '9':
+		formatted = strconv.Itoa(number)
+	case first >= '０' && first <= '９':
+		formatted = strings.Map(func(r rune) rune { return r - '0' + '０' }, strconv.Itoa(number))
+	default:
+		formatted = FormatChineseNumber(number)
+	}
+	return marker[:start] + formatted + marker[end:]
+}
+
+func markerNumberRange(marker string) (int, int) {
+	rest := marker
+	offset := 0
+	if m := markdownHeadingPattern.FindStringIndex(rest); m != nil {
+		offset = m[1]
+		rest = rest[m[1]:]
+	}
+	m := numberedHeadingPattern.FindStringSubmatchIndex(rest)
+	if m == nil {
+		return -1, -1
+	}
+	return offset + m[2], offset + m[3]
+}
+
+var chineseDigits = map[rune]int{
+	'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+var chineseUnits = map[rune]int{'十': 10, '百': 100, '千': 1000}
+
+// ParseChineseNumber 解析阿拉伯数字（含全角）或中文数字，支持到九千九百九十九
+func ParseChineseNumber(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	halfWidth := strings.Map(func(r rune) rune {
+		if r >= '０' && r <= '９' {
+			return r - '０' + '0'
+		}
+		return r
+	}, s)
+	if n, err := strconv.Atoi(halfWidth); err == nil {
+		return n, true
+	}
+
+	total, digit := 0, -1
+	for _, r := range s {
+		if d, ok := chineseDigits[r]; ok {
+			digit = d
+			continue
+		}
+		unit, ok := chineseUnits[r]
+		if !ok {
+			return 0, false
+		}
+		if digit < 0 {
+			digit = 1 // “十二”中省略的“一”
+		}
+		total += digit * unit
+		digit = -1
+	}
+	if digit > 0 {
+		total += digit
+	}
+	return total, total > 0
+}
+
+// FormatChineseNumber 将数字格式化为中文数字，超过9999时使用阿拉伯数字
+func FormatChineseNumber(n int) string {
+	if n <= 0 || n > 9999 {
+		return strconv.Itoa(n)
+	}
+	digits := []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	units := []string{"千", "百", "十", ""}
+	values := []int{n / 1000, n / 100 % 10, n / 10 % 10, n % 10}
+
+	var builder strings.Builder
+	zero := false
+	for i, v := range values {
+		if v == 0 {
+			if builder.Len() > 0 {
+				zero = true
+			}
+			continue
+		}
+		if zero {
+			builder.WriteString("零")
+			zero = false
+		}
+		// 10到19读作“十X”而不是“一十X”
+		if !(i == 2 && v == 1 && builder.Len() == 0) {
+			builder.WriteString(digits[v])
+		}
+		builder.WriteString(units[i])
+	}
+	return builder.String()
+}
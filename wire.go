@@ -8,6 +8,7 @@ import (
 	"gin-template/repository"
 	"gin-template/router"
 	"gin-template/service"
+	"gin-template/task"
 	"github.com/google/wire"
 	"gorm.io/gorm"
 )
@@ -23,6 +24,16 @@ func InitializeAllController(db *gorm.DB) (*router.APIControllers, error) {
 	return &router.APIControllers{}, nil
 }
 
+// InitializeStoryBibleService 异步AI任务使用的故事设定服务
+func InitializeStoryBibleService(db *gorm.DB) (*service.StoryBibleService, error) {
+	panic(wire.Build(
+		service.NewStoryBibleService,
+		repository.NewStoryBibleRepository,
+		repository.NewPromptTemplateRepository,
+	))
+	return &service.StoryBibleService{}, nil
+}
+
 // ServiceSet 大纲服务集合
 var ServiceSet = wire.NewSet(
 	service.NewOutlineService,
@@ -30,6 +41,12 @@ var ServiceSet = wire.NewSet(
 	service.NewProjectService,
 	service.NewReferralService,
 	service.NewPackageService,
+	service.NewSearchService,
+	service.NewStylePresetService,
+	service.NewPromptTemplateService,
+	service.NewStoryBibleService,
+	service.NewModerationService,
+	service.NewAIJobService,
 )
 
 // repository.RepositorySet 基础仓库集合
@@ -37,18 +54,35 @@ var RepositorySet = wire.NewSet(
 	repository.NewTokenRepository,
 	repository.NewTokenReconciliationRepository,
 	repository.NewOutlineRepository,
+	repository.NewOutlineDraftRepository,
 	repository.NewProjectRepository,
 	repository.NewReferralRepository,
 	repository.NewPackageRepository,
+	repository.NewSearchRepository,
+	repository.NewStylePresetRepository,
+	repository.NewPromptTemplateRepository,
+	repository.NewAICandidateRepository,
+	repository.NewOutlineSummaryRepository,
+	repository.NewStoryBibleRepository,
+	repository.NewContinuityReportRepository,
+	repository.NewModerationRepository,
+	task.NewDBJobQueue,
 )
 
 // 控制器依赖注入集合
 var ControllerSet = wire.NewSet(
 	controller.NewReferralController,
+	controller.NewTokenController,
 	controller.NewProjectController,
 	controller.NewOutlineController,
 	controller.NewPackageController,
 	controller.NewReconciliationController,
 	controller.NewHealthController,
 	controller.NewAgentController,
+	controller.NewSearchController,
+	controller.NewStylePresetController,
+	controller.NewPromptTemplateController,
+	controller.NewStoryBibleController,
+	controller.NewModerationController,
+	controller.NewAIJobController,
 )
@@ -11,6 +11,7 @@ import (
 	"gin-template/repository"
 	"gin-template/router"
 	"gin-template/service"
+	"gin-template/task"
 	"github.com/google/wire"
 	"gorm.io/gorm"
 )
@@ -32,31 +33,72 @@ func InitializeAllController(db *gorm.DB) (*router.APIControllers, error) {
 	projectController := controller.NewProjectController(projectService)
 	tokenReconciliationRepository := repository.NewTokenReconciliationRepository(db)
 	outlineRepository := repository.NewOutlineRepository(db)
-	outlineService := service.NewOutlineService(tokenRepository, tokenReconciliationRepository, outlineRepository)
+	outlineDraftRepository := repository.NewOutlineDraftRepository(db)
+	stylePresetRepository := repository.NewStylePresetRepository(db)
+	promptTemplateRepository := repository.NewPromptTemplateRepository(db)
+	aiCandidateRepository := repository.NewAICandidateRepository(db)
+	outlineSummaryRepository := repository.NewOutlineSummaryRepository(db)
+	storyBibleRepository := repository.NewStoryBibleRepository(db)
+	continuityReportRepository := repository.NewContinuityReportRepository(db)
+	outlineService := service.NewOutlineService(tokenRepository, tokenReconciliationRepository, outlineRepository, outlineDraftRepository, stylePresetRepository, promptTemplateRepository, aiCandidateRepository, outlineSummaryRepository, storyBibleRepository, continuityReportRepository)
 	outlineController := controller.NewOutlineController(outlineService)
 	packageRepository := repository.NewPackageRepository(db)
 	packageService := service.NewPackageService(packageRepository, tokenService)
 	packageController := controller.NewPackageController(packageService)
 	healthController := controller.NewHealthController()
-	agentController := controller.NewAgentController()
+	storyBibleService := service.NewStoryBibleService(storyBibleRepository, promptTemplateRepository)
+	agentController := controller.NewAgentController(storyBibleService)
+	searchRepository := repository.NewSearchRepository(db)
+	searchService := service.NewSearchService(searchRepository)
+	searchController := controller.NewSearchController(searchService)
+	stylePresetService := service.NewStylePresetService(stylePresetRepository)
+	stylePresetController := controller.NewStylePresetController(stylePresetService)
+	promptTemplateService := service.NewPromptTemplateService(promptTemplateRepository)
+	promptTemplateController := controller.NewPromptTemplateController(promptTemplateService)
+	storyBibleController := controller.NewStoryBibleController(storyBibleService)
+	moderationRepository := repository.NewModerationRepository(db)
+	moderationService := service.NewModerationService(moderationRepository, promptTemplateRepository)
+	moderationController := controller.NewModerationController(moderationService)
+	dbJobQueue, err := task.NewDBJobQueue(db)
+	if err != nil {
+		return nil, err
+	}
+	aiJobService := service.NewAIJobService(dbJobQueue)
+	aiJobController := controller.NewAIJobController(aiJobService)
+	tokenController := controller.NewTokenController(tokenService)
 	apiControllers := &router.APIControllers{
-		ReferralController: referralController,
-		ProjectController:  projectController,
-		OutlineController:  outlineController,
-		PackageController:  packageController,
-		HealthController:   healthController,
-		AgentController:    agentController,
+		ReferralController:       referralController,
+		TokenController:          tokenController,
+		ProjectController:        projectController,
+		OutlineController:        outlineController,
+		PackageController:        packageController,
+		HealthController:         healthController,
+		AgentController:          agentController,
+		SearchController:         searchController,
+		StylePresetController:    stylePresetController,
+		PromptTemplateController: promptTemplateController,
+		StoryBibleController:     storyBibleController,
+		ModerationController:     moderationController,
+		AIJobController:          aiJobController,
 	}
 	return apiControllers, nil
 }
 
+// InitializeStoryBibleService 异步AI任务使用的故事设定服务
+func InitializeStoryBibleService(db *gorm.DB) (*service.StoryBibleService, error) {
+	storyBibleRepository := repository.NewStoryBibleRepository(db)
+	promptTemplateRepository := repository.NewPromptTemplateRepository(db)
+	storyBibleService := service.NewStoryBibleService(storyBibleRepository, promptTemplateRepository)
+	return storyBibleService, nil
+}
+
 // wire.go:
 
 // ServiceSet 大纲服务集合
-var ServiceSet = wire.NewSet(service.NewOutlineService, service.NewTokenService, service.NewProjectService, service.NewReferralService, service.NewPackageService)
+var ServiceSet = wire.NewSet(service.NewOutlineService, service.NewTokenService, service.NewProjectService, service.NewReferralService, service.NewPackageService, service.NewSearchService, service.NewStylePresetService, service.NewPromptTemplateService, service.NewStoryBibleService, service.NewModerationService, service.NewAIJobService)
 
 // repository.RepositorySet 基础仓库集合
-var RepositorySet = wire.NewSet(repository.NewTokenRepository, repository.NewTokenReconciliationRepository, repository.NewOutlineRepository, repository.NewProjectRepository, repository.NewReferralRepository, repository.NewPackageRepository)
+var RepositorySet = wire.NewSet(repository.NewTokenRepository, repository.NewTokenReconciliationRepository, repository.NewOutlineRepository, repository.NewOutlineDraftRepository, repository.NewProjectRepository, repository.NewReferralRepository, repository.NewPackageRepository, repository.NewSearchRepository, repository.NewStylePresetRepository, repository.NewPromptTemplateRepository, repository.NewAICandidateRepository, repository.NewOutlineSummaryRepository, repository.NewStoryBibleRepository, repository.NewContinuityReportRepository, repository.NewModerationRepository, task.NewDBJobQueue)
 
 // 控制器依赖注入集合
-var ControllerSet = wire.NewSet(controller.NewReferralController, controller.NewProjectController, controller.NewOutlineController, controller.NewPackageController, controller.NewReconciliationController, controller.NewHealthController, controller.NewAgentController)
+var ControllerSet = wire.NewSet(controller.NewReferralController, controller.NewTokenController, controller.NewProjectController, controller.NewOutlineController, controller.NewPackageController, controller.NewReconciliationController, controller.NewHealthController, controller.NewAgentController, controller.NewSearchController, controller.NewStylePresetController, controller.NewPromptTemplateController, controller.NewStoryBibleController, controller.NewModerationController, controller.NewAIJobController)